@@ -189,6 +189,11 @@ type CartCoProc interface {
 
 	// read coprocessor memory address for 32bit value. return false if address is out of range
 	Peek(addr uint32) (uint32, bool)
+
+	// read and write coprocessor memory address for 8bit value. return false if address is out
+	// of range
+	PeekByte(addr uint32) (uint8, bool)
+	PokeByte(addr uint32, value uint8) bool
 }
 
 // CartCoProcBus is implemented by cartridge mappers that have a coprocessor
@@ -261,8 +266,9 @@ type CartCoProcProfiler struct {
 // CartCoProcDeveloper is implemented by a coprocessor to provide functions
 // available to developers when the source code is available.
 type CartCoProcDeveloper interface {
-	// a memory fault has occured
-	MemoryFault(event string, explanation faults.Category, instructionAddr uint32, accessAddr uint32)
+	// a memory fault has occured. returns the policy that the coprocessor
+	// should apply to the fault
+	MemoryFault(event string, explanation faults.Category, instructionAddr uint32, accessAddr uint32) faults.Policy
 
 	// returns the highest address used by the program. the coprocessor uses
 	// this value to detect stack collisions
@@ -339,6 +345,11 @@ const (
 	// returned when an infinite loop is detected
 	YieldInfiniteLoop CoProcYieldType = "Infinite Loop Detected"
 
+	// the coprocessor program was still executing when the console required the
+	// coprocessor to be synchronised. only possible when the coprocessor is
+	// counting cycles (ie. not in immediate mode)
+	YieldSyncOverrun CoProcYieldType = "Sync Overrun"
+
 	// the coprocessor has not yet yielded and is still running
 	YieldRunning CoProcYieldType = "Running"
 )
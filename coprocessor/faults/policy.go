@@ -0,0 +1,62 @@
+package faults
+
+import "strings"
+
+// Policy specifies how a memory fault is handled
+type Policy string
+
+// List of valid Policy values
+const (
+	// the fault is not recorded and execution continues
+	Ignore Policy = "IGNORE"
+
+	// the fault is recorded and execution continues
+	Log Policy = "LOG"
+
+	// the fault is recorded and the coprocessor yields so that the fault can
+	// be inspected. execution can be resumed
+	Break Policy = "BREAK"
+
+	// the fault is recorded and the coprocessor yields. execution cannot be
+	// resumed until the cartridge is reset
+	Abort Policy = "ABORT"
+)
+
+// PolicyOptions lists the valid policies as strings. suitable for use as
+// preference options
+var PolicyOptions = []string{string(Ignore), string(Log), string(Break), string(Abort)}
+
+// Categories lists the categories that can be assigned a policy. ProgramMemory
+// is not included because a program memory fault is always fatal
+var Categories = []Category{
+	NullDereference,
+	StackError,
+	IllegalAddress,
+	MisalignedAddressing,
+	UnimplementedPeripheral,
+	UndefinedSymbol,
+}
+
+// Key returns the category as a single word, suitable for use as part of a
+// preference key
+func (c Category) Key() string {
+	return strings.ReplaceAll(string(c), " ", "")
+}
+
+// DefaultPolicy returns the policy for the category when no other policy has
+// been specified. access to an unimplemented peripheral is usually harmless so
+// it is only logged
+func DefaultPolicy(c Category) Policy {
+	switch c {
+	case ProgramMemory:
+		return Abort
+	case UnimplementedPeripheral:
+		return Log
+	}
+	return Break
+}
+
+// Stops returns true if the policy causes the coprocessor to stop execution
+func (p Policy) Stops() bool {
+	return p == Break || p == Abort
+}
@@ -0,0 +1,371 @@
+package debugger
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jetsetilly/test7800/hardware/memory/external"
+	"github.com/jetsetilly/test7800/hardware/memory/external/a78"
+)
+
+// the modes of the a78 tool
+var a78Modes = []string{"info", "set", "strip", "add"}
+
+// the changes to an a78 header requested by the arguments to the a78 tool. an
+// empty string means that the field is not changed
+type a78Edits struct {
+	version     string
+	title       string
+	controller1 string
+	controller2 string
+	tv          string
+	hsc         string
+	savekey     string
+	mapper      string
+	pokey       string
+	ym2151      string
+	elfModel    string
+}
+
+func (ed *a78Edits) flags(flgs *flag.FlagSet) {
+	mappers := strings.Join(slices.Sorted(maps.Keys(a78.Mappers)), ", ")
+	flgs.StringVar(&ed.version, "version", "", "header version. version 4 adds the v4 mapper and audio fields")
+	flgs.StringVar(&ed.title, "title", "", "cartridge title")
+	flgs.StringVar(&ed.controller1, "controller", "", fmt.Sprintf("controller for both ports: %s", strings.Join(a78.Controllers, ", ")))
+	flgs.StringVar(&ed.controller2, "controller2", "", "controller for the second port if it is different to the first")
+	flgs.StringVar(&ed.tv, "tv", "", "TV specification: NTSC or PAL")
+	flgs.StringVar(&ed.hsc, "hsc", "", "use high score cartridge: TRUE or FALSE")
+	flgs.StringVar(&ed.savekey, "savekey", "", "use savekey: TRUE or FALSE")
+	flgs.StringVar(&ed.mapper, "mapper", "", fmt.Sprintf("mapper: %s", mappers))
+	flgs.StringVar(&ed.pokey, "pokey", "", "POKEY addresses separated by commas or NONE. eg. 0450,0440")
+	flgs.StringVar(&ed.ym2151, "ym2151", "", "YM2151 is present: TRUE or FALSE")
+	flgs.StringVar(&ed.elfModel, "elfmodel", "", fmt.Sprintf("hardware model of an ELF cartridge: %s", strings.Join(a78.ELFModels, ", ")))
+}
+
+// apply the changes to the header
+func (ed *a78Edits) apply(hdr *a78.Header) error {
+	if ed.version != "" {
+		v, err := strconv.ParseUint(ed.version, 10, 8)
+		if err != nil {
+			return fmt.Errorf("version is not valid: %s", ed.version)
+		}
+		upgrade := hdr.Version < 4 && v >= 4
+		hdr.Version = uint8(v)
+
+		// the version 4 fields must agree with the cartridge type
+		if upgrade {
+			if _, ok := a78.Mappers[hdr.MapperName()]; ok {
+				err = hdr.SetMapper(hdr.MapperName())
+				if err != nil {
+					return err
+				}
+			}
+			err = hdr.SetAudio(hdr.Pokeys(), hdr.CartType&a78.CartTypeYM2151 == a78.CartTypeYM2151)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if ed.title != "" {
+		hdr.Title = ed.title
+	}
+
+	if ed.controller1 != "" {
+		for port := range 2 {
+			err := hdr.SetController(port+1, ed.controller1)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if ed.controller2 != "" {
+		err := hdr.SetController(2, ed.controller2)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ed.tv != "" {
+		err := hdr.SetTV(ed.tv)
+		if err != nil {
+			return err
+		}
+	}
+
+	hsc := hdr.UseHSC()
+	savekey := hdr.UseSavekey()
+	if ed.hsc != "" {
+		var err error
+		hsc, err = strconv.ParseBool(ed.hsc)
+		if err != nil {
+			return fmt.Errorf("hsc must be TRUE or FALSE")
+		}
+	}
+	if ed.savekey != "" {
+		var err error
+		savekey, err = strconv.ParseBool(ed.savekey)
+		if err != nil {
+			return fmt.Errorf("savekey must be TRUE or FALSE")
+		}
+	}
+	hdr.SetSaveDevice(hsc, savekey)
+
+	if ed.mapper != "" {
+		err := hdr.SetMapper(ed.mapper)
+		if err != nil {
+			return err
+		}
+	}
+
+	pokeys := hdr.Pokeys()
+	ym2151 := hdr.CartType&a78.CartTypeYM2151 == a78.CartTypeYM2151
+	if ed.pokey != "" {
+		pokeys = pokeys[:0]
+		if strings.ToUpper(ed.pokey) != "NONE" {
+			for _, s := range strings.Split(ed.pokey, ",") {
+				a, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(s), "$"), 16, 16)
+				if err != nil {
+					return fmt.Errorf("POKEY address is not valid: %s", s)
+				}
+				pokeys = append(pokeys, uint16(a))
+			}
+		}
+	}
+	if ed.ym2151 != "" {
+		var err error
+		ym2151, err = strconv.ParseBool(ed.ym2151)
+		if err != nil {
+			return fmt.Errorf("ym2151 must be TRUE or FALSE")
+		}
+	}
+	if ed.pokey != "" || ed.ym2151 != "" {
+		err := hdr.SetAudio(pokeys, ym2151)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ed.elfModel != "" {
+		err := hdr.SetELFModel(ed.elfModel)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// print every field of the header. the size of the cartridge data in the file
+// is compared with the size field
+func a78Info(hdr a78.Header, dataLen int) {
+	field := func(name string, value string, args ...any) {
+		fmt.Printf("%-18s %s\n", name+":", fmt.Sprintf(value, args...))
+	}
+
+	yesno := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+
+	field("version", "%d", hdr.Version)
+	field("title", "%s", hdr.Title)
+	if int(hdr.Size) == dataLen {
+		field("size", "%d", hdr.Size)
+	} else {
+		field("size", "%d (file contains %d bytes of data)", hdr.Size, dataLen)
+	}
+	field("cart type", "%08b %08b", uint8(hdr.CartType>>8), uint8(hdr.CartType))
+	field("mapper", "%s", hdr.MapperName())
+
+	var pokeys []string
+	for _, a := range hdr.Pokeys() {
+		pokeys = append(pokeys, fmt.Sprintf("%04x", a))
+	}
+	if len(pokeys) == 0 {
+		pokeys = append(pokeys, "none")
+	}
+	field("pokey", "%s", strings.Join(pokeys, ", "))
+	field("ym2151", "%s", yesno(hdr.CartType&a78.CartTypeYM2151 == a78.CartTypeYM2151))
+
+	field("controller 1", "%s", a78.ControllerName(hdr.Controller1))
+	field("controller 2", "%s", a78.ControllerName(hdr.Controller2))
+
+	tv := "NTSC"
+	if hdr.IsPAL() {
+		tv = "PAL"
+	}
+	if hdr.TV&0x02 == 0x02 {
+		tv = fmt.Sprintf("%s, composite", tv)
+	} else {
+		tv = fmt.Sprintf("%s, component", tv)
+	}
+	if hdr.TV&0x04 == 0x04 {
+		tv = fmt.Sprintf("%s, multi-region", tv)
+	}
+	field("tv", "%s", tv)
+
+	field("high score cart", "%s", yesno(hdr.UseHSC()))
+	field("savekey", "%s", yesno(hdr.UseSavekey()))
+	field("expansion module", "%s", yesno(hdr.Expansion&0x01 == 0x01))
+
+	if m := hdr.ELFModelName(); m != "" {
+		field("elf model", "%s", m)
+	} else if hdr.ELFModel != 0 {
+		field("elf model", "%#02x (not recognised)", hdr.ELFModel)
+	} else {
+		field("elf model", "none")
+	}
+
+	if hdr.Version < 4 {
+		return
+	}
+
+	field("v4 mapper", "%s", hdr.MapperV4Name())
+	field("v4 mapper options", "%08b", hdr.MapperOptions)
+	audio := hdr.AudioV4Names()
+	if len(audio) == 0 {
+		audio = append(audio, "none")
+	}
+	field("v4 audio", "%s", strings.Join(audio, ", "))
+	field("v4 interrupts", "%016b", hdr.Interrupts)
+}
+
+// write the header and cartridge data to the file. the size field of the
+// header is the length of the cartridge data. any trailing data is written
+// after the cartridge data and is not included in the size
+func a78Write(filename string, hdr a78.Header, data []byte, trailing []byte) error {
+	hdr.Size = uint32(len(data))
+	d := append(hdr.Bytes(), data...)
+	d = append(d, trailing...)
+	err := os.WriteFile(filename, d, 0644)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("written to %s\n", filename)
+	return nil
+}
+
+// the output filename for the modes that create a new file. the default is
+// the ROM filename with a new extension. an existing file is not replaced
+// unless the output filename has been given explicitly
+func a78Output(output string, filename string, ext string) (string, error) {
+	if output != "" {
+		return output, nil
+	}
+	output = strings.TrimSuffix(filename, filepath.Ext(filename)) + ext
+	if _, err := os.Stat(output); err == nil {
+		return "", fmt.Errorf("%s already exists. use the -o argument to replace it", output)
+	}
+	return output, nil
+}
+
+// a78Tool is the a78 mode of the program. the header is parsed by the same
+// code that is used when an a78 file is loaded by the emulator
+//
+//	test7800 a78 info|set|strip|add [arguments] rom
+func a78Tool(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("a78: mode should be one of %s", strings.Join(a78Modes, ", "))
+	}
+
+	mode := strings.ToLower(args[0])
+
+	flgs := flag.NewFlagSet(fmt.Sprintf("%s a78 %s", programName, mode), flag.ExitOnError)
+	var output string
+	var ed a78Edits
+
+	switch mode {
+	case "info":
+	case "set":
+		flgs.StringVar(&output, "o", "", "output file. the ROM file is changed by default")
+		ed.flags(flgs)
+	case "strip":
+		flgs.StringVar(&output, "o", "", "output file. the ROM filename with the .bin extension by default")
+	case "add":
+		flgs.StringVar(&output, "o", "", "output file. the ROM filename with the .a78 extension by default")
+		ed.flags(flgs)
+	default:
+		return fmt.Errorf("a78: mode should be one of %s", strings.Join(a78Modes, ", "))
+	}
+
+	err := flgs.Parse(args[1:])
+	if err != nil {
+		return err
+	}
+	if flgs.NArg() != 1 {
+		return fmt.Errorf("a78 %s: requires one ROM file", mode)
+	}
+	filename := flgs.Arg(0)
+
+	d, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	if mode == "add" {
+		if a78.IsA78(d) {
+			return fmt.Errorf("a78 add: %s already has an a78 header", filename)
+		}
+		hdr, err := external.NewHeader(filename, d)
+		if err != nil {
+			return err
+		}
+		err = ed.apply(&hdr)
+		if err != nil {
+			return err
+		}
+		output, err = a78Output(output, filename, ".a78")
+		if err != nil {
+			return err
+		}
+		a78Info(hdr, len(d))
+		return a78Write(output, hdr, d, nil)
+	}
+
+	hdr, dataStart, err := a78.Parse(d)
+	if err != nil {
+		return err
+	}
+
+	// the cartridge data as it is seen by the emulator. any data after that is
+	// kept when the file is rewritten
+	data := d[dataStart:min(len(d), dataStart+int(hdr.Size))]
+	trailing := d[dataStart+len(data):]
+
+	switch mode {
+	case "info":
+		a78Info(hdr, len(d)-dataStart)
+	case "set":
+		err = ed.apply(&hdr)
+		if err != nil {
+			return err
+		}
+		if output == "" {
+			output = filename
+		}
+		hdr.Size = uint32(len(data))
+		a78Info(hdr, len(data)+len(trailing))
+		return a78Write(output, hdr, data, trailing)
+	case "strip":
+		output, err = a78Output(output, filename, ".bin")
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(output, data, 0644)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("written to %s\n", output)
+	}
+
+	return nil
+}
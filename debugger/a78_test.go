@@ -0,0 +1,43 @@
+package debugger
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jetsetilly/test7800/hardware/memory/external"
+	"github.com/jetsetilly/test7800/hardware/memory/external/a78"
+	"github.com/jetsetilly/test7800/test"
+)
+
+func TestA78SetTrailing(t *testing.T) {
+	data := make([]byte, 0x8000)
+	for i := range data {
+		data[i] = uint8(i)
+	}
+	trailing := []byte("trailing data")
+
+	hdr, err := external.NewHeader("game.bin", data)
+	test.DemandSuccess(t, err)
+	hdr.Size = uint32(len(data))
+
+	fn := filepath.Join(t.TempDir(), "game.a78")
+	d := append(hdr.Bytes(), data...)
+	d = append(d, trailing...)
+	test.DemandSuccess(t, os.WriteFile(fn, d, 0644))
+
+	test.DemandSuccess(t, a78Tool([]string{"set", "-title", "changed", fn}))
+
+	d, err = os.ReadFile(fn)
+	test.DemandSuccess(t, err)
+	hdr, dataStart, err := a78.Parse(d)
+	test.DemandSuccess(t, err)
+	test.ExpectEquality(t, hdr.Title, "changed")
+
+	// the size field still describes the cartridge data and the trailing data
+	// follows it
+	test.ExpectEquality(t, hdr.Size, uint32(len(data)))
+	test.ExpectSuccess(t, bytes.Equal(d[dataStart:dataStart+len(data)], data))
+	test.ExpectSuccess(t, bytes.Equal(d[dataStart+len(data):], trailing))
+}
@@ -0,0 +1,293 @@
+package debugger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"strings"
+
+	"github.com/jetsetilly/test7800/debugger/api"
+	"github.com/jetsetilly/test7800/gui"
+	"github.com/jetsetilly/test7800/hardware/memory"
+)
+
+// apiHandler implements the api.Handler interface
+type apiHandler struct {
+	m *debugger
+}
+
+func decodeParams(params json.RawMessage, v any) error {
+	if params == nil {
+		return fmt.Errorf("%w: parameters required", api.ErrInvalidParams)
+	}
+	err := json.Unmarshal(params, v)
+	if err != nil {
+		return fmt.Errorf("%w: %w", api.ErrInvalidParams, err)
+	}
+	return nil
+}
+
+// the names of the input actions that can be used with the input method
+var apiInputActions = map[string]gui.Action{
+	"SELECT":       gui.Select,
+	"START":        gui.Start,
+	"PAUSE":        gui.Pause,
+	"P0PRO":        gui.P0Pro,
+	"P1PRO":        gui.P1Pro,
+	"LEFT":         gui.StickLeft,
+	"UP":           gui.StickUp,
+	"RIGHT":        gui.StickRight,
+	"DOWN":         gui.StickDown,
+	"BUTTONA":      gui.StickButtonA,
+	"BUTTONB":      gui.StickButtonB,
+	"ANALOGUE":     gui.AnalogueSelect,
+	"PADDLEFIRE":   gui.PaddleFire,
+	"PADDLEMOVE":   gui.PaddleMove,
+	"TRAKBALLFIRE": gui.TrakballFire,
+	"TRAKBALLMOVE": gui.TrakballMove,
+}
+
+// the names of the input ports that can be used with the input method
+var apiInputPorts = map[string]gui.Port{
+	"P0":    gui.Player0,
+	"P1":    gui.Player1,
+	"PANEL": gui.Panel,
+}
+
+func (h *apiHandler) Call(method string, params json.RawMessage) (any, error) {
+	m := h.m
+
+	switch strings.ToLower(method) {
+	case "load":
+		var p struct {
+			Filename string `json:"filename"`
+			Mapper   string `json:"mapper"`
+		}
+		err := decodeParams(params, &p)
+		if err != nil {
+			return nil, err
+		}
+		if p.Mapper == "" {
+			p.Mapper = "AUTO"
+		}
+		loader, err := fingerprint(m.g, m.useDialog, p.Filename, p.Mapper)
+		if err != nil {
+			return nil, err
+		}
+		m.sync(func() {
+			m.loader = loader
+			m.reset()
+		})
+		return nil, nil
+
+	case "reset":
+		m.sync(func() {
+			m.reset()
+		})
+		return nil, nil
+
+	case "run":
+		m.resumeNoWait()
+		return nil, nil
+
+	case "pause":
+		m.interruptRun()
+		return nil, nil
+
+	case "step":
+		var p struct {
+			Rule []string `json:"rule"`
+		}
+		if params != nil {
+			err := decodeParams(params, &p)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		var ok bool
+		m.resume(func() bool {
+			if len(p.Rule) > 0 {
+				ok = m.parseStepRule(p.Rule)
+			} else {
+				m.stepRule = func() bool {
+					return true
+				}
+				ok = true
+			}
+			return ok
+		})
+		if !ok {
+			return nil, fmt.Errorf("%w: step rule not valid: %s", api.ErrInvalidParams, strings.Join(p.Rule, " "))
+		}
+
+		var cpu string
+		m.sync(func() {
+			cpu = m.console.MC.String()
+		})
+		return cpu, nil
+
+	case "command":
+		var p struct {
+			Command string `json:"command"`
+		}
+		err := decodeParams(params, &p)
+		if err != nil {
+			return nil, err
+		}
+
+		// output from the command is to the terminal as normal
+		done := make(chan bool)
+		m.requests <- request{
+			resume: true,
+			run: func() bool {
+				defer close(done)
+				m.clearInterrupt()
+				return m.parseCommand(strings.Fields(p.Command))
+			},
+		}
+		<-done
+		return nil, nil
+
+	case "peek":
+		var p struct {
+			Address string `json:"address"`
+		}
+		err := decodeParams(params, &p)
+		if err != nil {
+			return nil, err
+		}
+
+		var data uint8
+		m.syncInline(func() {
+			var ma mappedAddress
+			ma, err = m.parseAddress(p.Address)
+			if err != nil {
+				return
+			}
+			data, err = memory.Read(ma.area, ma.idx)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return data, nil
+
+	case "poke":
+		var p struct {
+			Address string `json:"address"`
+			Value   uint8  `json:"value"`
+		}
+		err := decodeParams(params, &p)
+		if err != nil {
+			return nil, err
+		}
+
+		m.syncInline(func() {
+			var ma mappedAddress
+			ma, err = m.parseAddress(p.Address)
+			if err != nil {
+				return
+			}
+			err = memory.Write(ma.area, ma.idx, p.Value)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return nil, nil
+
+	case "state":
+		var p struct {
+			Component string `json:"component"`
+		}
+		err := decodeParams(params, &p)
+		if err != nil {
+			return nil, err
+		}
+
+		var s string
+		var ok bool
+		m.syncInline(func() {
+			s, _, ok = m.componentState(p.Component)
+		})
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown component: %s", api.ErrInvalidParams, p.Component)
+		}
+		return s, nil
+
+	case "input":
+		var p struct {
+			Port   string `json:"port"`
+			Action string `json:"action"`
+			Value  bool   `json:"value"`
+			Paddle int    `json:"paddle"`
+			Delta  int    `json:"delta"`
+			DeltaX int    `json:"dx"`
+			DeltaY int    `json:"dy"`
+		}
+		err := decodeParams(params, &p)
+		if err != nil {
+			return nil, err
+		}
+
+		inp := gui.Input{Port: gui.Undefined}
+		if p.Port != "" {
+			var ok bool
+			inp.Port, ok = apiInputPorts[strings.ToUpper(p.Port)]
+			if !ok {
+				return nil, fmt.Errorf("%w: unknown port: %s", api.ErrInvalidParams, p.Port)
+			}
+		}
+
+		var ok bool
+		inp.Action, ok = apiInputActions[strings.ToUpper(p.Action)]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown action: %s", api.ErrInvalidParams, p.Action)
+		}
+
+		switch inp.Action {
+		case gui.PaddleFire:
+			inp.Data = gui.PaddleFireData{Paddle: p.Paddle, Fire: p.Value}
+		case gui.PaddleMove:
+			inp.Data = gui.PaddleMoveData{Paddle: p.Paddle, Delta: p.Delta}
+		case gui.TrakballMove:
+			inp.Data = gui.TrakballMoveData{DeltaX: p.DeltaX, DeltaY: p.DeltaY}
+		default:
+			inp.Data = p.Value
+		}
+
+		m.syncInline(func() {
+			m.console.HandleInput(inp)
+		})
+		return nil, nil
+
+	case "frame":
+		var img *image.RGBA
+		m.syncInline(func() {
+			frame := m.console.MARIA.CompletedFrame()
+			img = image.NewRGBA(frame.Bounds())
+			draw.Draw(img, img.Bounds(), frame, frame.Bounds().Min, draw.Src)
+		})
+
+		var b bytes.Buffer
+		err := png.Encode(&b, img)
+		if err != nil {
+			return nil, err
+		}
+
+		// byte slices are encoded as base64 strings by the json package
+		return struct {
+			Width  int    `json:"width"`
+			Height int    `json:"height"`
+			PNG    []byte `json:"png"`
+		}{
+			Width:  img.Bounds().Dx(),
+			Height: img.Bounds().Dy(),
+			PNG:    b.Bytes(),
+		}, nil
+	}
+
+	return nil, api.ErrMethodNotFound
+}
@@ -0,0 +1,276 @@
+package api_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jetsetilly/test7800/debugger/api"
+	"github.com/jetsetilly/test7800/test"
+)
+
+type handler struct {
+	notified bool
+}
+
+func (h *handler) Call(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "add":
+		var args [2]int
+		err := json.Unmarshal(params, &args)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", api.ErrInvalidParams, err)
+		}
+		return args[0] + args[1], nil
+	case "notify":
+		h.notified = true
+		return nil, nil
+	case "fail":
+		return nil, fmt.Errorf("failed")
+	}
+	return nil, api.ErrMethodNotFound
+}
+
+func postWith(t *testing.T, srv *api.Server, host string, origin string, contentType string, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s/", srv.Addr()), strings.NewReader(body))
+	test.ExpectSuccess(t, err)
+	if host != "" {
+		req.Host = host
+	}
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	test.ExpectSuccess(t, err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	test.ExpectSuccess(t, err)
+	return resp.StatusCode, string(b)
+}
+
+func post(t *testing.T, srv *api.Server, body string) (int, string) {
+	t.Helper()
+	return postWith(t, srv, "", "http://localhost", "application/json", body)
+}
+
+func TestHTTP(t *testing.T) {
+	h := &handler{}
+	srv, err := api.Listen("127.0.0.1:0", h)
+	test.ExpectSuccess(t, err)
+	defer srv.Close()
+
+	code, resp := post(t, srv, `{"jsonrpc":"2.0","method":"add","params":[1,2],"id":1}`)
+	test.ExpectEquality(t, code, http.StatusOK)
+	test.ExpectEquality(t, resp, `{"jsonrpc":"2.0","result":3,"id":1}`)
+
+	_, resp = post(t, srv, `{"jsonrpc":"2.0","method":"add","params":"foo","id":"a"}`)
+	test.ExpectEquality(t, strings.HasPrefix(resp, `{"jsonrpc":"2.0","error":{"code":-32602,`), true, resp)
+
+	_, resp = post(t, srv, `{"jsonrpc":"2.0","method":"unknown","id":2}`)
+	test.ExpectEquality(t, resp, `{"jsonrpc":"2.0","error":{"code":-32601,"message":"method not found"},"id":2}`)
+
+	_, resp = post(t, srv, `{"jsonrpc":"2.0","method":"fail","id":3}`)
+	test.ExpectEquality(t, resp, `{"jsonrpc":"2.0","error":{"code":-32000,"message":"failed"},"id":3}`)
+
+	_, resp = post(t, srv, `{"method":"add","id":4}`)
+	test.ExpectEquality(t, resp, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"not a JSON-RPC 2.0 request"},"id":4}`)
+
+	_, resp = post(t, srv, `not json`)
+	test.ExpectEquality(t, strings.HasPrefix(resp, `{"jsonrpc":"2.0","error":{"code":-32700,`), true, resp)
+
+	code, resp = post(t, srv, `{"jsonrpc":"2.0","method":"notify"}`)
+	test.ExpectEquality(t, code, http.StatusNoContent)
+	test.ExpectEquality(t, resp, "")
+	test.ExpectEquality(t, h.notified, true)
+}
+
+func TestHTTPOrigin(t *testing.T) {
+	srv, err := api.Listen("127.0.0.1:0", &handler{})
+	test.ExpectSuccess(t, err)
+	defer srv.Close()
+
+	const body = `{"jsonrpc":"2.0","method":"add","params":[1,2],"id":1}`
+
+	// requests without an Origin header are not from a web page and don't need
+	// the JSON content type
+	code, _ := postWith(t, srv, "", "", "application/json", body)
+	test.ExpectEquality(t, code, http.StatusOK)
+
+	code, _ = postWith(t, srv, "", "", "application/x-www-form-urlencoded", body)
+	test.ExpectEquality(t, code, http.StatusOK)
+
+	code, _ = postWith(t, srv, "", "http://example.com", "application/json", body)
+	test.ExpectEquality(t, code, http.StatusForbidden)
+
+	code, _ = postWith(t, srv, "", "http://localhost.example.com", "application/json", body)
+	test.ExpectEquality(t, code, http.StatusForbidden)
+
+	code, _ = postWith(t, srv, "", "null", "application/json", body)
+	test.ExpectEquality(t, code, http.StatusForbidden)
+
+	code, _ = postWith(t, srv, "", "http://localhost", "text/plain", body)
+	test.ExpectEquality(t, code, http.StatusUnsupportedMediaType)
+
+	code, _ = postWith(t, srv, "", "http://localhost", "", body)
+	test.ExpectEquality(t, code, http.StatusUnsupportedMediaType)
+
+	code, _ = postWith(t, srv, "", "http://127.0.0.1:8080", "application/json; charset=utf-8", body)
+	test.ExpectEquality(t, code, http.StatusOK)
+
+	code, _ = postWith(t, srv, "", "http://[::1]", "application/json", body)
+	test.ExpectEquality(t, code, http.StatusOK)
+}
+
+func TestHTTPHost(t *testing.T) {
+	srv, err := api.Listen("127.0.0.1:0", &handler{})
+	test.ExpectSuccess(t, err)
+	defer srv.Close()
+
+	const body = `{"jsonrpc":"2.0","method":"add","params":[1,2],"id":1}`
+
+	for _, host := range []string{"localhost", "localhost:7800", "127.0.0.1:7800", "[::1]:7800", "[::1]"} {
+		code, _ := postWith(t, srv, host, "", "application/json", body)
+		test.ExpectEquality(t, code, http.StatusOK)
+	}
+
+	// a remote host name is rejected even if the origin is the same. this is
+	// what a DNS rebinding attack looks like
+	for _, host := range []string{"example.com", "example.com:7800", "192.168.0.1:7800"} {
+		code, _ := postWith(t, srv, host, "", "application/json", body)
+		test.ExpectEquality(t, code, http.StatusForbidden)
+		code, _ = postWith(t, srv, host, "http://"+host, "application/json", body)
+		test.ExpectEquality(t, code, http.StatusForbidden)
+	}
+}
+
+// write a masked frame as a client would
+func writeFrame(w io.Writer, opcode byte, payload []byte) error {
+	hdr := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+	hdr = append(hdr, mask...)
+	for i := range payload {
+		hdr = append(hdr, payload[i]^mask[i%4])
+	}
+	_, err := w.Write(hdr)
+	return err
+}
+
+// read an unmasked frame as sent by the server
+func readFrame(r *bufio.Reader) (byte, string, error) {
+	var hdr [2]byte
+	_, err := io.ReadFull(r, hdr[:])
+	if err != nil {
+		return 0, "", err
+	}
+	length := int(hdr[1] & 0x7f)
+	if length == 126 {
+		var ext [2]byte
+		_, err = io.ReadFull(r, ext[:])
+		if err != nil {
+			return 0, "", err
+		}
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	_, err = io.ReadFull(r, payload)
+	return hdr[0] & 0x0f, string(payload), err
+}
+
+// send an upgrade request to the server and return the response
+func upgrade(t *testing.T, conn net.Conn, host string, origin string) (*bufio.Reader, *http.Response) {
+	t.Helper()
+
+	if host == "" {
+		host = conn.RemoteAddr().String()
+	}
+
+	// example key and accept values taken from RFC 6455
+	fmt.Fprintf(conn, "GET /ws HTTP/1.1\r\n")
+	fmt.Fprintf(conn, "Host: %s\r\n", host)
+	if origin != "" {
+		fmt.Fprintf(conn, "Origin: %s\r\n", origin)
+	}
+	fmt.Fprintf(conn, "Upgrade: websocket\r\n")
+	fmt.Fprintf(conn, "Connection: Upgrade\r\n")
+	fmt.Fprintf(conn, "Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n")
+	fmt.Fprintf(conn, "Sec-WebSocket-Version: 13\r\n\r\n")
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	test.ExpectSuccess(t, err)
+	return r, resp
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	srv, err := api.Listen("127.0.0.1:0", &handler{})
+	test.ExpectSuccess(t, err)
+	defer srv.Close()
+
+	for _, origin := range []string{"http://example.com", "file://"} {
+		conn, err := net.Dial("tcp", srv.Addr().String())
+		test.ExpectSuccess(t, err)
+		_, resp := upgrade(t, conn, "", origin)
+		test.ExpectEquality(t, resp.StatusCode, http.StatusForbidden)
+		conn.Close()
+	}
+
+	// a remote host name is rejected even if the origin is the same
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	test.ExpectSuccess(t, err)
+	_, resp := upgrade(t, conn, "example.com:7800", "http://example.com:7800")
+	test.ExpectEquality(t, resp.StatusCode, http.StatusForbidden)
+	conn.Close()
+
+	// a connection without an Origin header is not from a web page
+	conn, err = net.Dial("tcp", srv.Addr().String())
+	test.ExpectSuccess(t, err)
+	_, resp = upgrade(t, conn, "", "")
+	test.ExpectEquality(t, resp.StatusCode, http.StatusSwitchingProtocols)
+	conn.Close()
+}
+
+func TestWebSocket(t *testing.T) {
+	srv, err := api.Listen("127.0.0.1:0", &handler{})
+	test.ExpectSuccess(t, err)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	test.ExpectSuccess(t, err)
+	defer conn.Close()
+
+	r, resp := upgrade(t, conn, "", "http://localhost:7800")
+	test.ExpectEquality(t, resp.StatusCode, http.StatusSwitchingProtocols)
+	test.ExpectEquality(t, resp.Header.Get("Sec-WebSocket-Accept"), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=")
+
+	err = writeFrame(conn, 0x1, []byte(`{"jsonrpc":"2.0","method":"add","params":[10,20],"id":1}`))
+	test.ExpectSuccess(t, err)
+	opcode, msg, err := readFrame(r)
+	test.ExpectSuccess(t, err)
+	test.ExpectEquality(t, opcode, 0x1)
+	test.ExpectEquality(t, msg, `{"jsonrpc":"2.0","result":30,"id":1}`)
+
+	// ping is answered with a pong containing the same payload
+	err = writeFrame(conn, 0x9, []byte("hello"))
+	test.ExpectSuccess(t, err)
+	opcode, msg, err = readFrame(r)
+	test.ExpectSuccess(t, err)
+	test.ExpectEquality(t, opcode, 0xa)
+	test.ExpectEquality(t, msg, "hello")
+
+	// close is acknowledged
+	err = writeFrame(conn, 0x8, nil)
+	test.ExpectSuccess(t, err)
+	opcode, _, err = readFrame(r)
+	test.ExpectSuccess(t, err)
+	test.ExpectEquality(t, opcode, 0x8)
+}
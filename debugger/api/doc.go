@@ -0,0 +1,24 @@
+// Package api implements a JSON-RPC 2.0 server for controlling the emulation
+// from external tools. For example, test bots or an external memory viewer.
+//
+// Requests can be made with an HTTP POST to the root path of the server or
+// over a WebSocket connection on the /ws path. A WebSocket connection is
+// preferable for tools that make many requests because the connection is
+// kept open between requests. Each WebSocket text message should contain
+// exactly one request and the response is sent as a single text message.
+//
+// Requests must be made to the local machine, as given by the Host header. If
+// the request has an Origin header, as it will if it is made by a web page,
+// then the origin must also be the local machine (eg. "http://localhost") and
+// HTTP requests must have a Content-Type of "application/json". This prevents
+// web pages open in a browser from controlling the emulation.
+//
+// The package knows nothing about the emulation. Method calls are passed on to
+// an implementation of the Handler interface.
+//
+// The JSON-RPC specification can be found at:
+//
+// https://www.jsonrpc.org/specification
+//
+// Batch requests are not supported.
+package api
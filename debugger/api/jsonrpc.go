@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Handler is implemented by the type that services the method calls
+type Handler interface {
+	// Call the named method with the parameters. The params argument will be
+	// nil if the request has no parameters. The returned value will be encoded
+	// as JSON
+	Call(method string, params json.RawMessage) (any, error)
+}
+
+// Errors that can be returned by the Handler implementation to indicate a
+// specific JSON-RPC error code. The errors can be wrapped
+var (
+	ErrMethodNotFound = errors.New("method not found")
+	ErrInvalidParams  = errors.New("invalid params")
+)
+
+// error codes from the JSON-RPC specification
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+
+	// the start of the range reserved for implementation-defined server errors
+	codeServerError = -32000
+)
+
+type rpcRequest struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	Version string          `json:"jsonrpc"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// the ID for responses where the ID of the request could not be determined
+var nullID = json.RawMessage("null")
+
+func errorResponse(id json.RawMessage, code int, message string) rpcResponse {
+	return rpcResponse{
+		Version: "2.0",
+		Error: &rpcError{
+			Code:    code,
+			Message: message,
+		},
+		ID: id,
+	}
+}
+
+// handle a single request and return the encoded response. returns nil if the
+// request is a notification and no response should be sent
+func handle(h Handler, data []byte) []byte {
+	var req rpcRequest
+	var resp rpcResponse
+
+	err := json.Unmarshal(data, &req)
+	if err != nil {
+		resp = errorResponse(nullID, codeParseError, err.Error())
+	} else if req.Version != "2.0" || req.Method == "" {
+		id := req.ID
+		if id == nil {
+			id = nullID
+		}
+		resp = errorResponse(id, codeInvalidRequest, "not a JSON-RPC 2.0 request")
+	} else {
+		result, err := h.Call(req.Method, req.Params)
+
+		// a request without an ID is a notification and does not get a response
+		if req.ID == nil {
+			return nil
+		}
+
+		if err != nil {
+			code := codeServerError
+			if errors.Is(err, ErrMethodNotFound) {
+				code = codeMethodNotFound
+			} else if errors.Is(err, ErrInvalidParams) {
+				code = codeInvalidParams
+			}
+			resp = errorResponse(req.ID, code, err.Error())
+		} else {
+			// the result field must be present on success
+			if result == nil {
+				result = true
+			}
+			resp = rpcResponse{
+				Version: "2.0",
+				Result:  result,
+				ID:      req.ID,
+			}
+		}
+	}
+
+	b, err := json.Marshal(resp)
+	if err != nil {
+		b, _ = json.Marshal(errorResponse(resp.ID, codeServerError, err.Error()))
+	}
+	return b
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jetsetilly/test7800/logger"
+)
+
+// Server accepts JSON-RPC requests over HTTP and WebSocket connections
+type Server struct {
+	handler  Handler
+	listener net.Listener
+	http     *http.Server
+}
+
+// Listen creates a new Server and starts listening on the TCP address
+func Listen(address string, handler Handler) (*Server, error) {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("api: %w", err)
+	}
+
+	srv := &Server{
+		handler:  handler,
+		listener: l,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", srv.serveHTTP)
+	mux.HandleFunc("/ws", srv.serveWebSocket)
+	srv.http = &http.Server{Handler: mux}
+
+	go func() {
+		err := srv.http.Serve(l)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Log(logger.Allow, "api", err)
+		}
+	}()
+
+	logger.Logf(logger.Allow, "api", "listening on %s", l.Addr())
+
+	return srv, nil
+}
+
+// Addr returns the address the server is listening on
+func (srv *Server) Addr() net.Addr {
+	return srv.listener.Addr()
+}
+
+// Close stops the server. Open WebSocket connections are not closed
+func (srv *Server) Close() error {
+	return srv.http.Close()
+}
+
+func (srv *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "JSON-RPC requests must be sent with POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !localRequest(r) {
+		http.Error(w, "requests must be made to and from the local machine", http.StatusForbidden)
+		return
+	}
+
+	// requiring the JSON content type for requests from a browser means a web
+	// page can't make the request without first making a CORS preflight
+	// request, which we never answer
+	if r.Header.Get("Origin") != "" {
+		mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mt != "application/json" {
+			http.Error(w, "JSON-RPC requests must have a Content-Type of application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, wsMaxMessage))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := handle(srv.handler, data)
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(resp)
+	if err != nil {
+		logger.Log(logger.Allow, "api", err)
+	}
+}
+
+func (srv *Server) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	ws, err := wsUpgrade(w, r)
+	if err != nil {
+		logger.Log(logger.Allow, "api", err)
+		return
+	}
+	defer ws.Close()
+
+	logger.Logf(logger.Allow, "api", "websocket connection from %s", r.RemoteAddr)
+
+	for {
+		msg, err := ws.readMessage()
+		if err != nil {
+			if !errors.Is(err, wsClosed) && !errors.Is(err, io.EOF) {
+				logger.Log(logger.Allow, "api", err)
+			}
+			return
+		}
+
+		resp := handle(srv.handler, msg)
+		if resp == nil {
+			continue // for loop
+		}
+
+		err = ws.writeMessage(resp)
+		if err != nil {
+			logger.Log(logger.Allow, "api", err)
+			return
+		}
+	}
+}
+
+// localRequest returns true if the request was made to the local machine and,
+// if the request has an Origin header, that the origin is also the local
+// machine. requests without an Origin header are not made by a web page in
+// the user's browser
+//
+// checking the Host header prevents a DNS rebinding attack, where a web page
+// from a remote host is made to look like it has the same origin as the server
+func localRequest(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	if !localHost(host) {
+		return false
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	return localHost(u.Hostname())
+}
+
+// localHost returns true if the host name refers to the local machine
+func localHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
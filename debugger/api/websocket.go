@@ -0,0 +1,233 @@
+package api
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// this is a minimal implementation of the server side of the WebSocket
+// protocol as described by RFC 6455. it is sufficient for exchanging JSON
+// messages with a client and nothing more. extensions and subprotocols are
+// not supported
+
+// the GUID used when creating the Sec-WebSocket-Accept header
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// the maximum size of a message we're willing to receive
+const wsMaxMessage = 1 << 20
+
+// frame opcodes
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+// the error returned by readMessage() when the client closes the connection
+var wsClosed = errors.New("websocket closed")
+
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+}
+
+// checks whether the header field contains the token. the comparison is case
+// insensitive and the field can contain a comma separated list of tokens
+func headerContains(h http.Header, field string, token string) bool {
+	for _, v := range h.Values(field) {
+		for t := range strings.SplitSeq(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func wsAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// upgrade an HTTP request to a WebSocket connection
+func wsUpgrade(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || key == "" ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return nil, fmt.Errorf("websocket: not an upgrade request")
+	}
+
+	// browsers always send the Origin header with a WebSocket upgrade request
+	// but they don't apply the same-origin policy to the connection
+	if !localRequest(r) {
+		http.Error(w, "requests must be made to and from the local machine", http.StatusForbidden)
+		return nil, fmt.Errorf("websocket: rejected connection from %s with origin %q",
+			r.RemoteAddr, r.Header.Get("Origin"))
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, fmt.Errorf("websocket: connection cannot be hijacked")
+	}
+
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("websocket: %w", err)
+	}
+
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n")
+	fmt.Fprintf(rw, "Upgrade: websocket\r\n")
+	fmt.Fprintf(rw, "Connection: Upgrade\r\n")
+	fmt.Fprintf(rw, "Sec-WebSocket-Accept: %s\r\n\r\n", wsAccept(key))
+	err = rw.Flush()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: %w", err)
+	}
+
+	return &wsConn{conn: conn, rw: rw}, nil
+}
+
+func (ws *wsConn) Close() error {
+	return ws.conn.Close()
+}
+
+// read a single frame from the connection. the payload is unmasked
+func (ws *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var hdr [2]byte
+	_, err = io.ReadFull(ws.rw, hdr[:])
+	if err != nil {
+		return
+	}
+
+	fin = hdr[0]&0x80 == 0x80
+	opcode = hdr[0] & 0x0f
+	masked := hdr[1]&0x80 == 0x80
+
+	length := uint64(hdr[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		_, err = io.ReadFull(ws.rw, ext[:])
+		if err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, err = io.ReadFull(ws.rw, ext[:])
+		if err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if length > wsMaxMessage {
+		err = fmt.Errorf("websocket: frame too large")
+		return
+	}
+
+	// all frames sent by a client must be masked
+	if !masked {
+		err = fmt.Errorf("websocket: frame from client is not masked")
+		return
+	}
+
+	var mask [4]byte
+	_, err = io.ReadFull(ws.rw, mask[:])
+	if err != nil {
+		return
+	}
+
+	payload = make([]byte, length)
+	_, err = io.ReadFull(ws.rw, payload)
+	if err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return
+}
+
+// write a single unmasked frame to the connection
+func (ws *wsConn) writeFrame(opcode byte, payload []byte) error {
+	hdr := []byte{0x80 | opcode}
+	switch {
+	case len(payload) < 126:
+		hdr = append(hdr, byte(len(payload)))
+	case len(payload) <= 0xffff:
+		hdr = append(hdr, 126)
+		hdr = binary.BigEndian.AppendUint16(hdr, uint16(len(payload)))
+	default:
+		hdr = append(hdr, 127)
+		hdr = binary.BigEndian.AppendUint64(hdr, uint64(len(payload)))
+	}
+
+	_, err := ws.rw.Write(hdr)
+	if err != nil {
+		return err
+	}
+	_, err = ws.rw.Write(payload)
+	if err != nil {
+		return err
+	}
+	return ws.rw.Flush()
+}
+
+// read the next complete text or binary message. control frames are handled
+// as they are received
+func (ws *wsConn) readMessage() ([]byte, error) {
+	var msg []byte
+	for {
+		fin, opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case wsClose:
+			_ = ws.writeFrame(wsClose, nil)
+			return nil, wsClosed
+		case wsPing:
+			err = ws.writeFrame(wsPong, payload)
+			if err != nil {
+				return nil, err
+			}
+			continue // for loop
+		case wsPong:
+			continue // for loop
+		case wsText, wsBinary, wsContinuation:
+			msg = append(msg, payload...)
+			if len(msg) > wsMaxMessage {
+				return nil, fmt.Errorf("websocket: message too large")
+			}
+		default:
+			return nil, fmt.Errorf("websocket: unknown opcode %#x", opcode)
+		}
+
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+func (ws *wsConn) writeMessage(msg []byte) error {
+	return ws.writeFrame(wsText, msg)
+}
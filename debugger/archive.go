@@ -0,0 +1,64 @@
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/jetsetilly/test7800/gui"
+	"github.com/jetsetilly/test7800/hardware/memory/external"
+)
+
+// fingerprint is the same as external.Fingerprint() except that the user is
+// asked to choose the cartridge if the file is an archive containing more than
+// one cartridge. the user is only asked if useDialog is true
+func fingerprint(g *gui.ChannelsDebugger, useDialog bool, filename string, mapper string) (external.CartridgeInsertor, error) {
+	loader, err := external.Fingerprint(filename, mapper)
+	return chooseFromArchive(g, useDialog, loader, err, mapper)
+}
+
+// fingerprintBlob is the same as external.FingerprintBlob() except that the
+// user is asked to choose the cartridge if the data is an archive containing
+// more than one cartridge. the user is only asked if useDialog is true
+func fingerprintBlob(g *gui.ChannelsDebugger, useDialog bool, filename string, d []uint8, mapper string) (external.CartridgeInsertor, error) {
+	loader, err := external.FingerprintBlob(filename, d, mapper)
+	return chooseFromArchive(g, useDialog, loader, err, mapper)
+}
+
+// if the error is an external.ArchiveChoice then the user is asked to choose one
+// of the entries in the archive, using a dialog presented by the GUI
+//
+// if dialogs are not being used then the ArchiveChoice error is returned. the
+// error lists the entries in the archive and the user can choose one by loading
+// the file again with the archive.zip:file form of the filename
+func chooseFromArchive(g *gui.ChannelsDebugger, useDialog bool, loader external.CartridgeInsertor, err error, mapper string) (external.CartridgeInsertor, error) {
+	var choice external.ArchiveChoice
+	if !errors.As(err, &choice) || !useDialog {
+		return loader, err
+	}
+
+	req := gui.ChoiceRequest{
+		Title: fmt.Sprintf("Load cartridge from %s?", filepath.Base(choice.Archive)),
+	}
+	for _, e := range choice.Entries {
+		req.Options = append(req.Options, e.Path)
+	}
+
+	var chosen string
+	select {
+	case g.ChoiceRequest <- req:
+		chosen = <-g.RequestedChoice
+	default:
+		return external.CartridgeInsertor{}, err
+	}
+
+	if chosen == "" {
+		return external.CartridgeInsertor{}, fmt.Errorf("no cartridge chosen from %s", filepath.Base(choice.Archive))
+	}
+
+	e, err := external.SelectArchiveEntry(choice.Entries, chosen)
+	if err != nil {
+		return external.CartridgeInsertor{}, err
+	}
+	return external.FingerprintBlob(e.Filename, e.Data, mapper)
+}
@@ -0,0 +1,155 @@
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jetsetilly/test7800/debugger/capture"
+)
+
+// audioRecorder writes the mixed audio to a WAV file. optionally, the output of
+// each audio channel is written to a separate WAV file
+type audioRecorder struct {
+	filename string
+	freq     int
+	wav      *capture.WAV
+
+	// one file for each audio channel. nil if stems are not being recorded
+	stems     []*capture.WAV
+	withStems bool
+
+	// the first error encountered while creating a stem file
+	err error
+}
+
+// returns the filename for the named stem. eg. out_pokey_ch2.wav
+func stemFilename(filename string, name string) string {
+	ext := filepath.Ext(filename)
+	return fmt.Sprintf("%s_%s%s", strings.TrimSuffix(filename, ext), name, ext)
+}
+
+func (r *audioRecorder) sample(left int16, right int16) {
+	r.wav.Sample(left, right)
+}
+
+// a stem file is created the first time the channel is seen. this means that
+// channels of an external sound chip are recorded even if the chip is inserted
+// after the recording started. new files are padded with silence so that every
+// stem is synchronised with the mixed audio
+func (r *audioRecorder) stemSamples(v []int16, names func() []string) {
+	if r.err != nil {
+		return
+	}
+
+	if len(v) > len(r.stems) {
+		n := names()
+		for i := len(r.stems); i < len(v) && i < len(n); i++ {
+			w, err := capture.NewMonoWAV(stemFilename(r.filename, n[i]), r.freq)
+			if err != nil {
+				r.err = err
+				return
+			}
+			for range r.wav.Samples() - 1 {
+				w.Sample(0, 0)
+			}
+			r.stems = append(r.stems, w)
+		}
+	}
+
+	for i, s := range r.stems {
+		if i < len(v) {
+			s.Sample(v[i], 0)
+		} else {
+			s.Sample(0, 0)
+		}
+	}
+}
+
+func (r *audioRecorder) close() error {
+	err := errors.Join(r.err, r.wav.Close())
+	for _, s := range r.stems {
+		err = errors.Join(err, s.Close())
+	}
+	return err
+}
+
+// audioTap forwards the audio samples from the TIA to the active recordings
+type audioTap struct {
+	m *debugger
+}
+
+func (t audioTap) Sample(left int16, right int16) {
+	if t.m.record != nil {
+		t.m.record.Sample(left, right)
+	}
+	if t.m.audioRecord != nil {
+		t.m.audioRecord.sample(left, right)
+	}
+}
+
+func (t audioTap) Stems(v []int16) {
+	if t.m.audioRecord != nil {
+		t.m.audioRecord.stemSamples(v, t.m.console.TIA.StemNames)
+	}
+}
+
+// attaches or detaches the audio tap depending on which recordings are active
+func (m *debugger) updateAudioTap() {
+	if m.record != nil || m.audioRecord != nil {
+		m.console.TIA.SetAudioTap(audioTap{m: m})
+	} else {
+		m.console.TIA.SetAudioTap(nil)
+	}
+	if m.audioRecord != nil && m.audioRecord.withStems {
+		m.console.TIA.SetStemTap(audioTap{m: m})
+	} else {
+		m.console.TIA.SetStemTap(nil)
+	}
+}
+
+func (m *debugger) audioRecordStart(filename string, withStems bool) error {
+	if m.audioRecord != nil {
+		return fmt.Errorf("audio recording already active: %s", m.audioRecord.filename)
+	}
+
+	freq := m.console.TIA.SampleRate()
+	wav, err := capture.NewWAV(filename, freq)
+	if err != nil {
+		return err
+	}
+
+	m.audioRecord = &audioRecorder{
+		filename:  filename,
+		freq:      freq,
+		wav:       wav,
+		withStems: withStems,
+	}
+	m.updateAudioTap()
+
+	return nil
+}
+
+func (m *debugger) audioRecordStop() error {
+	if m.audioRecord == nil {
+		return fmt.Errorf("audio recording is not active")
+	}
+
+	r := m.audioRecord
+	m.audioRecord = nil
+	m.updateAudioTap()
+
+	err := r.close()
+	if err != nil {
+		return err
+	}
+
+	s := fmt.Sprintf("recorded %d samples to %s", r.wav.Samples(), r.filename)
+	if len(r.stems) > 0 {
+		s = fmt.Sprintf("%s with %d stems", s, len(r.stems))
+	}
+	fmt.Println(m.styles.debugger.Render(s))
+
+	return nil
+}
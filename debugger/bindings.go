@@ -0,0 +1,55 @@
+package debugger
+
+import (
+	"crypto/md5"
+	"fmt"
+
+	"github.com/jetsetilly/test7800/gui/bindings"
+)
+
+// the MD5 sum of the loaded ROM. used to select the bindings for the ROM
+func (m *debugger) romMD5() string {
+	if len(m.loader.Data()) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%x", md5.Sum(m.loader.Data()))
+}
+
+// notify the GUI of the input bindings for the loaded ROM. the send blocks
+// until the GUI has accepted any earlier setup so that a change of bindings is
+// never lost. the GUI is never notified in headless mode
+func (m *debugger) setBindings() {
+	if m.headless {
+		return
+	}
+	m.g.InputSetup <- m.bindings.Setup(m.romMD5())
+}
+
+// parse and apply the arguments of the BIND command that change a binding. the
+// changed bindings are saved and sent to the GUI
+func (m *debugger) bind(rom bool, args []string) error {
+	if len(args) < 3 {
+		return fmt.Errorf("BIND requires a device, a port and a control")
+	}
+
+	d, err := bindings.ParseDevice(args[0])
+	if err != nil {
+		return err
+	}
+	c, err := bindings.ParseControl(args[1], args[2])
+	if err != nil {
+		return err
+	}
+
+	var md5 string
+	if rom {
+		md5 = m.romMD5()
+		if md5 == "" {
+			return fmt.Errorf("no ROM is loaded")
+		}
+	}
+
+	m.bindings.Bind(md5, d, c, args[3:])
+	m.setBindings()
+	return m.bindings.Save()
+}
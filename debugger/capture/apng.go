@@ -0,0 +1,189 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"os"
+)
+
+// the eight byte signature at the start of every PNG file
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// apng writes frames as an animated PNG. each frame is encoded with the
+// standard PNG encoder and the resulting chunks are rearranged into the APNG
+// format. programs that don't understand APNG will show the first frame
+type apng struct {
+	f      *os.File
+	rate   Rate
+	canvas canvas
+	frames int
+
+	// the sequence number of the next fcTL or fdAT chunk
+	seq uint32
+
+	// the file offset of the acTL chunk. the number of frames in the acTL chunk
+	// is updated by Close()
+	actl int64
+
+	enc png.Encoder
+	buf bytes.Buffer
+}
+
+func newAPNG(filename string, rate Rate) (*apng, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("apng: %w", err)
+	}
+	return &apng{
+		f:    f,
+		rate: rate,
+		enc: png.Encoder{
+			CompressionLevel: png.BestSpeed,
+		},
+	}, nil
+}
+
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+// splits an encoded PNG into chunks
+func pngChunks(b []byte) ([]pngChunk, error) {
+	if !bytes.HasPrefix(b, []byte(pngSignature)) {
+		return nil, fmt.Errorf("not a PNG")
+	}
+	b = b[len(pngSignature):]
+
+	var chunks []pngChunk
+	for len(b) >= 12 {
+		n := int(binary.BigEndian.Uint32(b))
+		if len(b) < n+12 {
+			return nil, fmt.Errorf("truncated chunk")
+		}
+		chunks = append(chunks, pngChunk{
+			typ:  string(b[4:8]),
+			data: b[8 : 8+n],
+		})
+		b = b[n+12:]
+	}
+	return chunks, nil
+}
+
+func writeChunk(w io.Writer, typ string, data []byte) error {
+	var h [8]byte
+	binary.BigEndian.PutUint32(h[:], uint32(len(data)))
+	copy(h[4:], typ)
+
+	crc := crc32.NewIEEE()
+	crc.Write(h[4:])
+	crc.Write(data)
+
+	var c [4]byte
+	binary.BigEndian.PutUint32(c[:], crc.Sum32())
+
+	for _, b := range [][]byte{h[:], data, c[:]} {
+		_, err := w.Write(b)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// returns the data for an acTL chunk
+func (v *apng) actlData() []byte {
+	var d [8]byte
+	binary.BigEndian.PutUint32(d[0:], uint32(v.frames))
+	binary.BigEndian.PutUint32(d[4:], 0) // loop forever
+	return d[:]
+}
+
+func (v *apng) Frame(img *image.RGBA) error {
+	img = v.canvas.set(img)
+
+	v.buf.Reset()
+	err := v.enc.Encode(&v.buf, img)
+	if err != nil {
+		return fmt.Errorf("apng: %w", err)
+	}
+
+	chunks, err := pngChunks(v.buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("apng: %w", err)
+	}
+
+	// the chunks are written to a buffer before being written to the file
+	var out bytes.Buffer
+
+	if v.frames == 0 {
+		out.WriteString(pngSignature)
+		for _, c := range chunks {
+			if c.typ == "IHDR" {
+				writeChunk(&out, c.typ, c.data)
+			}
+		}
+		v.actl = int64(out.Len())
+		writeChunk(&out, "acTL", v.actlData())
+	}
+
+	// the frame control chunk. the delay is the length of one frame
+	var fctl [26]byte
+	binary.BigEndian.PutUint32(fctl[0:], v.seq)
+	binary.BigEndian.PutUint32(fctl[4:], uint32(img.Bounds().Dx()))
+	binary.BigEndian.PutUint32(fctl[8:], uint32(img.Bounds().Dy()))
+	binary.BigEndian.PutUint16(fctl[20:], uint16(v.rate.Den))
+	binary.BigEndian.PutUint16(fctl[22:], uint16(v.rate.Num))
+	writeChunk(&out, "fcTL", fctl[:])
+	v.seq++
+
+	// the image data of the first frame is also the default image and uses
+	// IDAT chunks. later frames use fdAT chunks
+	for _, c := range chunks {
+		if c.typ != "IDAT" {
+			continue
+		}
+		if v.frames == 0 {
+			writeChunk(&out, "IDAT", c.data)
+		} else {
+			d := make([]byte, 4, len(c.data)+4)
+			binary.BigEndian.PutUint32(d, v.seq)
+			writeChunk(&out, "fdAT", append(d, c.data...))
+			v.seq++
+		}
+	}
+
+	_, err = v.f.Write(out.Bytes())
+	if err != nil {
+		return fmt.Errorf("apng: %w", err)
+	}
+
+	v.frames++
+	return nil
+}
+
+func (v *apng) Frames() int {
+	return v.frames
+}
+
+func (v *apng) Close() error {
+	err := writeChunk(v.f, "IEND", nil)
+
+	// update the number of frames in the acTL chunk
+	if err == nil && v.frames > 0 {
+		var out bytes.Buffer
+		writeChunk(&out, "acTL", v.actlData())
+		_, err = v.f.WriteAt(out.Bytes(), v.actl)
+	}
+
+	if err != nil {
+		v.f.Close()
+		return fmt.Errorf("apng: %w", err)
+	}
+	return v.f.Close()
+}
@@ -0,0 +1,174 @@
+package capture_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jetsetilly/test7800/debugger/capture"
+	"github.com/jetsetilly/test7800/test"
+)
+
+// a frame filled with a single colour
+func frame(w int, h int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestWAV(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.wav")
+
+	wav, err := capture.NewWAV(filename, 48000)
+	test.DemandSuccess(t, err)
+	wav.Sample(1, -1)
+	wav.Sample(0x1234, 0x7fff)
+	test.ExpectEquality(t, wav.Samples(), 2)
+	test.DemandSuccess(t, wav.Close())
+
+	b, err := os.ReadFile(filename)
+	test.DemandSuccess(t, err)
+	test.DemandEquality(t, len(b), 44+8)
+	test.ExpectEquality(t, string(b[0:4]), "RIFF")
+	test.ExpectEquality(t, binary.LittleEndian.Uint32(b[4:]), uint32(36+8))
+	test.ExpectEquality(t, string(b[8:16]), "WAVEfmt ")
+	test.ExpectEquality(t, binary.LittleEndian.Uint16(b[22:]), uint16(2))
+	test.ExpectEquality(t, binary.LittleEndian.Uint32(b[24:]), uint32(48000))
+	test.ExpectEquality(t, string(b[36:40]), "data")
+	test.ExpectEquality(t, binary.LittleEndian.Uint32(b[40:]), uint32(8))
+	test.ExpectEquality(t, int16(binary.LittleEndian.Uint16(b[44:])), int16(1))
+	test.ExpectEquality(t, int16(binary.LittleEndian.Uint16(b[46:])), int16(-1))
+	test.ExpectEquality(t, int16(binary.LittleEndian.Uint16(b[48:])), int16(0x1234))
+	test.ExpectEquality(t, int16(binary.LittleEndian.Uint16(b[50:])), int16(0x7fff))
+}
+
+func TestAPNG(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.png")
+
+	v, err := capture.NewVideo(filename, capture.Rate{Num: 15734, Den: 263})
+	test.DemandSuccess(t, err)
+
+	red := color.RGBA{R: 255, A: 255}
+	test.DemandSuccess(t, v.Frame(frame(16, 8, red)))
+	test.DemandSuccess(t, v.Frame(frame(16, 8, color.RGBA{G: 255, A: 255})))
+
+	// a frame of a different size and with transparent pixels
+	test.DemandSuccess(t, v.Frame(frame(8, 8, color.RGBA{})))
+	test.ExpectEquality(t, v.Frames(), 3)
+	test.DemandSuccess(t, v.Close())
+
+	b, err := os.ReadFile(filename)
+	test.DemandSuccess(t, err)
+
+	// the default image is the first frame
+	img, err := png.Decode(bytes.NewReader(b))
+	test.DemandSuccess(t, err)
+	test.ExpectEquality(t, img.Bounds(), image.Rect(0, 0, 16, 8))
+	r, g, bl, a := img.At(3, 3).RGBA()
+	test.ExpectEquality(t, [4]uint32{r, g, bl, a}, [4]uint32{0xffff, 0, 0, 0xffff})
+
+	// check the animation chunks
+	var types []string
+	var frames uint32
+	var seq []uint32
+	var delay [2]uint16
+	p := b[8:]
+	for len(p) >= 12 {
+		n := binary.BigEndian.Uint32(p)
+		typ := string(p[4:8])
+		data := p[8 : 8+n]
+		types = append(types, typ)
+		switch typ {
+		case "acTL":
+			frames = binary.BigEndian.Uint32(data)
+		case "fcTL":
+			seq = append(seq, binary.BigEndian.Uint32(data))
+			delay = [2]uint16{binary.BigEndian.Uint16(data[20:]), binary.BigEndian.Uint16(data[22:])}
+		case "fdAT":
+			seq = append(seq, binary.BigEndian.Uint32(data))
+		}
+		p = p[n+12:]
+	}
+
+	test.ExpectEquality(t, frames, uint32(3))
+	test.ExpectEquality(t, fmt.Sprint(types), "[IHDR acTL fcTL IDAT fcTL fdAT fcTL fdAT IEND]")
+	test.ExpectEquality(t, fmt.Sprint(seq), "[0 1 2 3 4]")
+	test.ExpectEquality(t, delay, [2]uint16{263, 15734})
+}
+
+func TestY4M(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.y4m")
+
+	v, err := capture.NewVideo(filename, capture.Rate{Num: 15625, Den: 313})
+	test.DemandSuccess(t, err)
+	test.DemandSuccess(t, v.Frame(frame(4, 2, color.RGBA{R: 255, G: 255, B: 255, A: 255})))
+	test.DemandSuccess(t, v.Frame(frame(4, 2, color.RGBA{A: 255})))
+	test.DemandSuccess(t, v.Close())
+
+	b, err := os.ReadFile(filename)
+	test.DemandSuccess(t, err)
+
+	header := "YUV4MPEG2 W4 H2 F15625:313 Ip A1:1 C444\n"
+	test.DemandSuccess(t, bytes.HasPrefix(b, []byte(header)))
+	b = b[len(header):]
+
+	// each frame is the FRAME marker followed by three full size planes
+	const planes = 4 * 2 * 3
+	test.DemandEquality(t, len(b), 2*(len("FRAME\n")+planes))
+
+	// white and black in studio swing
+	test.ExpectEquality(t, string(b[6:6+8]), string([]byte{235, 235, 235, 235, 235, 235, 235, 235}))
+	test.ExpectEquality(t, string(b[6+8:6+16]), string([]byte{128, 128, 128, 128, 128, 128, 128, 128}))
+	b = b[6+planes:]
+	test.ExpectEquality(t, string(b[6:6+8]), string([]byte{16, 16, 16, 16, 16, 16, 16, 16}))
+}
+
+func TestNewVideo(t *testing.T) {
+	_, err := capture.NewVideo(filepath.Join(t.TempDir(), "test.avi"), capture.Rate{Num: 60, Den: 1})
+	test.ExpectFailure(t, err)
+	_, err = capture.NewVideo(filepath.Join(t.TempDir(), "test.y4m"), capture.Rate{})
+	test.ExpectFailure(t, err)
+
+	test.ExpectEquality(t, capture.AudioFilename("out/video.y4m"), "out/video.wav")
+}
+
+func TestScale(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.SetRGBA(1, 0, color.RGBA{R: 255, A: 255})
+
+	s := capture.Scale(img, 3)
+	test.DemandEquality(t, s.Bounds(), image.Rect(0, 0, 6, 3))
+	test.ExpectEquality(t, s.RGBAAt(2, 2), color.RGBA{})
+	test.ExpectEquality(t, s.RGBAAt(3, 0), color.RGBA{R: 255, A: 255})
+	test.ExpectEquality(t, capture.Scale(img, 1), img)
+}
+
+func TestMonoWAV(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.wav")
+
+	wav, err := capture.NewMonoWAV(filename, 31440)
+	test.DemandSuccess(t, err)
+	wav.Sample(100, 200)
+	wav.Sample(-100, 200)
+	test.DemandSuccess(t, wav.Close())
+
+	b, err := os.ReadFile(filename)
+	test.DemandSuccess(t, err)
+	test.DemandEquality(t, len(b), 44+4)
+	test.ExpectEquality(t, binary.LittleEndian.Uint16(b[22:]), uint16(1))
+	test.ExpectEquality(t, binary.LittleEndian.Uint32(b[28:]), uint32(31440*2))
+	test.ExpectEquality(t, binary.LittleEndian.Uint16(b[32:]), uint16(2))
+	test.ExpectEquality(t, binary.LittleEndian.Uint32(b[40:]), uint32(4))
+	test.ExpectEquality(t, int16(binary.LittleEndian.Uint16(b[44:])), int16(100))
+	test.ExpectEquality(t, int16(binary.LittleEndian.Uint16(b[46:])), int16(-100))
+}
@@ -0,0 +1,13 @@
+// Package capture writes the output of the emulation to files. Video is
+// written as an animated PNG or as an uncompressed YUV4MPEG2 stream and audio
+// is written as a WAV file.
+//
+// None of the formats use lossy compression and every frame is written, so a
+// recording made from a deterministic run of the emulation is always the same.
+// The files can be combined and compressed afterwards with a tool like ffmpeg:
+//
+//	ffmpeg -i out.y4m -i out.wav out.mp4
+//
+// The writers do not depend on the GUI and so can be used when the emulation
+// is running headless.
+package capture
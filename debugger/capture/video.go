@@ -0,0 +1,92 @@
+package capture
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"path/filepath"
+	"strings"
+)
+
+// Video is implemented by the video formats
+type Video interface {
+	// Frame adds a frame to the video. The first frame decides the size of the
+	// video. Later frames of a different size are cropped or padded with black
+	Frame(img *image.RGBA) error
+
+	// Frames returns the number of frames added so far
+	Frames() int
+
+	// Close completes the video file
+	Close() error
+}
+
+// Rate is the number of frames per second expressed as a fraction. For example,
+// the rate of an NTSC console is the number of scanlines per second divided by
+// the number of scanlines per frame
+type Rate struct {
+	Num int
+	Den int
+}
+
+func (r Rate) String() string {
+	return fmt.Sprintf("%.2ffps", float64(r.Num)/float64(r.Den))
+}
+
+// NewVideo creates a video file. The format of the video is decided by the
+// filename extension: y4m for a YUV4MPEG2 stream and png or apng for an
+// animated PNG
+func NewVideo(filename string, rate Rate) (Video, error) {
+	if rate.Num <= 0 || rate.Den <= 0 {
+		return nil, fmt.Errorf("video: frame rate is not valid: %d/%d", rate.Num, rate.Den)
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".y4m":
+		return newY4M(filename, rate)
+	case ".png", ".apng":
+		return newAPNG(filename, rate)
+	}
+	return nil, fmt.Errorf("video: unsupported file type: %s", filename)
+}
+
+// AudioFilename returns the filename of the WAV file that accompanies the video
+// file
+func AudioFilename(videoFilename string) string {
+	return fmt.Sprintf("%s.wav", strings.TrimSuffix(videoFilename, filepath.Ext(videoFilename)))
+}
+
+// Scale returns a copy of the image enlarged by the scale factor. Each pixel
+// in the image becomes a square block of pixels in the new image. A scale of
+// one or less returns the original image
+func Scale(img *image.RGBA, scale int) *image.RGBA {
+	if scale <= 1 {
+		return img
+	}
+
+	b := img.Bounds()
+	s := image.NewRGBA(image.Rect(0, 0, b.Dx()*scale, b.Dy()*scale))
+	for y := range s.Bounds().Dy() {
+		for x := range s.Bounds().Dx() {
+			s.SetRGBA(x, y, img.RGBAAt(b.Min.X+x/scale, b.Min.Y+y/scale))
+		}
+	}
+	return s
+}
+
+// canvas is an opaque image of fixed size that frames are copied onto. this
+// means that every frame in a video has the same size and that transparent
+// pixels in the emulation image are black
+type canvas struct {
+	img *image.RGBA
+}
+
+func (c *canvas) set(img *image.RGBA) *image.RGBA {
+	if c.img == nil {
+		c.img = image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	}
+	draw.Draw(c.img, c.img.Bounds(), image.NewUniform(color.RGBA{A: 255}), image.Point{}, draw.Src)
+	draw.Draw(c.img, c.img.Bounds(), img, img.Bounds().Min, draw.Over)
+	return c.img
+}
@@ -0,0 +1,133 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// the length of the RIFF header written by the WAV type
+const wavHeaderLen = 44
+
+// WAV writes 16bit samples to a WAV file. The Sample() function satisfies the
+// tia.AudioTap interface
+type WAV struct {
+	f    *os.File
+	w    *bufio.Writer
+	freq int
+	mono bool
+
+	// the number of samples written
+	samples int
+
+	// the first error encountered by Sample(). returned by Close()
+	err error
+}
+
+// NewWAV creates a new stereo WAV file with the specified sampling rate
+func NewWAV(filename string, freq int) (*WAV, error) {
+	return newWAV(filename, freq, false)
+}
+
+// NewMonoWAV creates a new mono WAV file with the specified sampling rate. Only
+// the left channel of each sample is written
+func NewMonoWAV(filename string, freq int) (*WAV, error) {
+	return newWAV(filename, freq, true)
+}
+
+func newWAV(filename string, freq int, mono bool) (*WAV, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("wav: %w", err)
+	}
+
+	wav := &WAV{
+		f:    f,
+		w:    bufio.NewWriter(f),
+		freq: freq,
+		mono: mono,
+	}
+
+	// the header will be written again with the correct lengths by Close()
+	err = wav.header()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("wav: %w", err)
+	}
+
+	return wav, nil
+}
+
+func (wav *WAV) header() error {
+	channels := 2
+	if wav.mono {
+		channels = 1
+	}
+	const bytesPerSample = 2
+	dataLen := uint32(wav.samples * channels * bytesPerSample)
+
+	var h [wavHeaderLen]byte
+	copy(h[0:], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:], wavHeaderLen-8+dataLen)
+	copy(h[8:], "WAVE")
+	copy(h[12:], "fmt ")
+	binary.LittleEndian.PutUint32(h[16:], 16)
+	binary.LittleEndian.PutUint16(h[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(h[22:], uint16(channels))
+	binary.LittleEndian.PutUint32(h[24:], uint32(wav.freq))
+	binary.LittleEndian.PutUint32(h[28:], uint32(wav.freq*channels*bytesPerSample))
+	binary.LittleEndian.PutUint16(h[32:], uint16(channels*bytesPerSample))
+	binary.LittleEndian.PutUint16(h[34:], bytesPerSample*8)
+	copy(h[36:], "data")
+	binary.LittleEndian.PutUint32(h[40:], dataLen)
+
+	_, err := wav.w.Write(h[:])
+	return err
+}
+
+// Sample adds a single sample to the WAV file. The right channel is ignored if
+// the file is mono
+func (wav *WAV) Sample(left int16, right int16) {
+	if wav.err != nil {
+		return
+	}
+	var b [4]byte
+	binary.LittleEndian.PutUint16(b[0:], uint16(left))
+	binary.LittleEndian.PutUint16(b[2:], uint16(right))
+	if wav.mono {
+		_, wav.err = wav.w.Write(b[:2])
+	} else {
+		_, wav.err = wav.w.Write(b[:])
+	}
+	wav.samples++
+}
+
+// Samples returns the number of samples written so far
+func (wav *WAV) Samples() int {
+	return wav.samples
+}
+
+// Close completes the WAV file. Any error encountered while adding samples is
+// returned
+func (wav *WAV) Close() error {
+	err := wav.err
+	if err == nil {
+		err = wav.w.Flush()
+	}
+	if err == nil {
+		_, err = wav.f.Seek(0, 0)
+	}
+	if err == nil {
+		wav.w.Reset(wav.f)
+		err = wav.header()
+	}
+	if err == nil {
+		err = wav.w.Flush()
+	}
+	if err != nil {
+		wav.f.Close()
+		return fmt.Errorf("wav: %w", err)
+	}
+	return wav.f.Close()
+}
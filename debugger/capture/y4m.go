@@ -0,0 +1,87 @@
+package capture
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"os"
+)
+
+// y4m writes frames as an uncompressed YUV4MPEG2 stream. the chroma is not
+// subsampled (the C444 colour space) so the only loss is the conversion from
+// RGB to YUV
+type y4m struct {
+	f      *os.File
+	w      *bufio.Writer
+	rate   Rate
+	canvas canvas
+	frames int
+
+	// the three planes of a frame
+	planes []byte
+}
+
+func newY4M(filename string, rate Rate) (*y4m, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("y4m: %w", err)
+	}
+	return &y4m{
+		f:    f,
+		w:    bufio.NewWriter(f),
+		rate: rate,
+	}, nil
+}
+
+// converts an RGB value to BT.601 YUV with studio swing
+func yuv(r, g, b uint8) (uint8, uint8, uint8) {
+	rf, gf, bf := float64(r), float64(g), float64(b)
+	y := 16 + (65.481*rf+128.553*gf+24.966*bf)/255
+	u := 128 + (-37.797*rf-74.203*gf+112.0*bf)/255
+	v := 128 + (112.0*rf-93.786*gf-18.214*bf)/255
+	return uint8(y + 0.5), uint8(u + 0.5), uint8(v + 0.5)
+}
+
+func (v *y4m) Frame(img *image.RGBA) error {
+	img = v.canvas.set(img)
+	w := img.Bounds().Dx()
+	h := img.Bounds().Dy()
+
+	if v.frames == 0 {
+		_, err := fmt.Fprintf(v.w, "YUV4MPEG2 W%d H%d F%d:%d Ip A1:1 C444\n", w, h, v.rate.Num, v.rate.Den)
+		if err != nil {
+			return fmt.Errorf("y4m: %w", err)
+		}
+		v.planes = make([]byte, w*h*3)
+	}
+
+	for i := range w * h {
+		p := img.Pix[i*4:]
+		v.planes[i], v.planes[w*h+i], v.planes[w*h*2+i] = yuv(p[0], p[1], p[2])
+	}
+
+	_, err := v.w.WriteString("FRAME\n")
+	if err != nil {
+		return fmt.Errorf("y4m: %w", err)
+	}
+	_, err = v.w.Write(v.planes)
+	if err != nil {
+		return fmt.Errorf("y4m: %w", err)
+	}
+
+	v.frames++
+	return nil
+}
+
+func (v *y4m) Frames() int {
+	return v.frames
+}
+
+func (v *y4m) Close() error {
+	err := v.w.Flush()
+	if err != nil {
+		v.f.Close()
+		return fmt.Errorf("y4m: %w", err)
+	}
+	return v.f.Close()
+}
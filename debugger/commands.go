@@ -6,13 +6,24 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/charmbracelet/lipgloss"
 	"github.com/jetsetilly/dialog"
 	"github.com/jetsetilly/test7800/disassembly"
+	"github.com/jetsetilly/test7800/gui/crt"
 	"github.com/jetsetilly/test7800/hardware/memory"
-	"github.com/jetsetilly/test7800/hardware/memory/external"
+	"github.com/jetsetilly/test7800/hardware/spec"
 	"github.com/jetsetilly/test7800/logger"
 )
 
+// prints the reason a command could not be completed. the command counts as a
+// failure if it is part of a script
+func (m *debugger) commandErr(s string) {
+	fmt.Println(m.styles.err.Render(s))
+	if m.scriptDepth > 0 {
+		m.failures++
+	}
+}
+
 // returns true if debugger is to quit
 func (m *debugger) parseCommand(cmd []string) bool {
 	if len(cmd) == 0 {
@@ -22,36 +33,60 @@ func (m *debugger) parseCommand(cmd []string) bool {
 	switch strings.ToUpper(cmd[0]) {
 	case "INSERT":
 		if len(cmd) < 2 {
-			fmt.Println(m.styles.err.Render(
-				"INSERT requires a filename",
-			))
+			m.commandErr("INSERT requires a filename")
 			break // switch
 		}
 
 		var err error
-		m.loader, err = external.Fingerprint(cmd[1], "AUTO")
+		m.loader, err = fingerprint(m.g, m.useDialog, cmd[1], "AUTO")
 		if err != nil {
-			dialog.Message("Problem with selected file\n\n%v", err).Error()
+			if m.useDialog {
+				dialog.Message("Problem with selected file\n\n%v", err).Error()
+			} else {
+				m.commandErr(err.Error())
+			}
 		} else {
 			m.reset()
 		}
 
 	case "BOOT":
 		if len(cmd) < 5 {
-			fmt.Println(m.styles.err.Render(
+			m.commandErr(
 				"BOOT requires a ROM file, an origin address, an entry address and the INPTCTRL value",
-			))
+			)
+			break // switch
+		}
+
+		if m.coprocSuspendedCheck() {
 			break // switch
 		}
 
 		err := m.bootParse(cmd[1:])
 		if err != nil {
-			fmt.Println(m.styles.err.Render(err.Error()))
+			m.commandErr(err.Error())
 			break // switch
 		}
 
 	case "R", "RUN":
-		return m.run()
+		if len(cmd) > 1 {
+			if strings.ToUpper(cmd[1]) != "UNTIL" || len(cmd) < 3 {
+				m.commandErr("RUN can only be qualified with UNTIL and a step rule")
+				break // switch
+			}
+			if !m.parseStepRule(cmd[2:]) {
+				break // switch
+			}
+		}
+		m.clearInterrupt()
+		if m.run() {
+			return true
+		}
+
+		// a run in a script should only end because of its step rule. anything
+		// else, such as a breakpoint or the CPU being killed, is a failure
+		if m.scriptDepth > 0 && !m.stepRuleMet {
+			m.commandErr(fmt.Sprintf("%s was halted", strings.Join(cmd, " ")))
+		}
 
 	case "ST", "STEP":
 		if len(cmd) > 1 {
@@ -64,15 +99,15 @@ func (m *debugger) parseCommand(cmd []string) bool {
 				return true
 			}
 		}
+		m.clearInterrupt()
 		return m.run()
 
 	case "RESET":
 		m.reset()
 
-	case "CPU":
-		fmt.Println(m.styles.cpu.Render(
-			m.console.MC.String(),
-		))
+	case "CPU", "BIOS", "MARIA", "VIDEO", "INPTCTRL", "RAM7800", "RAMRIOT", "TIA", "RIOT":
+		s, style, _ := m.componentState(cmd[0])
+		fmt.Println(style.Render(s))
 
 	case "DISASM":
 		func() {
@@ -81,9 +116,7 @@ func (m *debugger) parseCommand(cmd []string) bool {
 			if len(cmd) == 2 {
 				f, err := os.Create(cmd[1])
 				if err != nil {
-					fmt.Println(m.styles.err.Render(
-						"cannot open file to write DISASM to",
-					))
+					m.commandErr("cannot open file to write DISASM to")
 					return
 				}
 				w = f
@@ -111,9 +144,7 @@ func (m *debugger) parseCommand(cmd []string) bool {
 					// if argument is not a number then use it as the name of the file to save to
 					f, err := os.Create(cmd[1])
 					if err != nil {
-						fmt.Println(m.styles.err.Render(
-							"cannot open file to write DISASM to",
-						))
+						m.commandErr("cannot open file to write DISASM to")
 						return
 					}
 					n = len(m.recent)
@@ -135,17 +166,46 @@ func (m *debugger) parseCommand(cmd []string) bool {
 			}
 		}()
 
-	case "BIOS":
-		fmt.Println(m.styles.mem.Render(
-			m.console.Mem.BIOS.String(),
-		))
-
-	case "MARIA":
-		fmt.Println(m.styles.mem.Render(
-			m.console.MARIA.String(),
-		))
-
 	case "DL":
+		if len(cmd) > 1 {
+			switch strings.ToUpper(cmd[1]) {
+			case "FRAME":
+				if len(cmd) == 2 {
+					fmt.Println(m.styles.mem.Render(
+						m.console.MARIA.CompletedFrameDL().String(),
+					))
+					break // switch
+				}
+				err := m.frameDLExport(cmd[2])
+				if err != nil {
+					m.commandErr(fmt.Sprintf("DL FRAME: %s", err.Error()))
+					break // switch
+				}
+				fmt.Println(m.styles.debugger.Render(
+					fmt.Sprintf("display lists for frame %d written to %s", m.console.MARIA.CompletedFrameDL().Frame, cmd[2]),
+				))
+			case "AT":
+				if len(cmd) != 4 {
+					m.commandErr("DL AT requires an x and a y coordinate")
+					break // switch
+				}
+				x, err := strconv.Atoi(cmd[2])
+				if err != nil {
+					m.commandErr(fmt.Sprintf("DL AT: x coordinate not valid: %s", cmd[2]))
+					break // switch
+				}
+				y, err := strconv.Atoi(cmd[3])
+				if err != nil {
+					m.commandErr(fmt.Sprintf("DL AT: y coordinate not valid: %s", cmd[3]))
+					break // switch
+				}
+				m.frameDLAt(x, y)
+			default:
+				m.commandErr(fmt.Sprintf("unrecognised argument for DL command: %s", cmd[1]))
+			}
+			break // switch
+		}
+
 		if len(m.console.MARIA.RecentDL) == 0 {
 			fmt.Println(m.styles.mem.Render("no DL activity this scanline"))
 		}
@@ -166,9 +226,7 @@ func (m *debugger) parseCommand(cmd []string) bool {
 					))
 				}
 			} else {
-				fmt.Println(m.styles.err.Render(
-					fmt.Sprintf("unrecognised argument for DLL command: %s", cmd[1]),
-				))
+				m.commandErr(fmt.Sprintf("unrecognised argument for DLL command: %s", cmd[1]))
 			}
 		} else {
 			fmt.Println(m.styles.mem.Render(
@@ -176,66 +234,182 @@ func (m *debugger) parseCommand(cmd []string) bool {
 			))
 		}
 
-	case "VIDEO":
-		fmt.Println(m.styles.video.Render(
-			m.console.MARIA.Coords.String(),
-		))
+	case "DMA":
+		if len(cmd) == 1 {
+			m.dmaSummary()
+			break // switch
+		}
 
-	case "INPTCTRL":
-		fmt.Println(m.styles.mem.Render(
-			m.console.Mem.INPTCTRL.String(),
-		))
+		switch strings.ToUpper(cmd[1]) {
+		case "FRAME":
+			fmt.Print(m.styles.mem.Render(
+				dmaHistogram(m.console.MARIA.CompletedFrameDMA()),
+			))
+		case "EXPORT":
+			if len(cmd) != 3 {
+				m.commandErr("DMA EXPORT requires a filename")
+				break // switch
+			}
+			err := m.dmaExport(cmd[2])
+			if err != nil {
+				m.commandErr(fmt.Sprintf("DMA EXPORT: %s", err.Error()))
+				break // switch
+			}
+			fmt.Println(m.styles.debugger.Render(
+				fmt.Sprintf("DMA usage written to %s", cmd[2]),
+			))
+		default:
+			m.commandErr(fmt.Sprintf("unrecognised argument for DMA command: %s", cmd[1]))
+		}
 
-	case "RAM7800":
-		fmt.Println(m.styles.mem.Render(
-			m.console.Mem.RAM7800.String(),
-		))
+	case "CRT":
+		if len(cmd) == 1 {
+			fmt.Println(m.styles.debugger.Render(m.crt.String()))
+			break // switch
+		}
+		if strings.ToUpper(cmd[1]) == "LIST" {
+			fmt.Println(m.styles.debugger.Render(
+				strings.Join(crt.Presets(), "\n"),
+			))
+			break // switch
+		}
+		p, err := crt.ParsePreset(strings.Join(cmd[1:], ""))
+		if err != nil {
+			m.commandErr(err.Error())
+			break // switch
+		}
+		m.setCRT(p)
+		fmt.Println(m.styles.debugger.Render(p.String()))
+
+	case "PREFS":
+		if len(cmd) == 1 {
+			var l []string
+			for _, k := range m.prefs.dsk.Keys() {
+				v, _ := m.prefs.dsk.Get(k)
+				l = append(l, fmt.Sprintf("%s: %s", k, v))
+			}
+			fmt.Println(m.styles.debugger.Render(strings.Join(l, "\n")))
+			break // switch
+		}
 
-	case "RAMRIOT":
-		fmt.Println(m.styles.mem.Render(
-			m.console.Mem.RAMRIOT.String(),
-		))
+		switch strings.ToUpper(cmd[1]) {
+		case "SAVE":
+			err := m.prefs.dsk.Save()
+			if err != nil {
+				m.commandErr(err.Error())
+				break // switch
+			}
+			fmt.Println(m.styles.debugger.Render("preferences saved"))
+		case "RESET":
+			m.prefs.dsk.Reset()
+			fmt.Println(m.styles.debugger.Render("preferences reset to default values"))
+		default:
+			v, ok := m.prefs.dsk.Get(cmd[1])
+			if !ok {
+				m.commandErr(fmt.Sprintf("unrecognised preference: %s", cmd[1]))
+				break // switch
+			}
+			if len(cmd) > 2 {
+				err := m.prefs.dsk.Set(cmd[1], strings.Join(cmd[2:], " "))
+				if err != nil {
+					m.commandErr(err.Error())
+					break // switch
+				}
+			}
+			fmt.Println(m.styles.debugger.Render(
+				fmt.Sprintf("%s: %s", strings.ToLower(cmd[1]), v),
+			))
+		}
 
-	case "TIA":
-		fmt.Println(m.styles.mem.Render(
-			m.console.TIA.String(),
-		))
+	case "BIND":
+		if len(cmd) == 1 {
+			var l []string
+			for _, b := range m.bindings.List(m.romMD5()) {
+				l = append(l, b.String())
+			}
+			fmt.Println(m.styles.debugger.Render(strings.Join(l, "\n")))
+			break // switch
+		}
+
+		var err error
+		switch strings.ToUpper(cmd[1]) {
+		case "RESET":
+			m.bindings.Reset()
+			m.setBindings()
+			err = m.bindings.Save()
+		case "ROM":
+			if len(cmd) == 3 && strings.ToUpper(cmd[2]) == "CLEAR" {
+				m.bindings.ClearROM(m.romMD5())
+				m.setBindings()
+				err = m.bindings.Save()
+			} else {
+				err = m.bind(true, cmd[2:])
+			}
+		default:
+			err = m.bind(false, cmd[1:])
+		}
+		if err != nil {
+			m.commandErr(err.Error())
+		}
+
+	case "PALETTE":
+		if len(cmd) == 1 {
+			m.printPalette()
+			break // switch
+		}
+
+		switch strings.ToUpper(cmd[1]) {
+		case "LIST":
+			fmt.Println(m.styles.debugger.Render(
+				strings.Join(append(spec.Palettes(), "DEFAULT", "GENERATE"), "\n"),
+			))
+		case "SAVE":
+			if len(cmd) != 3 {
+				m.commandErr("PALETTE SAVE requires a filename")
+				break // switch
+			}
+			err := m.savePalette(cmd[2])
+			if err != nil {
+				m.commandErr(fmt.Sprintf("PALETTE SAVE: %s", err.Error()))
+				break // switch
+			}
+			fmt.Println(m.styles.debugger.Render(
+				fmt.Sprintf("palette written to %s", cmd[2]),
+			))
+		default:
+			err := m.setPalette(strings.Join(cmd[1:], " "))
+			if err != nil {
+				m.commandErr(err.Error())
+				break // switch
+			}
+			fmt.Println(m.styles.debugger.Render("palette changed"))
+		}
 
 	case "DUMP":
 		if len(cmd) < 3 {
-			fmt.Println(m.styles.err.Render(
-				"DUMP requires a 'from' and a 'to' address",
-			))
+			m.commandErr("DUMP requires a 'from' and a 'to' address")
 			break // switch
 		}
 
 		from, err := m.parseAddress(cmd[1])
 		if err != nil {
-			fmt.Println(m.styles.err.Render(
-				fmt.Sprintf("dump: %s", err.Error()),
-			))
+			m.commandErr(fmt.Sprintf("dump: %s", err.Error()))
 			break // switch
 		}
 
 		to, err := m.parseAddress(cmd[2])
 		if err != nil {
-			fmt.Println(m.styles.err.Render(
-				fmt.Sprintf("dump: %s", err.Error()),
-			))
+			m.commandErr(fmt.Sprintf("dump: %s", err.Error()))
 			break // switch
 		}
 
 		if to.address < from.address {
-			fmt.Println(m.styles.err.Render(
-				"dump: the 'to' address is less than the 'from' address",
-			))
+			m.commandErr("dump: the 'to' address is less than the 'from' address")
 			break // switch
 		}
 
 		if from.area != to.area {
-			fmt.Println(m.styles.err.Render(
-				"dump: the 'from' and 'to' addresses are in different memory areas",
-			))
+			m.commandErr("dump: the 'from' and 'to' addresses are in different memory areas")
 			break // switch
 		}
 
@@ -249,9 +423,7 @@ func (m *debugger) parseCommand(cmd []string) bool {
 
 			data, err := memory.Read(from.area, i)
 			if err != nil {
-				fmt.Println(m.styles.err.Render(
-					fmt.Sprintf("dump address is not readable: %04x", address),
-				))
+				m.commandErr(fmt.Sprintf("dump address is not readable: %04x", address))
 				break // switch
 			}
 			fmt.Printf(" %02x", data)
@@ -268,25 +440,19 @@ func (m *debugger) parseCommand(cmd []string) bool {
 
 	case "PEEK":
 		if len(cmd) < 2 {
-			fmt.Println(m.styles.err.Render(
-				"PEEK requires an address",
-			))
+			m.commandErr("PEEK requires an address")
 			break // switch
 		}
 
 		ma, err := m.parseAddress(cmd[1])
 		if err != nil {
-			fmt.Println(m.styles.err.Render(
-				fmt.Sprintf("peek: %s", err.Error()),
-			))
+			m.commandErr(fmt.Sprintf("peek: %s", err.Error()))
 			break // switch
 		}
 
 		data, err := memory.Read(ma.area, ma.idx)
 		if err != nil {
-			fmt.Println(m.styles.err.Render(
-				fmt.Sprintf("peek address is not readable: %s", cmd[1]),
-			))
+			m.commandErr(fmt.Sprintf("peek address is not readable: %s", cmd[1]))
 			break // switch
 		}
 
@@ -296,41 +462,31 @@ func (m *debugger) parseCommand(cmd []string) bool {
 
 	case "POKE":
 		if len(cmd) < 3 {
-			fmt.Println(m.styles.err.Render(
-				"POKE requires an address and a value",
-			))
+			m.commandErr("POKE requires an address and a value")
 			break // switch
 		}
 
 		ma, err := m.parseAddress(cmd[1])
 		if err != nil {
-			fmt.Println(m.styles.err.Render(
-				fmt.Sprintf("poke: %s", err.Error()),
-			))
+			m.commandErr(fmt.Sprintf("poke: %s", err.Error()))
 			break // switch
 		}
 
 		v, err := strconv.ParseUint(cmd[2], 0, 16)
 		if err != nil {
-			fmt.Println(m.styles.err.Render(
-				fmt.Sprintf("poke: %s", err.Error()),
-			))
+			m.commandErr(fmt.Sprintf("poke: %s", err.Error()))
 			break // switch
 		}
 
 		err = memory.Write(ma.area, ma.idx, uint8(v))
 		if err != nil {
-			fmt.Println(m.styles.err.Render(
-				fmt.Sprintf("poke address is not writeable: %s", cmd[1]),
-			))
+			m.commandErr(fmt.Sprintf("poke address is not writeable: %s", cmd[1]))
 			break // switch
 		}
 
 		data, err := memory.Read(ma.area, ma.idx)
 		if err != nil {
-			fmt.Println(m.styles.err.Render(
-				fmt.Sprintf("poke address is not readable: %s", cmd[1]),
-			))
+			m.commandErr(fmt.Sprintf("poke address is not readable: %s", cmd[1]))
 			break // switch
 		}
 
@@ -340,9 +496,7 @@ func (m *debugger) parseCommand(cmd []string) bool {
 
 	case "BREAK":
 		if len(cmd) < 2 {
-			fmt.Println(m.styles.err.Render(
-				"BREAK requires an address",
-			))
+			m.commandErr("BREAK requires an address")
 			break // switch
 		}
 
@@ -352,9 +506,7 @@ func (m *debugger) parseCommand(cmd []string) bool {
 
 		if arg == "DROP" {
 			if len(cmd) < 3 {
-				fmt.Println(m.styles.err.Render(
-					"BREAK DROP requires an address",
-				))
+				m.commandErr("BREAK DROP requires an address")
 				break // switch
 			}
 
@@ -363,9 +515,7 @@ func (m *debugger) parseCommand(cmd []string) bool {
 			} else {
 				ma, err := m.parseAddress(cmd[2])
 				if err != nil {
-					fmt.Println(m.styles.err.Render(
-						fmt.Sprintf("breakpoint: %s", err.Error()),
-					))
+					m.commandErr(fmt.Sprintf("breakpoint: %s", err.Error()))
 					break // switch
 				}
 				if _, ok := m.breakpoints[ma.address]; !ok {
@@ -389,14 +539,20 @@ func (m *debugger) parseCommand(cmd []string) bool {
 				fmt.Println(m.styles.debugger.Render("context breakpoints disabled"))
 			}
 			break // switch
+		} else if arg == "DMA" {
+			m.breakpointDMA = !m.breakpointDMA
+			if m.breakpointDMA {
+				fmt.Println(m.styles.debugger.Render("DMA overrun breakpoints enabled"))
+			} else {
+				fmt.Println(m.styles.debugger.Render("DMA overrun breakpoints disabled"))
+			}
+			break // switch
 		}
 
 		for i := 1; i < len(cmd); i++ {
 			ma, err := m.parseAddress(cmd[i])
 			if err != nil {
-				fmt.Println(m.styles.err.Render(
-					fmt.Sprintf("breakpoint: %s", err.Error()),
-				))
+				m.commandErr(fmt.Sprintf("breakpoint: %s", err.Error()))
 				break // switch
 			}
 
@@ -415,9 +571,7 @@ func (m *debugger) parseCommand(cmd []string) bool {
 
 	case "WATCH":
 		if len(cmd) < 2 {
-			fmt.Println(m.styles.err.Render(
-				"WATCH requires an address",
-			))
+			m.commandErr("WATCH requires an address")
 			break // switch
 		}
 
@@ -427,9 +581,7 @@ func (m *debugger) parseCommand(cmd []string) bool {
 
 		if arg == "DROP" {
 			if len(cmd) < 3 {
-				fmt.Println(m.styles.err.Render(
-					"WATCH DROP requires an address",
-				))
+				m.commandErr("WATCH DROP requires an address")
 				break // switch
 			}
 
@@ -438,9 +590,7 @@ func (m *debugger) parseCommand(cmd []string) bool {
 			} else {
 				ma, err := m.parseAddress(cmd[2])
 				if err != nil {
-					fmt.Println(m.styles.err.Render(
-						fmt.Sprintf("watch: %s", err.Error()),
-					))
+					m.commandErr(fmt.Sprintf("watch: %s", err.Error()))
 					break // switch
 				}
 				if _, ok := m.watches[ma.address]; !ok {
@@ -468,24 +618,18 @@ func (m *debugger) parseCommand(cmd []string) bool {
 		for i := i; i < len(cmd); i++ {
 			ma, err := m.parseAddress(cmd[i])
 			if err != nil {
-				fmt.Println(m.styles.err.Render(
-					fmt.Sprintf("watch: %s", err.Error()),
-				))
+				m.commandErr(fmt.Sprintf("watch: %s", err.Error()))
 				break // switch
 			}
 
 			if _, ok := m.watches[ma.address]; ok {
-				fmt.Println(m.styles.err.Render(
-					fmt.Sprintf("watch for %04x already present", ma),
-				))
+				m.commandErr(fmt.Sprintf("watch for %04x already present", ma))
 				break // switch
 			}
 
 			d, err := memory.Read(ma.area, ma.idx)
 			if err != nil {
-				fmt.Println(m.styles.err.Render(
-					fmt.Sprintf("watch address is not readable: %04x", ma),
-				))
+				m.commandErr(fmt.Sprintf("watch address is not readable: %04x", ma))
 				break // switch
 			}
 
@@ -517,11 +661,16 @@ func (m *debugger) parseCommand(cmd []string) bool {
 	case "COPROC":
 		coproc := m.console.Mem.External.GetCoProcBus()
 		if coproc == nil {
-			fmt.Println(m.styles.err.Render(
-				"external device does not have a coprocessor",
-			))
+			m.commandErr("external device does not have a coprocessor")
+			break // switch
+		}
+
+		// BREAK takes any number of arguments
+		if len(cmd) > 1 && strings.ToUpper(cmd[1]) == "BREAK" {
+			m.coprocBreak(coproc, cmd[2:])
 			break // switch
 		}
+
 		switch len(cmd) {
 		case 1:
 			fmt.Println(m.styles.debugger.Render(
@@ -530,6 +679,19 @@ func (m *debugger) parseCommand(cmd []string) bool {
 		case 2:
 			c := strings.ToUpper(cmd[1])
 			switch c {
+			case "STEP":
+				// run until the coprocessor executes an instruction. the
+				// coprocessor will be suspended at that instruction
+				m.coprocDev.step = true
+				m.coprocBreakpointsEnable()
+				m.clearInterrupt()
+				quit := m.run()
+				m.coprocDev.step = false
+				m.coprocBreakpointsEnable()
+				return quit
+			case "CONTINUE":
+				// COPROC CONTINUE is handled by the coprocessor suspension
+				m.commandErr("coprocessor is not suspended")
 			case "DISASM":
 				coproc.GetCoProc().SetDisassembler(m.coprocDisasm)
 				m.coprocDisasm.enabled = true
@@ -547,6 +709,11 @@ func (m *debugger) parseCommand(cmd []string) bool {
 							fmt.Println(f)
 						}
 					}
+					if m.coprocDev.aborted {
+						fmt.Println(m.styles.coprocErr.Render(
+							"coprocessor has aborted. reset the cartridge to continue",
+						))
+					}
 				}
 			case "REGS", "REG":
 				if s, ok := coproc.GetCoProc().(fmt.Stringer); ok {
@@ -559,14 +726,10 @@ func (m *debugger) parseCommand(cmd []string) bool {
 					))
 				}
 			default:
-				fmt.Println(m.styles.err.Render(
-					fmt.Sprintf("unrecognised argument for COPROC command: %s", c),
-				))
+				m.commandErr(fmt.Sprintf("unrecognised argument for COPROC command: %s", c))
 			}
 		default:
-			fmt.Println(m.styles.err.Render(
-				"too many arguments to COPROC command",
-			))
+			m.commandErr("too many arguments to COPROC command")
 		}
 
 	case "LOG":
@@ -581,24 +744,305 @@ func (m *debugger) parseCommand(cmd []string) bool {
 			case "NOECHO":
 				logger.SetEcho(nil, false)
 			default:
-				fmt.Println(m.styles.err.Render(
-					fmt.Sprintf("unrecognised argument for LOG command: %s", c),
+				m.commandErr(fmt.Sprintf("unrecognised argument for LOG command: %s", c))
+			}
+		default:
+			m.commandErr("too many arguments to LOG command")
+		}
+
+	case "TRACE":
+		if len(cmd) < 2 {
+			if m.trace == nil {
+				fmt.Println(m.styles.debugger.Render("trace is not active"))
+			} else {
+				fmt.Println(m.styles.debugger.Render(
+					fmt.Sprintf("tracing to %s (%s): %d instructions", m.trace.filename, m.trace.filter, m.trace.count),
 				))
 			}
+			break // switch
+		}
+
+		switch strings.ToUpper(cmd[1]) {
+		case "START":
+			if len(cmd) < 3 {
+				m.commandErr("TRACE START requires a filename")
+				break // switch
+			}
+			err := m.traceStart(cmd[2], cmd[3:])
+			if err != nil {
+				m.commandErr(err.Error())
+				break // switch
+			}
+			fmt.Println(m.styles.debugger.Render(
+				fmt.Sprintf("tracing to %s (%s)", m.trace.filename, m.trace.filter),
+			))
+		case "STOP":
+			err := m.traceStop()
+			if err != nil {
+				m.commandErr(err.Error())
+			}
+		case "CONVERT":
+			if len(cmd) < 4 {
+				m.commandErr("TRACE CONVERT requires a trace file and a text file")
+				break // switch
+			}
+			err := traceConvert(cmd[2], cmd[3])
+			if err != nil {
+				m.commandErr(err.Error())
+				break // switch
+			}
+			fmt.Println(m.styles.debugger.Render(
+				fmt.Sprintf("trace %s converted to %s", cmd[2], cmd[3]),
+			))
+		case "DIFF":
+			if len(cmd) < 4 {
+				m.commandErr("TRACE DIFF requires a trace file and a reference file")
+				break // switch
+			}
+			context := 10
+			if len(cmd) > 4 {
+				var err error
+				context, err = strconv.Atoi(cmd[4])
+				if err != nil || context < 0 {
+					m.commandErr(fmt.Sprintf("trace diff: context is not valid: %s", cmd[4]))
+					break // switch
+				}
+			}
+			m.traceDiff(cmd[2], cmd[3], context)
 		default:
-			fmt.Println(m.styles.err.Render(
-				"too many arguments to LOG command",
+			m.commandErr(fmt.Sprintf("unrecognised argument for TRACE command: %s", cmd[1]))
+		}
+
+	case "SCREENSHOT":
+		if len(cmd) < 2 {
+			m.commandErr("SCREENSHOT requires a filename")
+			break // switch
+		}
+		scale, ok := m.captureScale("SCREENSHOT", cmd[2:])
+		if !ok {
+			break // switch
+		}
+		err := m.screenshot(cmd[1], scale)
+		if err != nil {
+			m.commandErr(fmt.Sprintf("SCREENSHOT: %s", err.Error()))
+			break // switch
+		}
+		fmt.Println(m.styles.debugger.Render(
+			fmt.Sprintf("screenshot written to %s", cmd[1]),
+		))
+
+	case "RECORD":
+		if len(cmd) < 2 {
+			if m.record == nil {
+				fmt.Println(m.styles.debugger.Render("recording is not active"))
+			} else {
+				fmt.Println(m.styles.debugger.Render(
+					fmt.Sprintf("recording to %s: %d frames", m.record.filename, m.record.video.Frames()),
+				))
+			}
+			break // switch
+		}
+
+		switch strings.ToUpper(cmd[1]) {
+		case "START":
+			if len(cmd) < 3 {
+				m.commandErr("RECORD START requires a filename")
+				break // switch
+			}
+			scale, ok := m.captureScale("RECORD START", cmd[3:])
+			if !ok {
+				break // switch
+			}
+			err := m.recordStart(cmd[2], scale)
+			if err != nil {
+				m.commandErr(err.Error())
+				break // switch
+			}
+			fmt.Println(m.styles.debugger.Render(
+				fmt.Sprintf("recording to %s", m.record.filename),
 			))
+		case "STOP":
+			err := m.recordStop()
+			if err != nil {
+				m.commandErr(err.Error())
+			}
+		default:
+			m.commandErr(fmt.Sprintf("unrecognised argument for RECORD command: %s", cmd[1]))
 		}
 
+	case "AUDIO":
+		if len(cmd) < 2 {
+			if m.audioRecord == nil {
+				fmt.Println(m.styles.debugger.Render("audio recording is not active"))
+			} else {
+				fmt.Println(m.styles.debugger.Render(
+					fmt.Sprintf("recording audio to %s: %d samples", m.audioRecord.filename, m.audioRecord.wav.Samples()),
+				))
+			}
+			break // switch
+		}
+
+		switch strings.ToUpper(cmd[1]) {
+		case "RECORD":
+			if len(cmd) < 3 {
+				m.commandErr("AUDIO RECORD requires a filename")
+				break // switch
+			}
+			var withStems bool
+			if len(cmd) > 3 {
+				if len(cmd) > 4 || strings.ToUpper(cmd[3]) != "STEMS" {
+					m.commandErr("AUDIO RECORD only accepts the STEMS option after the filename")
+					break // switch
+				}
+				withStems = true
+			}
+			err := m.audioRecordStart(cmd[2], withStems)
+			if err != nil {
+				m.commandErr(err.Error())
+				break // switch
+			}
+			fmt.Println(m.styles.debugger.Render(
+				fmt.Sprintf("recording audio to %s", cmd[2]),
+			))
+		case "STOP":
+			err := m.audioRecordStop()
+			if err != nil {
+				m.commandErr(err.Error())
+			}
+		default:
+			m.commandErr(fmt.Sprintf("unrecognised argument for AUDIO command: %s", cmd[1]))
+		}
+
+	case "SPEED":
+		if len(cmd) < 2 {
+			fmt.Println(m.styles.debugger.Render(m.speedString()))
+			break // switch
+		}
+
+		var speed float64
+		switch strings.ToUpper(cmd[1]) {
+		case "NORMAL":
+			speed = 1
+		case "TURBO", "MAX":
+			speed = 0
+		default:
+			var err error
+			speed, err = strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(cmd[1]), "x"), 64)
+			if err != nil {
+				m.commandErr(fmt.Sprintf("unrecognised argument for SPEED command: %s", cmd[1]))
+				break // switch
+			}
+		}
+
+		err := m.console.SetSpeed(speed)
+		if err != nil {
+			m.commandErr(err.Error())
+			break // switch
+		}
+		fmt.Println(m.styles.debugger.Render(m.speedString()))
+
+	case "SOURCE":
+		if len(cmd) < 2 {
+			m.commandErr("SOURCE requires a filename")
+			break // switch
+		}
+		return m.source(cmd[1])
+
+	case "SET":
+		if len(cmd) < 3 {
+			m.commandErr("SET requires a variable name and a value")
+			break // switch
+		}
+
+		if !validVariableName(cmd[1]) {
+			m.commandErr(fmt.Sprintf("set: %s cannot be used as a variable name", cmd[1]))
+			break // switch
+		}
+
+		v, err := m.evaluate(cmd[2:])
+		if err != nil {
+			m.commandErr(fmt.Sprintf("set: %s", err.Error()))
+			break // switch
+		}
+		m.variables[strings.ToLower(cmd[1])] = v
+
+	case "ASSERT":
+		ok, err := m.evaluateCondition(cmd[1:])
+		if err != nil {
+			m.commandErr(fmt.Sprintf("assert: %s", err.Error()))
+			break // switch
+		}
+		if !ok {
+			m.commandErr(fmt.Sprintf("assertion failed: %s", strings.Join(cmd[1:], " ")))
+			break // switch
+		}
+		fmt.Println(m.styles.debugger.Render(
+			fmt.Sprintf("assertion passed: %s", strings.Join(cmd[1:], " ")),
+		))
+
+	case "EXPECT":
+		if len(cmd) < 3 || strings.ToUpper(cmd[1]) != "SCREEN" {
+			m.commandErr("EXPECT requires SCREEN and a PNG filename")
+			break // switch
+		}
+		err := m.expectScreen(cmd[2])
+		if err != nil {
+			m.commandErr(fmt.Sprintf("expectation failed: %s", err.Error()))
+			break // switch
+		}
+		fmt.Println(m.styles.debugger.Render(
+			fmt.Sprintf("screen matches %s", cmd[2]),
+		))
+
 	case "QUIT":
 		return true
 
 	default:
-		fmt.Println(m.styles.err.Render(
-			fmt.Sprintf("unrecognised command: %s", strings.Join(cmd, " ")),
-		))
+		if isControlStatement(cmd[0]) {
+			m.commandErr(fmt.Sprintf("%s can only be used in a script", strings.ToUpper(cmd[0])))
+			break // switch
+		}
+		m.commandErr(fmt.Sprintf("unrecognised command: %s", strings.Join(cmd, " ")))
 	}
 
 	return false
 }
+
+// returns the state of the named hardware component as a string. the style to
+// use when printing the string to the terminal is also returned. the component
+// name is not case sensitive
+func (m *debugger) componentState(component string) (string, lipgloss.Style, bool) {
+	switch strings.ToUpper(component) {
+	case "CPU":
+		return m.console.MC.String(), m.styles.cpu, true
+	case "BIOS":
+		return m.console.Mem.BIOS.String(), m.styles.mem, true
+	case "MARIA":
+		return m.console.MARIA.String(), m.styles.mem, true
+	case "VIDEO":
+		return m.console.MARIA.Coords.String(), m.styles.video, true
+	case "INPTCTRL":
+		return m.console.Mem.INPTCTRL.String(), m.styles.mem, true
+	case "RAM7800":
+		return m.console.Mem.RAM7800.String(), m.styles.mem, true
+	case "RAMRIOT":
+		return m.console.Mem.RAMRIOT.String(), m.styles.mem, true
+	case "TIA":
+		return m.console.TIA.String(), m.styles.mem, true
+	case "RIOT":
+		return m.console.RIOT.String(), m.styles.mem, true
+	}
+	return "", m.styles.err, false
+}
+
+// description of the current emulation speed suitable for the SPEED command
+func (m *debugger) speedString() string {
+	speed, turbo := m.console.Speed()
+	if turbo || speed == 0 {
+		return "emulation is running as fast as possible"
+	}
+	if speed == 1 {
+		return "emulation is running at normal speed"
+	}
+	return fmt.Sprintf("emulation is running at %gx speed. audio is muted", speed)
+}
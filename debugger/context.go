@@ -1,8 +1,10 @@
 package debugger
 
 import (
+	"image/color"
 	"math/rand/v2"
 
+	"github.com/jetsetilly/test7800/hardware/arm"
 	"github.com/jetsetilly/test7800/hardware/spec"
 )
 
@@ -16,8 +18,19 @@ type context struct {
 	useOverlay    bool
 	audio         string
 	sampleRate    int
+	vsync         bool
 	overscan      string
 	savekey       bool
+
+	// palette to use instead of the palette of the specification. nil if the
+	// default palette should be used
+	palette *[256]color.RGBA
+
+	// preferences for the ARM coprocessor
+	armPrefs *arm.Preferences
+
+	// hardware model for ELF cartridges
+	elfModel string
 }
 
 func (ctx *context) AllowLogging() bool {
@@ -25,6 +38,14 @@ func (ctx *context) AllowLogging() bool {
 }
 
 func (ctx *context) Spec() spec.Spec {
+	s := ctx.spec()
+	if ctx.palette != nil {
+		s.Palette = *ctx.palette
+	}
+	return s
+}
+
+func (ctx *context) spec() spec.Spec {
 	if ctx.requestedSpec == "AUTO" {
 		switch ctx.loaderSpec {
 		case "NTSC":
@@ -48,6 +69,14 @@ func (ctx *context) IsAtari7800() bool {
 	return ctx.console == "7800"
 }
 
+func (ctx *context) ARMPreferences() *arm.Preferences {
+	return ctx.armPrefs
+}
+
+func (ctx *context) ELFModel() string {
+	return ctx.elfModel
+}
+
 func (ctx *context) Reset() {
 	ctx.allowLogging = false
 	ctx.breaks = ctx.breaks[:0]
@@ -82,6 +111,10 @@ func (ctx *context) UseStereo() bool {
 	return ctx.audio == "STEREO"
 }
 
+func (ctx *context) UseVSync() bool {
+	return ctx.vsync
+}
+
 // returns false is the sample rate hasn't be specified
 func (ctx *context) SampleRate() (int, bool) {
 	return ctx.sampleRate, ctx.sampleRate > 0
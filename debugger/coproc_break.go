@@ -0,0 +1,104 @@
+package debugger
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jetsetilly/test7800/coprocessor"
+)
+
+// implemented by cartridges that can look up the address of a symbol in the
+// coprocessor program
+type coprocSymbols interface {
+	Symbol(name string) (uint32, bool)
+}
+
+// parse a coprocessor address. the address can be a number or the name of a
+// symbol in the coprocessor program. the lowest bit of the address is cleared
+// because Thumb instructions are always at an even address
+func (m *debugger) parseCoprocAddress(coproc coprocessor.CartCoProcBus, address string) (uint32, error) {
+	if sym, ok := coproc.(coprocSymbols); ok {
+		if addr, ok := sym.Symbol(address); ok {
+			return addr &^ 1, nil
+		}
+	}
+
+	if strings.HasPrefix(address, "$") {
+		address = fmt.Sprintf("0x%s", address[1:])
+	}
+
+	addr, err := strconv.ParseUint(address, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("address is not valid or is not a symbol: %s", address)
+	}
+	return uint32(addr) &^ 1, nil
+}
+
+// the COPROC BREAK command. the arguments are those that follow BREAK
+func (m *debugger) coprocBreak(coproc coprocessor.CartCoProcBus, args []string) {
+	defer m.coprocBreakpointsEnable()
+
+	if len(args) == 0 {
+		fmt.Println(m.styles.debugger.Render("coproc breakpoints"))
+		if len(m.coprocDev.breakpoints) == 0 {
+			fmt.Println("none")
+		} else {
+			for _, a := range slices.Sorted(maps.Keys(m.coprocDev.breakpoints)) {
+				fmt.Printf("%08x\n", a)
+			}
+		}
+		return
+	}
+
+	if strings.ToUpper(args[0]) == "DROP" {
+		if len(args) < 2 {
+			m.commandErr("COPROC BREAK DROP requires an address")
+			return
+		}
+
+		if strings.ToUpper(args[1]) == "ALL" {
+			clear(m.coprocDev.breakpoints)
+			return
+		}
+
+		addr, err := m.parseCoprocAddress(coproc, args[1])
+		if err != nil {
+			m.commandErr(fmt.Sprintf("coproc breakpoint: %s", err.Error()))
+			return
+		}
+		if _, ok := m.coprocDev.breakpoints[addr]; !ok {
+			fmt.Println(m.styles.debugger.Render(
+				fmt.Sprintf("coproc breakpoint for %08x not present", addr),
+			))
+			return
+		}
+		delete(m.coprocDev.breakpoints, addr)
+		fmt.Println(m.styles.debugger.Render(
+			fmt.Sprintf("coproc breakpoint %08x has been removed", addr),
+		))
+		return
+	}
+
+	for _, a := range args {
+		addr, err := m.parseCoprocAddress(coproc, a)
+		if err != nil {
+			m.commandErr(fmt.Sprintf("coproc breakpoint: %s", err.Error()))
+			return
+		}
+
+		if _, ok := m.coprocDev.breakpoints[addr]; ok {
+			fmt.Println(m.styles.debugger.Render(
+				fmt.Sprintf("coproc breakpoint on %08x already present", addr),
+			))
+			continue // for loop
+		}
+
+		m.coprocDev.breakpoints[addr] = true
+		fmt.Println(m.styles.debugger.Render(
+			fmt.Sprintf("added coproc breakpoint for %08x", addr),
+		))
+	}
+}
@@ -3,32 +3,116 @@ package debugger
 import (
 	"github.com/jetsetilly/test7800/coprocessor"
 	"github.com/jetsetilly/test7800/coprocessor/faults"
+	"github.com/jetsetilly/test7800/prefs"
 )
 
+// implemented by cartridges that can report the highest address used by the
+// variables of the coprocessor program
+type coprocHighAddress interface {
+	HighAddress() uint32
+}
+
 type coprocDev struct {
 	faults faults.Faults
+
+	// the policy for each category of memory fault. categories without an
+	// entry use the default policy
+	policies map[faults.Category]*prefs.String
+
+	// the most recent fault that stopped the coprocessor
+	lastFault faults.Entry
+
+	// a fault with the abort policy has occurred. the coprocessor cannot
+	// continue until the cartridge is reset
+	aborted bool
+
+	// the highest address used by the coprocessor program
+	highAddress uint32
+
+	// breakpoints on coprocessor addresses
+	breakpoints map[uint32]bool
+
+	// break on the next instruction checked by the coprocessor
+	step bool
+
+	// the address of the most recent breakpoint. the breakpoint is ignored the
+	// first time it is checked after the coprocessor resumes, otherwise
+	// execution would never be able to move past it
+	resumeAddr uint32
+	resuming   bool
+
+	// the emulation is being run on behalf of a remote debugger. breakpoints
+	// halt the emulation rather than suspending the coprocessor
+	remote bool
 }
 
-func newCoprocDev() *coprocDev {
+func newCoprocDev(policies map[faults.Category]*prefs.String) *coprocDev {
 	return &coprocDev{
-		faults: faults.NewFaults(),
+		faults:      faults.NewFaults(),
+		policies:    policies,
+		breakpoints: make(map[uint32]bool),
+	}
+}
+
+// prepare for a newly inserted cartridge. the fault log is cleared
+func (dev *coprocDev) reset(highAddress uint32) {
+	dev.faults = faults.NewFaults()
+	dev.lastFault = faults.Entry{}
+	dev.aborted = false
+	dev.highAddress = highAddress
+}
+
+// returns the policy for the fault category
+func (dev *coprocDev) policy(category faults.Category) faults.Policy {
+	if p, ok := dev.policies[category]; ok {
+		return faults.Policy(p.Get())
 	}
+	return faults.DefaultPolicy(category)
 }
 
-// a memory fault has occured
-func (dev *coprocDev) MemoryFault(event string, explanation faults.Category, instructionAddr uint32, accessAddr uint32) {
+// whether the coprocessor needs to check for breakpoints
+func (dev *coprocDev) breakpointsRequired() bool {
+	return dev.step || len(dev.breakpoints) > 0
+}
+
+// a memory fault has occured. returns the policy that the coprocessor should
+// apply to the fault
+func (dev *coprocDev) MemoryFault(event string, explanation faults.Category, instructionAddr uint32, accessAddr uint32) faults.Policy {
+	policy := dev.policy(explanation)
+	if policy == faults.Ignore {
+		return policy
+	}
+
 	dev.faults.NewEntry(event, explanation, instructionAddr, accessAddr)
+
+	if policy.Stops() {
+		dev.lastFault = faults.Entry{
+			Category:        explanation,
+			Event:           event,
+			InstructionAddr: instructionAddr,
+			AccessAddr:      accessAddr,
+		}
+		dev.aborted = dev.aborted || policy == faults.Abort
+	}
+
+	return policy
 }
 
 // returns the highest address used by the program. the coprocessor uses
 // this value to detect stack collisions
 func (dev *coprocDev) HighAddress() uint32 {
-	return 0
+	return dev.highAddress
 }
 
 // checks if address has a breakpoint assigned to it
 func (dev *coprocDev) CheckBreakpoint(addr uint32) bool {
-	return false
+	if dev.resuming {
+		dev.resuming = false
+		if addr == dev.resumeAddr {
+			return false
+		}
+	}
+	return dev.step || dev.breakpoints[addr]
 }
 
 // returns a map that can be used to count cycles for each PC address
@@ -49,4 +133,9 @@ func (dev *coprocDev) ProcessProfiling() {
 // called whenever the ARM yields to the VCS. it communicates the address of
 // the most recent instruction and the reason for the yield
 func (dev *coprocDev) OnYield(addr uint32, reason coprocessor.CoProcYield) {
+	if reason.Type == coprocessor.YieldBreakpoint {
+		dev.resumeAddr = addr
+		dev.resuming = true
+		dev.step = false
+	}
 }
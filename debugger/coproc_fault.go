@@ -0,0 +1,84 @@
+package debugger
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+
+	"github.com/jetsetilly/test7800/coprocessor"
+	"github.com/jetsetilly/test7800/hardware/arm"
+)
+
+// the number of bytes before and after an instruction to disassemble when
+// showing the context of the instruction
+const (
+	coprocContextBefore = 8
+	coprocContextAfter  = 8
+)
+
+// print the ARM PC and the instructions around the most recent memory fault.
+// if coprocessor disassembly is enabled then the executed instructions will
+// already have been printed and only the PC is output
+func (m *debugger) coprocFaultContext() {
+	coproc := m.console.Mem.External.GetCoProcBus()
+	if coproc == nil {
+		return
+	}
+
+	if pc, ok := coproc.GetCoProc().Register(15); ok {
+		fmt.Println(m.styles.coprocCPU.Render(
+			fmt.Sprintf("ARM PC: %08x", pc),
+		))
+	}
+
+	if m.coprocDisasm.enabled {
+		return
+	}
+
+	m.coprocContext(coproc.GetCoProc(), m.coprocDev.lastFault.InstructionAddr)
+}
+
+// print a static disassembly of the instructions around the address. the
+// instruction at the address is marked
+func (m *debugger) coprocContext(coproc coprocessor.CartCoProc, addr uint32) {
+	disasm := func(origin uint32) []arm.DisasmEntry {
+		data := make([]byte, 0, coprocContextBefore+coprocContextAfter)
+		for a := origin; a < addr+coprocContextAfter; a++ {
+			d, ok := coproc.PeekByte(a)
+			if !ok {
+				break // for loop
+			}
+			data = append(data, d)
+		}
+
+		var entries []arm.DisasmEntry
+		_ = arm.StaticDisassemble(arm.StaticDisassembleConfig{
+			Data:      data[:len(data)&^1],
+			Origin:    origin,
+			ByteOrder: binary.LittleEndian,
+			Callback: func(e arm.DisasmEntry) {
+				entries = append(entries, e)
+			},
+		})
+		return entries
+	}
+
+	// disassembling from before the faulting instruction may start in the
+	// middle of a 32bit instruction. if that happens the faulting instruction
+	// will not be in the disassembly and we start from the faulting
+	// instruction instead
+	entries := disasm(addr - coprocContextBefore)
+	if !slices.ContainsFunc(entries, func(e arm.DisasmEntry) bool { return e.Addr == addr }) {
+		entries = disasm(addr)
+	}
+
+	for _, e := range entries {
+		marker := " "
+		if e.Addr == addr {
+			marker = ">"
+		}
+		fmt.Println(m.styles.coprocAsm.Render(
+			fmt.Sprintf("%s %s %s", marker, e.Address, e.String()),
+		))
+	}
+}
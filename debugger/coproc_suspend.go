@@ -0,0 +1,115 @@
+package debugger
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jetsetilly/test7800/coprocessor"
+	"github.com/jetsetilly/test7800/gui"
+)
+
+// suspend the coprocessor at a breakpoint. the function is called from inside
+// the cartridge, while the cartridge is servicing a bus access, so the CPU is
+// paused in the middle of an instruction and the ARM is paused at the
+// breakpoint. because of this the state of the cartridge, including any bytes
+// that have been queued for the CPU, is unaffected by the suspension
+//
+// commands are read and processed until the coprocessor is resumed with COPROC
+// STEP or COPROC CONTINUE. returns true if the coprocessor should continue or
+// false if the run should end
+func (m *debugger) coprocSuspend(yld coprocessor.CoProcYield) bool {
+	coproc := m.console.Mem.External.GetCoProcBus()
+	if coproc == nil {
+		return false
+	}
+
+	m.coprocSuspended = true
+	defer func() {
+		m.coprocSuspended = false
+	}()
+
+	m.setState(gui.StatePaused)
+	defer m.setState(gui.StateRunning)
+
+	fmt.Print("\r")
+	fmt.Println(m.styles.breakpoint.Render(
+		fmt.Sprintf("coproc breakpoint: %v", yld.Error),
+	))
+	m.coprocContext(coproc.GetCoProc(), m.coprocDev.resumeAddr)
+
+	for {
+		fmt.Printf("%s coproc> ", m.console.MARIA.Coords.ShortString())
+
+		select {
+		case <-m.sig:
+			fmt.Print("\r")
+			m.quitPending = true
+			return false
+		case <-m.endDebugger:
+			fmt.Print("\n")
+			m.quitPending = true
+			return false
+
+		case <-m.g.Blob:
+			fmt.Print("\r")
+			fmt.Println(m.styles.err.Render(
+				"cannot load a cartridge while the coprocessor is suspended",
+			))
+
+		case inp := <-m.g.UserInput:
+			if inp.Action != gui.Inspect && inp.Action != gui.FrameAdvance {
+				m.console.HandleInput(inp)
+			}
+
+		case input := <-m.commands:
+			if input.err != nil {
+				fmt.Println(m.styles.err.Render(input.err.Error()))
+				m.quitPending = true
+				return false
+			}
+
+			// an empty command steps the coprocessor
+			cmd := strings.Fields(input.s)
+			if len(cmd) == 0 {
+				cmd = []string{"COPROC", "STEP"}
+			}
+
+			if len(cmd) == 2 && strings.ToUpper(cmd[0]) == "COPROC" {
+				switch strings.ToUpper(cmd[1]) {
+				case "STEP":
+					m.coprocDev.step = true
+					m.coprocBreakpointsEnable()
+					return true
+				case "CONTINUE":
+					return true
+				}
+			}
+
+			if m.parseCommand(cmd) {
+				m.quitPending = true
+				return false
+			}
+
+		case req := <-m.requests:
+			// requests that resume the emulation cannot be serviced until the
+			// coprocessor has been resumed. the run ends as soon as possible
+			// and the request is serviced after that
+			if req.resume {
+				m.pendingRequest = &req
+				return true
+			}
+			if req.run() {
+				m.quitPending = true
+				return false
+			}
+		}
+	}
+}
+
+// prints an error and returns true if the coprocessor is suspended
+func (m *debugger) coprocSuspendedCheck() bool {
+	if m.coprocSuspended {
+		m.commandErr("coprocessor is suspended. use COPROC STEP or COPROC CONTINUE")
+	}
+	return m.coprocSuspended
+}
@@ -0,0 +1,162 @@
+package debugger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jetsetilly/test7800/gui"
+	"github.com/jetsetilly/test7800/gui/bindings"
+	"github.com/jetsetilly/test7800/hardware"
+	"github.com/jetsetilly/test7800/hardware/arm"
+	"github.com/jetsetilly/test7800/hardware/cpu/execution"
+	"github.com/jetsetilly/test7800/hardware/memory/external"
+	"github.com/jetsetilly/test7800/prefs"
+	"github.com/jetsetilly/test7800/test"
+)
+
+// the ELF program used by the tests in the elf package
+var testELF = filepath.Join("..", "hardware", "memory", "external", "elf", "test_data", "7800backgroundcolors.bin")
+
+// the number of console steps that the emulation runs for in the coproc test.
+// more than enough for the ARM program to start
+const testCoprocSteps = 10000
+
+// how long to wait for the emulation before failing the test
+const testCoprocTimeout = 5 * time.Second
+
+// returns a debugger with the test ELF program inserted and reset. the
+// debugger is not headless so that suspension of the coprocessor can be
+// observed by the state sent to the GUI
+func testCoprocDebugger(t *testing.T) (*debugger, *gui.Channels, chan input) {
+	t.Helper()
+
+	d, err := os.ReadFile(testELF)
+	test.DemandSuccess(t, err)
+	loader, err := external.FingerprintBlob("test.elf", d, "AUTO")
+	test.DemandSuccess(t, err)
+
+	chans := gui.NewChannels()
+	commands := make(chan input)
+	armPrefs := arm.NewPreferences()
+
+	m := &debugger{
+		ctx: context{
+			console:       "7800",
+			requestedSpec: "NTSC",
+			armPrefs:      armPrefs,
+		},
+		g:            chans.Debugger(),
+		sig:          make(chan os.Signal, 1),
+		commands:     commands,
+		loader:       loader,
+		styles:       newStyles(),
+		breakpoints:  make(map[uint16]bool),
+		watches:      make(map[uint16]watch),
+		disasm:       make([]*execution.Result, 0x10000),
+		coprocDisasm: &coprocDisasm{},
+		coprocDev:    newCoprocDev(nil),
+		biosHelper: biosHelper{
+			bypass: true,
+		},
+		hscAuto:     true,
+		savekeyAuto: true,
+		requests:    make(chan request),
+		interrupt:   make(chan bool, 1),
+		variables:   make(map[string]int),
+		bindings:    bindings.NewConfig(),
+		prefs: &preferences{
+			arm:        armPrefs,
+			controller: prefs.NewString("AUTO", controllerOptions...),
+		},
+	}
+	m.ctx.Reset()
+	m.console = hardware.Create(&m.ctx, m.g)
+	m.reset()
+
+	return m, chans, commands
+}
+
+func TestCoprocBreakpoint(t *testing.T) {
+	m, chans, commands := testCoprocDebugger(t)
+
+	// the breakpoint is on the entry function of the ARM program
+	coproc := m.console.Mem.External.GetCoProcBus()
+	test.DemandSuccess(t, coproc != nil)
+	m.parseCommand([]string{"COPROC", "BREAK", "elf_main"})
+	main, err := m.parseCoprocAddress(coproc, "elf_main")
+	test.DemandSuccess(t, err)
+	test.DemandSuccess(t, m.coprocDev.breakpoints[main])
+
+	// the console runs in a separate goroutine so that the test can send
+	// commands to the suspended coprocessor
+	end := make(chan bool)
+	m.endDebugger = end
+	done := make(chan error, 1)
+	go func() {
+		for range testCoprocSteps {
+			err := m.console.Step()
+			if err != nil || m.quitPending {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	defer close(end)
+
+	// wait for the next state sent to the GUI. returns false if the emulation
+	// ends before the state is sent
+	state := func() (gui.State, bool) {
+		t.Helper()
+		select {
+		case s := <-chans.State:
+			return s, true
+		case err := <-done:
+			test.ExpectSuccess(t, err)
+			return 0, false
+		case <-time.After(testCoprocTimeout):
+			t.Fatalf("emulation did not finish")
+		}
+		return 0, false
+	}
+
+	// the coprocessor is suspended at the breakpoint
+	s, ok := state()
+	test.DemandSuccess(t, ok)
+	test.ExpectEquality(t, s, gui.StatePaused)
+	test.ExpectEquality(t, m.coprocDev.resumeAddr, main)
+
+	// commands other than STEP and CONTINUE are processed without resuming the
+	// coprocessor. resetting the console is not allowed
+	commands <- input{s: "RESET"}
+
+	// STEP advances the coprocessor by one instruction. the first instruction
+	// of the program is a 16bit PUSH
+	commands <- input{s: "COPROC STEP"}
+	s, ok = state()
+	test.DemandSuccess(t, ok)
+	test.ExpectEquality(t, s, gui.StateRunning)
+	s, ok = state()
+	test.DemandSuccess(t, ok)
+	test.ExpectEquality(t, s, gui.StatePaused)
+	test.ExpectEquality(t, m.coprocDev.resumeAddr, main+2)
+
+	// BREAK DROP removes the breakpoint
+	commands <- input{s: "COPROC BREAK DROP elf_main"}
+
+	// CONTINUE resumes the coprocessor. without the breakpoint the coprocessor
+	// is not suspended again and the emulation runs to the end
+	commands <- input{s: "COPROC CONTINUE"}
+	s, ok = state()
+	test.DemandSuccess(t, ok)
+	test.ExpectEquality(t, s, gui.StateRunning)
+	test.ExpectEquality(t, len(m.coprocDev.breakpoints), 0)
+	test.ExpectFailure(t, m.coprocDev.step)
+
+	_, ok = state()
+	test.ExpectFailure(t, ok)
+	test.ExpectFailure(t, m.quitPending)
+	test.ExpectFailure(t, m.coprocSuspended)
+}
@@ -8,5 +8,27 @@ func (m *debugger) CartYield(yld coprocessor.CoProcYield) coprocessor.YieldHookR
 	if yld.Type.Normal() {
 		return coprocessor.YieldHookContinue
 	}
+
+	// breakpoints suspend the coprocessor immediately unless the breakpoint
+	// has been reached on behalf of a remote debugger or a script. in those
+	// cases the emulation halts at the end of the CPU instruction
+	if yld.Type == coprocessor.YieldBreakpoint && !m.coprocDev.remote && m.scriptDepth == 0 {
+		if m.coprocSuspend(yld) {
+			return coprocessor.YieldHookContinue
+		}
+	}
+
+	// note yield so that it can be handled once the CPU instruction has completed
+	m.coprocYield = yld
+
 	return coprocessor.YieldHookEnd
 }
+
+// enable or disable breakpoint checking in the coprocessor depending on whether
+// there are any breakpoints to check
+func (m *debugger) coprocBreakpointsEnable() {
+	coproc := m.console.Mem.External.GetCoProcBus()
+	if coproc != nil {
+		coproc.GetCoProc().BreakpointsEnable(m.coprocDev.breakpointsRequired())
+	}
+}
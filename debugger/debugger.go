@@ -17,13 +17,19 @@ import (
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/jetsetilly/test7800/coprocessor"
+	"github.com/jetsetilly/test7800/debugger/api"
+	"github.com/jetsetilly/test7800/debugger/gdbserver"
 	"github.com/jetsetilly/test7800/disassembly"
 	"github.com/jetsetilly/test7800/gui"
+	"github.com/jetsetilly/test7800/gui/bindings"
+	"github.com/jetsetilly/test7800/gui/crt"
 	"github.com/jetsetilly/test7800/hardware"
 	"github.com/jetsetilly/test7800/hardware/arm"
 	"github.com/jetsetilly/test7800/hardware/cpu/execution"
 	"github.com/jetsetilly/test7800/hardware/maria"
 	"github.com/jetsetilly/test7800/hardware/memory/external"
+	"github.com/jetsetilly/test7800/hardware/memory/external/elf"
 	"github.com/jetsetilly/test7800/logger"
 	"github.com/jetsetilly/test7800/resources"
 )
@@ -50,6 +56,7 @@ type debugger struct {
 	breakpoints    map[uint16]bool
 	watches        map[uint16]watch
 	breakspointCtx bool
+	breakpointDMA  bool
 
 	// last execution entries for each address. this will be initialised to 64k.
 	disasm []*execution.Result
@@ -61,16 +68,66 @@ type debugger struct {
 	coprocDisasm *coprocDisasm
 	coprocDev    *coprocDev
 
+	// the most recent coprocessor yield that wasn't a normal yield
+	coprocYield coprocessor.CoProcYield
+
+	// the coprocessor is suspended at a breakpoint. the emulation is paused in
+	// the middle of a CPU instruction and cannot be run or reset until the
+	// coprocessor is resumed
+	coprocSuspended bool
+
+	// the debugger should quit when the current run ends. used when a quit is
+	// requested while the coprocessor is suspended
+	quitPending bool
+
 	// rule for stepping. by default (the field is nil) the step will move
 	// forward one instruction
 	stepRule func() bool
 
+	// whether the most recent run ended because of the step rule
+	stepRuleMet bool
+
 	// the file to load on console reset. can be a bootfile or cartridge
 	loader external.CartridgeInsertor
 
 	// script of commands
 	script []string
 
+	// variables set by the SET command
+	variables map[string]int
+
+	// the number of failed assertions and expectations. also includes errors
+	// in scripts and commands in a script that could not be completed
+	failures int
+
+	// the depth of nested SOURCE commands
+	scriptDepth int
+
+	// the emulation is running without a GUI
+	headless bool
+
+	// dialogs can be presented to the user. false in headless mode or if the
+	// dialog option is false
+	useDialog bool
+
+	// execution trace. nil if no trace is active
+	trace *tracer
+
+	// video and audio recording. nil if no recording is active
+	record *recorder
+
+	// audio only recording. nil if no recording is active
+	audioRecord *audioRecorder
+
+	// post-processing applied to the emulation image by the GUI
+	crt crt.Preset
+
+	// bindings of keys and gamepad buttons to the controls of the console
+	bindings *bindings.Config
+
+	// preferences that can be changed with the PREFS command
+	prefs *preferences
+
 	// printing styles
 	styles styles
 
@@ -84,9 +141,26 @@ type debugger struct {
 	// insert savekey into right port
 	savekeyAuto  bool
 	savekeyForce bool
+
+	// requests from remote connections (GDB, API, etc.) to be serviced by the
+	// debugger's goroutine
+	requests chan request
+
+	// a request received while the emulation was running that could not be
+	// serviced immediately. the emulation is halted and the request is
+	// serviced before the next command is accepted from the terminal
+	pendingRequest *request
+
+	// interrupt the running emulation on behalf of a remote connection
+	interrupt   chan bool
+	interrupted bool
 }
 
 func (m *debugger) reset() {
+	if m.coprocSuspendedCheck() {
+		return
+	}
+
 	m.ctx.Reset()
 	m.ctx.allowLogging = true
 
@@ -106,7 +180,13 @@ func (m *debugger) reset() {
 	clear(m.disasm)
 	m.recent = m.recent[:0]
 
-	err := m.console.Insert(m.loader)
+	// the controller preference replaces the controller specified by the cartridge
+	loader := m.loader
+	if c := m.prefs.controller.Get(); c != "AUTO" {
+		loader.Controller = strings.ToLower(c)
+	}
+
+	err := m.console.Insert(loader)
 	if err != nil {
 		fmt.Println(m.styles.err.Render(err.Error()))
 	} else {
@@ -121,12 +201,19 @@ func (m *debugger) reset() {
 	// try and (re)attach coproc developer/disassembly to external device
 	coproc := m.console.Mem.External.GetCoProcBus()
 	if coproc != nil {
+		var highAddress uint32
+		if h, ok := coproc.(coprocHighAddress); ok {
+			highAddress = h.HighAddress()
+		}
+		m.coprocDev.reset(highAddress)
 		coproc.GetCoProc().SetDeveloper(m.coprocDev)
 		if m.coprocDisasm.enabled {
 			coproc.GetCoProc().SetDisassembler(m.coprocDisasm)
 		}
 		coproc.SetYieldHook(m)
+		m.coprocBreakpointsEnable()
 	}
+	m.coprocYield = coprocessor.CoProcYield{}
 
 	var noBIOS bool
 
@@ -159,6 +246,9 @@ func (m *debugger) reset() {
 		m.console.MC.String(),
 	))
 
+	// the input bindings may be different for the new ROM
+	m.setBindings()
+
 	// run preview to gather information about the ROM that can't be determined statically. we don't
 	// always need to do this. at the moment, we only need to do it overscan is AUTO
 	if m.ctx.overscan == "AUTO" {
@@ -181,16 +271,21 @@ func (m *debugger) contextBreaks() error {
 		return nil
 	}
 
-	if !m.breakspointCtx {
+	if !m.breakspointCtx && !m.breakpointDMA {
 		m.ctx.breaks = m.ctx.breaks[:0]
 		return nil
 	}
 
-	// filter errors to only deal with the ones we're interested in
+	// filter errors to only deal with the ones we're interested in. DMA overruns are
+	// controlled separately from other context breaks
 	// TODO: configurable filters
 	var f []error
 	for _, e := range m.ctx.breaks {
-		if !errors.Is(e, maria.ContextError) {
+		if errors.Is(e, maria.DMAOverrun) {
+			if m.breakpointDMA {
+				f = append(f, e)
+			}
+		} else if m.breakspointCtx && !errors.Is(e, maria.ContextError) {
 			f = append(f, e)
 		}
 	}
@@ -236,6 +331,9 @@ var (
 )
 
 func (m *debugger) run() bool {
+	if m.coprocSuspendedCheck() {
+		return false
+	}
 	for {
 		err := m.runLoop()
 		if errors.Is(err, runStop) {
@@ -271,10 +369,14 @@ func (m *debugger) runLoop() error {
 		quitErr       = errors.New("quit")
 	)
 
+	// the coprocessor stopped because of a memory fault
+	var coprocFault bool
+
 	// always cancel stepping rule
 	defer func() {
 		m.stepRule = nil
 	}()
+	m.stepRuleMet = false
 
 	// hook is called after every CPU instruction
 	hook := func() error {
@@ -285,6 +387,17 @@ func (m *debugger) runLoop() error {
 			return quitErr
 		case d := <-m.g.Blob:
 			m.loadBlob(d)
+		case <-m.interrupt:
+			m.interrupted = true
+			return endRunErr
+		case req := <-m.requests:
+			if !req.inline {
+				m.pendingRequest = &req
+				return endRunErr
+			}
+			if req.run() {
+				return quitErr
+			}
 		default:
 		}
 
@@ -305,16 +418,52 @@ func (m *debugger) runLoop() error {
 
 		instructionCt++
 
+		if m.trace != nil {
+			err := m.traceInstruction()
+			if err != nil {
+				return err
+			}
+		}
+
+		if m.record != nil {
+			err := m.recordFrame()
+			if err != nil {
+				return err
+			}
+		}
+
 		if m.console.MC.Killed {
 			return fmt.Errorf("CPU in KIL state")
 		}
 
-		if m.coprocDev != nil {
-			if len(m.coprocDev.faults.Log) > 0 {
-				return fmt.Errorf("%w%s", coprocErr, m.coprocDev.faults.Log[len(m.coprocDev.faults.Log)-1].String())
+		if m.quitPending {
+			return quitErr
+		}
+
+		// a request made while the coprocessor was suspended
+		if m.pendingRequest != nil {
+			return endRunErr
+		}
+
+		if m.coprocYield.Type != "" {
+			yld := m.coprocYield
+			m.coprocYield = coprocessor.CoProcYield{}
+			switch yld.Type {
+			case coprocessor.YieldBreakpoint:
+				return fmt.Errorf("%w: coproc %v", breakpointErr, yld.Error)
+			case coprocessor.YieldSyncOverrun:
+				return fmt.Errorf("%w%s: %v", coprocErr, yld.Type, yld.Error)
+			case coprocessor.YieldMemoryFault:
+				coprocFault = true
+				return fmt.Errorf("%w%s: %v", coprocErr, yld.Type, yld.Error)
 			}
 		}
 
+		// the emulation cannot continue once the coprocessor has aborted
+		if m.coprocDev != nil && m.coprocDev.aborted {
+			return fmt.Errorf("%wcoprocessor has aborted. reset the cartridge to continue", coprocErr)
+		}
+
 		err := m.contextBreaks()
 		if err != nil {
 			return fmt.Errorf("%w%w", contextErr, err)
@@ -339,6 +488,7 @@ func (m *debugger) runLoop() error {
 
 		// apply step rule and end the run if instructed
 		if m.stepRule != nil && m.stepRule() {
+			m.stepRuleMet = true
 			return endRunErr
 		}
 
@@ -357,9 +507,9 @@ func (m *debugger) runLoop() error {
 
 	startTime = time.Now()
 
-	m.g.State <- gui.StateRunning
+	m.setState(gui.StateRunning)
 	err := m.console.Run(hook)
-	m.g.State <- gui.StatePaused
+	m.setState(gui.StatePaused)
 
 	if errors.Is(err, quitErr) {
 		return runQuit
@@ -431,6 +581,9 @@ func (m *debugger) runLoop() error {
 	} else if errors.Is(err, coprocErr) {
 		s := strings.TrimPrefix(err.Error(), coprocErr.Error())
 		fmt.Println(m.styles.coprocErr.Render(s))
+		if coprocFault {
+			m.coprocFaultContext()
+		}
 	} else if errors.Is(err, breakpointErr) {
 		fmt.Println(m.styles.breakpoint.Render(err.Error()))
 	} else if errors.Is(err, watchErr) {
@@ -445,8 +598,26 @@ func (m *debugger) runLoop() error {
 	return runStop
 }
 
+// notify the GUI of the emulation state. the GUI is never notified in headless
+// mode, meaning that the GUI window is never opened
+func (m *debugger) setState(state gui.State) {
+	if m.headless {
+		return
+	}
+	m.g.State <- state
+}
+
+// notify the GUI of the CRT effects to use
+func (m *debugger) setCRT(preset crt.Preset) {
+	m.crt = preset
+	select {
+	case m.g.DisplaySetup <- gui.DisplaySetup{CRT: preset}:
+	default:
+	}
+}
+
 func (m *debugger) loadBlob(blob gui.Blob) {
-	loader, err := external.FingerprintBlob(blob.Filename, blob.Data, "AUTO")
+	loader, err := fingerprintBlob(m.g, m.useDialog, blob.Filename, blob.Data, "AUTO")
 	if err != nil {
 		return
 	}
@@ -455,8 +626,23 @@ func (m *debugger) loadBlob(blob gui.Blob) {
 }
 
 func (m *debugger) loop() {
+	prompt := true
+
 	for {
-		fmt.Printf("%s> ", m.console.MARIA.Coords.ShortString())
+		// service any remote request that caused the emulation to halt
+		if m.pendingRequest != nil {
+			req := m.pendingRequest
+			m.pendingRequest = nil
+			if req.run() {
+				return
+			}
+			prompt = true
+		}
+
+		if prompt {
+			fmt.Printf("%s> ", m.console.MARIA.Coords.ShortString())
+		}
+		prompt = true
 
 		select {
 		case <-m.sig:
@@ -469,6 +655,24 @@ func (m *debugger) loop() {
 		case d := <-m.g.Blob:
 			m.loadBlob(d)
 
+		case inp := <-m.g.UserInput:
+			// selecting a point in the image while paused shows which display list entries
+			// contributed to it. frame advance is the same as the STEP FRAME command. all
+			// other input is forwarded to the console as normal
+			if inp.Action == gui.Inspect {
+				d := inp.Data.(gui.InspectData)
+				fmt.Print("\r")
+				m.frameDLAt(d.X, d.Y)
+			} else if inp.Action == gui.FrameAdvance {
+				fmt.Print("\r")
+				if m.parseCommand([]string{"STEP", "FRAME"}) {
+					return
+				}
+			} else {
+				m.console.HandleInput(inp)
+				prompt = false
+			}
+
 		case input := <-m.commands:
 			if input.err != nil {
 				fmt.Println(m.styles.err.Render(input.err.Error()))
@@ -483,6 +687,15 @@ func (m *debugger) loop() {
 			if m.parseCommand(cmd) {
 				return
 			}
+
+		case req := <-m.requests:
+			if req.run() {
+				return
+			}
+
+			// only reprint the prompt if the request is likely to have
+			// produced output
+			prompt = req.resume
 		}
 	}
 }
@@ -490,6 +703,11 @@ func (m *debugger) loop() {
 const programName = "test7800"
 
 func Launch(endDebugger <-chan bool, g *gui.ChannelsDebugger, args []string) error {
+	// the a78 tool edits cartridge files and does not start the emulation
+	if len(args) > 0 && args[0] == "a78" {
+		return a78Tool(args[1:])
+	}
+
 	var (
 		filename   string
 		spec       string
@@ -504,16 +722,25 @@ func Launch(endDebugger <-chan bool, g *gui.ChannelsDebugger, args []string) err
 		audio      string
 		samplerate int
 		mapper     string
+		elfModel   string
 		overscan   string
 		useDialog  bool
+		gdb        string
+		gdbarm     string
+		apiAddr    string
+		script     string
+		headless   bool
+		palette    string
+		crtPreset  string
+		wav        string
+		stems      bool
+		vsync      bool
 	)
 
 	specOptions := []string{"AUTO", "NTSC", "PAL"}
 	profileOptions := []string{"NONE", "CPU", "MEM", "BOTH"}
 	hscOptions := []string{"AUTO", "ALWAYS", "NEVER"}
 	savekeyOptions := []string{"AUTO", "ALWAYS", "NEVER"}
-	audioOptions := []string{"MONO", "STEREO", "NONE"}
-	overscanOptions := []string{"AUTO", "NONE", "MODERN", "FULL"}
 
 	// creates a printable string from an options list. separated by commas and the last entry with "list"
 	// eg. "AUTO, NTSC list PAL"
@@ -529,6 +756,16 @@ func Launch(endDebugger <-chan bool, g *gui.ChannelsDebugger, args []string) err
 		return s
 	}
 
+	// preferences provide the default values for some command line arguments. a problem
+	// with the saved preferences is not fatal
+	prf, err := newPreferences()
+	if err != nil {
+		if prf == nil {
+			return err
+		}
+		logger.Log(logger.Allow, "prefs", err)
+	}
+
 	flgs := flag.NewFlagSet(programName, flag.ExitOnError)
 	flgs.StringVar(&spec, "spec", "AUTO", fmt.Sprintf("TV specification of the console: %s", list(specOptions)))
 	flgs.StringVar(&spec, "tv", "AUTO", "alternative name for 'spec' argument")
@@ -540,12 +777,23 @@ func Launch(endDebugger <-chan bool, g *gui.ChannelsDebugger, args []string) err
 	flgs.BoolVar(&overlay, "overlay", false, "add debugging overlay to display")
 	flgs.BoolVar(&run, "run", false, "start ROM in running state")
 	flgs.BoolVar(&log, "log", false, "echo log to stderr")
-	flgs.StringVar(&audio, "audio", "MONO", fmt.Sprintf("enable audio: %s", list(audioOptions)))
+	flgs.StringVar(&audio, "audio", prf.audio.Get(), fmt.Sprintf("enable audio: %s", list(audioOptions)))
 	flgs.IntVar(&samplerate, "samplerate", 48000, "sample rate of audio")
+	flgs.BoolVar(&vsync, "vsync", false, "pace the emulation with the refresh of the display. the display must be close to 50Hz or 60Hz as appropriate")
 	flgs.StringVar(&mapper, "mapper", "AUTO", "mapper selection. automatic selection by default")
-	flgs.StringVar(&overscan, "overscan", "AUTO", fmt.Sprintf("television overscan: %s", list(overscanOptions)))
-	flgs.BoolVar(&useDialog, "dialog", true, "present user with file dialogue on startup if no file is specified")
-	err := flgs.Parse(args)
+	flgs.StringVar(&elfModel, "elfmodel", "AUTO", fmt.Sprintf("hardware model for ELF cartridges: %s", list(elf.ModelOptions)))
+	flgs.StringVar(&overscan, "overscan", prf.overscan.Get(), fmt.Sprintf("television overscan: %s", list(overscanOptions)))
+	flgs.BoolVar(&useDialog, "dialog", true, "present user with dialogs. a file dialog is opened on startup if no file is specified")
+	flgs.StringVar(&gdb, "gdb", "", "listen for GDB connections to the 6502 on the address. eg. localhost:2345")
+	flgs.StringVar(&gdbarm, "gdbarm", "", "listen for GDB connections to the ARM coprocessor on the address. eg. localhost:2346")
+	flgs.StringVar(&apiAddr, "api", "", "listen for JSON-RPC requests on the address. eg. localhost:7800")
+	flgs.StringVar(&script, "script", "", "run the commands in the script file and then exit")
+	flgs.BoolVar(&headless, "headless", false, "run without opening the emulation window. audio is disabled")
+	flgs.StringVar(&crtPreset, "crt", "NONE", fmt.Sprintf("CRT effects: %s. effects can be adjusted. eg. TV:curvature=0.1", list(crt.Presets())))
+	flgs.StringVar(&wav, "wav", "", "record the audio to a WAV file")
+	flgs.BoolVar(&stems, "stems", false, "record each audio channel to a separate WAV file. requires the -wav argument")
+	flgs.StringVar(&palette, "palette", prf.palette.Get(), "palette name, palette file or GENERATE with optional parameters. eg. GENERATE:phase=26.2")
+	err = flgs.Parse(args)
 	if err != nil {
 		return err
 	}
@@ -620,8 +868,35 @@ func Launch(endDebugger <-chan bool, g *gui.ChannelsDebugger, args []string) err
 		return fmt.Errorf("overscan option should be one of %s", list(overscanOptions))
 	}
 
+	elfModel = strings.ToUpper(elfModel)
+	if !slices.Contains(elf.ModelOptions, elfModel) {
+		return fmt.Errorf("elfmodel option should be one of %s", list(elf.ModelOptions))
+	}
+
 	// TODO: validate -mapper argument
 
+	pal, err := loadPalette(palette)
+	if err != nil {
+		return err
+	}
+
+	crtp, err := crt.ParsePreset(crtPreset)
+	if err != nil {
+		return err
+	}
+
+	if stems && wav == "" {
+		return fmt.Errorf("stems option requires the wav option")
+	}
+
+	// there is no audio output in headless mode, no file dialog and no display
+	// to synchronise with
+	if headless {
+		audio = "NONE"
+		useDialog = false
+		vsync = false
+	}
+
 	// exit program immediately if program launched with a file dialog. works in conjunction with
 	// the run variable which is set via the -run option
 	var runQuitImmediately bool
@@ -649,7 +924,7 @@ func Launch(endDebugger <-chan bool, g *gui.ChannelsDebugger, args []string) err
 				return nil
 			}
 
-			loader, err = external.Fingerprint(filename, mapper)
+			loader, err = fingerprint(g, useDialog, filename, mapper)
 			if err != nil {
 				select {
 				case g.ErrorDialog <- fmt.Sprintf("Problem with selected file\n\n%v", err):
@@ -672,7 +947,7 @@ func Launch(endDebugger <-chan bool, g *gui.ChannelsDebugger, args []string) err
 		if args[0] != "-" {
 			filename = args[0]
 
-			loader, err = external.Fingerprint(filename, mapper)
+			loader, err = fingerprint(g, useDialog, filename, mapper)
 			if err != nil {
 				return err
 			}
@@ -682,13 +957,24 @@ func Launch(endDebugger <-chan bool, g *gui.ChannelsDebugger, args []string) err
 		return fmt.Errorf("too many arguments to debugger")
 	}
 
+	// a problem with the bindings file is not fatal. the default bindings are
+	// used instead
+	inputBindings, err := bindings.Load()
+	if err != nil {
+		logger.Log(logger.Allow, "bindings", err)
+	}
+
 	ctx := context{
 		console:       "7800",
 		requestedSpec: spec,
 		useOverlay:    overlay,
 		audio:         audio,
 		sampleRate:    samplerate,
+		vsync:         vsync,
 		overscan:      overscan,
+		palette:       pal,
+		armPrefs:      prf.arm,
+		elfModel:      elfModel,
 	}
 	ctx.Reset()
 
@@ -723,7 +1009,7 @@ func Launch(endDebugger <-chan bool, g *gui.ChannelsDebugger, args []string) err
 		watches:      make(map[uint16]watch),
 		disasm:       make([]*execution.Result, 0x10000),
 		coprocDisasm: &coprocDisasm{},
-		coprocDev:    newCoprocDev(),
+		coprocDev:    newCoprocDev(prf.faults),
 		biosHelper: biosHelper{
 			bypass:       !bios,
 			skipChecksum: !checksum,
@@ -732,10 +1018,92 @@ func Launch(endDebugger <-chan bool, g *gui.ChannelsDebugger, args []string) err
 		hscForce:     hscForce,
 		savekeyAuto:  savekeyAuto,
 		savekeyForce: savekeyForce,
+		requests:     make(chan request),
+		interrupt:    make(chan bool, 1),
+		variables:    make(map[string]int),
+		headless:     headless,
+		useDialog:    useDialog,
+		crt:          crtp,
+		bindings:     inputBindings,
+		prefs:        prf,
 	}
 	m.console = hardware.Create(&m.ctx, g)
 	defer m.console.End()
 
+	m.setCRT(m.crt)
+
+	// make sure an active trace is completely written before exiting
+	defer func() {
+		if m.trace != nil {
+			err := m.traceStop()
+			if err != nil {
+				logger.Log(logger.Allow, "trace", err)
+			}
+		}
+	}()
+
+	// and the same for an active recording
+	defer func() {
+		if m.record != nil {
+			err := m.recordStop()
+			if err != nil {
+				logger.Log(logger.Allow, "record", err)
+			}
+		}
+	}()
+
+	defer func() {
+		if m.audioRecord != nil {
+			err := m.audioRecordStop()
+			if err != nil {
+				logger.Log(logger.Allow, "audio", err)
+			}
+		}
+	}()
+
+	if wav != "" {
+		err := m.audioRecordStart(wav, stems)
+		if err != nil {
+			return err
+		}
+		fmt.Println(m.styles.debugger.Render(
+			fmt.Sprintf("recording audio to %s", wav),
+		))
+	}
+
+	if gdb != "" {
+		srv, err := gdbserver.Listen("6502", gdb, &gdb6502{m: m})
+		if err != nil {
+			return err
+		}
+		defer srv.Close()
+		fmt.Println(m.styles.debugger.Render(
+			fmt.Sprintf("GDB server for 6502 listening on %s", srv.Addr()),
+		))
+	}
+
+	if gdbarm != "" {
+		srv, err := gdbserver.Listen("ARM", gdbarm, &gdbARM{m: m})
+		if err != nil {
+			return err
+		}
+		defer srv.Close()
+		fmt.Println(m.styles.debugger.Render(
+			fmt.Sprintf("GDB server for ARM listening on %s", srv.Addr()),
+		))
+	}
+
+	if apiAddr != "" {
+		srv, err := api.Listen(apiAddr, &apiHandler{m: m})
+		if err != nil {
+			return err
+		}
+		defer srv.Close()
+		fmt.Println(m.styles.debugger.Render(
+			fmt.Sprintf("API server listening on %s", srv.Addr()),
+		))
+	}
+
 	signal.Notify(m.sig, syscall.SIGINT)
 
 	m.reset()
@@ -778,7 +1146,16 @@ func Launch(endDebugger <-chan bool, g *gui.ChannelsDebugger, args []string) err
 	}
 
 	// start off gui in the paused state. gui won't properly begin until it receives a state change
-	g.State <- gui.StatePaused
+	m.setState(gui.StatePaused)
+
+	// a script replaces the -run option and the debugging loop
+	if script != "" {
+		m.source(script)
+		if m.failures > 0 {
+			return fmt.Errorf("script: %d failures", m.failures)
+		}
+		return nil
+	}
 
 	// start in run state if required
 	if run {
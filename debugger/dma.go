@@ -0,0 +1,157 @@
+package debugger
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jetsetilly/test7800/hardware/maria"
+)
+
+// the width of the bars in the text histogram
+const dmaHistogramWidth = 50
+
+// summarises the DMA usage of the current scanline and of the most recently
+// completed frame
+func (m *debugger) dmaSummary() {
+	var s strings.Builder
+
+	cur := m.console.MARIA.CurrentFrameDMA()
+	if len(cur) > 0 && cur[len(cur)-1].Scanline == m.console.MARIA.Coords.Scanline {
+		s.WriteString(cur[len(cur)-1].String())
+	} else {
+		s.WriteString("no DMA this scanline")
+	}
+
+	lines := m.console.MARIA.CompletedFrameDMA()
+	if len(lines) == 0 {
+		fmt.Println(m.styles.mem.Render(s.String()))
+		return
+	}
+
+	busiest := lines[0]
+	var truncated []maria.DMALine
+	for _, l := range lines {
+		if l.Cycles > busiest.Cycles {
+			busiest = l
+		}
+		if l.Truncated {
+			truncated = append(truncated, l)
+		}
+	}
+
+	fmt.Fprintf(&s, "\n\nprevious frame: %d scanlines with DMA, budget of %d cycles", len(lines), maria.DMABudget)
+	fmt.Fprintf(&s, "\nbusiest %s", busiest.String())
+	if len(truncated) == 0 {
+		s.WriteString("\nno scanlines were truncated")
+	} else {
+		fmt.Fprintf(&s, "\n%d scanlines were truncated", len(truncated))
+		for _, l := range truncated {
+			fmt.Fprintf(&s, "\n  %s", l.String())
+		}
+	}
+
+	fmt.Println(m.styles.mem.Render(s.String()))
+}
+
+// returns the DMA usage of the most recently completed frame as a histogram with
+// one line per scanline. truncated scanlines are marked with an exclamation mark
+func dmaHistogram(lines []maria.DMALine) string {
+	var s strings.Builder
+	for _, l := range lines {
+		n := min(l.Cycles, maria.DMABudget) * dmaHistogramWidth / maria.DMABudget
+		var mark string
+		if l.Truncated {
+			mark = "!"
+		}
+		fmt.Fprintf(&s, "%03d %3d %-*s|%s\n", l.Scanline, l.Cycles, dmaHistogramWidth, strings.Repeat("#", n), mark)
+	}
+	return s.String()
+}
+
+// returns the DMA usage as an image with one row per scanline. the length of
+// each row indicates the number of cycles used. truncated scanlines are drawn
+// in red
+func dmaImage(lines []maria.DMALine) *image.RGBA {
+	if len(lines) == 0 {
+		return image.NewRGBA(image.Rect(0, 0, maria.DMABudget, 1))
+	}
+
+	top := lines[0].Scanline
+	bottom := lines[len(lines)-1].Scanline
+	img := image.NewRGBA(image.Rect(0, 0, maria.DMABudget, bottom-top+1))
+
+	for _, l := range lines {
+		col := color.RGBA{R: 64, G: 192, B: 64, A: 255}
+		if l.Truncated {
+			col = color.RGBA{R: 255, G: 64, B: 64, A: 255}
+		}
+		for x := range min(l.Cycles, maria.DMABudget) {
+			img.Set(x, l.Scanline-top, col)
+		}
+	}
+
+	return img
+}
+
+// exports the DMA usage of the most recently completed frame. the format of
+// the export is decided by the filename extension: PNG for an image of the
+// histogram, JSON and CSV for structured exports, and anything else for a text
+// histogram
+func (m *debugger) dmaExport(filename string) error {
+	lines := m.console.MARIA.CompletedFrameDMA()
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".png":
+		return writePNG(filename, dmaImage(lines))
+
+	case ".json":
+		b, err := json.MarshalIndent(lines, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filename, b, 0644)
+
+	case ".csv":
+		f, err := os.Create(filename)
+		if err != nil {
+			return err
+		}
+
+		w := csv.NewWriter(f)
+		w.Write([]string{"scanline", "zone", "objects", "cycles", "remaining", "truncated", "truncatedDL", "truncatedOrigin"})
+		for _, l := range lines {
+			var dl, origin string
+			if l.Truncated {
+				dl = strconv.Itoa(l.TruncatedDL)
+				origin = fmt.Sprintf("%04x", l.TruncatedOrigin)
+			}
+			w.Write([]string{
+				strconv.Itoa(l.Scanline),
+				strconv.Itoa(l.Zone),
+				strconv.Itoa(l.Objects),
+				strconv.Itoa(l.Cycles),
+				strconv.Itoa(l.Remaining()),
+				strconv.FormatBool(l.Truncated),
+				dl,
+				origin,
+			})
+		}
+		w.Flush()
+
+		err = w.Error()
+		if err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+
+	return os.WriteFile(filename, []byte(dmaHistogram(lines)), 0644)
+}
@@ -0,0 +1,135 @@
+package debugger
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jetsetilly/test7800/hardware/maria"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// the annotated image is scaled so that the labels are legible
+const annotationScale = 2
+
+// colours used to outline objects in the annotated image. the colour used for
+// an object depends on its position in the display list
+var annotationColours = []color.RGBA{
+	{R: 255, G: 64, B: 64, A: 255},
+	{R: 64, G: 255, B: 64, A: 255},
+	{R: 64, G: 160, B: 255, A: 255},
+	{R: 255, G: 255, B: 64, A: 255},
+	{R: 255, G: 64, B: 255, A: 255},
+	{R: 64, G: 255, B: 255, A: 255},
+}
+
+// annotateFrame draws the frame image with each object in the display lists
+// outlined and labelled. the label is the zone number and the object number
+// separated by a full-stop
+func annotateFrame(frame *image.RGBA, fdl maria.FrameDL) *image.RGBA {
+	bounds := frame.Bounds()
+	img := image.NewRGBA(image.Rect(0, 0, bounds.Dx()*annotationScale, bounds.Dy()*annotationScale))
+	for y := range img.Bounds().Dy() {
+		for x := range img.Bounds().Dx() {
+			img.Set(x, y, frame.At(bounds.Min.X+x/annotationScale, bounds.Min.Y+y/annotationScale))
+		}
+	}
+
+	drw := &font.Drawer{
+		Dst:  img,
+		Face: basicfont.Face7x13,
+	}
+
+	for _, z := range fdl.Zones {
+		for _, o := range z.Objects {
+			l, r := o.Bounds()
+			x0, y0 := fdl.ImagePosition(z.Scanline, l)
+			x1, _ := fdl.ImagePosition(z.Scanline, r)
+			y1 := y0 + z.Height
+
+			x0 *= annotationScale
+			y0 *= annotationScale
+			x1 = x1*annotationScale - 1
+			y1 = y1*annotationScale - 1
+
+			col := annotationColours[o.Index%len(annotationColours)]
+			for x := x0; x <= x1; x++ {
+				img.Set(x, y0, col)
+				img.Set(x, y1, col)
+			}
+			for y := y0; y <= y1; y++ {
+				img.Set(x0, y, col)
+				img.Set(x1, y, col)
+			}
+
+			// labels are drawn with a shadow so that they are visible on any
+			// background. objects that wrap around the left edge of the screen
+			// have their label moved to the edge
+			lx := max(x0, 0)
+			label := fmt.Sprintf("%d.%d", z.Index, o.Index)
+			ascent := basicfont.Face7x13.Metrics().Ascent.Ceil()
+			drw.Src = image.NewUniform(color.Black)
+			drw.Dot = fixed.P(lx+3, y0+2+ascent)
+			drw.DrawString(label)
+			drw.Src = image.NewUniform(col)
+			drw.Dot = fixed.P(lx+2, y0+1+ascent)
+			drw.DrawString(label)
+		}
+	}
+
+	return img
+}
+
+// exports the display lists of the most recently completed frame. the format
+// of the export is decided by the filename extension: PNG for an annotated
+// image, JSON for a structured export, and anything else for plain text
+func (m *debugger) frameDLExport(filename string) error {
+	fdl := m.console.MARIA.CompletedFrameDL()
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".png":
+		frame := m.console.MARIA.CompletedFrame()
+		if frame == nil {
+			return fmt.Errorf("no frame has been completed")
+		}
+		return writePNG(filename, annotateFrame(frame, fdl))
+
+	case ".json":
+		b, err := json.MarshalIndent(fdl, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filename, b, 0644)
+	}
+
+	return os.WriteFile(filename, []byte(fdl.String()), 0644)
+}
+
+// prints the zone and objects at the position in the image of the most
+// recently completed frame
+func (m *debugger) frameDLAt(x int, y int) {
+	fdl := m.console.MARIA.CompletedFrameDL()
+	z, objs := fdl.At(x, y)
+	if z == nil {
+		fmt.Println(m.styles.mem.Render(
+			fmt.Sprintf("no zone at %d,%d in frame %d", x, y, fdl.Frame),
+		))
+		return
+	}
+
+	var s strings.Builder
+	s.WriteString(z.String())
+	if len(objs) == 0 {
+		s.WriteString("\nno objects")
+	}
+	for _, o := range objs {
+		fmt.Fprintf(&s, "\n  %s", o.String())
+	}
+	fmt.Println(m.styles.mem.Render(s.String()))
+}
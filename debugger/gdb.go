@@ -0,0 +1,438 @@
+package debugger
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/jetsetilly/test7800/coprocessor"
+	"github.com/jetsetilly/test7800/debugger/gdbserver"
+	"github.com/jetsetilly/test7800/hardware/arm/architecture"
+	"github.com/jetsetilly/test7800/hardware/memory"
+)
+
+// the signal to report to GDB depending on whether the emulation was
+// interrupted or not
+func gdbSignal(interrupted bool) gdbserver.Signal {
+	if interrupted {
+		return gdbserver.SignalInterrupt
+	}
+	return gdbserver.SignalTrap
+}
+
+// gdb6502 implements the gdbserver.Target interface for the 6502
+type gdb6502 struct {
+	m *debugger
+}
+
+const gdb6502Description = `<?xml version="1.0"?>
+<!DOCTYPE target SYSTEM "gdb-target.dtd">
+<target version="1.0">
+  <feature name="org.test7800.6502">
+    <reg name="a" bitsize="8" type="uint8"/>
+    <reg name="x" bitsize="8" type="uint8"/>
+    <reg name="y" bitsize="8" type="uint8"/>
+    <reg name="sp" bitsize="8" type="uint8"/>
+    <reg name="p" bitsize="8" type="uint8"/>
+    <reg name="pc" bitsize="16" type="code_ptr"/>
+  </feature>
+</target>
+`
+
+func (t *gdb6502) Description() string {
+	return gdb6502Description
+}
+
+func (t *gdb6502) NumRegisters() int {
+	return 6
+}
+
+func (t *gdb6502) ReadRegister(n int) ([]byte, error) {
+	var v []byte
+	var err error
+	t.m.sync(func() {
+		mc := t.m.console.MC
+		switch n {
+		case 0:
+			v = []byte{mc.A.Value()}
+		case 1:
+			v = []byte{mc.X.Value()}
+		case 2:
+			v = []byte{mc.Y.Value()}
+		case 3:
+			v = []byte{mc.SP.Value()}
+		case 4:
+			v = []byte{mc.Status.Value()}
+		case 5:
+			v = binary.LittleEndian.AppendUint16(nil, mc.PC.Value())
+		default:
+			err = fmt.Errorf("6502 has no register %d", n)
+		}
+	})
+	return v, err
+}
+
+func (t *gdb6502) WriteRegister(n int, data []byte) error {
+	var err error
+	t.m.sync(func() {
+		mc := t.m.console.MC
+		switch n {
+		case 0, 1, 2, 3, 4:
+			if len(data) != 1 {
+				err = fmt.Errorf("6502 register %d is 8bit", n)
+				return
+			}
+			switch n {
+			case 0:
+				mc.A.Load(data[0])
+			case 1:
+				mc.X.Load(data[0])
+			case 2:
+				mc.Y.Load(data[0])
+			case 3:
+				mc.SP.Load(data[0])
+			case 4:
+				mc.Status.Load(data[0])
+			}
+		case 5:
+			if len(data) != 2 {
+				err = fmt.Errorf("6502 register %d is 16bit", n)
+				return
+			}
+			mc.PC.Load(binary.LittleEndian.Uint16(data))
+		default:
+			err = fmt.Errorf("6502 has no register %d", n)
+		}
+	})
+	return err
+}
+
+func (t *gdb6502) ReadMemory(addr uint32, n int) ([]byte, error) {
+	if uint64(addr)+uint64(n) > 0x10000 {
+		return nil, fmt.Errorf("6502 address out of range: %08x", addr)
+	}
+
+	data := make([]byte, n)
+	var err error
+	t.m.sync(func() {
+		for i := range data {
+			a := uint16(addr) + uint16(i)
+			idx, area := t.m.console.Mem.MapAddress(a, true)
+			if area == nil {
+				err = fmt.Errorf("address is not mapped: %04x", a)
+				return
+			}
+			data[i], err = memory.Read(area, idx)
+			if err != nil {
+				return
+			}
+		}
+	})
+	return data, err
+}
+
+func (t *gdb6502) WriteMemory(addr uint32, data []byte) error {
+	if uint64(addr)+uint64(len(data)) > 0x10000 {
+		return fmt.Errorf("6502 address out of range: %08x", addr)
+	}
+
+	var err error
+	t.m.sync(func() {
+		for i, d := range data {
+			a := uint16(addr) + uint16(i)
+			idx, area := t.m.console.Mem.MapAddress(a, false)
+			if area == nil {
+				err = fmt.Errorf("address is not mapped: %04x", a)
+				return
+			}
+			err = memory.Write(area, idx, d)
+			if err != nil {
+				return
+			}
+		}
+	})
+	return err
+}
+
+func (t *gdb6502) AddBreakpoint(addr uint32) error {
+	if addr > 0xffff {
+		return fmt.Errorf("6502 address out of range: %08x", addr)
+	}
+	t.m.sync(func() {
+		t.m.breakpoints[uint16(addr)] = true
+	})
+	return nil
+}
+
+func (t *gdb6502) RemoveBreakpoint(addr uint32) error {
+	t.m.sync(func() {
+		delete(t.m.breakpoints, uint16(addr))
+	})
+	return nil
+}
+
+func (t *gdb6502) Step() (gdbserver.Signal, error) {
+	return gdbSignal(t.m.resume(func() bool {
+		t.m.stepRule = func() bool {
+			return true
+		}
+		return true
+	})), nil
+}
+
+func (t *gdb6502) Continue() (gdbserver.Signal, error) {
+	return gdbSignal(t.m.resume(func() bool { return true })), nil
+}
+
+func (t *gdb6502) Interrupt() {
+	t.m.interruptRun()
+}
+
+// gdbARM implements the gdbserver.Target interface for the coprocessor of an
+// ELF cartridge. the target only makes sense if a suitable cartridge has been
+// inserted
+type gdbARM struct {
+	m *debugger
+}
+
+// the number of registers is the same for both ARM architectures. the core
+// registers plus the status register
+const gdbARMNumRegisters = 17
+
+// the index of the status register for the gdbARM target
+const gdbARMStatusRegister = 16
+
+// the ARM emulation only supports the thumb instruction set so the thumb bit
+// in the status register is always set. the location of the bit differs
+// between the two architectures
+const (
+	gdbARMThumbCPSR = 0x00000020
+	gdbARMThumbXPSR = 0x01000000
+)
+
+// the status register is not part of the CartCoProc interface
+type gdbARMStatus interface {
+	StatusRegister() uint32
+	SetStatusRegister(uint32)
+}
+
+func (t *gdbARM) coproc() (coprocessor.CartCoProc, error) {
+	bus := t.m.console.Mem.External.GetCoProcBus()
+	if bus == nil {
+		return nil, fmt.Errorf("external device does not have a coprocessor")
+	}
+	return bus.GetCoProc(), nil
+}
+
+func (t *gdbARM) Description() string {
+	var mprofile bool
+	t.m.sync(func() {
+		coproc, err := t.coproc()
+		mprofile = err != nil || coproc.ProcessorID() == string(architecture.ARMv7_M)
+	})
+
+	feature := "org.gnu.gdb.arm.core"
+	status := "cpsr"
+	if mprofile {
+		feature = "org.gnu.gdb.arm.m-profile"
+		status = "xpsr"
+	}
+
+	var s strings.Builder
+	s.WriteString("<?xml version=\"1.0\"?>\n")
+	s.WriteString("<!DOCTYPE target SYSTEM \"gdb-target.dtd\">\n")
+	s.WriteString("<target version=\"1.0\">\n")
+	s.WriteString("  <architecture>arm</architecture>\n")
+	fmt.Fprintf(&s, "  <feature name=\"%s\">\n", feature)
+	for i := range 13 {
+		fmt.Fprintf(&s, "    <reg name=\"r%d\" bitsize=\"32\" type=\"uint32\"/>\n", i)
+	}
+	s.WriteString("    <reg name=\"sp\" bitsize=\"32\" type=\"data_ptr\"/>\n")
+	s.WriteString("    <reg name=\"lr\" bitsize=\"32\" type=\"uint32\"/>\n")
+	s.WriteString("    <reg name=\"pc\" bitsize=\"32\" type=\"code_ptr\"/>\n")
+	fmt.Fprintf(&s, "    <reg name=\"%s\" bitsize=\"32\" type=\"uint32\"/>\n", status)
+	s.WriteString("  </feature>\n")
+	s.WriteString("</target>\n")
+
+	return s.String()
+}
+
+func (t *gdbARM) NumRegisters() int {
+	return gdbARMNumRegisters
+}
+
+func (t *gdbARM) ReadRegister(n int) ([]byte, error) {
+	var v uint32
+	var err error
+	t.m.sync(func() {
+		var coproc coprocessor.CartCoProc
+		coproc, err = t.coproc()
+		if err != nil {
+			return
+		}
+
+		switch {
+		case n == gdbARMStatusRegister:
+			if st, ok := coproc.(gdbARMStatus); ok {
+				v = st.StatusRegister()
+			}
+			if coproc.ProcessorID() == string(architecture.ARMv7_M) {
+				v |= gdbARMThumbXPSR
+			} else {
+				v |= gdbARMThumbCPSR
+			}
+		case n >= 0 && n < gdbARMStatusRegister:
+			var ok bool
+			v, ok = coproc.Register(n)
+			if !ok {
+				err = fmt.Errorf("coprocessor has no register %d", n)
+			}
+
+			// the PC register has been advanced by the prefetch. GDB wants
+			// to see the address of the instruction that will be executed next
+			if n == 15 {
+				v -= 2
+			}
+		default:
+			err = fmt.Errorf("coprocessor has no register %d", n)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return binary.LittleEndian.AppendUint32(nil, v), nil
+}
+
+func (t *gdbARM) WriteRegister(n int, data []byte) error {
+	if len(data) != 4 {
+		return fmt.Errorf("coprocessor register %d is 32bit", n)
+	}
+	v := binary.LittleEndian.Uint32(data)
+
+	var err error
+	t.m.sync(func() {
+		var coproc coprocessor.CartCoProc
+		coproc, err = t.coproc()
+		if err != nil {
+			return
+		}
+
+		switch {
+		case n == gdbARMStatusRegister:
+			if st, ok := coproc.(gdbARMStatus); ok {
+				st.SetStatusRegister(v)
+			}
+		case n >= 0 && n < gdbARMStatusRegister:
+			// see comment in ReadRegister()
+			if n == 15 {
+				v += 2
+			}
+			if !coproc.RegisterSet(n, v) {
+				err = fmt.Errorf("coprocessor has no register %d", n)
+			}
+		default:
+			err = fmt.Errorf("coprocessor has no register %d", n)
+		}
+	})
+	return err
+}
+
+func (t *gdbARM) ReadMemory(addr uint32, n int) ([]byte, error) {
+	data := make([]byte, n)
+	var err error
+	t.m.sync(func() {
+		var coproc coprocessor.CartCoProc
+		coproc, err = t.coproc()
+		if err != nil {
+			return
+		}
+		for i := range data {
+			var ok bool
+			data[i], ok = coproc.PeekByte(addr + uint32(i))
+			if !ok {
+				err = fmt.Errorf("coprocessor address is not readable: %08x", addr+uint32(i))
+				return
+			}
+		}
+	})
+	return data, err
+}
+
+func (t *gdbARM) WriteMemory(addr uint32, data []byte) error {
+	var err error
+	t.m.sync(func() {
+		var coproc coprocessor.CartCoProc
+		coproc, err = t.coproc()
+		if err != nil {
+			return
+		}
+		for i, d := range data {
+			if !coproc.PokeByte(addr+uint32(i), d) {
+				err = fmt.Errorf("coprocessor address is not writeable: %08x", addr+uint32(i))
+				return
+			}
+		}
+	})
+	return err
+}
+
+func (t *gdbARM) AddBreakpoint(addr uint32) error {
+	t.m.sync(func() {
+		t.m.coprocDev.breakpoints[addr] = true
+		t.m.coprocBreakpointsEnable()
+	})
+	return nil
+}
+
+func (t *gdbARM) RemoveBreakpoint(addr uint32) error {
+	t.m.sync(func() {
+		delete(t.m.coprocDev.breakpoints, addr)
+		t.m.coprocBreakpointsEnable()
+	})
+	return nil
+}
+
+// Step runs the emulation until the coprocessor has executed an instruction.
+// if the coprocessor is not currently running the emulation will continue
+// until it is
+func (t *gdbARM) Step() (gdbserver.Signal, error) {
+	var err error
+	t.m.sync(func() {
+		_, err = t.coproc()
+	})
+	if err != nil {
+		return gdbserver.SignalTrap, err
+	}
+
+	interrupted := t.m.resume(func() bool {
+		t.m.coprocDev.step = true
+		t.m.coprocDev.remote = true
+		t.m.coprocBreakpointsEnable()
+		return true
+	})
+
+	t.m.sync(func() {
+		t.m.coprocDev.step = false
+		t.m.coprocDev.remote = false
+		t.m.coprocBreakpointsEnable()
+	})
+
+	return gdbSignal(interrupted), nil
+}
+
+func (t *gdbARM) Continue() (gdbserver.Signal, error) {
+	interrupted := t.m.resume(func() bool {
+		t.m.coprocDev.remote = true
+		return true
+	})
+
+	t.m.sync(func() {
+		t.m.coprocDev.remote = false
+	})
+
+	return gdbSignal(interrupted), nil
+}
+
+func (t *gdbARM) Interrupt() {
+	t.m.interruptRun()
+}
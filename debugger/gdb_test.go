@@ -0,0 +1,23 @@
+package debugger
+
+import (
+	"testing"
+
+	"github.com/jetsetilly/test7800/test"
+)
+
+func TestGDB6502Range(t *testing.T) {
+	// requests outside of the 6502 address space are rejected before the
+	// emulation is accessed so the target does not need a debugger
+	tgt := &gdb6502{}
+
+	_, err := tgt.ReadMemory(0xffff, 2)
+	test.ExpectFailure(t, err)
+	test.ExpectFailure(t, tgt.WriteMemory(0xffff, []byte{0, 0}))
+
+	// the end of the request would wrap around to a valid address if the
+	// address was a 32bit value
+	_, err = tgt.ReadMemory(0xffffffff, 2)
+	test.ExpectFailure(t, err)
+	test.ExpectFailure(t, tgt.WriteMemory(0xffffffff, []byte{0, 0}))
+}
@@ -0,0 +1,260 @@
+package gdbserver
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jetsetilly/test7800/logger"
+)
+
+// the reply sent for any error. GDB doesn't do anything with the error number
+// other than display it so we always use the same value
+const errorReply = "E01"
+
+// the largest packet we are willing to receive
+const packetSize = 0x4000
+
+// handles a single packet and returns the reply. the end flag is true if the
+// session should end after the reply has been sent
+func (sess *session) command(pkt string) (reply string, end bool) {
+	if len(pkt) == 0 {
+		return "", false
+	}
+
+	reply, err := sess.dispatch(pkt)
+	if err != nil {
+		logger.Logf(logger.Allow, "gdbserver", "%s: %v", pkt, err)
+		return errorReply, false
+	}
+
+	switch pkt[0] {
+	case 'D', 'k':
+		return reply, true
+	}
+
+	return reply, false
+}
+
+func (sess *session) dispatch(pkt string) (string, error) {
+	switch pkt[0] {
+	case '?':
+		return stopReply(sess.signal), nil
+
+	case 'q':
+		return sess.query(pkt[1:])
+
+	case 'Q':
+		if pkt == "QStartNoAckMode" {
+			sess.noAck.Store(true)
+			return "OK", nil
+		}
+		return "", nil
+
+	case 'H', 'T':
+		// there is only ever one thread so any thread selection or thread
+		// query is okay
+		return "OK", nil
+
+	case 'g':
+		var s strings.Builder
+		for n := range sess.target.NumRegisters() {
+			v, err := sess.target.ReadRegister(n)
+			if err != nil {
+				return "", err
+			}
+			s.WriteString(hex.EncodeToString(v))
+		}
+		return s.String(), nil
+
+	case 'G':
+		data, err := hex.DecodeString(pkt[1:])
+		if err != nil {
+			return "", err
+		}
+		for n := range sess.target.NumRegisters() {
+			// the size of each register is taken from the current value
+			v, err := sess.target.ReadRegister(n)
+			if err != nil {
+				return "", err
+			}
+			if len(data) < len(v) {
+				return "", fmt.Errorf("not enough data for register %d", n)
+			}
+			err = sess.target.WriteRegister(n, data[:len(v)])
+			if err != nil {
+				return "", err
+			}
+			data = data[len(v):]
+		}
+		return "OK", nil
+
+	case 'p':
+		n, err := strconv.ParseUint(pkt[1:], 16, 16)
+		if err != nil {
+			return "", err
+		}
+		v, err := sess.target.ReadRegister(int(n))
+		if err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(v), nil
+
+	case 'P':
+		r, d, ok := strings.Cut(pkt[1:], "=")
+		if !ok {
+			return "", fmt.Errorf("malformed packet")
+		}
+		n, err := strconv.ParseUint(r, 16, 16)
+		if err != nil {
+			return "", err
+		}
+		data, err := hex.DecodeString(d)
+		if err != nil {
+			return "", err
+		}
+		err = sess.target.WriteRegister(int(n), data)
+		if err != nil {
+			return "", err
+		}
+		return "OK", nil
+
+	case 'm':
+		addr, length, err := parseAddrLength(pkt[1:])
+		if err != nil {
+			return "", err
+		}
+		data, err := sess.target.ReadMemory(addr, length)
+		if err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(data), nil
+
+	case 'M':
+		a, d, ok := strings.Cut(pkt[1:], ":")
+		if !ok {
+			return "", fmt.Errorf("malformed packet")
+		}
+		addr, length, err := parseAddrLength(a)
+		if err != nil {
+			return "", err
+		}
+		data, err := hex.DecodeString(d)
+		if err != nil {
+			return "", err
+		}
+		if len(data) != length {
+			return "", fmt.Errorf("length of data does not match the length field")
+		}
+		err = sess.target.WriteMemory(addr, data)
+		if err != nil {
+			return "", err
+		}
+		return "OK", nil
+
+	case 'Z', 'z':
+		// only software (type 0) and hardware (type 1) breakpoints are
+		// supported. there is no difference between the two for our purposes
+		fields := strings.Split(pkt[1:], ",")
+		if len(fields) < 2 || (fields[0] != "0" && fields[0] != "1") {
+			return "", nil
+		}
+		addr, err := strconv.ParseUint(fields[1], 16, 32)
+		if err != nil {
+			return "", err
+		}
+		if pkt[0] == 'Z' {
+			err = sess.target.AddBreakpoint(uint32(addr))
+		} else {
+			err = sess.target.RemoveBreakpoint(uint32(addr))
+		}
+		if err != nil {
+			return "", err
+		}
+		return "OK", nil
+
+	case 'c':
+		return sess.resume(false), nil
+
+	case 's':
+		return sess.resume(true), nil
+
+	case 'D':
+		return "OK", nil
+
+	case 'k':
+		return "", nil
+	}
+
+	// the empty reply indicates that the packet is not supported
+	return "", nil
+}
+
+func (sess *session) query(q string) (string, error) {
+	switch {
+	case strings.HasPrefix(q, "Supported"):
+		return fmt.Sprintf("PacketSize=%x;qXfer:features:read+;QStartNoAckMode+", packetSize), nil
+
+	case strings.HasPrefix(q, "Xfer:features:read:"):
+		annex, ol, ok := strings.Cut(strings.TrimPrefix(q, "Xfer:features:read:"), ":")
+		if !ok {
+			return "", fmt.Errorf("malformed packet")
+		}
+		if annex != "target.xml" {
+			return "", fmt.Errorf("unknown annex: %s", annex)
+		}
+		offset, length, err := parseAddrLength(ol)
+		if err != nil {
+			return "", err
+		}
+		return xfer(sess.target.Description(), int(offset), length), nil
+
+	case q == "Attached":
+		return "1", nil
+
+	case q == "C":
+		return "QC1", nil
+
+	case q == "fThreadInfo":
+		return "m1", nil
+
+	case q == "sThreadInfo":
+		return "l", nil
+	}
+
+	return "", nil
+}
+
+// returns the section of data requested by a qXfer packet. the first
+// character of the reply indicates whether there is more data to come
+func xfer(data string, offset int, length int) string {
+	if offset >= len(data) {
+		return "l"
+	}
+	data = data[offset:]
+	if length >= len(data) {
+		return "l" + data
+	}
+	return "m" + data[:length]
+}
+
+// parses the common "addr,length" form used by several packets
+func parseAddrLength(s string) (uint32, int, error) {
+	a, l, ok := strings.Cut(s, ",")
+	if !ok {
+		return 0, 0, fmt.Errorf("malformed packet")
+	}
+	addr, err := strconv.ParseUint(a, 16, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	length, err := strconv.ParseUint(l, 16, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	if length > packetSize {
+		return 0, 0, fmt.Errorf("length too large: %d", length)
+	}
+	return uint32(addr), int(length), nil
+}
@@ -0,0 +1,21 @@
+// Package gdbserver implements the GDB Remote Serial Protocol over TCP. It allows
+// external debugging front-ends (eg. gdb-multiarch or the VS Code debugger) to
+// attach to the emulation.
+//
+// The package knows nothing about the emulation itself. Instead, it is given an
+// implementation of the Target interface for each processor that should be
+// exposed. Each Target is served on its own address because GDB can only deal
+// with a single architecture per connection. In the case of test7800 this means
+// that the 6502 and the ARM coprocessor of an ELF cartridge are separate
+// targets.
+//
+// The protocol is documented at:
+//
+// https://sourceware.org/gdb/current/onlinedocs/gdb.html/Remote-Protocol.html
+//
+// Only the subset of the protocol required for an all-stop debugging session
+// is implemented: register and memory read/write; software breakpoints; and
+// single-step and continue. The target description is sent to GDB with the
+// qXfer:features:read packet so that the register layout is described by the
+// Target and not by any assumption inside GDB.
+package gdbserver
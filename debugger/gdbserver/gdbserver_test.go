@@ -0,0 +1,182 @@
+package gdbserver_test
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/jetsetilly/test7800/debugger/gdbserver"
+	"github.com/jetsetilly/test7800/test"
+)
+
+type target struct {
+	regs        [][]byte
+	mem         [0x100]byte
+	breakpoints map[uint32]bool
+	interrupt   chan bool
+}
+
+func newTarget() *target {
+	return &target{
+		regs:        [][]byte{{0x01}, {0x02}, {0x34, 0x12}},
+		breakpoints: make(map[uint32]bool),
+		interrupt:   make(chan bool, 1),
+	}
+}
+
+func (t *target) Description() string {
+	return `<?xml version="1.0"?><target></target>`
+}
+
+func (t *target) NumRegisters() int {
+	return len(t.regs)
+}
+
+func (t *target) ReadRegister(n int) ([]byte, error) {
+	if n >= len(t.regs) {
+		return nil, fmt.Errorf("no register")
+	}
+	return t.regs[n], nil
+}
+
+func (t *target) WriteRegister(n int, data []byte) error {
+	if n >= len(t.regs) || len(data) != len(t.regs[n]) {
+		return fmt.Errorf("no register")
+	}
+	copy(t.regs[n], data)
+	return nil
+}
+
+func (t *target) ReadMemory(addr uint32, n int) ([]byte, error) {
+	if int(addr)+n > len(t.mem) {
+		return nil, fmt.Errorf("out of range")
+	}
+	return t.mem[addr : int(addr)+n], nil
+}
+
+func (t *target) WriteMemory(addr uint32, data []byte) error {
+	if int(addr)+len(data) > len(t.mem) {
+		return fmt.Errorf("out of range")
+	}
+	copy(t.mem[addr:], data)
+	return nil
+}
+
+func (t *target) AddBreakpoint(addr uint32) error {
+	t.breakpoints[addr] = true
+	return nil
+}
+
+func (t *target) RemoveBreakpoint(addr uint32) error {
+	delete(t.breakpoints, addr)
+	return nil
+}
+
+func (t *target) Step() (gdbserver.Signal, error) {
+	t.regs[2][0]++
+	return gdbserver.SignalTrap, nil
+}
+
+func (t *target) Continue() (gdbserver.Signal, error) {
+	if len(t.breakpoints) > 0 {
+		return gdbserver.SignalTrap, nil
+	}
+	<-t.interrupt
+	return gdbserver.SignalInterrupt, nil
+}
+
+func (t *target) Interrupt() {
+	t.interrupt <- true
+}
+
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (c *client) send(pkt string) string {
+	c.t.Helper()
+
+	var sum uint8
+	for i := 0; i < len(pkt); i++ {
+		sum += pkt[i]
+	}
+	_, err := fmt.Fprintf(c.conn, "$%s#%02x", pkt, sum)
+	test.ExpectSuccess(c.t, err)
+
+	ack, err := c.r.ReadByte()
+	test.ExpectSuccess(c.t, err)
+	test.ExpectEquality(c.t, ack, '+')
+
+	return c.reply()
+}
+
+func (c *client) reply() string {
+	c.t.Helper()
+
+	s, err := c.r.ReadString('#')
+	test.ExpectSuccess(c.t, err)
+	_, err = c.r.Discard(2)
+	test.ExpectSuccess(c.t, err)
+	s = strings.TrimSuffix(s, "#")
+
+	// discard any acknowledgements that preceded the reply
+	return strings.TrimLeft(s, "+$")
+}
+
+func TestServer(t *testing.T) {
+	tgt := newTarget()
+	srv, err := gdbserver.Listen("test", "127.0.0.1:0", tgt)
+	test.ExpectSuccess(t, err)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	test.ExpectSuccess(t, err)
+	defer conn.Close()
+
+	c := &client{t: t, conn: conn, r: bufio.NewReader(conn)}
+
+	test.ExpectEquality(t, c.send("qSupported:multiprocess+"), "PacketSize=4000;qXfer:features:read+;QStartNoAckMode+")
+	test.ExpectEquality(t, c.send("?"), "S05")
+	test.ExpectEquality(t, c.send("qXfer:features:read:target.xml:0,8"), "m<?xml ve")
+	test.ExpectEquality(t, c.send("qXfer:features:read:target.xml:8,100"), "lrsion=\"1.0\"?><target></target>")
+	test.ExpectEquality(t, c.send("vMustReplyEmpty"), "")
+
+	// registers
+	test.ExpectEquality(t, c.send("g"), "01023412")
+	test.ExpectEquality(t, c.send("p2"), "3412")
+	test.ExpectEquality(t, c.send("P1=ff"), "OK")
+	test.ExpectEquality(t, c.send("G0a0b0c0d"), "OK")
+	test.ExpectEquality(t, c.send("g"), "0a0b0c0d")
+	test.ExpectEquality(t, c.send("p3"), "E01")
+
+	// memory
+	test.ExpectEquality(t, c.send("M10,3:aabbcc"), "OK")
+	test.ExpectEquality(t, c.send("m0f,5"), "00aabbcc00")
+	test.ExpectEquality(t, c.send("mff,2"), "E01")
+
+	// stepping
+	test.ExpectEquality(t, c.send("s"), "S05")
+	test.ExpectEquality(t, c.send("p2"), "0d0d")
+
+	// continue with a breakpoint
+	test.ExpectEquality(t, c.send("Z0,80,2"), "OK")
+	test.ExpectEquality(t, tgt.breakpoints[0x80], true)
+	test.ExpectEquality(t, c.send("c"), "S05")
+	test.ExpectEquality(t, c.send("z0,80,2"), "OK")
+	test.ExpectEquality(t, len(tgt.breakpoints), 0)
+
+	// unsupported breakpoint types
+	test.ExpectEquality(t, c.send("Z2,80,1"), "")
+
+	// continue without any breakpoints and then interrupt the target
+	test.ExpectEquality(t, c.send("QStartNoAckMode"), "OK")
+	_, err = fmt.Fprintf(conn, "$c#63")
+	test.ExpectSuccess(t, err)
+	_, err = conn.Write([]byte{0x03})
+	test.ExpectSuccess(t, err)
+	test.ExpectEquality(t, c.reply(), "S02")
+}
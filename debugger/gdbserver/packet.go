@@ -0,0 +1,97 @@
+package gdbserver
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// the byte sent by GDB outside of the packet framing to interrupt a running
+// target
+const interruptByte = 0x03
+
+func checksum(data string) uint8 {
+	var sum uint8
+	for i := 0; i < len(data); i++ {
+		sum += data[i]
+	}
+	return sum
+}
+
+// escape bytes in a reply that would otherwise be mistaken for the packet
+// framing or for run-length encoding
+func escape(data string) string {
+	if !strings.ContainsAny(data, "#$}*") {
+		return data
+	}
+	var s strings.Builder
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '#', '$', '}', '*':
+			s.WriteByte('}')
+			s.WriteByte(data[i] ^ 0x20)
+		default:
+			s.WriteByte(data[i])
+		}
+	}
+	return s.String()
+}
+
+// frame the data ready for sending
+func frame(data string) []byte {
+	data = escape(data)
+	return fmt.Appendf(nil, "$%s#%02x", data, checksum(data))
+}
+
+// event is the result of reading from the connection. either a packet or an
+// interrupt request is received
+type event struct {
+	packet    string
+	interrupt bool
+
+	// badChecksum is true if the packet was not received correctly. the
+	// packet field should not be used in this case
+	badChecksum bool
+}
+
+// read the next event from the reader. acknowledgements sent by GDB are
+// skipped over
+func readEvent(r *bufio.Reader) (event, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return event{}, err
+		}
+
+		switch b {
+		case interruptByte:
+			return event{interrupt: true}, nil
+
+		case '$':
+			data, err := r.ReadString('#')
+			if err != nil {
+				return event{}, err
+			}
+			data = strings.TrimSuffix(data, "#")
+
+			var sum [2]byte
+			for i := range sum {
+				sum[i], err = r.ReadByte()
+				if err != nil {
+					return event{}, err
+				}
+			}
+
+			var expected [1]byte
+			_, err = hex.Decode(expected[:], sum[:])
+			if err != nil || expected[0] != checksum(data) {
+				return event{badChecksum: true}, nil
+			}
+
+			return event{packet: data}, nil
+		}
+
+		// anything else is either an acknowledgement or noise and can be ignored
+	}
+}
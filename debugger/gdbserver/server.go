@@ -0,0 +1,203 @@
+package gdbserver
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+
+	"github.com/jetsetilly/test7800/logger"
+)
+
+// Server accepts GDB connections for a single Target. Only one connection is
+// served at a time
+type Server struct {
+	name     string
+	target   Target
+	listener net.Listener
+}
+
+// Listen creates a new Server and starts listening on the TCP address. The
+// name is used to identify the server in the log
+func Listen(name string, address string, target Target) (*Server, error) {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("gdbserver: %w", err)
+	}
+
+	srv := &Server{
+		name:     name,
+		target:   target,
+		listener: l,
+	}
+	go srv.serve()
+
+	logger.Logf(logger.Allow, "gdbserver", "%s listening on %s", srv.name, l.Addr())
+
+	return srv, nil
+}
+
+// Addr returns the address the server is listening on
+func (srv *Server) Addr() net.Addr {
+	return srv.listener.Addr()
+}
+
+// Close stops the server from listening for new connections
+func (srv *Server) Close() error {
+	return srv.listener.Close()
+}
+
+func (srv *Server) serve() {
+	for {
+		c, err := srv.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logger.Log(logger.Allow, "gdbserver", err)
+			}
+			return
+		}
+
+		logger.Logf(logger.Allow, "gdbserver", "%s connection from %s", srv.name, c.RemoteAddr())
+		sess := newSession(srv.target, c)
+		sess.run()
+		logger.Logf(logger.Allow, "gdbserver", "%s connection closed", srv.name)
+	}
+}
+
+type session struct {
+	target Target
+	conn   net.Conn
+
+	// events received by the reader goroutine. the channel is closed when the
+	// connection is closed
+	events chan event
+
+	// done is closed when the session ends, so that the reader goroutine
+	// doesn't block forever
+	done chan bool
+
+	// acknowledgment of packets is not required if noAck is true
+	noAck atomic.Bool
+
+	// the most recent reason for the target stopping
+	signal Signal
+}
+
+func newSession(target Target, conn net.Conn) *session {
+	sess := &session{
+		target: target,
+		conn:   conn,
+		events: make(chan event),
+		done:   make(chan bool),
+		signal: SignalTrap,
+	}
+
+	go func() {
+		defer close(sess.events)
+		r := bufio.NewReader(conn)
+		for {
+			ev, err := readEvent(r)
+			if err != nil {
+				return
+			}
+
+			if !ev.interrupt && !sess.noAck.Load() {
+				ack := []byte{'+'}
+				if ev.badChecksum {
+					ack[0] = '-'
+				}
+				_, err = conn.Write(ack)
+				if err != nil {
+					return
+				}
+			}
+
+			if ev.badChecksum {
+				continue // for loop
+			}
+
+			select {
+			case sess.events <- ev:
+			case <-sess.done:
+				return
+			}
+		}
+	}()
+
+	return sess
+}
+
+func (sess *session) run() {
+	defer sess.conn.Close()
+	defer close(sess.done)
+
+	for {
+		ev, ok := <-sess.events
+		if !ok {
+			return
+		}
+
+		// an interrupt is meaningless if the target isn't running
+		if ev.interrupt {
+			continue // for loop
+		}
+
+		reply, end := sess.command(ev.packet)
+		_, err := sess.conn.Write(frame(reply))
+		if err != nil || end {
+			return
+		}
+	}
+}
+
+// resume the target by either stepping or continuing. the events channel is
+// monitored while the target is running so that an interrupt can be
+// forwarded to the target
+func (sess *session) resume(step bool) string {
+	type result struct {
+		signal Signal
+		err    error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		var r result
+		if step {
+			r.signal, r.err = sess.target.Step()
+		} else {
+			r.signal, r.err = sess.target.Continue()
+		}
+		done <- r
+	}()
+
+	events := sess.events
+	for {
+		select {
+		case r := <-done:
+			if r.err != nil {
+				logger.Log(logger.Allow, "gdbserver", r.err)
+				r.signal = SignalTrap
+			}
+			sess.signal = r.signal
+			return stopReply(sess.signal)
+
+		case ev, ok := <-events:
+			if !ok {
+				// connection has been lost. interrupt the target and wait for
+				// it to stop. stop monitoring the events channel
+				events = nil
+				sess.target.Interrupt()
+			} else if ev.interrupt {
+				sess.target.Interrupt()
+			}
+
+			// packets other than the interrupt are ignored while the target is
+			// running
+		}
+	}
+}
+
+func stopReply(sig Signal) string {
+	return fmt.Sprintf("S%02x", uint8(sig))
+}
@@ -0,0 +1,51 @@
+package gdbserver
+
+// Signal is the reason given to GDB for why the target has stopped
+type Signal uint8
+
+// List of valid Signal values. The values are the ones used by GDB, which are
+// the same as the POSIX signal numbers
+const (
+	SignalInterrupt Signal = 2
+	SignalTrap      Signal = 5
+)
+
+// Target is implemented by anything that can be debugged through the server.
+//
+// The functions will be called from the server's goroutine. Implementations
+// must take care of synchronisation with the emulation as required.
+type Target interface {
+	// Description returns the target description in the GDB XML format. The
+	// order of the registers in the description must match the register
+	// numbering used by ReadRegister() and WriteRegister()
+	Description() string
+
+	// the number of registers in the target
+	NumRegisters() int
+
+	// read and write the specified register. the byte slice is in the target's
+	// byte order and is the full width of the register
+	ReadRegister(n int) ([]byte, error)
+	WriteRegister(n int, data []byte) error
+
+	// read and write memory. an error should be returned if any part of the
+	// memory range is not accessible
+	ReadMemory(addr uint32, n int) ([]byte, error)
+	WriteMemory(addr uint32, data []byte) error
+
+	// add and remove breakpoints on the specified address
+	AddBreakpoint(addr uint32) error
+	RemoveBreakpoint(addr uint32) error
+
+	// Step executes a single instruction and returns the reason for stopping
+	Step() (Signal, error)
+
+	// Continue runs the target until it halts for some reason. For example,
+	// because it has reached a breakpoint or because Interrupt() has been
+	// called
+	Continue() (Signal, error)
+
+	// Interrupt causes a Continue() in progress to return. The function will
+	// be called from a different goroutine to the Continue() call
+	Interrupt()
+}
@@ -0,0 +1,71 @@
+package debugger
+
+import (
+	"fmt"
+	"image/color"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jetsetilly/test7800/hardware/spec"
+)
+
+// the palette option accepts the name of an embedded palette, the filename of
+// a palette file or a generated palette. the value DEFAULT uses the palette of
+// the television specification
+func loadPalette(name string) (*[256]color.RGBA, error) {
+	if name == "" || strings.ToUpper(name) == "DEFAULT" {
+		return nil, nil
+	}
+	pal, err := spec.LoadPalette(name)
+	if err != nil {
+		return nil, err
+	}
+	return &pal, nil
+}
+
+// changes the palette used by the emulation. the change takes effect immediately
+func (m *debugger) setPalette(name string) error {
+	pal, err := loadPalette(name)
+	if err != nil {
+		return err
+	}
+	m.ctx.palette = pal
+	m.console.MARIA.Spec.Palette = m.ctx.Spec().Palette
+	return nil
+}
+
+// prints the current palette as a table. each entry is shown in its own colour
+func (m *debugger) printPalette() {
+	pal := m.console.MARIA.Spec.Palette
+
+	var s strings.Builder
+	s.WriteString("    ")
+	for lum := range 16 {
+		fmt.Fprintf(&s, "   %x   ", lum)
+	}
+	for hue := range 16 {
+		fmt.Fprintf(&s, "\n%x0  ", hue)
+		for lum := range 16 {
+			c := pal[(hue<<4)|lum]
+
+			// use a foreground colour that contrasts with the entry
+			fg := lipgloss.Color("#ffffff")
+			if 0.299*float64(c.R)+0.587*float64(c.G)+0.114*float64(c.B) > 128 {
+				fg = lipgloss.Color("#000000")
+			}
+
+			style := lipgloss.NewStyle().
+				Background(lipgloss.Color(fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B))).
+				Foreground(fg)
+			s.WriteString(style.Render(fmt.Sprintf("%02x%02x%02x", c.R, c.G, c.B)))
+			s.WriteString(" ")
+		}
+	}
+	fmt.Println(s.String())
+}
+
+// writes the current palette to a palette file
+func (m *debugger) savePalette(filename string) error {
+	return os.WriteFile(filename, spec.EncodePalette(m.console.MARIA.Spec.Palette), 0644)
+}
@@ -0,0 +1,80 @@
+package debugger
+
+import (
+	"github.com/jetsetilly/test7800/coprocessor/faults"
+	"github.com/jetsetilly/test7800/hardware/arm"
+	"github.com/jetsetilly/test7800/prefs"
+)
+
+// the name of the preferences file in the resources directory
+const prefsFile = "preferences"
+
+// valid values for the audio and overscan options. shared by the command line
+// arguments and the preferences
+var (
+	audioOptions    = []string{"MONO", "STEREO", "NONE"}
+	overscanOptions = []string{"AUTO", "NONE", "MODERN", "FULL"}
+)
+
+// controller options are the same as the controller names used by the
+// cartridge loader. AUTO means that the controller specified by the cartridge
+// is used
+var controllerOptions = []string{"AUTO", "7800_JOYSTICK", "2600_JOYSTICK", "PADDLE", "TRAKBALL", "SNES2ATARI"}
+
+type preferences struct {
+	dsk *prefs.Disk
+
+	// preferences for the ARM coprocessor. these are applied whenever the
+	// ARM starts running
+	arm *arm.Preferences
+
+	// the policy for each category of coprocessor memory fault
+	faults map[faults.Category]*prefs.String
+
+	// default values for the command line arguments of the same name
+	audio    *prefs.String
+	overscan *prefs.String
+	palette  *prefs.String
+
+	// the controller to plug in when a cartridge is inserted
+	controller *prefs.String
+}
+
+// create preferences and load any saved values. the preferences are returned
+// even if there is an error loading the saved values
+func newPreferences() (*preferences, error) {
+	p := &preferences{
+		dsk:        prefs.NewDisk(prefsFile),
+		arm:        arm.NewPreferences(),
+		audio:      prefs.NewString("MONO", audioOptions...),
+		overscan:   prefs.NewString("AUTO", overscanOptions...),
+		palette:    prefs.NewString("DEFAULT"),
+		controller: prefs.NewString("AUTO", controllerOptions...),
+		faults:     make(map[faults.Category]*prefs.String),
+	}
+
+	err := p.arm.Add(p.dsk)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range faults.Categories {
+		p.faults[c] = prefs.NewString(string(faults.DefaultPolicy(c)), faults.PolicyOptions...)
+		err := p.dsk.Add("coproc.fault."+c.Key(), p.faults[c])
+		if err != nil {
+			return nil, err
+		}
+	}
+	for k, v := range map[string]prefs.Value{
+		"audio":      p.audio,
+		"overscan":   p.overscan,
+		"palette":    p.palette,
+		"controller": p.controller,
+	} {
+		err := p.dsk.Add(k, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p, p.dsk.Load()
+}
@@ -0,0 +1,135 @@
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/jetsetilly/test7800/debugger/capture"
+)
+
+// recorder writes every completed frame and every audio sample to files
+type recorder struct {
+	filename string
+	scale    int
+	video    capture.Video
+	wav      *capture.WAV
+
+	// the frame number of the most recently completed frame. recording begins
+	// on the first frame boundary after the recording starts so that the video
+	// and audio are synchronised
+	frame   int
+	started bool
+}
+
+// called by the audioTap for every audio sample
+func (r *recorder) Sample(left int16, right int16) {
+	if r.started {
+		r.wav.Sample(left, right)
+	}
+}
+
+// the largest scale factor accepted by the SCREENSHOT and RECORD commands
+const maxCaptureScale = 8
+
+// parses the optional scale argument of the SCREENSHOT and RECORD commands.
+// prints an error and returns false if the argument is not valid
+func (m *debugger) captureScale(command string, args []string) (int, bool) {
+	if len(args) == 0 {
+		return 1, true
+	}
+	if len(args) > 1 {
+		fmt.Println(m.styles.err.Render(
+			fmt.Sprintf("too many arguments to %s command", command),
+		))
+		return 0, false
+	}
+	scale, err := strconv.Atoi(args[0])
+	if err != nil || scale < 1 || scale > maxCaptureScale {
+		fmt.Println(m.styles.err.Render(
+			fmt.Sprintf("%s: scale should be between 1 and %d: %s", command, maxCaptureScale, args[0]),
+		))
+		return 0, false
+	}
+	return scale, true
+}
+
+// saves the most recently completed frame to a PNG file
+func (m *debugger) screenshot(filename string, scale int) error {
+	img := m.console.MARIA.CompletedFrame()
+	if img == nil {
+		return fmt.Errorf("no frame has been completed")
+	}
+	return writePNG(filename, capture.Scale(img, scale))
+}
+
+func (m *debugger) recordStart(filename string, scale int) error {
+	if m.record != nil {
+		return fmt.Errorf("recording already active: %s", m.record.filename)
+	}
+
+	spec := m.console.MARIA.Spec
+	video, err := capture.NewVideo(filename, capture.Rate{
+		Num: int(math.Round(spec.HorizScan)),
+		Den: spec.AbsoluteBottom,
+	})
+	if err != nil {
+		return err
+	}
+
+	wav, err := capture.NewWAV(capture.AudioFilename(filename), m.console.TIA.SampleRate())
+	if err != nil {
+		video.Close()
+		return err
+	}
+
+	m.record = &recorder{
+		filename: filename,
+		scale:    scale,
+		video:    video,
+		wav:      wav,
+		frame:    m.console.MARIA.Coords.Frame,
+	}
+	m.updateAudioTap()
+
+	return nil
+}
+
+// called after every instruction. adds the most recently completed frame to
+// the video if a new frame has begun
+func (m *debugger) recordFrame() error {
+	r := m.record
+	if r.frame == m.console.MARIA.Coords.Frame {
+		return nil
+	}
+	r.frame = m.console.MARIA.Coords.Frame
+
+	if !r.started {
+		r.started = true
+		return nil
+	}
+
+	return r.video.Frame(capture.Scale(m.console.MARIA.CompletedFrame(), r.scale))
+}
+
+func (m *debugger) recordStop() error {
+	if m.record == nil {
+		return fmt.Errorf("recording is not active")
+	}
+
+	r := m.record
+	m.record = nil
+	m.updateAudioTap()
+
+	err := errors.Join(r.video.Close(), r.wav.Close())
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(m.styles.debugger.Render(
+		fmt.Sprintf("recorded %d frames to %s and %s", r.video.Frames(), r.filename, capture.AudioFilename(r.filename)),
+	))
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package debugger
+
+// request is sent by a remote connection (GDB, API, etc.) to the debugger's
+// goroutine. the emulation is only ever touched by the debugger's goroutine
+type request struct {
+	// returns true if the debugger should quit
+	run func() bool
+
+	// whether the request resumes the emulation. a request that resumes the
+	// emulation will produce output on the terminal
+	resume bool
+
+	// an inline request can be serviced while the emulation is running,
+	// between CPU instructions. requests that are not inline will halt the
+	// emulation before being serviced
+	inline bool
+}
+
+// run function in the debugger's goroutine and wait for it to complete. the
+// emulation will be halted if it is running
+func (m *debugger) sync(f func()) {
+	m.send(f, false)
+}
+
+// run function in the debugger's goroutine and wait for it to complete. the
+// emulation will not be halted if it is running
+func (m *debugger) syncInline(f func()) {
+	m.send(f, true)
+}
+
+func (m *debugger) send(f func(), inline bool) {
+	done := make(chan bool)
+	m.requests <- request{
+		run: func() bool {
+			defer close(done)
+			f()
+			return false
+		},
+		inline: inline,
+	}
+	<-done
+}
+
+// run the emulation in the debugger's goroutine and wait for it to stop. the
+// prepare function is called before the emulation is started and the emulation
+// will not be started if it returns false. returns true if the emulation was
+// stopped with interruptRun()
+func (m *debugger) resume(prepare func() bool) bool {
+	var interrupted bool
+	done := make(chan bool)
+	m.requests <- request{
+		resume: true,
+		run: func() bool {
+			defer close(done)
+			m.clearInterrupt()
+			if !prepare() {
+				return false
+			}
+			quit := m.run()
+			interrupted = m.interrupted
+			return quit
+		},
+	}
+	<-done
+	return interrupted
+}
+
+// start the emulation in the debugger's goroutine but return as soon as it
+// has started
+func (m *debugger) resumeNoWait() {
+	done := make(chan bool)
+	m.requests <- request{
+		resume: true,
+		run: func() bool {
+			m.clearInterrupt()
+			close(done)
+			return m.run()
+		},
+	}
+	<-done
+}
+
+// stop the emulation if it is running
+func (m *debugger) interruptRun() {
+	select {
+	case m.interrupt <- true:
+	default:
+	}
+}
+
+// discard any interrupt that arrived too late to affect a previous run
+func (m *debugger) clearInterrupt() {
+	select {
+	case <-m.interrupt:
+	default:
+	}
+	m.interrupted = false
+}
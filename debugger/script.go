@@ -0,0 +1,444 @@
+package debugger
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/jetsetilly/test7800/hardware/memory"
+)
+
+// a script is a file of debugger commands, one command per line. blank lines
+// and lines beginning with # are ignored. in addition to the normal debugger
+// commands, scripts can make use of the following control statements
+//
+//	IF <condition> ... ELSE ... END
+//	WHILE <condition> ... END
+//	REPEAT <count> ... END
+//
+// a condition is two values separated by one of the comparison operators: ==,
+// !=, <, <=, > or >=. a value is a number, a variable set with the SET command,
+// one of the CPU registers (A, X, Y, SP, PC), one of the television coordinates
+// (FRAME, SCANLINE, CLK) or a memory read using PEEK. values can be added or
+// subtracted with the + and - operators
+//
+//	SET count PEEK $2000 + 1
+//	ASSERT count == $05
+
+// the maximum depth of nested SOURCE commands
+const maxScriptDepth = 10
+
+type scriptLine struct {
+	num int
+	cmd []string
+}
+
+type scriptStatement struct {
+	scriptLine
+
+	// the body of a control statement. the alt block is the ELSE block of
+	// an IF statement
+	body []scriptStatement
+	alt  []scriptStatement
+}
+
+func isControlStatement(cmd string) bool {
+	switch strings.ToUpper(cmd) {
+	case "IF", "WHILE", "REPEAT", "ELSE", "END":
+		return true
+	}
+	return false
+}
+
+// parses the lines of a script into a list of statements
+func parseScript(lines []string) ([]scriptStatement, error) {
+	var sl []scriptLine
+	for i, l := range lines {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue // for loop
+		}
+		sl = append(sl, scriptLine{num: i + 1, cmd: strings.Fields(l)})
+	}
+
+	block, n, err := parseScriptBlock(sl, 0)
+	if err != nil {
+		return nil, err
+	}
+	if n < len(sl) {
+		return nil, fmt.Errorf("line %d: unexpected %s", sl[n].num, strings.ToUpper(sl[n].cmd[0]))
+	}
+	return block, nil
+}
+
+// parses lines until the end of the script or until an ELSE or END is found.
+// returns the index of the line that ended the block
+func parseScriptBlock(lines []scriptLine, i int) ([]scriptStatement, int, error) {
+	var block []scriptStatement
+
+	for i < len(lines) {
+		s := scriptStatement{scriptLine: lines[i]}
+
+		switch strings.ToUpper(s.cmd[0]) {
+		case "ELSE", "END":
+			return block, i, nil
+
+		case "IF", "WHILE", "REPEAT":
+			if len(s.cmd) < 2 {
+				return nil, i, fmt.Errorf("line %d: %s requires an argument", s.num, strings.ToUpper(s.cmd[0]))
+			}
+
+			var err error
+			s.body, i, err = parseScriptBlock(lines, i+1)
+			if err != nil {
+				return nil, i, err
+			}
+			if i >= len(lines) {
+				return nil, i, fmt.Errorf("line %d: %s has no END", s.num, strings.ToUpper(s.cmd[0]))
+			}
+
+			if strings.ToUpper(lines[i].cmd[0]) == "ELSE" {
+				if strings.ToUpper(s.cmd[0]) != "IF" {
+					return nil, i, fmt.Errorf("line %d: ELSE without IF", lines[i].num)
+				}
+				s.alt, i, err = parseScriptBlock(lines, i+1)
+				if err != nil {
+					return nil, i, err
+				}
+				if i >= len(lines) || strings.ToUpper(lines[i].cmd[0]) != "END" {
+					return nil, i, fmt.Errorf("line %d: IF has no END", s.num)
+				}
+			}
+		}
+
+		block = append(block, s)
+		i++
+	}
+
+	return block, i, nil
+}
+
+// runs the named script file. returns true if the debugger should quit
+func (m *debugger) source(filename string) bool {
+	if m.scriptDepth >= maxScriptDepth {
+		fmt.Println(m.styles.err.Render(
+			fmt.Sprintf("script: too many nested scripts: %s", filename),
+		))
+		m.failures++
+		return false
+	}
+
+	d, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Println(m.styles.err.Render(
+			fmt.Sprintf("script: %s", err.Error()),
+		))
+		m.failures++
+		return false
+	}
+
+	block, err := parseScript(strings.Split(string(d), "\n"))
+	if err != nil {
+		fmt.Println(m.styles.err.Render(
+			fmt.Sprintf("script: %s: %s", filepath.Base(filename), err.Error()),
+		))
+		m.failures++
+		return false
+	}
+
+	m.scriptDepth++
+	defer func() {
+		m.scriptDepth--
+	}()
+
+	quit, err := m.runScript(block)
+	if err != nil {
+		fmt.Println(m.styles.err.Render(
+			fmt.Sprintf("script: %s: %s", filepath.Base(filename), err.Error()),
+		))
+		m.failures++
+	}
+	return quit
+}
+
+// runs a list of statements. returns true if the debugger should quit. an
+// error is returned if the script could not continue
+func (m *debugger) runScript(block []scriptStatement) (bool, error) {
+	for _, s := range block {
+		// a script can be interrupted between statements in the same way as a
+		// running emulation
+		select {
+		case <-m.sig:
+			return false, fmt.Errorf("interrupted")
+		case <-m.endDebugger:
+			return true, nil
+		default:
+		}
+
+		switch strings.ToUpper(s.cmd[0]) {
+		case "IF":
+			ok, err := m.evaluateCondition(s.cmd[1:])
+			if err != nil {
+				return false, fmt.Errorf("line %d: %w", s.num, err)
+			}
+			b := s.body
+			if !ok {
+				b = s.alt
+			}
+			quit, err := m.runScript(b)
+			if quit || err != nil {
+				return quit, err
+			}
+
+		case "WHILE":
+			for {
+				ok, err := m.evaluateCondition(s.cmd[1:])
+				if err != nil {
+					return false, fmt.Errorf("line %d: %w", s.num, err)
+				}
+				if !ok {
+					break // for loop
+				}
+				quit, err := m.runScript(s.body)
+				if quit || err != nil {
+					return quit, err
+				}
+			}
+
+		case "REPEAT":
+			n, err := m.evaluate(s.cmd[1:])
+			if err != nil {
+				return false, fmt.Errorf("line %d: %w", s.num, err)
+			}
+			for range n {
+				quit, err := m.runScript(s.body)
+				if quit || err != nil {
+					return quit, err
+				}
+			}
+
+		default:
+			fmt.Printf("%s> %s\n", m.console.MARIA.Coords.ShortString(), strings.Join(s.cmd, " "))
+			if m.parseCommand(s.cmd) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// parses a number in any of the formats accepted by the debugger. numbers
+// prefixed with $ are hexadecimal
+func parseNumber(s string) (int, error) {
+	if strings.HasPrefix(s, "$") {
+		s = fmt.Sprintf("0x%s", s[1:])
+	}
+	v, err := strconv.ParseInt(s, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("not a number: %s", s)
+	}
+	return int(v), nil
+}
+
+// variable names must begin with a letter and must not be the same as any of
+// the built-in values
+func validVariableName(name string) bool {
+	if name == "" || !unicode.IsLetter(rune(name[0])) {
+		return false
+	}
+	switch strings.ToUpper(name) {
+	case "PEEK", "A", "X", "Y", "SP", "PC", "FRAME", "SCANLINE", "SL", "CLK":
+		return false
+	}
+	return true
+}
+
+// returns the value of a single term in an expression. the number of tokens
+// consumed is also returned
+func (m *debugger) term(tokens []string) (int, int, error) {
+	if len(tokens) == 0 {
+		return 0, 0, fmt.Errorf("value missing")
+	}
+
+	switch strings.ToUpper(tokens[0]) {
+	case "PEEK":
+		if len(tokens) < 2 {
+			return 0, 0, fmt.Errorf("PEEK requires an address")
+		}
+		ma, err := m.parseAddress(tokens[1])
+		if err != nil {
+			return 0, 0, err
+		}
+		data, err := memory.Read(ma.area, ma.idx)
+		if err != nil {
+			return 0, 0, fmt.Errorf("address is not readable: %s", tokens[1])
+		}
+		return int(data), 2, nil
+	case "A":
+		return int(m.console.MC.A.Value()), 1, nil
+	case "X":
+		return int(m.console.MC.X.Value()), 1, nil
+	case "Y":
+		return int(m.console.MC.Y.Value()), 1, nil
+	case "SP":
+		return int(m.console.MC.SP.Value()), 1, nil
+	case "PC":
+		return int(m.console.MC.PC.Value()), 1, nil
+	case "FRAME":
+		return m.console.MARIA.Coords.Frame, 1, nil
+	case "SCANLINE", "SL":
+		return m.console.MARIA.Coords.Scanline, 1, nil
+	case "CLK":
+		return m.console.MARIA.Coords.Clk, 1, nil
+	}
+
+	if v, ok := m.variables[strings.ToLower(tokens[0])]; ok {
+		return v, 1, nil
+	}
+
+	v, err := parseNumber(tokens[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("unknown value: %s", tokens[0])
+	}
+	return v, 1, nil
+}
+
+// evaluates a list of terms separated by the + and - operators
+func (m *debugger) evaluate(tokens []string) (int, error) {
+	v, n, err := m.term(tokens)
+	if err != nil {
+		return 0, err
+	}
+	tokens = tokens[n:]
+
+	for len(tokens) > 0 {
+		op := tokens[0]
+		if op != "+" && op != "-" {
+			return 0, fmt.Errorf("unexpected %s", op)
+		}
+		w, n, err := m.term(tokens[1:])
+		if err != nil {
+			return 0, err
+		}
+		if op == "+" {
+			v += w
+		} else {
+			v -= w
+		}
+		tokens = tokens[n+1:]
+	}
+
+	return v, nil
+}
+
+// evaluates a comparison of two expressions
+func (m *debugger) evaluateCondition(tokens []string) (bool, error) {
+	for i, op := range tokens {
+		switch op {
+		case "==", "!=", "<", "<=", ">", ">=":
+		default:
+			continue // for loop
+		}
+
+		l, err := m.evaluate(tokens[:i])
+		if err != nil {
+			return false, err
+		}
+		r, err := m.evaluate(tokens[i+1:])
+		if err != nil {
+			return false, err
+		}
+
+		switch op {
+		case "==":
+			return l == r, nil
+		case "!=":
+			return l != r, nil
+		case "<":
+			return l < r, nil
+		case "<=":
+			return l <= r, nil
+		case ">":
+			return l > r, nil
+		default:
+			return l >= r, nil
+		}
+	}
+
+	return false, fmt.Errorf("condition has no comparison operator")
+}
+
+// compares the most recently completed frame with the PNG file. if the frame
+// doesn't match then the frame is saved alongside the PNG file so that it can
+// be inspected
+func (m *debugger) expectScreen(filename string) error {
+	frame := m.console.MARIA.CompletedFrame()
+	if frame == nil {
+		return fmt.Errorf("no frame has been completed")
+	}
+
+	cmp := func() error {
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		img, err := png.Decode(f)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+
+		if img.Bounds().Dx() != frame.Bounds().Dx() || img.Bounds().Dy() != frame.Bounds().Dy() {
+			return fmt.Errorf("screen is %dx%d but %s is %dx%d",
+				frame.Bounds().Dx(), frame.Bounds().Dy(), filename,
+				img.Bounds().Dx(), img.Bounds().Dy())
+		}
+
+		var diff int
+		for y := range frame.Bounds().Dy() {
+			for x := range frame.Bounds().Dx() {
+				fr, fg, fb, _ := frame.At(frame.Bounds().Min.X+x, frame.Bounds().Min.Y+y).RGBA()
+				ir, ig, ib, _ := img.At(img.Bounds().Min.X+x, img.Bounds().Min.Y+y).RGBA()
+				if fr != ir || fg != ig || fb != ib {
+					diff++
+				}
+			}
+		}
+		if diff > 0 {
+			return fmt.Errorf("screen differs from %s in %d pixels", filename, diff)
+		}
+		return nil
+	}
+
+	err := cmp()
+	if err == nil {
+		return nil
+	}
+
+	actual := fmt.Sprintf("%s_actual%s", strings.TrimSuffix(filename, filepath.Ext(filename)), filepath.Ext(filename))
+	werr := writePNG(actual, frame)
+	if werr != nil {
+		return fmt.Errorf("%w (and could not save screen: %w)", err, werr)
+	}
+	return fmt.Errorf("%w (screen saved to %s)", err, actual)
+}
+
+func writePNG(filename string, img image.Image) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	err = png.Encode(f, img)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
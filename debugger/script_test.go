@@ -0,0 +1,49 @@
+package debugger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jetsetilly/test7800/test"
+)
+
+// runs the script and returns the number of failures
+func testScript(t *testing.T, m *debugger, script string) int {
+	t.Helper()
+	fn := filepath.Join(t.TempDir(), "test.script")
+	test.DemandSuccess(t, os.WriteFile(fn, []byte(script), 0o644))
+	m.failures = 0
+	test.ExpectFailure(t, m.source(fn))
+	return m.failures
+}
+
+func TestScriptFailures(t *testing.T) {
+	m, _, _ := testCoprocDebugger(t)
+	m.headless = true
+
+	// a script that runs successfully has no failures
+	test.ExpectEquality(t, testScript(t, m, "RUN UNTIL FRAME 2\nASSERT FRAME == 2\n"), 0)
+
+	// an unknown command is a failure
+	test.ExpectEquality(t, testScript(t, m, "NOSUCHCOMMAND\n"), 1)
+
+	// a step rule that is not valid, or that cannot be met, is a failure
+	test.ExpectEquality(t, testScript(t, m, "RUN UNTIL NOSUCHRULE\n"), 1)
+	test.ExpectEquality(t, testScript(t, m, "RUN UNTIL FRAME 1\n"), 1)
+
+	// errors outside of a script are not counted
+	m.failures = 0
+	m.parseCommand([]string{"NOSUCHCOMMAND"})
+	test.ExpectEquality(t, m.failures, 0)
+}
+
+func TestScriptHalted(t *testing.T) {
+	m, _, _ := testCoprocDebugger(t)
+	m.headless = true
+
+	// a breakpoint on the next instruction halts the run before the step rule
+	// is met
+	m.breakpoints[m.console.MC.PC.Address()] = true
+	test.ExpectEquality(t, testScript(t, m, "RUN UNTIL FRAME 2\n"), 1)
+}
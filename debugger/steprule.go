@@ -14,12 +14,12 @@ func (m *debugger) parseStepRule(cmd []string) bool {
 	switch rule {
 	case "BRANCH", "FAIL":
 		if m.console.MC.LastResult.Defn == nil {
-			fmt.Println(m.styles.err.Render("no instructions executed since reset"))
+			m.commandErr("no instructions executed since reset")
 			return false
 		}
 
 		if !m.console.MC.LastResult.Defn.IsBranch() {
-			fmt.Println(m.styles.err.Render("last instruction was not a branch"))
+			m.commandErr("last instruction was not a branch")
 			return false
 		}
 
@@ -35,11 +35,11 @@ func (m *debugger) parseStepRule(cmd []string) bool {
 			var err error
 			tgt, err = strconv.Atoi(cmd[1])
 			if err != nil {
-				fmt.Println(m.styles.err.Render(err.Error()))
+				m.commandErr(err.Error())
 				return false
 			}
 			if tgt <= m.console.MARIA.Coords.Frame {
-				fmt.Println(m.styles.err.Render(fmt.Sprintf("FRAME %d is in the past", tgt)))
+				m.commandErr(fmt.Sprintf("FRAME %d is in the past", tgt))
 				return false
 			}
 		} else {
@@ -55,7 +55,7 @@ func (m *debugger) parseStepRule(cmd []string) bool {
 			var err error
 			sl, err = strconv.Atoi(cmd[1])
 			if err != nil {
-				fmt.Println(m.styles.err.Render(err.Error()))
+				m.commandErr(err.Error())
 				return false
 			}
 		} else {
@@ -117,9 +117,7 @@ func (m *debugger) parseStepRule(cmd []string) bool {
 			}
 		}
 		if !found {
-			fmt.Println(m.styles.err.Render(
-				fmt.Sprintf("STEP %s is unsupported", rule),
-			))
+			m.commandErr(fmt.Sprintf("STEP %s is unsupported", rule))
 			return false
 		}
 		m.stepRule = func() bool {
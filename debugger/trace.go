@@ -0,0 +1,203 @@
+package debugger
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jetsetilly/test7800/debugger/trace"
+)
+
+// tracer writes every executed CPU instruction to a file
+type tracer struct {
+	filename string
+	f        *os.File
+	w        *trace.Writer
+	filter   trace.Filter
+
+	// number of CPU cycles since the trace started
+	cycle uint64
+
+	// bus activity for the current instruction
+	bus []trace.Access
+
+	// number of records written
+	count int
+}
+
+func (m *debugger) traceStart(filename string, filter []string) error {
+	if m.trace != nil {
+		return fmt.Errorf("trace already active: %s", m.trace.filename)
+	}
+
+	flt, err := trace.ParseFilter(filter)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("trace: %w", err)
+	}
+
+	w, err := trace.NewWriter(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	t := &tracer{
+		filename: filename,
+		f:        f,
+		w:        w,
+		filter:   flt,
+	}
+
+	m.console.Mem.BusTrace = func(address uint16, data uint8, write bool) {
+		t.bus = append(t.bus, trace.Access{
+			Address: address,
+			Data:    data,
+			Write:   write,
+		})
+	}
+	m.trace = t
+
+	return nil
+}
+
+func (m *debugger) traceStop() error {
+	if m.trace == nil {
+		return fmt.Errorf("trace is not active")
+	}
+
+	t := m.trace
+	m.trace = nil
+	m.console.Mem.BusTrace = nil
+
+	err := t.w.Flush()
+	if err != nil {
+		t.f.Close()
+		return err
+	}
+
+	err = t.f.Close()
+	if err != nil {
+		return fmt.Errorf("trace: %w", err)
+	}
+
+	fmt.Println(m.styles.debugger.Render(
+		fmt.Sprintf("%d instructions written to %s", t.count, t.filename),
+	))
+	return nil
+}
+
+// called after every CPU step while a trace is active
+func (m *debugger) traceInstruction() error {
+	t := m.trace
+	t.cycle += uint64(m.console.StepCycles)
+
+	res := m.console.MC.LastResult
+	if !res.Final || res.Defn == nil {
+		return nil
+	}
+
+	// bus activity is collected until the instruction is complete
+	defer func() {
+		t.bus = t.bus[:0]
+	}()
+
+	r := trace.Record{
+		Cycle:       t.cycle,
+		Frame:       m.console.MARIA.Coords.Frame,
+		Scanline:    m.console.MARIA.Coords.Scanline,
+		Clk:         m.console.MARIA.Coords.Clk,
+		PC:          res.Address,
+		Opcode:      res.Defn.OpCode,
+		Operand:     res.InstructionData,
+		A:           m.console.MC.A.Value(),
+		X:           m.console.MC.X.Value(),
+		Y:           m.console.MC.Y.Value(),
+		SP:          m.console.MC.SP.Value(),
+		Status:      m.console.MC.Status.Value(),
+		Cycles:      res.Cycles,
+		Stall:       max(m.console.StepCycles-res.Cycles, 0),
+		InInterrupt: res.InInterrupt,
+		Bank:        -1,
+		Bus:         t.bus,
+	}
+
+	if idx, area := m.console.Mem.MapAddress(res.Address, true); area == m.console.Mem.External {
+		if b, ok := m.console.Mem.External.Bank(idx); ok {
+			r.Bank = b
+		}
+	}
+
+	if !t.filter.Match(&r) {
+		return nil
+	}
+
+	t.count++
+	return t.w.Write(r)
+}
+
+// converts a binary trace file to a text file
+func traceConvert(in string, out string) error {
+	r, err := os.Open(in)
+	if err != nil {
+		return fmt.Errorf("trace: %w", err)
+	}
+	defer r.Close()
+
+	w, err := os.Create(out)
+	if err != nil {
+		return fmt.Errorf("trace: %w", err)
+	}
+
+	err = trace.Convert(w, r)
+	if err != nil {
+		w.Close()
+		return err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return fmt.Errorf("trace: %w", err)
+	}
+	return nil
+}
+
+// compares a trace file with a reference file and prints the first divergence.
+// a divergence counts as a failure for the purposes of scripts
+func (m *debugger) traceDiff(ours string, ref string, context int) {
+	o, err := os.Open(ours)
+	if err != nil {
+		fmt.Println(m.styles.err.Render(fmt.Sprintf("trace diff: %s", err.Error())))
+		m.failures++
+		return
+	}
+	defer o.Close()
+
+	r, err := os.Open(ref)
+	if err != nil {
+		fmt.Println(m.styles.err.Render(fmt.Sprintf("trace diff: %s", err.Error())))
+		m.failures++
+		return
+	}
+	defer r.Close()
+
+	d, n, err := trace.Diff(o, r, context)
+	if err != nil {
+		fmt.Println(m.styles.err.Render(err.Error()))
+		m.failures++
+		return
+	}
+
+	if d == nil {
+		fmt.Println(m.styles.debugger.Render(
+			fmt.Sprintf("traces agree for %d entries", n),
+		))
+		return
+	}
+
+	fmt.Println(m.styles.err.Render(d.String()))
+	m.failures++
+}
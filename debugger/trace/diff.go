@@ -0,0 +1,574 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Divergence describes the first point at which two traces differ
+type Divergence struct {
+	// the number of instructions (or bus accesses) after the point at which
+	// the two traces were aligned
+	Position int
+
+	// the name of the value that differed and the value in each trace
+	Field  string
+	Ours   string
+	Theirs string
+
+	// lines from each trace leading up to and including the divergence
+	OursContext   []string
+	TheirsContext []string
+}
+
+func (d *Divergence) String() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "first divergence at position %d: %s is %s but reference is %s\n", d.Position, d.Field, d.Ours, d.Theirs)
+	s.WriteString("test7800\n")
+	for _, l := range d.OursContext {
+		fmt.Fprintf(&s, "  %s\n", l)
+	}
+	s.WriteString("reference\n")
+	for _, l := range d.TheirsContext {
+		fmt.Fprintf(&s, "  %s\n", l)
+	}
+	return strings.TrimSuffix(s.String(), "\n")
+}
+
+// the lines leading up to a divergence
+type contextLines struct {
+	n     int
+	lines []string
+}
+
+func (c *contextLines) push(l string) {
+	c.lines = append(c.lines, l)
+	if len(c.lines) > c.n+1 {
+		c.lines = c.lines[1:]
+	}
+}
+
+// Diff compares a test7800 trace with a reference trace and returns the first
+// divergence. A nil Divergence indicates that the traces agree for the length
+// of the shorter trace. The number of instructions (or bus accesses) compared
+// is also returned.
+//
+// The context argument is the number of lines before the divergence to include
+// in the Divergence.
+//
+// The reference can be one of the following:
+//
+// 1) Another test7800 trace. Every value in the records are compared.
+//
+// 2) A CSV file of executed instructions. The first line of the file names the
+// columns. The PC column is required and the A, X, Y, SP, P and CYCLE columns
+// are optional. Other columns are ignored. Register values are the values
+// before the instruction is executed, as is usual in the logs of other
+// emulators, and the cycle value is the cycle count at the start of the
+// instruction. Values are hexadecimal except for the CYCLE column, which is
+// decimal.
+//
+// 3) A CSV file of bus activity, such as the capture from a logic analyser. The
+// ADDRESS, DATA and RW columns are required. The RW column is either R or W,
+// or the state of the 6502's R/W pin (1 for read and 0 for write). Values are
+// hexadecimal. Consecutive identical reads in both traces are treated as one
+// read because the CPU repeats the same read while RDY is held low.
+//
+// The traces are aligned at the first point in the test7800 trace that matches
+// the first entry in the reference
+func Diff(ours io.Reader, ref io.Reader, context int) (*Divergence, int, error) {
+	tr, err := NewReader(ours)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	br := bufio.NewReader(ref)
+	if hdr, err := br.Peek(len(header)); err == nil && string(hdr[:len(hdr)-1]) == string(header[:len(header)-1]) {
+		rr, err := NewReader(br)
+		if err != nil {
+			return nil, 0, err
+		}
+		return diffTrace(tr, rr, context)
+	}
+
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+
+	cols, err := cr.Read()
+	if err != nil {
+		return nil, 0, fmt.Errorf("trace diff: reference is not a trace or a CSV file")
+	}
+	for i := range cols {
+		cols[i] = strings.ToLower(strings.TrimSpace(cols[i]))
+	}
+
+	if slices.Contains(cols, "pc") {
+		return diffInstructions(tr, newCSVReader(cr, cols), context)
+	}
+	if slices.Contains(cols, "address") && slices.Contains(cols, "data") && slices.Contains(cols, "rw") {
+		return diffBus(tr, newCSVReader(cr, cols), context)
+	}
+
+	return nil, 0, fmt.Errorf("trace diff: CSV file must have a PC column or ADDRESS, DATA and RW columns")
+}
+
+// reads rows from a CSV file as named values
+type csvReader struct {
+	r    *csv.Reader
+	cols []string
+}
+
+type csvRow struct {
+	line   string
+	values map[string]string
+}
+
+func newCSVReader(r *csv.Reader, cols []string) *csvReader {
+	return &csvReader{r: r, cols: cols}
+}
+
+func (cr *csvReader) read() (csvRow, error) {
+	rec, err := cr.r.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return csvRow{}, err
+		}
+		return csvRow{}, fmt.Errorf("trace diff: %w", err)
+	}
+
+	row := csvRow{
+		line:   strings.Join(rec, ","),
+		values: make(map[string]string),
+	}
+	for i, v := range rec {
+		if i < len(cr.cols) {
+			row.values[cr.cols[i]] = strings.TrimSpace(v)
+		}
+	}
+	return row, nil
+}
+
+// returns a hexadecimal value from the row. the boolean result is false if the
+// column is not present
+func (row csvRow) hex(col string) (uint64, bool, error) {
+	s, ok := row.values[col]
+	if !ok || s == "" {
+		return 0, false, nil
+	}
+	n := strings.TrimPrefix(s, "$")
+	n = strings.TrimPrefix(strings.TrimPrefix(n, "0x"), "0X")
+	v, err := strconv.ParseUint(n, 16, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("trace diff: %s is not a hexadecimal value: %s", strings.ToUpper(col), s)
+	}
+	return v, true, nil
+}
+
+func (row csvRow) decimal(col string) (uint64, bool, error) {
+	s, ok := row.values[col]
+	if !ok || s == "" {
+		return 0, false, nil
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("trace diff: %s is not a decimal value: %s", strings.ToUpper(col), s)
+	}
+	return v, true, nil
+}
+
+// compares records from two test7800 traces
+func diffTrace(ours *Reader, ref *Reader, context int) (*Divergence, int, error) {
+	r, err := ref.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, 0, fmt.Errorf("trace diff: reference is empty")
+		}
+		return nil, 0, err
+	}
+
+	// align traces
+	var o Record
+	for {
+		o, err = ours.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, 0, fmt.Errorf("trace diff: cannot align traces. PC %04x not found", r.PC)
+			}
+			return nil, 0, err
+		}
+		if o.PC == r.PC {
+			break // for loop
+		}
+	}
+
+	oc := &contextLines{n: context}
+	rc := &contextLines{n: context}
+
+	for pos := 0; ; pos++ {
+		oc.push(o.String())
+		rc.push(r.String())
+
+		diverge := func(field string, ours string, theirs string) *Divergence {
+			return &Divergence{
+				Position:      pos,
+				Field:         field,
+				Ours:          ours,
+				Theirs:        theirs,
+				OursContext:   oc.lines,
+				TheirsContext: rc.lines,
+			}
+		}
+
+		hex16 := func(v uint16) string { return fmt.Sprintf("%04x", v) }
+		hex8 := func(v uint8) string { return fmt.Sprintf("%02x", v) }
+
+		switch {
+		case o.PC != r.PC:
+			return diverge("PC", hex16(o.PC), hex16(r.PC)), pos, nil
+		case o.Opcode != r.Opcode:
+			return diverge("opcode", hex8(o.Opcode), hex8(r.Opcode)), pos, nil
+		case o.Operand != r.Operand:
+			return diverge("operand", hex16(o.Operand), hex16(r.Operand)), pos, nil
+		case o.A != r.A:
+			return diverge("A", hex8(o.A), hex8(r.A)), pos, nil
+		case o.X != r.X:
+			return diverge("X", hex8(o.X), hex8(r.X)), pos, nil
+		case o.Y != r.Y:
+			return diverge("Y", hex8(o.Y), hex8(r.Y)), pos, nil
+		case o.SP != r.SP:
+			return diverge("SP", hex8(o.SP), hex8(r.SP)), pos, nil
+		case o.Status != r.Status:
+			return diverge("P", hex8(o.Status), hex8(r.Status)), pos, nil
+		case o.Cycles != r.Cycles:
+			return diverge("cycles", strconv.Itoa(o.Cycles), strconv.Itoa(r.Cycles)), pos, nil
+		case o.Stall != r.Stall:
+			return diverge("stall", strconv.Itoa(o.Stall), strconv.Itoa(r.Stall)), pos, nil
+		}
+
+		for i := range max(len(o.Bus), len(r.Bus)) {
+			if i >= len(o.Bus) {
+				return diverge("bus", "nothing", r.Bus[i].String()), pos, nil
+			}
+			if i >= len(r.Bus) {
+				return diverge("bus", o.Bus[i].String(), "nothing"), pos, nil
+			}
+			if o.Bus[i] != r.Bus[i] {
+				return diverge("bus", o.Bus[i].String(), r.Bus[i].String()), pos, nil
+			}
+		}
+
+		o, err = ours.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, pos + 1, nil
+			}
+			return nil, pos, err
+		}
+		r, err = ref.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, pos + 1, nil
+			}
+			return nil, pos, err
+		}
+	}
+}
+
+// compares test7800 trace with a CSV file of instructions
+func diffInstructions(ours *Reader, ref *csvReader, context int) (*Divergence, int, error) {
+	row, err := ref.read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, 0, fmt.Errorf("trace diff: reference is empty")
+		}
+		return nil, 0, err
+	}
+
+	// the registers in the reference are the values before the instruction
+	// is executed. in a test7800 trace these are the values in the previous
+	// record
+	var prev *Record
+
+	// the cycle count at the start of the instruction. test7800 records the
+	// cycle count at the end of the instruction
+	start := func(r Record) uint64 {
+		return r.Cycle - uint64(r.Cycles) - uint64(r.Stall)
+	}
+
+	// align traces
+	pc, ok, err := row.hex("pc")
+	if err != nil {
+		return nil, 0, err
+	}
+	if !ok {
+		return nil, 0, fmt.Errorf("trace diff: first row of reference has no PC value")
+	}
+
+	var o Record
+	for {
+		o, err = ours.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, 0, fmt.Errorf("trace diff: cannot align traces. PC %04x not found", pc)
+			}
+			return nil, 0, err
+		}
+		if uint64(o.PC) == pc {
+			break // for loop
+		}
+		p := o
+		prev = &p
+	}
+
+	oursBase := start(o)
+	refBase, _, err := row.decimal("cycle")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	oc := &contextLines{n: context}
+	rc := &contextLines{n: context}
+
+	for pos := 0; ; pos++ {
+		oc.push(o.String())
+		rc.push(row.line)
+
+		diverge := func(field string, ours string, theirs string) *Divergence {
+			return &Divergence{
+				Position:      pos,
+				Field:         field,
+				Ours:          ours,
+				Theirs:        theirs,
+				OursContext:   oc.lines,
+				TheirsContext: rc.lines,
+			}
+		}
+
+		pc, ok, err := row.hex("pc")
+		if err != nil {
+			return nil, pos, err
+		}
+		if ok && uint64(o.PC) != pc {
+			return diverge("PC", fmt.Sprintf("%04x", o.PC), fmt.Sprintf("%04x", pc)), pos, nil
+		}
+
+		if prev != nil {
+			regs := []struct {
+				col  string
+				name string
+				v    uint8
+			}{
+				{col: "a", name: "A", v: prev.A},
+				{col: "x", name: "X", v: prev.X},
+				{col: "y", name: "Y", v: prev.Y},
+				{col: "sp", name: "SP", v: prev.SP},
+				{col: "p", name: "P", v: prev.Status},
+			}
+			for _, reg := range regs {
+				v, ok, err := row.hex(reg.col)
+				if err != nil {
+					return nil, pos, err
+				}
+				if ok && uint64(reg.v) != v {
+					return diverge(reg.name, fmt.Sprintf("%02x", reg.v), fmt.Sprintf("%02x", v)), pos, nil
+				}
+			}
+		}
+
+		cycle, ok, err := row.decimal("cycle")
+		if err != nil {
+			return nil, pos, err
+		}
+		if ok {
+			oursCycle := start(o) - oursBase
+			refCycle := cycle - refBase
+			if oursCycle != refCycle {
+				return diverge("cycle", strconv.FormatUint(oursCycle, 10), strconv.FormatUint(refCycle, 10)), pos, nil
+			}
+		}
+
+		p := o
+		prev = &p
+
+		o, err = ours.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, pos + 1, nil
+			}
+			return nil, pos, err
+		}
+		row, err = ref.read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, pos + 1, nil
+			}
+			return nil, pos, err
+		}
+	}
+}
+
+// a bus access and the line it was read from
+type busLine struct {
+	acc  Access
+	line string
+}
+
+// produces a stream of bus accesses from a test7800 trace
+type oursBus struct {
+	r       *Reader
+	pending []busLine
+	last    *Access
+}
+
+func (ob *oursBus) next() (busLine, error) {
+	for {
+		for len(ob.pending) > 0 {
+			b := ob.pending[0]
+			ob.pending = ob.pending[1:]
+			if isRepeatedRead(ob.last, b.acc) {
+				continue // for loop
+			}
+			ob.last = &b.acc
+			return b, nil
+		}
+
+		rec, err := ob.r.Read()
+		if err != nil {
+			return busLine{}, err
+		}
+
+		e := rec.instruction()
+		for _, a := range rec.Bus {
+			ob.pending = append(ob.pending, busLine{
+				acc:  a,
+				line: fmt.Sprintf("%s  (%04x %s %s)", a, rec.PC, e.Operator, e.Operand),
+			})
+		}
+	}
+}
+
+// produces a stream of bus accesses from a CSV file
+type refBus struct {
+	r    *csvReader
+	last *Access
+}
+
+func (rb *refBus) next() (busLine, error) {
+	for {
+		row, err := rb.r.read()
+		if err != nil {
+			return busLine{}, err
+		}
+
+		address, _, err := row.hex("address")
+		if err != nil {
+			return busLine{}, err
+		}
+		data, _, err := row.hex("data")
+		if err != nil {
+			return busLine{}, err
+		}
+
+		var write bool
+		switch strings.ToUpper(row.values["rw"]) {
+		case "R", "READ", "1":
+		case "W", "WRITE", "0":
+			write = true
+		default:
+			return busLine{}, fmt.Errorf("trace diff: RW value not recognised: %s", row.values["rw"])
+		}
+
+		a := Access{
+			Address: uint16(address),
+			Data:    uint8(data),
+			Write:   write,
+		}
+		if isRepeatedRead(rb.last, a) {
+			continue // for loop
+		}
+		rb.last = &a
+
+		return busLine{acc: a, line: row.line}, nil
+	}
+}
+
+func isRepeatedRead(last *Access, a Access) bool {
+	return last != nil && !a.Write && *last == a
+}
+
+// compares test7800 trace with a CSV file of bus activity
+func diffBus(ours *Reader, ref *csvReader, context int) (*Divergence, int, error) {
+	ob := &oursBus{r: ours}
+	rb := &refBus{r: ref}
+
+	r, err := rb.next()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, 0, fmt.Errorf("trace diff: reference is empty")
+		}
+		return nil, 0, err
+	}
+
+	// align traces
+	var o busLine
+	for {
+		o, err = ob.next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, 0, fmt.Errorf("trace diff: cannot align traces. %s not found", r.acc)
+			}
+			return nil, 0, err
+		}
+		if o.acc == r.acc {
+			break // for loop
+		}
+	}
+
+	oc := &contextLines{n: context}
+	rc := &contextLines{n: context}
+
+	for pos := 0; ; pos++ {
+		oc.push(o.line)
+		rc.push(r.line)
+
+		if o.acc != r.acc {
+			field := "data"
+			if o.acc.Address != r.acc.Address {
+				field = "address"
+			} else if o.acc.Write != r.acc.Write {
+				field = "RW"
+			}
+			return &Divergence{
+				Position:      pos,
+				Field:         field,
+				Ours:          o.acc.String(),
+				Theirs:        r.acc.String(),
+				OursContext:   oc.lines,
+				TheirsContext: rc.lines,
+			}, pos, nil
+		}
+
+		o, err = ob.next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, pos + 1, nil
+			}
+			return nil, pos, err
+		}
+		r, err = rb.next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, pos + 1, nil
+			}
+			return nil, pos, err
+		}
+	}
+}
@@ -0,0 +1,20 @@
+// Package trace defines the file format used to record the execution of the
+// 6502 in the emulation. Each executed instruction is recorded along with the
+// state of the CPU registers, the television coordinates, the number of cycles
+// taken and the memory accesses made during the instruction.
+//
+// The format is binary and compact. A trace file begins with a short header
+// that identifies the file and the version of the format. The header is
+// followed by a sequence of records, one per instruction. Each record has a
+// fixed length part and a variable length list of bus accesses. All values are
+// little-endian.
+//
+// A trace file can be converted to text with the Convert() function. The text
+// format is intended to be easy to compare with the output of other emulators.
+//
+// Which instructions are recorded can be controlled with a Filter.
+//
+// The Diff() function compares a trace with a reference trace. The reference
+// can be another trace or a CSV file produced by another emulator or by a logic
+// analyser.
+package trace
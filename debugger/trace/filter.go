@@ -0,0 +1,146 @@
+package trace
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Filter decides which records are written to a trace. A record must match
+// all rules in the filter. A filter with no rules matches every record
+type Filter struct {
+	rules []func(r *Record) bool
+	desc  []string
+}
+
+// parse a number in the formats accepted by the debugger. numbers prefixed
+// with $ are hexadecimal
+func parseNumber(s string, bits int) (uint64, error) {
+	n := s
+	if strings.HasPrefix(n, "$") {
+		n = fmt.Sprintf("0x%s", n[1:])
+	}
+	v, err := strconv.ParseUint(n, 0, bits)
+	if err != nil {
+		return 0, fmt.Errorf("not a valid number: %s", s)
+	}
+	return v, nil
+}
+
+// ParseFilter creates a Filter from a list of arguments. Keywords are not case
+// sensitive. The filter rules are:
+//
+//	RANGE <from> <to>    the instruction address is in the range
+//	BANK <n>             the instruction is in the cartridge bank
+//	INTERRUPT            the instruction is executed inside an interrupt
+//	NOINTERRUPT          the instruction is not executed inside an interrupt
+//	FRAME <from> [<to>]  the frame number is in the range
+//
+// Ranges are inclusive. A FRAME rule with no upper limit matches every frame
+// from the lower limit onwards
+func ParseFilter(args []string) (Filter, error) {
+	var f Filter
+
+	for i := 0; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "RANGE":
+			if i+2 >= len(args) {
+				return f, fmt.Errorf("trace filter: RANGE requires two addresses")
+			}
+			from, err := parseNumber(args[i+1], 16)
+			if err != nil {
+				return f, fmt.Errorf("trace filter: %w", err)
+			}
+			to, err := parseNumber(args[i+2], 16)
+			if err != nil {
+				return f, fmt.Errorf("trace filter: %w", err)
+			}
+			if to < from {
+				return f, fmt.Errorf("trace filter: RANGE end is before the start")
+			}
+			f.rules = append(f.rules, func(r *Record) bool {
+				return uint64(r.PC) >= from && uint64(r.PC) <= to
+			})
+			f.desc = append(f.desc, fmt.Sprintf("RANGE $%04x $%04x", from, to))
+			i += 2
+
+		case "BANK":
+			if i+1 >= len(args) {
+				return f, fmt.Errorf("trace filter: BANK requires a bank number")
+			}
+			bank, err := parseNumber(args[i+1], 8)
+			if err != nil {
+				return f, fmt.Errorf("trace filter: %w", err)
+			}
+			f.rules = append(f.rules, func(r *Record) bool {
+				return r.Bank == int(bank)
+			})
+			f.desc = append(f.desc, fmt.Sprintf("BANK %d", bank))
+			i++
+
+		case "INTERRUPT":
+			f.rules = append(f.rules, func(r *Record) bool {
+				return r.InInterrupt
+			})
+			f.desc = append(f.desc, "INTERRUPT")
+
+		case "NOINTERRUPT":
+			f.rules = append(f.rules, func(r *Record) bool {
+				return !r.InInterrupt
+			})
+			f.desc = append(f.desc, "NOINTERRUPT")
+
+		case "FRAME":
+			if i+1 >= len(args) {
+				return f, fmt.Errorf("trace filter: FRAME requires a frame number")
+			}
+			from, err := parseNumber(args[i+1], 32)
+			if err != nil {
+				return f, fmt.Errorf("trace filter: %w", err)
+			}
+			i++
+
+			// the upper limit is optional
+			to := uint64(1<<32 - 1)
+			if i+1 < len(args) {
+				if v, err := parseNumber(args[i+1], 32); err == nil {
+					to = v
+					i++
+				}
+			}
+			if to < from {
+				return f, fmt.Errorf("trace filter: FRAME end is before the start")
+			}
+			f.rules = append(f.rules, func(r *Record) bool {
+				return uint64(r.Frame) >= from && uint64(r.Frame) <= to
+			})
+			if to == 1<<32-1 {
+				f.desc = append(f.desc, fmt.Sprintf("FRAME %d onwards", from))
+			} else {
+				f.desc = append(f.desc, fmt.Sprintf("FRAME %d %d", from, to))
+			}
+
+		default:
+			return f, fmt.Errorf("trace filter: unrecognised rule: %s", args[i])
+		}
+	}
+
+	return f, nil
+}
+
+// Match returns true if the record matches every rule in the filter
+func (f Filter) Match(r *Record) bool {
+	for _, m := range f.rules {
+		if !m(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f Filter) String() string {
+	if len(f.desc) == 0 {
+		return "no filter"
+	}
+	return strings.Join(f.desc, ", ")
+}
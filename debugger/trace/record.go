@@ -0,0 +1,302 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jetsetilly/test7800/disassembly"
+	"github.com/jetsetilly/test7800/hardware/cpu/execution"
+	"github.com/jetsetilly/test7800/hardware/cpu/instructions"
+)
+
+// the header at the start of every trace file. the final byte is the version
+// number of the format
+var header = []byte{'T', '7', '8', '0', '0', 'T', 'R', 'C', 0x01}
+
+// the number of bytes in the fixed length part of a record
+const recordSize = 31
+
+// the number of bytes for each bus access in a record
+const accessSize = 4
+
+// the maximum number of bus accesses that can be recorded for an instruction
+const maxAccesses = 255
+
+// Access is a single read or write on the memory bus. Reads made by MARIA
+// during DMA are included
+type Access struct {
+	Address uint16
+	Data    uint8
+	Write   bool
+}
+
+func (a Access) String() string {
+	if a.Write {
+		return fmt.Sprintf("W:%04x=%02x", a.Address, a.Data)
+	}
+	return fmt.Sprintf("R:%04x=%02x", a.Address, a.Data)
+}
+
+// Record is the trace of a single instruction
+type Record struct {
+	// the number of CPU cycles since the start of the trace, including the
+	// cycles of this instruction
+	Cycle uint64
+
+	// television coordinates at the end of the instruction
+	Frame    int
+	Scanline int
+	Clk      int
+
+	// the address, opcode and operand of the instruction
+	PC      uint16
+	Opcode  uint8
+	Operand uint16
+
+	// the state of the registers at the end of the instruction
+	A      uint8
+	X      uint8
+	Y      uint8
+	SP     uint8
+	Status uint8
+
+	// the number of cycles taken by the instruction and the number of cycles
+	// the CPU was stalled by DMA or WSYNC
+	Cycles int
+	Stall  int
+
+	// the instruction was executed inside an interrupt
+	InInterrupt bool
+
+	// the cartridge bank the instruction was executed from. a value of -1
+	// indicates that the instruction was not executed from a banked address
+	Bank int
+
+	// memory bus accesses made during the instruction
+	Bus []Access
+}
+
+// the instruction of the record in the same form as the disassembly used
+// elsewhere in the debugger
+func (r Record) instruction() *disassembly.Entry {
+	defn := instructions.Definitions[r.Opcode]
+	return disassembly.FormatResult(execution.Result{
+		Defn:            defn,
+		ByteCount:       defn.Bytes,
+		Address:         r.PC,
+		InstructionData: r.Operand,
+		Final:           true,
+	})
+}
+
+// String returns the record as a single line of text. Lines are arranged in
+// columns so that a trace can be compared with a trace from another emulator
+// with the standard diff tools
+func (r Record) String() string {
+	var s strings.Builder
+
+	e := r.instruction()
+	fmt.Fprintf(&s, "%010d f%05d %03d %03d  %04x  %-8s  %-3s %-10s  ", r.Cycle, r.Frame, r.Scanline, r.Clk,
+		r.PC, e.Bytecode, e.Operator, e.Operand)
+	fmt.Fprintf(&s, "A=%02x X=%02x Y=%02x SP=%02x P=%02x  %d+%d", r.A, r.X, r.Y, r.SP, r.Status, r.Cycles, r.Stall)
+
+	if r.Bank >= 0 {
+		fmt.Fprintf(&s, "  b%d", r.Bank)
+	} else {
+		s.WriteString("  --")
+	}
+	if r.InInterrupt {
+		s.WriteString(" !!")
+	} else {
+		s.WriteString("   ")
+	}
+
+	for _, a := range r.Bus {
+		fmt.Fprintf(&s, " %s", a)
+	}
+
+	return s.String()
+}
+
+// Writer writes trace records in the binary format
+type Writer struct {
+	w   *bufio.Writer
+	buf []byte
+}
+
+// NewWriter creates a new Writer and writes the header to the io.Writer
+func NewWriter(w io.Writer) (*Writer, error) {
+	tw := &Writer{
+		w:   bufio.NewWriter(w),
+		buf: make([]byte, 0, recordSize+(accessSize*maxAccesses)),
+	}
+	_, err := tw.w.Write(header)
+	if err != nil {
+		return nil, fmt.Errorf("trace: %w", err)
+	}
+	return tw, nil
+}
+
+// Write a single record. Bus accesses beyond the maximum of 255 are not
+// recorded
+func (tw *Writer) Write(r Record) error {
+	b := tw.buf[:0]
+	b = binary.LittleEndian.AppendUint64(b, r.Cycle)
+	b = binary.LittleEndian.AppendUint32(b, uint32(r.Frame))
+	b = binary.LittleEndian.AppendUint16(b, uint16(r.Scanline))
+	b = binary.LittleEndian.AppendUint16(b, uint16(r.Clk))
+	b = binary.LittleEndian.AppendUint16(b, r.PC)
+	b = append(b, r.Opcode)
+	b = binary.LittleEndian.AppendUint16(b, r.Operand)
+	b = append(b, r.A, r.X, r.Y, r.SP, r.Status)
+	b = append(b, uint8(r.Cycles), uint8(min(r.Stall, 255)))
+
+	var flags uint8
+	if r.InInterrupt {
+		flags |= flagInInterrupt
+	}
+	if r.Bank >= 0 {
+		flags |= flagBanked
+	}
+	b = append(b, flags, uint8(r.Bank))
+
+	n := min(len(r.Bus), maxAccesses)
+	b = append(b, uint8(n))
+	for _, a := range r.Bus[:n] {
+		b = binary.LittleEndian.AppendUint16(b, a.Address)
+		var w uint8
+		if a.Write {
+			w = 1
+		}
+		b = append(b, a.Data, w)
+	}
+
+	_, err := tw.w.Write(b)
+	if err != nil {
+		return fmt.Errorf("trace: %w", err)
+	}
+	return nil
+}
+
+// Flush any buffered data to the underlying io.Writer
+func (tw *Writer) Flush() error {
+	err := tw.w.Flush()
+	if err != nil {
+		return fmt.Errorf("trace: %w", err)
+	}
+	return nil
+}
+
+const (
+	flagInInterrupt = 0x01
+	flagBanked      = 0x02
+)
+
+// Reader reads trace records in the binary format
+type Reader struct {
+	r   *bufio.Reader
+	buf [recordSize]byte
+}
+
+// NewReader creates a new Reader and checks that the header of the trace is
+// correct
+func NewReader(r io.Reader) (*Reader, error) {
+	tr := &Reader{
+		r: bufio.NewReader(r),
+	}
+
+	hdr := make([]byte, len(header))
+	_, err := io.ReadFull(tr.r, hdr)
+	if err != nil {
+		return nil, fmt.Errorf("trace: not a trace file")
+	}
+	if string(hdr[:len(hdr)-1]) != string(header[:len(header)-1]) {
+		return nil, fmt.Errorf("trace: not a trace file")
+	}
+	if hdr[len(hdr)-1] != header[len(header)-1] {
+		return nil, fmt.Errorf("trace: unsupported version (%d)", hdr[len(hdr)-1])
+	}
+
+	return tr, nil
+}
+
+// Read the next record. Returns io.EOF if there are no more records
+func (tr *Reader) Read() (Record, error) {
+	var r Record
+
+	b := tr.buf[:]
+	_, err := io.ReadFull(tr.r, b)
+	if err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return r, fmt.Errorf("trace: truncated record")
+		}
+		return r, err
+	}
+
+	r.Cycle = binary.LittleEndian.Uint64(b[0:])
+	r.Frame = int(binary.LittleEndian.Uint32(b[8:]))
+	r.Scanline = int(binary.LittleEndian.Uint16(b[12:]))
+	r.Clk = int(binary.LittleEndian.Uint16(b[14:]))
+	r.PC = binary.LittleEndian.Uint16(b[16:])
+	r.Opcode = b[18]
+	r.Operand = binary.LittleEndian.Uint16(b[19:])
+	r.A, r.X, r.Y, r.SP, r.Status = b[21], b[22], b[23], b[24], b[25]
+	r.Cycles = int(b[26])
+	r.Stall = int(b[27])
+	r.InInterrupt = b[28]&flagInInterrupt == flagInInterrupt
+	r.Bank = -1
+	if b[28]&flagBanked == flagBanked {
+		r.Bank = int(b[29])
+	}
+
+	n := int(b[30])
+	if n > 0 {
+		acc := make([]byte, n*accessSize)
+		_, err = io.ReadFull(tr.r, acc)
+		if err != nil {
+			return r, fmt.Errorf("trace: truncated record")
+		}
+		r.Bus = make([]Access, n)
+		for i := range r.Bus {
+			o := i * accessSize
+			r.Bus[i].Address = binary.LittleEndian.Uint16(acc[o:])
+			r.Bus[i].Data = acc[o+2]
+			r.Bus[i].Write = acc[o+3] != 0
+		}
+	}
+
+	return r, nil
+}
+
+// Convert a binary trace to text. Each record is written as a single line
+func Convert(w io.Writer, r io.Reader) error {
+	tr, err := NewReader(r)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	for {
+		rec, err := tr.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break // for loop
+			}
+			return err
+		}
+		_, err = fmt.Fprintln(bw, rec.String())
+		if err != nil {
+			return fmt.Errorf("trace: %w", err)
+		}
+	}
+
+	err = bw.Flush()
+	if err != nil {
+		return fmt.Errorf("trace: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package trace_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jetsetilly/test7800/debugger/trace"
+	"github.com/jetsetilly/test7800/test"
+)
+
+func TestRoundTrip(t *testing.T) {
+	recs := []trace.Record{
+		{
+			Cycle: 2, Frame: 1, Scanline: 20, Clk: 300,
+			PC: 0xc000, Opcode: 0xa9, Operand: 0x05,
+			A: 0x05, X: 0x01, Y: 0x02, SP: 0xff, Status: 0x24,
+			Cycles: 2, Stall: 0, Bank: -1,
+			Bus: []trace.Access{
+				{Address: 0xc000, Data: 0xa9},
+				{Address: 0xc001, Data: 0x05},
+			},
+		},
+		{
+			Cycle: 10, Frame: 1, Scanline: 20, Clk: 332,
+			PC: 0x8002, Opcode: 0x8d, Operand: 0x2000,
+			A: 0x05, X: 0x01, Y: 0x02, SP: 0xfd, Status: 0x24,
+			Cycles: 4, Stall: 4, InInterrupt: true, Bank: 3,
+			Bus: []trace.Access{
+				{Address: 0x8002, Data: 0x8d},
+				{Address: 0x8003, Data: 0x00},
+				{Address: 0x8004, Data: 0x20},
+				{Address: 0x2000, Data: 0x05, Write: true},
+			},
+		},
+	}
+
+	var b bytes.Buffer
+	w, err := trace.NewWriter(&b)
+	test.ExpectSuccess(t, err)
+	for _, r := range recs {
+		test.ExpectSuccess(t, w.Write(r))
+	}
+	test.ExpectSuccess(t, w.Flush())
+
+	r, err := trace.NewReader(bytes.NewReader(b.Bytes()))
+	test.ExpectSuccess(t, err)
+	for _, want := range recs {
+		got, err := r.Read()
+		test.ExpectSuccess(t, err)
+		test.ExpectEquality(t, got.String(), want.String())
+	}
+	_, err = r.Read()
+	test.ExpectFailure(t, err)
+
+	var txt strings.Builder
+	err = trace.Convert(&txt, bytes.NewReader(b.Bytes()))
+	test.ExpectSuccess(t, err)
+	lines := strings.Split(strings.TrimSpace(txt.String()), "\n")
+	test.ExpectEquality(t, len(lines), 2)
+	test.ExpectEquality(t, lines[0], "0000000002 f00001 020 300  c000  a9 05     lda #$05        A=05 X=01 Y=02 SP=ff P=24  2+0  --    R:c000=a9 R:c001=05")
+	test.ExpectEquality(t, strings.HasSuffix(lines[1], "4+4  b3 !! R:8002=8d R:8003=00 R:8004=20 W:2000=05"), true, lines[1])
+
+	_, err = trace.NewReader(strings.NewReader("not a trace file"))
+	test.ExpectFailure(t, err)
+}
+
+func TestFilter(t *testing.T) {
+	r := trace.Record{PC: 0xc010, Frame: 50, Bank: 2}
+
+	f, err := trace.ParseFilter(nil)
+	test.ExpectSuccess(t, err)
+	test.ExpectEquality(t, f.Match(&r), true)
+
+	f, err = trace.ParseFilter([]string{"range", "$c000", "$cfff", "BANK", "2", "NOINTERRUPT"})
+	test.ExpectSuccess(t, err)
+	test.ExpectEquality(t, f.Match(&r), true)
+
+	r.InInterrupt = true
+	test.ExpectEquality(t, f.Match(&r), false)
+
+	f, err = trace.ParseFilter([]string{"FRAME", "60"})
+	test.ExpectSuccess(t, err)
+	test.ExpectEquality(t, f.Match(&r), false)
+
+	f, err = trace.ParseFilter([]string{"FRAME", "10", "50", "INTERRUPT"})
+	test.ExpectSuccess(t, err)
+	test.ExpectEquality(t, f.Match(&r), true)
+	test.ExpectEquality(t, f.String(), "FRAME 10 50, INTERRUPT")
+
+	_, err = trace.ParseFilter([]string{"RANGE", "$d000", "$c000"})
+	test.ExpectFailure(t, err)
+	_, err = trace.ParseFilter([]string{"UNKNOWN"})
+	test.ExpectFailure(t, err)
+}
+
+// creates a trace from a small program that loads the accumulator and stores
+// the value. the first instruction is a NOP that the reference traces skip
+func sampleTrace(t *testing.T, stall int) []byte {
+	t.Helper()
+	recs := []trace.Record{
+		{Cycle: 2, PC: 0xc000, Opcode: 0xea, A: 0x00, SP: 0xff, Status: 0x24, Cycles: 2, Bank: -1,
+			Bus: []trace.Access{{Address: 0xc000, Data: 0xea}, {Address: 0xc001, Data: 0xa9}}},
+		{Cycle: 4, PC: 0xc001, Opcode: 0xa9, Operand: 0x05, A: 0x05, SP: 0xff, Status: 0x24, Cycles: 2, Bank: -1,
+			Bus: []trace.Access{{Address: 0xc001, Data: 0xa9}, {Address: 0xc002, Data: 0x05}}},
+		{Cycle: uint64(7 + stall), PC: 0xc003, Opcode: 0x85, Operand: 0x80, A: 0x05, SP: 0xff, Status: 0x24, Cycles: 3, Stall: stall, Bank: -1,
+			Bus: []trace.Access{{Address: 0xc003, Data: 0x85}, {Address: 0xc004, Data: 0x80}, {Address: 0x0080, Data: 0x05, Write: true}}},
+		{Cycle: uint64(9 + stall), PC: 0xc005, Opcode: 0xe8, A: 0x05, X: 0x01, SP: 0xff, Status: 0x24, Cycles: 2, Bank: -1,
+			Bus: []trace.Access{{Address: 0xc005, Data: 0xe8}, {Address: 0xc006, Data: 0x00}}},
+	}
+
+	var b bytes.Buffer
+	w, err := trace.NewWriter(&b)
+	test.DemandSuccess(t, err)
+	for _, r := range recs {
+		test.DemandSuccess(t, w.Write(r))
+	}
+	test.DemandSuccess(t, w.Flush())
+	return b.Bytes()
+}
+
+func TestDiffTrace(t *testing.T) {
+	d, n, err := trace.Diff(bytes.NewReader(sampleTrace(t, 0)), bytes.NewReader(sampleTrace(t, 0)), 2)
+	test.ExpectSuccess(t, err)
+	test.ExpectEquality(t, d == nil, true)
+	test.ExpectEquality(t, n, 4)
+
+	d, _, err = trace.Diff(bytes.NewReader(sampleTrace(t, 0)), bytes.NewReader(sampleTrace(t, 1)), 1)
+	test.ExpectSuccess(t, err)
+	test.DemandEquality(t, d != nil, true)
+	test.ExpectEquality(t, d.Position, 2)
+	test.ExpectEquality(t, d.Field, "stall")
+	test.ExpectEquality(t, len(d.OursContext), 2)
+	test.ExpectEquality(t, len(d.TheirsContext), 2)
+}
+
+func TestDiffInstructions(t *testing.T) {
+	// the reference starts at the second instruction. registers are the
+	// values before the instruction is executed
+	ref := "pc,a,x,y,sp,p,cycle\n" +
+		"c001,00,00,00,ff,24,100\n" +
+		"c003,05,00,00,ff,24,102\n" +
+		"c005,05,00,00,ff,24,105\n"
+
+	d, n, err := trace.Diff(bytes.NewReader(sampleTrace(t, 0)), strings.NewReader(ref), 2)
+	test.ExpectSuccess(t, err)
+	test.ExpectEquality(t, d == nil, true)
+	test.ExpectEquality(t, n, 3)
+
+	// a DMA stall in the test7800 trace shows up as a cycle divergence
+	d, _, err = trace.Diff(bytes.NewReader(sampleTrace(t, 3)), strings.NewReader(ref), 2)
+	test.ExpectSuccess(t, err)
+	test.DemandEquality(t, d != nil, true)
+	test.ExpectEquality(t, d.Position, 2)
+	test.ExpectEquality(t, d.Field, "cycle")
+	test.ExpectEquality(t, d.Ours, "8")
+	test.ExpectEquality(t, d.Theirs, "5")
+
+	ref = "PC,A\nc001,00\nc003,06\n"
+	d, _, err = trace.Diff(bytes.NewReader(sampleTrace(t, 0)), strings.NewReader(ref), 2)
+	test.ExpectSuccess(t, err)
+	test.DemandEquality(t, d != nil, true)
+	test.ExpectEquality(t, d.Field, "A")
+	test.ExpectEquality(t, d.Ours, "05")
+	test.ExpectEquality(t, d.Theirs, "06")
+
+	_, _, err = trace.Diff(bytes.NewReader(sampleTrace(t, 0)), strings.NewReader("pc\nd000\n"), 2)
+	test.ExpectFailure(t, err)
+}
+
+func TestDiffBus(t *testing.T) {
+	// the repeated read of c002 is the CPU being held by RDY
+	ref := "address,data,rw\n" +
+		"c001,a9,1\n" +
+		"c002,05,1\n" +
+		"c002,05,1\n" +
+		"c003,85,1\n" +
+		"c004,80,1\n" +
+		"0080,05,0\n" +
+		"c005,e8,R\n"
+
+	d, n, err := trace.Diff(bytes.NewReader(sampleTrace(t, 0)), strings.NewReader(ref), 2)
+	test.ExpectSuccess(t, err)
+	test.ExpectEquality(t, d == nil, true)
+	test.ExpectEquality(t, n, 6)
+
+	ref = "address,data,rw\nc003,85,R\nc004,80,R\n0080,06,W\n"
+	d, _, err = trace.Diff(bytes.NewReader(sampleTrace(t, 0)), strings.NewReader(ref), 2)
+	test.ExpectSuccess(t, err)
+	test.DemandEquality(t, d != nil, true)
+	test.ExpectEquality(t, d.Position, 2)
+	test.ExpectEquality(t, d.Field, "data")
+	test.ExpectEquality(t, d.Ours, "W:0080=05")
+	test.ExpectEquality(t, d.Theirs, "W:0080=06")
+}
@@ -0,0 +1,469 @@
+// Package bindings maps the keys of the keyboard and the buttons of gamepads
+// to the controls of the emulated console.
+//
+// The bindings are saved to the resources directory in a text file. Each line
+// of the file binds a control to zero or more inputs. A control with no inputs
+// is unbound:
+//
+//	keyboard p1 left A
+//	gamepad p0 a Button0 Button2
+//	keyboard panel select F1
+//
+// Keys are named as they are by ebiten (eg. ArrowLeft, Space, F1) and gamepad
+// buttons are named Button0 to Button31. The gamepad bindings for player zero
+// apply to the first gamepad and the bindings for player one apply to the
+// second gamepad. Gamepad bindings for the panel apply to both gamepads.
+//
+// A line consisting of the word "rom" followed by the MD5 sum of a ROM starts
+// a section of bindings that override the default bindings for that ROM.
+// Lines beginning with a # are ignored.
+package bindings
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/jetsetilly/test7800/gui"
+	"github.com/jetsetilly/test7800/resources"
+)
+
+// the name of the file in the resources directory
+const bindingsFile = "bindings"
+
+// Device is the type of physical input device
+type Device int
+
+// List of valid Device values
+const (
+	Keyboard Device = iota
+	Gamepad
+)
+
+func (d Device) String() string {
+	switch d {
+	case Keyboard:
+		return "keyboard"
+	case Gamepad:
+		return "gamepad"
+	}
+	return "unknown device"
+}
+
+// ParseDevice returns the Device with the name. The name is not case sensitive
+func ParseDevice(name string) (Device, error) {
+	switch strings.ToLower(name) {
+	case "keyboard", "key":
+		return Keyboard, nil
+	case "gamepad", "pad":
+		return Gamepad, nil
+	}
+	return Keyboard, fmt.Errorf("unrecognised device: %s", name)
+}
+
+type controlName struct {
+	name   string
+	action gui.Action
+}
+
+// the controls of each player's joystick
+var stickControls = []controlName{
+	{"left", gui.StickLeft},
+	{"right", gui.StickRight},
+	{"up", gui.StickUp},
+	{"down", gui.StickDown},
+	{"a", gui.StickButtonA},
+	{"b", gui.StickButtonB},
+}
+
+// the controls on the console panel
+var panelControls = []controlName{
+	{"select", gui.Select},
+	{"start", gui.Start},
+	{"pause", gui.Pause},
+	{"p0pro", gui.P0Pro},
+	{"p1pro", gui.P1Pro},
+}
+
+func portName(port gui.Port) string {
+	switch port {
+	case gui.Player0:
+		return "p0"
+	case gui.Player1:
+		return "p1"
+	case gui.Panel:
+		return "panel"
+	}
+	return "unknown"
+}
+
+func controlNames(port gui.Port) []controlName {
+	if port == gui.Panel {
+		return panelControls
+	}
+	return stickControls
+}
+
+// ControlString returns the name of the control in the same form as is accepted
+// by ParseControl()
+func ControlString(c gui.Control) string {
+	for _, n := range controlNames(c.Port) {
+		if n.action == c.Action {
+			return fmt.Sprintf("%s %s", portName(c.Port), n.name)
+		}
+	}
+	return fmt.Sprintf("%s unknown", portName(c.Port))
+}
+
+// ParseControl returns the control with the name. The port is one of P0, P1 or
+// PANEL. The names of the joystick controls are LEFT, RIGHT, UP, DOWN, A and B.
+// The names of the panel controls are SELECT, START, PAUSE, P0PRO and P1PRO. The
+// names are not case sensitive
+func ParseControl(port string, name string) (gui.Control, error) {
+	var c gui.Control
+
+	switch strings.ToLower(port) {
+	case "p0":
+		c.Port = gui.Player0
+	case "p1":
+		c.Port = gui.Player1
+	case "panel":
+		c.Port = gui.Panel
+	default:
+		return c, fmt.Errorf("unrecognised port: %s", port)
+	}
+
+	name = strings.ToLower(name)
+	for _, n := range controlNames(c.Port) {
+		if n.name == name {
+			c.Action = n.action
+			return c, nil
+		}
+	}
+
+	return c, fmt.Errorf("unrecognised control for %s: %s", portName(c.Port), name)
+}
+
+// the binding of a control on a device
+type binding struct {
+	device  Device
+	control gui.Control
+}
+
+func (b binding) String() string {
+	return fmt.Sprintf("%s %s", b.device, ControlString(b.control))
+}
+
+// compare two bindings. used to order bindings in a consistent way
+func (b binding) compare(c binding) int {
+	if b.device != c.device {
+		return int(b.device) - int(c.device)
+	}
+	if b.control.Port != c.control.Port {
+		return int(b.control.Port) - int(c.control.Port)
+	}
+	return int(b.control.Action) - int(c.control.Action)
+}
+
+// returns true if the same input can not be used for both bindings. gamepad
+// bindings for different players are on different gamepads and so do not
+// overlap. gamepad bindings for the panel overlap with both players
+func (b binding) overlaps(c binding) bool {
+	if b.device != c.device {
+		return false
+	}
+	if b.device == Gamepad {
+		return b.control.Port == c.control.Port || b.control.Port == gui.Panel || c.control.Port == gui.Panel
+	}
+	return true
+}
+
+// the inputs bound to each control
+type set map[binding][]string
+
+// bind the inputs to the control, removing them from any other control that
+// they overlap with
+func (s set) bind(b binding, inputs []string) {
+	for o, in := range s {
+		if o == b || !o.overlaps(b) {
+			continue
+		}
+		s[o] = slices.DeleteFunc(in, func(i string) bool {
+			return slices.ContainsFunc(inputs, func(j string) bool {
+				return strings.EqualFold(i, j)
+			})
+		})
+	}
+	s[b] = slices.Clone(inputs)
+}
+
+func (s set) sorted() []binding {
+	return slices.SortedFunc(maps.Keys(s), binding.compare)
+}
+
+// Config is the complete set of bindings, including the bindings for individual
+// ROMs
+type Config struct {
+	defaults set
+
+	// overrides for individual ROMs. keyed by the MD5 sum of the ROM
+	roms map[string]set
+}
+
+// NewConfig returns the default bindings
+func NewConfig() *Config {
+	c := &Config{
+		roms: make(map[string]set),
+	}
+	c.Reset()
+	return c
+}
+
+// Reset the default bindings to the built-in bindings. The bindings for
+// individual ROMs are not affected
+func (c *Config) Reset() {
+	c.defaults = make(set)
+
+	stick := func(d Device, port gui.Port, in [][]string) {
+		for i, n := range stickControls {
+			c.defaults[binding{device: d, control: gui.Control{Port: port, Action: n.action}}] = in[i]
+		}
+	}
+
+	// keyboard player 0 uses the cursor keys and player 1 uses WASD
+	stick(Keyboard, gui.Player0, [][]string{
+		{"ArrowLeft", "Numpad4"},
+		{"ArrowRight", "Numpad6"},
+		{"ArrowUp", "Numpad8"},
+		{"ArrowDown", "Numpad2"},
+		{"Space", "Z"},
+		{"B", "X"},
+	})
+	stick(Keyboard, gui.Player1, [][]string{
+		{"A"},
+		{"D"},
+		{"W"},
+		{"S"},
+		{"Q"},
+		{"E"},
+	})
+
+	for i, k := range []string{"F1", "F2", "F3", "F4", "F5"} {
+		c.defaults[binding{device: Keyboard, control: gui.Control{Port: gui.Panel, Action: panelControls[i].action}}] = []string{k}
+	}
+
+	// the default gamepad bindings are for an XBox 360 style controller
+	for _, port := range []gui.Port{gui.Player0, gui.Player1} {
+		stick(Gamepad, port, [][]string{
+			{"Button14"},
+			{"Button12"},
+			{"Button11"},
+			{"Button13"},
+			{"Button0", "Button2"},
+			{"Button1", "Button3"},
+		})
+	}
+	c.defaults[binding{device: Gamepad, control: gui.Control{Port: gui.Panel, Action: gui.Select}}] = []string{"Button8"}
+	c.defaults[binding{device: Gamepad, control: gui.Control{Port: gui.Panel, Action: gui.Start}}] = []string{"Button7"}
+	c.defaults[binding{device: Gamepad, control: gui.Control{Port: gui.Panel, Action: gui.Pause}}] = []string{"Button6"}
+}
+
+// Bind the inputs to the control on the device. If rom is not empty then the
+// binding only applies to the ROM with that MD5 sum. The inputs are removed from
+// any other control that they were bound to. An empty list of inputs unbinds
+// the control
+func (c *Config) Bind(rom string, device Device, control gui.Control, inputs []string) {
+	b := binding{device: device, control: control}
+	if rom == "" {
+		c.defaults.bind(b, inputs)
+		return
+	}
+
+	s, ok := c.roms[rom]
+	if !ok {
+		s = make(set)
+		c.roms[rom] = s
+	}
+	s.bind(b, inputs)
+}
+
+// ClearROM removes all the bindings for the ROM with the MD5 sum
+func (c *Config) ClearROM(rom string) {
+	delete(c.roms, rom)
+}
+
+// Binding is the inputs bound to a control on a device
+type Binding struct {
+	Device  Device
+	Control gui.Control
+	Inputs  []string
+
+	// the binding is specific to the ROM
+	ROM bool
+}
+
+func (b Binding) String() string {
+	s := fmt.Sprintf("%s %s: %s", b.Device, ControlString(b.Control), strings.Join(b.Inputs, ", "))
+	if b.ROM {
+		s = fmt.Sprintf("%s (rom)", s)
+	}
+	return s
+}
+
+// List returns the bindings that apply to the ROM with the MD5 sum. The rom
+// argument can be empty, in which case the default bindings are returned
+func (c *Config) List(rom string) []Binding {
+	var l []Binding
+	s := c.roms[rom]
+	for _, b := range c.defaults.sorted() {
+		if in, ok := s[b]; ok {
+			l = append(l, Binding{Device: b.device, Control: b.control, Inputs: in, ROM: true})
+		} else {
+			l = append(l, Binding{Device: b.device, Control: b.control, Inputs: c.defaults[b]})
+		}
+	}
+
+	// ROM bindings for controls that have no default binding
+	for _, b := range s.sorted() {
+		if _, ok := c.defaults[b]; !ok {
+			l = append(l, Binding{Device: b.device, Control: b.control, Inputs: s[b], ROM: true})
+		}
+	}
+	slices.SortStableFunc(l, func(a, b Binding) int {
+		return binding{device: a.Device, control: a.Control}.compare(binding{device: b.Device, control: b.Control})
+	})
+
+	return l
+}
+
+// Setup returns the bindings for the ROM with the MD5 sum in the form required
+// by the GUI. The rom argument can be empty, in which case only the default
+// bindings are used
+func (c *Config) Setup(rom string) gui.InputSetup {
+	setup := gui.InputSetup{
+		Keyboard: make(map[string]gui.Control),
+	}
+	for i := range setup.Gamepad {
+		setup.Gamepad[i] = make(map[string]gui.Control)
+	}
+
+	add := func(b binding, inputs []string) {
+		for _, in := range inputs {
+			switch b.device {
+			case Keyboard:
+				setup.Keyboard[in] = b.control
+			case Gamepad:
+				switch b.control.Port {
+				case gui.Player0:
+					setup.Gamepad[0][in] = b.control
+				case gui.Player1:
+					setup.Gamepad[1][in] = b.control
+				default:
+					setup.Gamepad[0][in] = b.control
+					setup.Gamepad[1][in] = b.control
+				}
+			}
+		}
+	}
+
+	// the ROM bindings are added after the default bindings so that an input
+	// in a ROM binding replaces the same input in a default binding
+	s := c.roms[rom]
+	for _, b := range c.defaults.sorted() {
+		if _, ok := s[b]; !ok {
+			add(b, c.defaults[b])
+		}
+	}
+	for _, b := range s.sorted() {
+		add(b, s[b])
+	}
+
+	return setup
+}
+
+// Parse bindings from the text. The bindings replace the existing bindings for
+// the same controls
+func (c *Config) Parse(text string) error {
+	var rom string
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	var ln int
+	for scanner.Scan() {
+		ln++
+		f := strings.Fields(scanner.Text())
+		if len(f) == 0 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+
+		if strings.ToLower(f[0]) == "rom" {
+			if len(f) != 2 {
+				return fmt.Errorf("bindings: line %d: rom requires an MD5 sum", ln)
+			}
+			rom = strings.ToLower(f[1])
+			continue
+		}
+
+		if len(f) < 3 {
+			return fmt.Errorf("bindings: line %d: incomplete binding", ln)
+		}
+		d, err := ParseDevice(f[0])
+		if err != nil {
+			return fmt.Errorf("bindings: line %d: %w", ln, err)
+		}
+		ctrl, err := ParseControl(f[1], f[2])
+		if err != nil {
+			return fmt.Errorf("bindings: line %d: %w", ln, err)
+		}
+		c.Bind(rom, d, ctrl, f[3:])
+	}
+
+	return scanner.Err()
+}
+
+func (c *Config) String() string {
+	var s strings.Builder
+
+	write := func(bs set) {
+		for _, b := range bs.sorted() {
+			s.WriteString(b.String())
+			for _, in := range bs[b] {
+				s.WriteString(" ")
+				s.WriteString(in)
+			}
+			s.WriteString("\n")
+		}
+	}
+
+	write(c.defaults)
+	for _, rom := range slices.Sorted(maps.Keys(c.roms)) {
+		if len(c.roms[rom]) == 0 {
+			continue
+		}
+		fmt.Fprintf(&s, "\nrom %s\n", rom)
+		write(c.roms[rom])
+	}
+
+	return s.String()
+}
+
+// Load the bindings from the resources directory. The default bindings are
+// returned if there is no bindings file
+func Load() (*Config, error) {
+	c := NewConfig()
+	s, err := resources.Read(bindingsFile)
+	if err != nil {
+		return c, err
+	}
+	err = c.Parse(s)
+	if err != nil {
+		return NewConfig(), err
+	}
+	return c, nil
+}
+
+// Save the bindings to the resources directory
+func (c *Config) Save() error {
+	return resources.Write(bindingsFile, c.String())
+}
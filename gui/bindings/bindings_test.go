@@ -0,0 +1,135 @@
+package bindings_test
+
+import (
+	"testing"
+
+	"github.com/jetsetilly/test7800/gui"
+	"github.com/jetsetilly/test7800/gui/bindings"
+	"github.com/jetsetilly/test7800/test"
+)
+
+const rom = "0123456789abcdef0123456789abcdef"
+
+func control(t *testing.T, port string, name string) gui.Control {
+	t.Helper()
+	c, err := bindings.ParseControl(port, name)
+	test.DemandSuccess(t, err)
+	return c
+}
+
+func TestDefaults(t *testing.T) {
+	s := bindings.NewConfig().Setup("")
+
+	test.ExpectEquality(t, s.Keyboard["ArrowLeft"], gui.Control{Port: gui.Player0, Action: gui.StickLeft})
+	test.ExpectEquality(t, s.Keyboard["Space"], gui.Control{Port: gui.Player0, Action: gui.StickButtonA})
+	test.ExpectEquality(t, s.Keyboard["A"], gui.Control{Port: gui.Player1, Action: gui.StickLeft})
+	test.ExpectEquality(t, s.Keyboard["F4"], gui.Control{Port: gui.Panel, Action: gui.P0Pro})
+
+	// each gamepad controls a different player but both can use the panel
+	test.ExpectEquality(t, s.Gamepad[0]["Button0"], gui.Control{Port: gui.Player0, Action: gui.StickButtonA})
+	test.ExpectEquality(t, s.Gamepad[1]["Button0"], gui.Control{Port: gui.Player1, Action: gui.StickButtonA})
+	test.ExpectEquality(t, s.Gamepad[0]["Button7"], gui.Control{Port: gui.Panel, Action: gui.Start})
+	test.ExpectEquality(t, s.Gamepad[1]["Button7"], gui.Control{Port: gui.Panel, Action: gui.Start})
+}
+
+func TestParseControl(t *testing.T) {
+	test.ExpectEquality(t, control(t, "P1", "B"), gui.Control{Port: gui.Player1, Action: gui.StickButtonB})
+	test.ExpectEquality(t, control(t, "panel", "Select"), gui.Control{Port: gui.Panel, Action: gui.Select})
+	test.ExpectEquality(t, bindings.ControlString(control(t, "p0", "up")), "p0 up")
+
+	_, err := bindings.ParseControl("p2", "up")
+	test.ExpectFailure(t, err)
+	_, err = bindings.ParseControl("panel", "up")
+	test.ExpectFailure(t, err)
+	_, err = bindings.ParseDevice("mouse")
+	test.ExpectFailure(t, err)
+}
+
+func TestBind(t *testing.T) {
+	c := bindings.NewConfig()
+
+	// binding a key that is already in use removes it from the other control
+	c.Bind("", bindings.Keyboard, control(t, "p1", "a"), []string{"Space"})
+	s := c.Setup("")
+	test.ExpectEquality(t, s.Keyboard["Space"], gui.Control{Port: gui.Player1, Action: gui.StickButtonA})
+	test.ExpectEquality(t, s.Keyboard["Z"], gui.Control{Port: gui.Player0, Action: gui.StickButtonA})
+	_, ok := s.Keyboard["Q"]
+	test.ExpectFailure(t, ok)
+
+	// the same gamepad button can be used by both players
+	c.Bind("", bindings.Gamepad, control(t, "p0", "b"), []string{"Button5"})
+	s = c.Setup("")
+	test.ExpectEquality(t, s.Gamepad[0]["Button5"], gui.Control{Port: gui.Player0, Action: gui.StickButtonB})
+	_, ok = s.Gamepad[1]["Button5"]
+	test.ExpectFailure(t, ok)
+	test.ExpectEquality(t, s.Gamepad[1]["Button1"], gui.Control{Port: gui.Player1, Action: gui.StickButtonB})
+
+	// unbinding a control
+	c.Bind("", bindings.Keyboard, control(t, "panel", "pause"), nil)
+	s = c.Setup("")
+	_, ok = s.Keyboard["F3"]
+	test.ExpectFailure(t, ok)
+}
+
+func TestROM(t *testing.T) {
+	c := bindings.NewConfig()
+	c.Bind(rom, bindings.Keyboard, control(t, "p0", "a"), []string{"ControlLeft"})
+	c.Bind(rom, bindings.Keyboard, control(t, "p1", "left"), []string{"ArrowLeft"})
+
+	// the ROM bindings replace the default bindings for the same control and
+	// take inputs from the default bindings
+	s := c.Setup(rom)
+	test.ExpectEquality(t, s.Keyboard["ControlLeft"], gui.Control{Port: gui.Player0, Action: gui.StickButtonA})
+	test.ExpectEquality(t, s.Keyboard["ArrowLeft"], gui.Control{Port: gui.Player1, Action: gui.StickLeft})
+	_, ok := s.Keyboard["Space"]
+	test.ExpectFailure(t, ok)
+	_, ok = s.Keyboard["A"]
+	test.ExpectFailure(t, ok)
+
+	// other ROMs are not affected
+	s = c.Setup("")
+	test.ExpectEquality(t, s.Keyboard["Space"], gui.Control{Port: gui.Player0, Action: gui.StickButtonA})
+	test.ExpectEquality(t, s.Keyboard["ArrowLeft"], gui.Control{Port: gui.Player0, Action: gui.StickLeft})
+
+	var n int
+	for _, b := range c.List(rom) {
+		if b.ROM {
+			n++
+		}
+	}
+	test.ExpectEquality(t, n, 2)
+
+	c.ClearROM(rom)
+	s = c.Setup(rom)
+	test.ExpectEquality(t, s.Keyboard["Space"], gui.Control{Port: gui.Player0, Action: gui.StickButtonA})
+}
+
+func TestParse(t *testing.T) {
+	c := bindings.NewConfig()
+	c.Bind("", bindings.Keyboard, control(t, "p1", "up"), []string{"I"})
+	c.Bind(rom, bindings.Gamepad, control(t, "panel", "p0pro"), []string{"Button4"})
+	c.Bind(rom, bindings.Keyboard, control(t, "p0", "down"), nil)
+
+	// the text form of the bindings produces the same bindings when parsed
+	d := bindings.NewConfig()
+	err := d.Parse(c.String())
+	test.DemandSuccess(t, err)
+	test.ExpectEquality(t, d.String(), c.String())
+
+	s := d.Setup(rom)
+	test.ExpectEquality(t, s.Keyboard["I"], gui.Control{Port: gui.Player1, Action: gui.StickUp})
+	test.ExpectEquality(t, s.Gamepad[1]["Button4"], gui.Control{Port: gui.Panel, Action: gui.P0Pro})
+	_, ok := s.Keyboard["ArrowDown"]
+	test.ExpectFailure(t, ok)
+
+	err = d.Parse("# comment\n\nkeyboard p0 left J\n")
+	test.ExpectSuccess(t, err)
+	test.ExpectEquality(t, d.Setup("").Keyboard["J"], gui.Control{Port: gui.Player0, Action: gui.StickLeft})
+
+	err = d.Parse("keyboard p0\n")
+	test.ExpectFailure(t, err)
+	err = d.Parse("joystick p0 left J\n")
+	test.ExpectFailure(t, err)
+	err = d.Parse("rom\n")
+	test.ExpectFailure(t, err)
+}
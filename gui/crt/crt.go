@@ -0,0 +1,153 @@
+// Package crt implements post-processing effects that make the emulated image
+// look like the image on a CRT television.
+//
+// The effects are implemented as a Kage shader for use with ebiten and also
+// as a CPU renderer. The CPU renderer produces the same result as the shader
+// and allows the effects to be tested without a GPU.
+package crt
+
+import (
+	_ "embed"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Shader is the source of the Kage shader. The shader expects the current
+// frame as the first source image and the previous frame as the second
+// source image. The uniforms of the shader are the fields of the Preset
+// type and the position of the image on the destination. They can be set with
+// the Uniforms() function
+//
+//go:embed "crt.kage"
+var Shader []byte
+
+// Preset is the strength of each effect. A value of zero disables the effect.
+// Values are normally between zero and one
+type Preset struct {
+	// curvature of the screen. the corners of the image are lost
+	Curvature float64
+
+	// softening of the image and bleeding of colour caused by composite video
+	Composite float64
+
+	// glow around bright areas of the image
+	Bloom float64
+
+	// blending of the current frame with the previous frame
+	Interlace float64
+
+	// darkening between the scanlines of the image
+	Scanlines float64
+
+	// red, green and blue phosphor stripes
+	Mask float64
+}
+
+// Enabled returns true if any of the effects in the preset are enabled
+func (p Preset) Enabled() bool {
+	return p != Preset{}
+}
+
+func (p Preset) String() string {
+	return fmt.Sprintf("curvature=%.2f,composite=%.2f,bloom=%.2f,interlace=%.2f,scanlines=%.2f,mask=%.2f",
+		p.Curvature, p.Composite, p.Bloom, p.Interlace, p.Scanlines, p.Mask)
+}
+
+// Uniforms returns the preset as uniforms for the shader. The left argument is
+// the x position of the left edge of the image on the destination. The mask
+// is aligned with that edge in the same way as Render() aligns the mask with
+// the left edge of the destination image
+func (p Preset) Uniforms(left float64) map[string]any {
+	return map[string]any{
+		"Left":      float32(left),
+		"Curvature": float32(p.Curvature),
+		"Composite": float32(p.Composite),
+		"Bloom":     float32(p.Bloom),
+		"Interlace": float32(p.Interlace),
+		"Scanlines": float32(p.Scanlines),
+		"Mask":      float32(p.Mask),
+	}
+}
+
+// the named presets
+var presets = map[string]Preset{
+	"NONE": {},
+	"SCANLINES": {
+		Scanlines: 0.5,
+	},
+	"COMPOSITE": {
+		Composite: 1.0,
+		Bloom:     0.1,
+		Interlace: 0.3,
+	},
+	"TV": {
+		Curvature: 0.04,
+		Composite: 0.6,
+		Bloom:     0.15,
+		Scanlines: 0.35,
+		Mask:      0.15,
+	},
+}
+
+// Presets returns the names of the presets that can be used with ParsePreset()
+func Presets() []string {
+	return slices.Sorted(maps.Keys(presets))
+}
+
+// ParsePreset returns the named preset. The name can optionally be followed
+// by a colon and a list of key=value pairs that change the strength of the
+// effects in the preset. For example:
+//
+//	TV:curvature=0.1,mask=0
+//
+// The keys are the names of the fields in the Preset type
+func ParsePreset(s string) (Preset, error) {
+	name, params, _ := strings.Cut(s, ":")
+
+	p, ok := presets[strings.ToUpper(strings.TrimSpace(name))]
+	if !ok {
+		return Preset{}, fmt.Errorf("crt: unrecognised preset: %s", name)
+	}
+
+	params = strings.TrimSpace(params)
+	if params == "" {
+		return p, nil
+	}
+
+	for _, kv := range strings.Split(params, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return Preset{}, fmt.Errorf("crt: parameter should be in the form key=value: %s", kv)
+		}
+
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return Preset{}, fmt.Errorf("crt: value for %s is not a number: %s", k, v)
+		}
+		if f < 0 {
+			return Preset{}, fmt.Errorf("crt: value for %s cannot be negative", k)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(k)) {
+		case "curvature":
+			p.Curvature = f
+		case "composite":
+			p.Composite = f
+		case "bloom":
+			p.Bloom = f
+		case "interlace":
+			p.Interlace = f
+		case "scanlines":
+			p.Scanlines = f
+		case "mask":
+			p.Mask = f
+		default:
+			return Preset{}, fmt.Errorf("crt: unrecognised parameter: %s", k)
+		}
+	}
+
+	return p, nil
+}
@@ -0,0 +1,177 @@
+package crt_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/jetsetilly/test7800/gui/crt"
+	"github.com/jetsetilly/test7800/test"
+)
+
+// a test card of coloured vertical bars
+func testCard(w int, h int) *image.RGBA {
+	cols := []color.RGBA{
+		{R: 255, G: 255, B: 255, A: 255},
+		{R: 255, G: 255, B: 0, A: 255},
+		{R: 0, G: 255, B: 255, A: 255},
+		{R: 0, G: 255, B: 0, A: 255},
+		{R: 255, G: 0, B: 255, A: 255},
+		{R: 255, G: 0, B: 0, A: 255},
+		{R: 0, G: 0, B: 255, A: 255},
+		{R: 0, G: 0, B: 0, A: 255},
+	}
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.SetRGBA(x, y, cols[x*len(cols)/w])
+		}
+	}
+	return img
+}
+
+func solid(w int, h int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestParsePreset(t *testing.T) {
+	for _, n := range crt.Presets() {
+		_, err := crt.ParsePreset(n)
+		test.ExpectSuccess(t, err, n)
+	}
+
+	p, err := crt.ParsePreset("none")
+	test.DemandSuccess(t, err)
+	test.ExpectFailure(t, p.Enabled())
+
+	p, err = crt.ParsePreset("scanlines:scanlines=0.25,mask=0.5")
+	test.DemandSuccess(t, err)
+	test.ExpectSuccess(t, p.Enabled())
+	test.ExpectEquality(t, p.Scanlines, 0.25)
+	test.ExpectEquality(t, p.Mask, 0.5)
+
+	_, err = crt.ParsePreset("unknown")
+	test.ExpectFailure(t, err)
+	_, err = crt.ParsePreset("tv:sharpness=1")
+	test.ExpectFailure(t, err)
+	_, err = crt.ParsePreset("tv:mask")
+	test.ExpectFailure(t, err)
+	_, err = crt.ParsePreset("tv:mask=-1")
+	test.ExpectFailure(t, err)
+}
+
+func TestRenderNone(t *testing.T) {
+	src := testCard(64, 16)
+	dst := image.NewRGBA(src.Bounds())
+	crt.Render(dst, src, nil, crt.Preset{})
+	test.ExpectEquality(t, string(dst.Pix), string(src.Pix))
+
+	// scaling without any effects is the same as nearest neighbour scaling
+	dst = image.NewRGBA(image.Rect(0, 0, 128, 48))
+	crt.Render(dst, src, nil, crt.Preset{})
+	for y := range 48 {
+		for x := range 128 {
+			test.DemandEquality(t, dst.RGBAAt(x, y), src.RGBAAt(x/2, y/3))
+		}
+	}
+}
+
+func TestRenderScanlines(t *testing.T) {
+	src := solid(8, 4, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	dst := image.NewRGBA(image.Rect(0, 0, 8, 16))
+	crt.Render(dst, src, nil, crt.Preset{Scanlines: 0.5})
+
+	// each source row is drawn over four rows of the destination. the middle
+	// rows are brighter than the outer rows
+	for y := 0; y < 16; y += 4 {
+		test.ExpectSuccess(t, dst.RGBAAt(0, y+1).R > dst.RGBAAt(0, y).R)
+		test.ExpectSuccess(t, dst.RGBAAt(0, y+2).R > dst.RGBAAt(0, y+3).R)
+		test.ExpectEquality(t, dst.RGBAAt(0, y), dst.RGBAAt(0, y+3))
+		test.ExpectEquality(t, dst.RGBAAt(0, y+1), dst.RGBAAt(0, y+2))
+	}
+}
+
+func TestRenderMask(t *testing.T) {
+	src := solid(9, 1, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	dst := image.NewRGBA(src.Bounds())
+	crt.Render(dst, src, nil, crt.Preset{Mask: 0.5})
+
+	for x := 0; x < 9; x += 3 {
+		test.ExpectEquality(t, dst.RGBAAt(x, 0), color.RGBA{R: 255, G: 128, B: 128, A: 255})
+		test.ExpectEquality(t, dst.RGBAAt(x+1, 0), color.RGBA{R: 128, G: 255, B: 128, A: 255})
+		test.ExpectEquality(t, dst.RGBAAt(x+2, 0), color.RGBA{R: 128, G: 128, B: 255, A: 255})
+	}
+
+	// the mask is aligned with the left edge of the destination, wherever that
+	// edge is
+	offset := image.NewRGBA(image.Rect(5, 0, 14, 1))
+	crt.Render(offset, src, nil, crt.Preset{Mask: 0.5})
+	for x := range 9 {
+		test.ExpectEquality(t, offset.RGBAAt(x+5, 0), dst.RGBAAt(x, 0))
+	}
+
+	// the shader is given the same edge
+	u := crt.Preset{Mask: 0.5}.Uniforms(5)
+	test.ExpectEquality(t, u["Left"], any(float32(5)))
+}
+
+func TestRenderCurvature(t *testing.T) {
+	src := solid(32, 32, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	dst := image.NewRGBA(src.Bounds())
+	crt.Render(dst, src, nil, crt.Preset{Curvature: 0.2})
+
+	// corners are lost and the centre is unchanged
+	test.ExpectEquality(t, dst.RGBAAt(0, 0), color.RGBA{A: 255})
+	test.ExpectEquality(t, dst.RGBAAt(31, 31), color.RGBA{A: 255})
+	test.ExpectEquality(t, dst.RGBAAt(16, 16), src.RGBAAt(16, 16))
+}
+
+func TestRenderComposite(t *testing.T) {
+	// a single white pixel on a black background
+	src := solid(9, 3, color.RGBA{A: 255})
+	src.SetRGBA(4, 1, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	dst := image.NewRGBA(src.Bounds())
+	crt.Render(dst, src, nil, crt.Preset{Composite: 1.0})
+
+	// the pixel is softened horizontally but not vertically
+	test.ExpectSuccess(t, dst.RGBAAt(4, 1).R < 255)
+	test.ExpectSuccess(t, dst.RGBAAt(3, 1).R > 0)
+	test.ExpectSuccess(t, dst.RGBAAt(5, 1).R > 0)
+	test.ExpectEquality(t, dst.RGBAAt(4, 0), color.RGBA{A: 255})
+	test.ExpectEquality(t, dst.RGBAAt(4, 2), color.RGBA{A: 255})
+}
+
+func TestRenderBloom(t *testing.T) {
+	src := testCard(64, 8)
+	dst := image.NewRGBA(src.Bounds())
+	crt.Render(dst, src, nil, crt.Preset{Bloom: 1.0})
+
+	// bloom never makes the image darker and the black bar next to the blue
+	// bar gains some colour
+	for y := range 8 {
+		for x := range 64 {
+			s := src.RGBAAt(x, y)
+			d := dst.RGBAAt(x, y)
+			test.DemandSuccess(t, d.R >= s.R && d.G >= s.G && d.B >= s.B)
+		}
+	}
+	test.ExpectSuccess(t, dst.RGBAAt(56, 4).B > 0)
+}
+
+func TestRenderInterlace(t *testing.T) {
+	src := solid(4, 4, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	prev := solid(4, 4, color.RGBA{A: 255})
+	dst := image.NewRGBA(src.Bounds())
+	crt.Render(dst, src, prev, crt.Preset{Interlace: 1.0})
+	test.ExpectEquality(t, dst.RGBAAt(2, 2), color.RGBA{R: 128, G: 128, B: 128, A: 255})
+
+	// without a previous frame the image is blended with black
+	crt.Render(dst, src, nil, crt.Preset{Interlace: 1.0})
+	test.ExpectEquality(t, dst.RGBAAt(2, 2), color.RGBA{R: 128, G: 128, B: 128, A: 255})
+}
@@ -0,0 +1,145 @@
+package crt
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// the CPU implementation works with colour values between zero and one in the
+// same way as the shader
+type rgb [3]float64
+
+func (c rgb) add(d rgb) rgb {
+	return rgb{c[0] + d[0], c[1] + d[1], c[2] + d[2]}
+}
+
+func (c rgb) sub(d rgb) rgb {
+	return rgb{c[0] - d[0], c[1] - d[1], c[2] - d[2]}
+}
+
+func (c rgb) mul(d rgb) rgb {
+	return rgb{c[0] * d[0], c[1] * d[1], c[2] * d[2]}
+}
+
+func (c rgb) scale(f float64) rgb {
+	return rgb{c[0] * f, c[1] * f, c[2] * f}
+}
+
+func (c rgb) mix(d rgb, f float64) rgb {
+	return c.scale(1 - f).add(d.scale(f))
+}
+
+func (c rgb) luma() float64 {
+	return c[0]*0.299 + c[1]*0.587 + c[2]*0.114
+}
+
+func grey(v float64) rgb {
+	return rgb{v, v, v}
+}
+
+// returns the colour and alpha of the pixel at the position. positions outside
+// of the image are transparent
+func at(img *image.RGBA, x float64, y float64) (rgb, float64) {
+	if img == nil {
+		return rgb{}, 0
+	}
+	b := img.Bounds()
+	ix := b.Min.X + int(math.Floor(x))
+	iy := b.Min.Y + int(math.Floor(y))
+	if ix < b.Min.X || iy < b.Min.Y || ix >= b.Max.X || iy >= b.Max.Y {
+		return rgb{}, 0
+	}
+	c := img.RGBAAt(ix, iy)
+	return rgb{float64(c.R) / 255, float64(c.G) / 255, float64(c.B) / 255}, float64(c.A) / 255
+}
+
+// Render applies the preset to the src image and draws the result to the dst
+// image. The src image is scaled to fill the dst image. The prev image is the
+// previous frame and can be nil. The result is the same as drawing with the
+// Shader. The mask is aligned with the left edge of the dst image
+func Render(dst *image.RGBA, src *image.RGBA, prev *image.RGBA, p Preset) {
+	db := dst.Bounds()
+	sb := src.Bounds()
+	size := [2]float64{float64(sb.Dx()), float64(sb.Dy())}
+
+	for dy := range db.Dy() {
+		for dx := range db.Dx() {
+			// the position of the centre of the pixel in the destination and the
+			// equivalent position in the source image
+			dstX := float64(dx) + 0.5
+			u := dstX / float64(db.Dx())
+			v := (float64(dy) + 0.5) / float64(db.Dy())
+
+			c := renderPixel(src, prev, p, dstX, u, v, size)
+			dst.SetRGBA(db.Min.X+dx, db.Min.Y+dy, c)
+		}
+	}
+}
+
+func renderPixel(src *image.RGBA, prev *image.RGBA, p Preset, dstX float64, u float64, v float64, size [2]float64) color.RGBA {
+	// curvature of the screen
+	if p.Curvature > 0 {
+		cx := u*2 - 1
+		cy := v*2 - 1
+		cx, cy = cx*(1+p.Curvature*cy*cy), cy*(1+p.Curvature*cx*cx)
+		u = (cx + 1) / 2
+		v = (cy + 1) / 2
+		if u < 0 || u >= 1 || v < 0 || v >= 1 {
+			return color.RGBA{A: 255}
+		}
+	}
+	x := u * size[0]
+	y := v * size[1]
+
+	col, alpha := at(src, x, y)
+
+	// composite video
+	if p.Composite > 0 {
+		l1, _ := at(src, x-1, y)
+		l2, _ := at(src, x-2, y)
+		r1, _ := at(src, x+1, y)
+		r2, _ := at(src, x+2, y)
+		b3 := l1.add(col).add(r1).scale(1.0 / 3)
+		b5 := l2.add(l1).add(col).add(r1).add(r2).scale(1.0 / 5)
+		comp := b5.sub(grey(b5.luma())).add(grey(b3.luma()))
+		col = col.mix(comp, p.Composite)
+	}
+
+	// bloom
+	if p.Bloom > 0 {
+		var b rgb
+		for _, o := range [][2]float64{{-2, 0}, {-1, 0}, {1, 0}, {2, 0}, {0, -1}, {0, 1}} {
+			c, _ := at(src, x+o[0], y+o[1])
+			b = b.add(c)
+		}
+		b = b.scale(1.0 / 6)
+		col = col.add(b.scale(p.Bloom * b.luma()))
+	}
+
+	// blending with the previous frame
+	if p.Interlace > 0 {
+		c, _ := at(prev, x, y)
+		col = col.mix(c, p.Interlace*0.5)
+	}
+
+	// scanlines
+	if p.Scanlines > 0 {
+		_, f := math.Modf(y)
+		d := math.Abs(f-0.5) * 2
+		col = col.scale(1 - p.Scanlines*d*d)
+	}
+
+	// aperture grille
+	if p.Mask > 0 {
+		mv := grey(1 - p.Mask)
+		mv[int(math.Mod(math.Floor(dstX), 3))] = 1
+		col = col.mul(mv)
+	}
+
+	clamp := func(v float64) uint8 {
+		return uint8(math.Round(max(0, min(1, v)) * 255))
+	}
+
+	return color.RGBA{R: clamp(col[0]), G: clamp(col[1]), B: clamp(col[2]), A: clamp(alpha)}
+}
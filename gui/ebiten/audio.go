@@ -38,13 +38,6 @@ func (a *audioPlayer) Read(buf []uint8) (int, error) {
 		return 0, nil
 	}
 
-	const prefetch = 2048
-
-	sz := a.p.BufferedSize()
-	if sz < prefetch {
-		a.r.Nudge()
-	}
-
 	n, err := a.r.Read(buf)
 	if err != nil {
 		return 0, err
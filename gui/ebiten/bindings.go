@@ -0,0 +1,73 @@
+package ebiten
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/jetsetilly/test7800/gui"
+	"github.com/jetsetilly/test7800/logger"
+)
+
+// bindings of keys and gamepad buttons to the emulated controls. the gamepad
+// bindings are indexed by player
+type inputBindings struct {
+	keyboard map[ebiten.Key]gui.Control
+	gamepad  [2]map[ebiten.GamepadButton]gui.Control
+}
+
+// parse the name of a gamepad button. buttons are named Button0 to Button31
+func parseGamepadButton(name string) (ebiten.GamepadButton, error) {
+	var n int
+	_, err := fmt.Sscanf(strings.ToLower(name), "button%d", &n)
+	if err != nil || n < 0 || n > int(ebiten.GamepadButtonMax) {
+		return 0, fmt.Errorf("unexpected gamepad button name: %s", name)
+	}
+	return ebiten.GamepadButton(n), nil
+}
+
+// replace bindings with the input setup. unrecognised key and button names are
+// logged and otherwise ignored
+func (eg *guiEbiten) setBindings(setup gui.InputSetup) {
+	eg.bindings.keyboard = make(map[ebiten.Key]gui.Control)
+	for n, c := range setup.Keyboard {
+		var k ebiten.Key
+		err := k.UnmarshalText([]byte(n))
+		if err != nil {
+			logger.Log(logger.Allow, "gui", fmt.Errorf("bindings: %w", err).Error())
+			continue
+		}
+		eg.bindings.keyboard[k] = c
+	}
+
+	for i := range eg.bindings.gamepad {
+		eg.bindings.gamepad[i] = make(map[ebiten.GamepadButton]gui.Control)
+		for n, c := range setup.Gamepad[i] {
+			b, err := parseGamepadButton(n)
+			if err != nil {
+				logger.Log(logger.Allow, "gui", fmt.Errorf("bindings: %w", err).Error())
+				continue
+			}
+			eg.bindings.gamepad[i][b] = c
+		}
+	}
+}
+
+// returns the input to push to the emulation for the bound control. the pro
+// difficulty switches are toggled when the control is pressed and the state
+// of the switch is sent when the control is released
+func (eg *guiEbiten) boundInput(c gui.Control, pressed bool) (gui.Input, bool) {
+	switch c.Action {
+	case gui.P0Pro, gui.P1Pro:
+		i := 0
+		if c.Action == gui.P1Pro {
+			i = 1
+		}
+		if pressed {
+			eg.proDifficulty[i] = !eg.proDifficulty[i]
+			return gui.Input{}, false
+		}
+		return gui.Input{Port: c.Port, Action: c.Action, Data: eg.proDifficulty[i]}, true
+	}
+	return gui.Input{Port: c.Port, Action: c.Action, Data: pressed}, true
+}
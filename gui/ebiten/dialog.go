@@ -7,12 +7,13 @@ import (
 	"path/filepath"
 
 	"github.com/jetsetilly/dialog"
+	"github.com/jetsetilly/test7800/gui"
 )
 
 func fileRequest(lastSelectedROM string) (string, error) {
 	dlg := dialog.File()
 	dlg = dlg.Title("Select 7800 ROM")
-	dlg = dlg.Filter("7800 Files", "a78", "bin", "elf", "boot")
+	dlg = dlg.Filter("7800 Files", "a78", "bin", "elf", "boot", "zip", "gz")
 	dlg = dlg.Filter("A78 Files Only", "a78")
 	dlg = dlg.Filter("All Files")
 	dlg = dlg.SetStartDir(filepath.Dir(lastSelectedROM))
@@ -26,6 +27,17 @@ func fileRequest(lastSelectedROM string) (string, error) {
 	return filename, nil
 }
 
+// the dialog package has no list dialog so the options are offered one at a
+// time until one is accepted
+func choiceRequest(req gui.ChoiceRequest) string {
+	for _, o := range req.Options {
+		if dialog.Message("%s", o).Title(req.Title).YesNo() {
+			return o
+		}
+	}
+	return ""
+}
+
 func showError(msg string) {
 	dialog.Message("%s", msg).Error()
 }
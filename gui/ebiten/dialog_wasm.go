@@ -2,9 +2,18 @@
 
 package ebiten
 
+import "github.com/jetsetilly/test7800/gui"
+
 func fileRequest(lastSelectedROM string) (string, error) {
 	return lastSelectedROM, nil
 }
 
+func choiceRequest(req gui.ChoiceRequest) string {
+	if len(req.Options) == 0 {
+		return ""
+	}
+	return req.Options[0]
+}
+
 func showError(msg string) {
 }
@@ -3,6 +3,7 @@ package ebiten
 import (
 	"bytes"
 	"fmt"
+	"image"
 	"image/color"
 	"log"
 	"math"
@@ -12,6 +13,8 @@ import (
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/text/v2"
 	"github.com/jetsetilly/test7800/gui"
+	"github.com/jetsetilly/test7800/gui/bindings"
+	"github.com/jetsetilly/test7800/gui/crt"
 	"github.com/jetsetilly/test7800/logger"
 	"github.com/jetsetilly/test7800/version"
 
@@ -50,6 +53,24 @@ type guiEbiten struct {
 	width  int
 	height int
 
+	// post-processing of the main image. the shader is compiled when it is first required
+	crt       crt.Preset
+	crtShader *ebiten.Shader
+
+	// the shader could not be compiled and the CPU implementation of the
+	// post-processing is used instead. the most recent images from the
+	// emulation are kept so that the result can be rendered again if the size
+	// of the window changes
+	crtFallback bool
+	crtMain     *image.RGBA
+	crtPrev     *image.RGBA
+	crtRender   *image.RGBA
+	crtImage    *ebiten.Image
+	crtDirty    bool
+
+	// used in place of the prev image by the crt shader if there is no suitable prev image
+	blank *ebiten.Image
+
 	// a simple counter used to implement a fade-in/fade-out effect for the
 	// debugging cursor
 	cursorFrame int
@@ -62,8 +83,11 @@ type guiEbiten struct {
 	// keeping track of the physical state.
 	proDifficulty [2]bool
 
-	// state of the left analogue stick of the first gamepad
-	gamepadAnalogue [2]float64
+	// bindings of keys and gamepad buttons to emulated controls
+	bindings inputBindings
+
+	// state of the left analogue stick of each player's gamepad
+	gamepadAnalogue [2][2]float64
 
 	// position of mouse cursor on last update
 	mouseX, mouseY int
@@ -98,11 +122,33 @@ func (eg *guiEbiten) Update() error {
 		case eg.g.RequestedFile <- n:
 		default:
 		}
+	case req := <-eg.g.ChoiceRequest:
+		select {
+		case eg.g.RequestedChoice <- choiceRequest(req):
+		default:
+		}
 	case msg := <-eg.g.ErrorDialog:
 		showError(msg)
+	case d := <-eg.g.DisplaySetup:
+		eg.crt = d.CRT
+		eg.crtDirty = true
+	case inp := <-eg.g.InputSetup:
+		eg.setBindings(inp)
 	default:
 	}
 
+	// compile crt shader if required. failure to compile means that the CPU
+	// implementation of the post-processing is used instead
+	if eg.crt.Enabled() && eg.crtShader == nil && !eg.crtFallback {
+		var err error
+		eg.crtShader, err = ebiten.NewShader(crt.Shader)
+		if err != nil {
+			logger.Log(logger.Allow, "gui", fmt.Errorf("crt: %w: using CPU renderer", err).Error())
+			eg.crtFallback = true
+			eg.crtDirty = true
+		}
+	}
+
 	// handle user input
 	err := eg.inputKeyboard()
 	if err != nil {
@@ -178,6 +224,8 @@ func (eg *guiEbiten) Update() error {
 				eg.main = ebiten.NewImage(eg.width, eg.height)
 			}
 			eg.main.WritePixels(img.Main.Pix)
+			eg.crtMain = img.Main
+			eg.crtDirty = true
 		}
 
 		if img.Prev != nil && img.ID != eg.prevID {
@@ -188,6 +236,7 @@ func (eg *guiEbiten) Update() error {
 				eg.prev = ebiten.NewImage(width, height)
 			}
 			eg.prev.WritePixels(img.Prev.Pix)
+			eg.crtPrev = img.Prev
 		}
 
 		if img.Overlay != nil {
@@ -203,16 +252,8 @@ func (eg *guiEbiten) Update() error {
 	return nil
 }
 
-func (eg *guiEbiten) Draw(screen *ebiten.Image) {
-	defer func() {
-		if eg.showInfo {
-			var opts text.DrawOptions
-			opts.GeoM.Translate(10, 10)
-			text.Draw(screen, fmt.Sprintf("%s", time.Since(eg.lastFrame)), eg.overlayFont, &opts)
-		}
-		eg.lastFrame = time.Now()
-	}()
-
+// the scaling and translation required to draw the emulation image in the window
+func (eg *guiEbiten) transform() (float64, float64, float64, float64) {
 	const aspectBias = 0.93
 
 	var scaling float64
@@ -230,6 +271,43 @@ func (eg *guiEbiten) Draw(screen *ebiten.Image) {
 	translateX := (float64(eg.geom.w) - (float64(eg.width) * scalingX)) / 2
 	translateY := (float64(eg.geom.h) - (float64(eg.height) * scalingY)) / 2
 
+	return scalingX, scalingY, translateX, translateY
+}
+
+// converts window coordinates to coordinates in the emulation image. returns
+// false if the coordinates are outside of the image
+func (eg *guiEbiten) imagePosition(x int, y int) (int, int, bool) {
+	if eg.width == 0 || eg.height == 0 {
+		return 0, 0, false
+	}
+	scalingX, scalingY, translateX, translateY := eg.transform()
+	ix := int((float64(x) - translateX) / scalingX)
+	iy := int((float64(y) - translateY) / scalingY)
+	if ix < 0 || iy < 0 || ix >= eg.width || iy >= eg.height {
+		return 0, 0, false
+	}
+	return ix, iy, true
+}
+
+func (eg *guiEbiten) Draw(screen *ebiten.Image) {
+	// Draw() is called once for every refresh of the display. the emulation
+	// uses this signal in vsync mode
+	select {
+	case eg.g.VSync <- true:
+	default:
+	}
+
+	defer func() {
+		if eg.showInfo {
+			var opts text.DrawOptions
+			opts.GeoM.Translate(10, 10)
+			text.Draw(screen, fmt.Sprintf("%s", time.Since(eg.lastFrame)), eg.overlayFont, &opts)
+		}
+		eg.lastFrame = time.Now()
+	}()
+
+	scalingX, scalingY, translateX, translateY := eg.transform()
+
 	if eg.main != nil {
 		if eg.prev != nil {
 			var op ebiten.DrawImageOptions
@@ -242,7 +320,11 @@ func (eg *guiEbiten) Draw(screen *ebiten.Image) {
 			op.ColorScale.SetA(1.0)
 			screen.DrawImage(eg.prev, &op)
 		}
-		if eg.main != nil {
+		if eg.crt.Enabled() && eg.crtShader != nil {
+			eg.drawCRT(screen, scalingX, scalingY, translateX, translateY)
+		} else if eg.crt.Enabled() && eg.crtFallback {
+			eg.drawCRTFallback(screen, scalingX, scalingY, translateX, translateY)
+		} else if eg.main != nil {
 			var op ebiten.DrawImageOptions
 			op.GeoM.Scale(scalingX, scalingY)
 			op.GeoM.Translate(translateX, translateY)
@@ -272,6 +354,80 @@ func (eg *guiEbiten) Draw(screen *ebiten.Image) {
 	}
 }
 
+// draws the main image with the crt shader. the result is the same as crt.Render()
+func (eg *guiEbiten) drawCRT(screen *ebiten.Image, scalingX, scalingY, translateX, translateY float64) {
+	w := float32(eg.width)
+	h := float32(eg.height)
+	x0 := float32(translateX)
+	y0 := float32(translateY)
+	x1 := float32(translateX + float64(eg.width)*scalingX)
+	y1 := float32(translateY + float64(eg.height)*scalingY)
+
+	vertices := []ebiten.Vertex{
+		{DstX: x0, DstY: y0, SrcX: 0, SrcY: 0, ColorR: 1, ColorG: 1, ColorB: 1, ColorA: 1},
+		{DstX: x1, DstY: y0, SrcX: w, SrcY: 0, ColorR: 1, ColorG: 1, ColorB: 1, ColorA: 1},
+		{DstX: x0, DstY: y1, SrcX: 0, SrcY: h, ColorR: 1, ColorG: 1, ColorB: 1, ColorA: 1},
+		{DstX: x1, DstY: y1, SrcX: w, SrcY: h, ColorR: 1, ColorG: 1, ColorB: 1, ColorA: 1},
+	}
+	indices := []uint16{0, 1, 2, 1, 2, 3}
+
+	// the previous frame is blended with the main image by the interlace effect. if there is no
+	// previous frame of the same size then a black image is used
+	prev := eg.prev
+	if prev == nil || prev.Bounds() != eg.main.Bounds() {
+		if eg.blank == nil || eg.blank.Bounds() != eg.main.Bounds() {
+			eg.blank = ebiten.NewImage(eg.width, eg.height)
+			eg.blank.Fill(color.Black)
+		}
+		prev = eg.blank
+	}
+
+	var op ebiten.DrawTrianglesShaderOptions
+	op.Uniforms = eg.crt.Uniforms(translateX)
+	op.Images[0] = eg.main
+	op.Images[1] = prev
+	op.Blend = ebiten.BlendSourceOver
+	screen.DrawTrianglesShader(vertices, indices, eg.crtShader, &op)
+}
+
+// draws the main image with crt.Render(). used when the crt shader cannot be
+// compiled. the image is only rendered when it has changed or when the size of
+// the window has changed
+func (eg *guiEbiten) drawCRTFallback(screen *ebiten.Image, scalingX, scalingY, translateX, translateY float64) {
+	if eg.crtMain == nil {
+		return
+	}
+
+	w := int(math.Round(float64(eg.width) * scalingX))
+	h := int(math.Round(float64(eg.height) * scalingY))
+	if w <= 0 || h <= 0 {
+		return
+	}
+
+	if eg.crtRender == nil || eg.crtRender.Bounds().Dx() != w || eg.crtRender.Bounds().Dy() != h {
+		eg.crtRender = image.NewRGBA(image.Rect(0, 0, w, h))
+		eg.crtImage = ebiten.NewImage(w, h)
+		eg.crtDirty = true
+	}
+
+	if eg.crtDirty {
+		// the previous frame is only used if it is the same size as the main
+		// image. this is the same as the shader, which uses a black image
+		prev := eg.crtPrev
+		if prev != nil && prev.Bounds() != eg.crtMain.Bounds() {
+			prev = nil
+		}
+		crt.Render(eg.crtRender, eg.crtMain, prev, eg.crt)
+		eg.crtImage.WritePixels(eg.crtRender.Pix)
+		eg.crtDirty = false
+	}
+
+	var op ebiten.DrawImageOptions
+	op.GeoM.Translate(translateX, translateY)
+	op.Blend = ebiten.BlendSourceOver
+	screen.DrawImage(eg.crtImage, &op)
+}
+
 func (eg *guiEbiten) Layout(width, height int) (int, int) {
 	eg.geom.x, eg.geom.y = ebiten.WindowPosition()
 	eg.geom.w = width
@@ -307,6 +463,7 @@ func Launch(endGui <-chan bool, g *gui.ChannelsGUI, update func() error) error {
 		lastFrame: time.Now(),
 		update:    update,
 	}
+	eg.setBindings(bindings.NewConfig().Setup(""))
 
 	// loop to service requests until the first state change. (the main service loop is in the
 	// Update() function)
@@ -331,6 +488,11 @@ func Launch(endGui <-chan bool, g *gui.ChannelsGUI, update func() error) error {
 			case g.RequestedFile <- n:
 			default:
 			}
+		case req := <-g.ChoiceRequest:
+			select {
+			case g.RequestedChoice <- choiceRequest(req):
+			default:
+			}
 		case msg := <-eg.g.ErrorDialog:
 			showError(msg)
 		}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"io/fs"
 	"math"
+	"slices"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
@@ -53,46 +54,61 @@ func (eg *guiEbiten) inputDragAndDrop() error {
 	return nil
 }
 
+// returns the IDs of the gamepads used by each player. the first connected
+// gamepad is used by player zero and the second by player one
+func gamepadIDs() []ebiten.GamepadID {
+	ids := ebiten.AppendGamepadIDs(nil)
+	slices.Sort(ids)
+	if len(ids) > 2 {
+		ids = ids[:2]
+	}
+	return ids
+}
+
 func (eg *guiEbiten) inputGamepadAxis() error {
-	const gamepad = 0
 	const deadzone = 0.25
 
-	// left and right direction of the stick
-	v := ebiten.GamepadAxis(gamepad, 0)
-	if eg.gamepadAnalogue[0] != 0 && v <= deadzone && v >= -deadzone {
-		// stick is in the deadzone so make sure left/right input is nullified
-		for _, v := range []gui.Input{{Action: gui.StickLeft, Data: false}, {Action: gui.StickRight, Data: false}} {
-			eg.pushInput(v)
-		}
+	for player, gamepad := range gamepadIDs() {
+		port := gui.Port(player)
+		analogue := &eg.gamepadAnalogue[player]
 
-		// all values in the deadzone are reduced to zero
-		eg.gamepadAnalogue[0] = 0
+		// left and right direction of the stick
+		v := ebiten.GamepadAxis(gamepad, 0)
+		if analogue[0] != 0 && v <= deadzone && v >= -deadzone {
+			// stick is in the deadzone so make sure left/right input is nullified
+			for _, v := range []gui.Input{{Port: port, Action: gui.StickLeft, Data: false}, {Port: port, Action: gui.StickRight, Data: false}} {
+				eg.pushInput(v)
+			}
 
-	} else if v != eg.gamepadAnalogue[0] {
-		if v < -deadzone {
-			eg.pushInput(gui.Input{Action: gui.StickLeft, Data: true})
-			eg.gamepadAnalogue[0] = v
-		} else if v > deadzone {
-			eg.pushInput(gui.Input{Action: gui.StickRight, Data: true})
-			eg.gamepadAnalogue[0] = v
-		}
-	}
+			// all values in the deadzone are reduced to zero
+			analogue[0] = 0
 
-	// up and down direction of the stick
-	v = ebiten.GamepadAxis(gamepad, 1)
-	if eg.gamepadAnalogue[1] != 0 && v <= deadzone && v >= -deadzone {
-		for _, v := range []gui.Input{{Action: gui.StickUp, Data: false}, {Action: gui.StickDown, Data: false}} {
-			eg.pushInput(v)
+		} else if v != analogue[0] {
+			if v < -deadzone {
+				eg.pushInput(gui.Input{Port: port, Action: gui.StickLeft, Data: true})
+				analogue[0] = v
+			} else if v > deadzone {
+				eg.pushInput(gui.Input{Port: port, Action: gui.StickRight, Data: true})
+				analogue[0] = v
+			}
 		}
-		eg.gamepadAnalogue[1] = 0
-
-	} else if v != eg.gamepadAnalogue[1] {
-		if v < -deadzone {
-			eg.pushInput(gui.Input{Action: gui.StickUp, Data: true})
-			eg.gamepadAnalogue[1] = v
-		} else if v > deadzone {
-			eg.pushInput(gui.Input{Action: gui.StickDown, Data: true})
-			eg.gamepadAnalogue[1] = v
+
+		// up and down direction of the stick
+		v = ebiten.GamepadAxis(gamepad, 1)
+		if analogue[1] != 0 && v <= deadzone && v >= -deadzone {
+			for _, v := range []gui.Input{{Port: port, Action: gui.StickUp, Data: false}, {Port: port, Action: gui.StickDown, Data: false}} {
+				eg.pushInput(v)
+			}
+			analogue[1] = 0
+
+		} else if v != analogue[1] {
+			if v < -deadzone {
+				eg.pushInput(gui.Input{Port: port, Action: gui.StickUp, Data: true})
+				analogue[1] = v
+			} else if v > deadzone {
+				eg.pushInput(gui.Input{Port: port, Action: gui.StickDown, Data: true})
+				analogue[1] = v
+			}
 		}
 	}
 
@@ -100,71 +116,27 @@ func (eg *guiEbiten) inputGamepadAxis() error {
 }
 
 func (eg *guiEbiten) inputGamepad() error {
-	var pressed []ebiten.GamepadButton
-	var released []ebiten.GamepadButton
-	pressed = inpututil.AppendJustPressedGamepadButtons(0, pressed)
-	released = inpututil.AppendJustReleasedGamepadButtons(0, released)
-
-	var inp gui.Input
-
-	for _, p := range released {
-		switch p {
-		// d-pad
-		case ebiten.GamepadButton14:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickLeft, Data: false}
-		case ebiten.GamepadButton12:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickRight, Data: false}
-		case ebiten.GamepadButton11:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickUp, Data: false}
-		case ebiten.GamepadButton13:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickDown, Data: false}
-
-		// fire buttons
-		case ebiten.GamepadButton0, ebiten.GamepadButton2:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickButtonA, Data: false}
-		case ebiten.GamepadButton1, ebiten.GamepadButton3:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickButtonB, Data: false}
-
-		// control
-		case ebiten.GamepadButton8: // xbox button
-			inp = gui.Input{Port: gui.Panel, Action: gui.Select, Data: false}
-		case ebiten.GamepadButton6: // back button
-			inp = gui.Input{Port: gui.Panel, Action: gui.Pause, Data: false}
-		case ebiten.GamepadButton7: // start button
-			inp = gui.Input{Port: gui.Panel, Action: gui.Start, Data: false}
+	for player, gamepad := range gamepadIDs() {
+		var pressed []ebiten.GamepadButton
+		var released []ebiten.GamepadButton
+		pressed = inpututil.AppendJustPressedGamepadButtons(gamepad, pressed)
+		released = inpututil.AppendJustReleasedGamepadButtons(gamepad, released)
+
+		for _, r := range released {
+			if c, ok := eg.bindings.gamepad[player][r]; ok {
+				if inp, ok := eg.boundInput(c, false); ok {
+					eg.pushInput(inp)
+				}
+			}
 		}
 
-		eg.pushInput(inp)
-	}
-
-	for _, p := range pressed {
-		switch p {
-		// d-pad
-		case ebiten.GamepadButton14:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickLeft, Data: true}
-		case ebiten.GamepadButton12:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickRight, Data: true}
-		case ebiten.GamepadButton11:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickUp, Data: true}
-		case ebiten.GamepadButton13:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickDown, Data: true}
-
-		// fire buttons
-		case ebiten.GamepadButton0, ebiten.GamepadButton2:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickButtonA, Data: true}
-		case ebiten.GamepadButton1, ebiten.GamepadButton3:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickButtonB, Data: true}
-
-		// control
-		case ebiten.GamepadButton8: // xbox button
-			inp = gui.Input{Port: gui.Panel, Action: gui.Select, Data: true}
-		case ebiten.GamepadButton6: // back button
-			inp = gui.Input{Port: gui.Panel, Action: gui.Pause, Data: true}
-		case ebiten.GamepadButton7: // start button
-			inp = gui.Input{Port: gui.Panel, Action: gui.Start, Data: true}
+		for _, p := range pressed {
+			if c, ok := eg.bindings.gamepad[player][p]; ok {
+				if inp, ok := eg.boundInput(c, true); ok {
+					eg.pushInput(inp)
+				}
+			}
 		}
-
-		eg.pushInput(inp)
 	}
 
 	return nil
@@ -176,72 +148,52 @@ func (eg *guiEbiten) inputKeyboard() error {
 	pressed = inpututil.AppendJustPressedKeys(pressed)
 	released = inpututil.AppendJustReleasedKeys(released)
 
-	var inp gui.Input
-
-	for _, p := range released {
-		switch p {
+	for _, r := range released {
+		switch r {
 		case ebiten.KeyEscape:
 			return ebiten.Termination
-		case ebiten.KeyArrowLeft, ebiten.KeyNumpad4:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickLeft, Data: false}
-		case ebiten.KeyArrowRight, ebiten.KeyNumpad6:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickRight, Data: false}
-		case ebiten.KeyArrowUp, ebiten.KeyNumpad8:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickUp, Data: false}
-		case ebiten.KeyArrowDown, ebiten.KeyNumpad2:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickDown, Data: false}
-		case ebiten.KeySpace, ebiten.KeyZ:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickButtonA, Data: false}
-		case ebiten.KeyB, ebiten.KeyX:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickButtonB, Data: false}
-		case ebiten.KeyF1:
-			inp = gui.Input{Port: gui.Panel, Action: gui.Select, Data: false}
-		case ebiten.KeyF2:
-			inp = gui.Input{Port: gui.Panel, Action: gui.Start, Data: false}
-		case ebiten.KeyF3:
-			inp = gui.Input{Port: gui.Panel, Action: gui.Pause, Data: false}
-		case ebiten.KeyF4:
-			inp = gui.Input{Port: gui.Panel, Action: gui.P0Pro, Data: eg.proDifficulty[0]}
-		case ebiten.KeyF5:
-			inp = gui.Input{Port: gui.Panel, Action: gui.P1Pro, Data: eg.proDifficulty[1]}
+
+		case ebiten.KeyTab:
+			eg.pushInput(gui.Input{Port: gui.Undefined, Action: gui.Turbo, Data: false})
+		case ebiten.KeyF6:
+			eg.pushInput(gui.Input{Port: gui.Undefined, Action: gui.Speed, Data: 0})
+		case ebiten.KeyF8:
+			eg.pushInput(gui.Input{Port: gui.Undefined, Action: gui.Speed, Data: -1})
+		case ebiten.KeyF9:
+			eg.pushInput(gui.Input{Port: gui.Undefined, Action: gui.Speed, Data: 1})
+		case ebiten.KeyF10:
+			if eg.state == gui.StatePaused {
+				eg.pushInput(gui.Input{Port: gui.Undefined, Action: gui.FrameAdvance})
+			}
 
 		case ebiten.KeyF7:
 			eg.showInfo = !eg.showInfo
 		case ebiten.KeyF11:
 			eg.geom.fullScreen = !eg.geom.fullScreen
 			ebiten.SetFullscreen(eg.geom.fullScreen)
+		case ebiten.KeyF12:
+			eg.screenshot()
+
+		default:
+			if c, ok := eg.bindings.keyboard[r]; ok {
+				if inp, ok := eg.boundInput(c, false); ok {
+					eg.pushInput(inp)
+				}
+			}
 		}
-
-		eg.pushInput(inp)
 	}
 
-	for _, r := range pressed {
-		switch r {
-		case ebiten.KeyArrowLeft, ebiten.KeyNumpad4:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickLeft, Data: true}
-		case ebiten.KeyArrowRight, ebiten.KeyNumpad6:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickRight, Data: true}
-		case ebiten.KeyArrowUp, ebiten.KeyNumpad8:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickUp, Data: true}
-		case ebiten.KeyArrowDown, ebiten.KeyNumpad2:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickDown, Data: true}
-		case ebiten.KeySpace, ebiten.KeyZ:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickButtonA, Data: true}
-		case ebiten.KeyB, ebiten.KeyX:
-			inp = gui.Input{Port: gui.Player0, Action: gui.StickButtonB, Data: true}
-		case ebiten.KeyF1:
-			inp = gui.Input{Port: gui.Panel, Action: gui.Select, Data: true}
-		case ebiten.KeyF2:
-			inp = gui.Input{Port: gui.Panel, Action: gui.Start, Data: true}
-		case ebiten.KeyF3:
-			inp = gui.Input{Port: gui.Panel, Action: gui.Pause, Data: true}
-		case ebiten.KeyF4:
-			eg.proDifficulty[0] = !eg.proDifficulty[0]
-		case ebiten.KeyF5:
-			eg.proDifficulty[1] = !eg.proDifficulty[1]
+	for _, p := range pressed {
+		switch p {
+		case ebiten.KeyTab:
+			eg.pushInput(gui.Input{Port: gui.Undefined, Action: gui.Turbo, Data: true})
+		default:
+			if c, ok := eg.bindings.keyboard[p]; ok {
+				if inp, ok := eg.boundInput(c, true); ok {
+					eg.pushInput(inp)
+				}
+			}
 		}
-
-		eg.pushInput(inp)
 	}
 
 	return nil
@@ -276,6 +228,16 @@ func (eg *guiEbiten) inputMouse() error {
 	}
 
 	if !eg.mouseCaptured {
+		// selecting a point in the image while the emulation is paused sends the position to the
+		// debugger for inspection
+		if eg.state == gui.StatePaused && isCursorInWindow() && inpututil.IsMouseButtonJustPressed(ebiten.MouseButton0) {
+			if x, y, ok := eg.imagePosition(ebiten.CursorPosition()); ok {
+				eg.pushInput(gui.Input{Port: gui.Undefined, Action: gui.Inspect, Data: gui.InspectData{
+					X: x,
+					Y: y,
+				}})
+			}
+		}
 		return nil
 	}
 
@@ -0,0 +1,43 @@
+package ebiten
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"time"
+
+	"github.com/jetsetilly/test7800/logger"
+)
+
+// saves the main image to a PNG file in the current directory. the image is
+// saved at the native resolution of the emulation and without any CRT effects
+func (eg *guiEbiten) screenshot() {
+	if eg.main == nil {
+		return
+	}
+
+	img := image.NewRGBA(eg.main.Bounds())
+	eg.main.ReadPixels(img.Pix)
+
+	filename := fmt.Sprintf("test7800_%s.png", time.Now().Format("20060102_150405"))
+
+	err := func() error {
+		f, err := os.Create(filename)
+		if err != nil {
+			return err
+		}
+		err = png.Encode(f, img)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}()
+	if err != nil {
+		logger.Log(logger.Allow, "screenshot", err.Error())
+		return
+	}
+
+	logger.Logf(logger.Allow, "screenshot", "saved to %s", filename)
+}
@@ -3,6 +3,8 @@ package gui
 import (
 	"image"
 	"io"
+
+	"github.com/jetsetilly/test7800/gui/crt"
 )
 
 type Image struct {
@@ -28,7 +30,6 @@ const (
 
 type AudioReader interface {
 	io.Reader
-	Nudge()
 }
 
 type AudioSetup struct {
@@ -36,11 +37,22 @@ type AudioSetup struct {
 	Read AudioReader
 }
 
+// DisplaySetup changes how the emulation image is presented
+type DisplaySetup struct {
+	CRT crt.Preset
+}
+
 type Blob struct {
 	Filename string
 	Data     []uint8
 }
 
+// ChoiceRequest asks the user to choose one of the options
+type ChoiceRequest struct {
+	Title   string
+	Options []string
+}
+
 type Channels struct {
 	SetImage  chan Image
 	UserInput chan Input
@@ -52,11 +64,27 @@ type Channels struct {
 	// AudioSetup should be nil if the emulation is to have no audio
 	AudioSetup chan AudioSetup
 
+	DisplaySetup chan DisplaySetup
+
+	// InputSetup replaces the bindings of keys and gamepad buttons. the gui
+	// should use the default bindings until it receives an InputSetup
+	InputSetup chan InputSetup
+
+	// the gui sends a signal every time the display is refreshed. used to pace
+	// the emulation in vsync mode
+	VSync chan bool
+
 	// gui receives a string (last selected file) over the FileRequest channel
 	// and returns result over the RequestedFile channel
 	FileRequest   chan string
 	RequestedFile chan string
 
+	// gui receives a list of options over the ChoiceRequest channel and
+	// returns the chosen option over the RequestedChoice channel. the empty
+	// string means that no option was chosen
+	ChoiceRequest   chan ChoiceRequest
+	RequestedChoice chan string
+
 	// display an error message
 	ErrorDialog chan string
 }
@@ -67,9 +95,15 @@ type ChannelsGUI struct {
 	Blob          chan<- Blob
 	State         <-chan State
 	AudioSetup    <-chan AudioSetup
+	DisplaySetup  <-chan DisplaySetup
+	InputSetup    <-chan InputSetup
+	VSync         chan<- bool
 	FileRequest   <-chan string
 	RequestedFile chan<- string
 	ErrorDialog   <-chan string
+
+	ChoiceRequest   <-chan ChoiceRequest
+	RequestedChoice chan<- string
 }
 
 type ChannelsDebugger struct {
@@ -78,9 +112,15 @@ type ChannelsDebugger struct {
 	Blob          <-chan Blob
 	State         chan<- State
 	AudioSetup    chan<- AudioSetup
+	DisplaySetup  chan<- DisplaySetup
+	InputSetup    chan<- InputSetup
+	VSync         <-chan bool
 	FileRequest   chan<- string
 	RequestedFile <-chan string
 	ErrorDialog   chan<- string
+
+	ChoiceRequest   chan<- ChoiceRequest
+	RequestedChoice <-chan string
 }
 
 func (c *Channels) GUI() *ChannelsGUI {
@@ -90,9 +130,15 @@ func (c *Channels) GUI() *ChannelsGUI {
 		Blob:          c.Blob,
 		State:         c.State,
 		AudioSetup:    c.AudioSetup,
+		DisplaySetup:  c.DisplaySetup,
+		InputSetup:    c.InputSetup,
+		VSync:         c.VSync,
 		FileRequest:   c.FileRequest,
 		RequestedFile: c.RequestedFile,
 		ErrorDialog:   c.ErrorDialog,
+
+		ChoiceRequest:   c.ChoiceRequest,
+		RequestedChoice: c.RequestedChoice,
 	}
 }
 
@@ -103,9 +149,15 @@ func (c *Channels) Debugger() *ChannelsDebugger {
 		Blob:          c.Blob,
 		State:         c.State,
 		AudioSetup:    c.AudioSetup,
+		DisplaySetup:  c.DisplaySetup,
+		InputSetup:    c.InputSetup,
+		VSync:         c.VSync,
 		FileRequest:   c.FileRequest,
 		RequestedFile: c.RequestedFile,
 		ErrorDialog:   c.ErrorDialog,
+
+		ChoiceRequest:   c.ChoiceRequest,
+		RequestedChoice: c.RequestedChoice,
 	}
 }
 
@@ -116,8 +168,14 @@ func NewChannels() *Channels {
 		Blob:          make(chan Blob, 1),
 		State:         make(chan State, 1),
 		AudioSetup:    make(chan AudioSetup, 1),
+		DisplaySetup:  make(chan DisplaySetup, 1),
+		InputSetup:    make(chan InputSetup, 1),
+		VSync:         make(chan bool, 1),
 		FileRequest:   make(chan string, 1),
 		RequestedFile: make(chan string, 1),
 		ErrorDialog:   make(chan string, 1),
+
+		ChoiceRequest:   make(chan ChoiceRequest, 1),
+		RequestedChoice: make(chan string, 1),
 	}
 }
@@ -33,6 +33,28 @@ type TrakballMoveData struct {
 	DeltaY int
 }
 
+// Control is a control of the emulated console. It is identified by the port
+// and the action that is sent to the emulation when the control is used
+type Control struct {
+	Port   Port
+	Action Action
+}
+
+// InputSetup maps the keys of the keyboard and the buttons of gamepads to the
+// controls of the emulated console. The maps are keyed by the name of the key
+// or button. The gamepad maps are indexed by player
+type InputSetup struct {
+	Keyboard map[string]Control
+	Gamepad  [2]map[string]Control
+}
+
+// InspectData is the position in the emulation image that has been selected
+// by the user
+type InspectData struct {
+	X int
+	Y int
+}
+
 const (
 	Nothing Action = iota
 
@@ -56,4 +78,21 @@ const (
 
 	TrakballFire // bool
 	TrakballMove // TrakballMove
+
+	// sent when the user selects a position in the image while the emulation
+	// is paused. not forwarded to the emulated hardware
+	Inspect // InspectData
+
+	// change the speed of the emulation. a positive value selects a faster
+	// speed, a negative value a slower speed and zero the normal speed. not
+	// forwarded to the emulated hardware
+	Speed // int
+
+	// the emulation runs as fast as possible while the value is true. not
+	// forwarded to the emulated hardware
+	Turbo // bool
+
+	// advance the emulation to the end of the next frame while the emulation is
+	// paused. not forwarded to the emulated hardware
+	FrameAdvance // nil
 )
@@ -65,6 +65,9 @@ type Map struct {
 	// some ARM architectures allow misaligned accesses for some instructions
 	MisalignedAccesses bool
 
+	// the clock speed of the ARM in the cartridge, in MHz
+	Clock float32
+
 	// list of memory regions
 	Regions map[string]*MemoryRegion
 
@@ -135,6 +138,7 @@ func NewMap(cart CartArchitecture) Map {
 	case Harmony:
 		mmap.ARMArchitecture = ARM7TDMI
 		mmap.MisalignedAccesses = false
+		mmap.Clock = 70
 
 		mmap.Regions["Flash"] = &MemoryRegion{
 			Name:    "Flash",
@@ -196,6 +200,7 @@ func NewMap(cart CartArchitecture) Map {
 	case PlusCart:
 		mmap.ARMArchitecture = ARMv7_M
 		mmap.MisalignedAccesses = true
+		mmap.Clock = 168
 
 		mmap.Regions["Flash"] = &MemoryRegion{
 			Name:    "Flash",
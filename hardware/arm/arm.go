@@ -221,6 +221,10 @@ type ARM struct {
 	// state of the ARM. saveable and restorable
 	state *ARMState
 
+	// preferences for the ARM emulation. the values used by the emulation are
+	// updated on every call to run()
+	prefs *Preferences
+
 	// updated on every call to run()
 	abortOnMemoryFault bool
 
@@ -313,10 +317,16 @@ type ARM struct {
 	breakpointsEnabled bool
 }
 
-// NewARM is the preferred method of initialisation for the ARM type.
-func NewARM(mmap architecture.Map, mem SharedMemory, hook CartridgeHook) *ARM {
+// NewARM is the preferred method of initialisation for the ARM type. The
+// default preferences are used if prefs is nil
+func NewARM(mmap architecture.Map, prefs *Preferences, mem SharedMemory, hook CartridgeHook) *ARM {
+	if prefs == nil {
+		prefs = NewPreferences()
+	}
+
 	arm := &ARM{
 		mmap:           mmap,
+		prefs:          prefs,
 		mem:            mem,
 		hook:           hook,
 		clkLen:         make([]clkLen, len(mmap.Regions)+1),
@@ -383,6 +393,12 @@ func (arm *ARM) ProcessorID() string {
 	return string(arm.mmap.ARMArchitecture)
 }
 
+// Preferences returns the preferences used by the ARM. Changes to the
+// preferences take effect the next time the ARM program is run
+func (arm *ARM) Preferences() *Preferences {
+	return arm.prefs
+}
+
 // ImmediateMode returns whether the most recent execution was in immediate mode
 // or not.
 func (arm *ARM) ImmediateMode() bool {
@@ -438,12 +454,11 @@ func (arm *ARM) resetRegisters() {
 // updatePrefs should be called periodically to ensure that the current
 // preference values are being used in the ARM emulation
 func (arm *ARM) updatePrefs() {
-	// THIS FUNCTION SETS CONSTANT VALUES FOR WHAT SHOULD BE PREFERENCE VALUES.
-	// IN THE ORIGINAL GOPHER2600 VERSION THIS WAS CONNECTED TO A PROPER
-	// PREFERENCE SYSTEM. THAT'S MISSING FOR NOW IN TEST7800
-
 	// update clock value from preferences
-	arm.Clk = 70.00
+	arm.Clk = float32(arm.prefs.Clock.Get())
+	if arm.Clk == ClockDriver {
+		arm.Clk = arm.mmap.Clock
+	}
 
 	// update clkLen entries
 	for _, r := range arm.mmap.Regions {
@@ -462,12 +477,12 @@ func (arm *ARM) updatePrefs() {
 	}
 
 	// get clock regulator from preferences
-	arm.cycleRegulator = 1.0
+	arm.cycleRegulator = float32(arm.prefs.CycleRegulator.Get())
 
-	arm.state.mam.updatePrefs()
+	arm.state.mam.updatePrefs(arm.prefs.MAM.Get())
 
 	// set cycle counting functions
-	arm.immediateMode = true
+	arm.immediateMode = arm.prefs.Immediate.Get()
 	if arm.immediateMode {
 		arm.Icycle = arm.iCycle_Stub
 		arm.Scycle = arm.sCycle_Stub
@@ -487,7 +502,7 @@ func (arm *ARM) updatePrefs() {
 		}
 	}
 
-	arm.abortOnMemoryFault = false
+	arm.abortOnMemoryFault = arm.prefs.AbortOnMemoryFault.Get()
 }
 
 func (arm *ARM) String() string {
@@ -959,14 +974,6 @@ func (arm *ARM) run() (coprocessor.CoProcYield, float32) {
 				arm.stackProtectCheckSP()
 			}
 		}
-
-		// handle memory access yields. we don't these want these to bleed out
-		// of the ARM unless the abort preference is set
-		if arm.state.yield.Type == coprocessor.YieldMemoryFault {
-			if !arm.abortOnMemoryFault {
-				arm.resetYield()
-			}
-		}
 	}
 
 	// cycles are stretched by the cycle regulator
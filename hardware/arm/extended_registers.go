@@ -134,6 +134,19 @@ func (arm *ARM) CoreRegisters() [NumCoreRegisters]uint32 {
 	return arm.state.registers
 }
 
+// StatusRegister returns the condition flags of the status register. The
+// flags are in the top bits of the value as they would be in the CPSR/APSR
+// register. Other bits in the value are always zero
+func (arm *ARM) StatusRegister() uint32 {
+	return arm.state.status.value()
+}
+
+// SetStatusRegister sets the condition flags of the status register. Only the
+// condition flags are affected
+func (arm *ARM) SetStatusRegister(v uint32) {
+	arm.state.status.load(v)
+}
+
 // Register implements the coprocess.CartCoProc interface. Returns the value in
 // the register. Returns false if the requested register is not recognised
 func (arm *ARM) Register(register int) (uint32, bool) {
@@ -52,8 +52,14 @@ func newMam(mmap architecture.Map) mam {
 	}
 }
 
-func (m *mam) updatePrefs() {
-	m.mamcr = m.mmap.PreferredMAMCR
+// update MAM from the preference value. the preference is either MAMDriver or
+// a value for the MAMCR register
+func (m *mam) updatePrefs(pref int) {
+	if pref == MAMDriver {
+		m.mamcr = m.mmap.PreferredMAMCR
+	} else {
+		m.setMAMCR(architecture.MAMCR(pref))
+	}
 	m.mamtim = 4.0
 }
 
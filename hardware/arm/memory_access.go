@@ -369,3 +369,24 @@ func (arm *ARM) Peek(addr uint32) (uint32, bool) {
 	}
 	return arm.byteOrder.Uint32((*mem)[addr:]), true
 }
+
+// PeekByte implements the coprocessor.CoProc interface
+func (arm *ARM) PeekByte(addr uint32) (uint8, bool) {
+	mem, origin := arm.mem.MapAddress(addr, false, false)
+	addr -= origin
+	if mem == nil || addr >= uint32(len(*mem)) {
+		return 0, false
+	}
+	return (*mem)[addr], true
+}
+
+// PokeByte implements the coprocessor.CoProc interface
+func (arm *ARM) PokeByte(addr uint32, value uint8) bool {
+	mem, origin := arm.mem.MapAddress(addr, true, false)
+	addr -= origin
+	if mem == nil || addr >= uint32(len(*mem)) {
+		return false
+	}
+	(*mem)[addr] = value
+	return true
+}
@@ -22,9 +22,26 @@ import (
 	"github.com/jetsetilly/test7800/coprocessor/faults"
 )
 
-func (arm *ARM) memoryFault(event string, fault faults.Category, addr uint32) {
+// returns the policy for the memory fault. the policy is decided by the
+// developer interface if it is available, otherwise by the AbortOnMemoryFault
+// preference. a program memory fault always aborts
+func (arm *ARM) faultPolicy(event string, fault faults.Category, instructionAddr uint32, accessAddr uint32) faults.Policy {
+	policy := faults.Log
+	if arm.abortOnMemoryFault {
+		policy = faults.Abort
+	}
 	if arm.dev != nil {
-		arm.dev.MemoryFault(event, fault, arm.state.instructionPC, addr)
+		policy = arm.dev.MemoryFault(event, fault, instructionAddr, accessAddr)
+	}
+	if fault == faults.ProgramMemory {
+		return faults.Abort
+	}
+	return policy
+}
+
+func (arm *ARM) memoryFault(event string, fault faults.Category, addr uint32) {
+	if !arm.faultPolicy(event, fault, arm.state.instructionPC, addr).Stops() {
+		return
 	}
 
 	arm.state.yield.Type = coprocessor.YieldMemoryFault
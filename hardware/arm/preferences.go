@@ -0,0 +1,65 @@
+package arm
+
+import (
+	"github.com/jetsetilly/test7800/prefs"
+)
+
+// MAMDriver is the value of the MAM preference that selects the MAM mode
+// preferred by the cartridge driver. Other values of the preference are the
+// same as the values of the MAMCR register
+const MAMDriver = -1
+
+// ClockDriver is the value of the clock preference that selects the clock speed
+// of the cartridge hardware
+const ClockDriver = 0
+
+// Preferences for the ARM emulation. The preferences are applied every time the
+// ARM starts running
+type Preferences struct {
+	// the speed of the ARM in MHz. either ClockDriver or a speed
+	Clock *prefs.Float
+
+	// stretches (or shrinks) the number of cycles used by each instruction. a
+	// value of 1.0 is neutral
+	CycleRegulator *prefs.Float
+
+	// the MAM mode. either MAMDriver or a MAMCR value
+	MAM *prefs.Int
+
+	// in immediate mode the ARM does not count cycles. instructions are
+	// executed as quickly as possible
+	Immediate *prefs.Bool
+
+	// memory faults stop the execution of the ARM program. if the value is false
+	// then the fault is logged and execution continues. the preference is only
+	// used when there is no developer interface to decide the fault policy
+	AbortOnMemoryFault *prefs.Bool
+}
+
+// NewPreferences returns the default ARM preferences
+func NewPreferences() *Preferences {
+	return &Preferences{
+		Clock:              prefs.NewFloat(ClockDriver, ClockDriver, 1000.0),
+		CycleRegulator:     prefs.NewFloat(1.0, 0.5, 2.0),
+		MAM:                prefs.NewInt(MAMDriver, MAMDriver, 2),
+		Immediate:          prefs.NewBool(true),
+		AbortOnMemoryFault: prefs.NewBool(false),
+	}
+}
+
+// Add the preferences to the disk. Keys are prefixed with "arm."
+func (p *Preferences) Add(dsk *prefs.Disk) error {
+	for k, v := range map[string]prefs.Value{
+		"arm.clock":          p.Clock,
+		"arm.cycleregulator": p.CycleRegulator,
+		"arm.mam":            p.MAM,
+		"arm.immediate":      p.Immediate,
+		"arm.abortonfault":   p.AbortOnMemoryFault,
+	} {
+		err := dsk.Add(k, v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
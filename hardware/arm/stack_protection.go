@@ -22,6 +22,19 @@ import (
 	"github.com/jetsetilly/test7800/coprocessor/faults"
 )
 
+// a stack error is only reported once. whether the ARM yields depends on the
+// policy for stack errors
+func (arm *ARM) stackFault(err error) {
+	arm.state.stackHasErrors = true
+
+	if !arm.faultPolicy(err.Error(), faults.StackError, arm.state.executingPC, arm.state.registers[rSP]).Stops() {
+		return
+	}
+
+	arm.state.yield.Type = coprocessor.YieldMemoryFault
+	arm.state.yield.Error = err
+}
+
 func (arm *ARM) stackProtectCheckSP() {
 	// do nothing if stack has already collided
 	if arm.state.stackHasErrors {
@@ -32,12 +45,10 @@ func (arm *ARM) stackProtectCheckSP() {
 	stackMemory, stackOrigin := arm.mem.MapAddress(arm.state.registers[rSP], true, false)
 
 	if stackMemory == nil {
-		arm.state.yield.Type = coprocessor.YieldMemoryFault
-		arm.state.yield.Error = fmt.Errorf("illegal stack address (%08x)", arm.state.registers[rSP])
+		arm.stackFault(fmt.Errorf("illegal stack address (%08x)", arm.state.registers[rSP]))
 
 	} else if stackMemory == arm.state.programMemory {
-		arm.state.yield.Type = coprocessor.YieldMemoryFault
-		arm.state.yield.Error = fmt.Errorf("stack is in program memory (%08x)", arm.state.registers[rSP])
+		arm.stackFault(fmt.Errorf("stack is in program memory (%08x)", arm.state.registers[rSP]))
 
 	} else if arm.state.protectVariableMemTop {
 		// return is stack and variable memory blocks are different
@@ -51,17 +62,8 @@ func (arm *ARM) stackProtectCheckSP() {
 			return
 		}
 
-		arm.state.yield.Type = coprocessor.YieldMemoryFault
-		arm.state.yield.Error = fmt.Errorf("stack collides (SP %08x) with variables (memtop %08x)",
-			arm.state.registers[rSP], arm.state.variableMemtop)
-	} else {
-		return
-	}
-
-	arm.state.stackHasErrors = true
-
-	if arm.dev != nil {
-		arm.dev.MemoryFault(arm.state.yield.Error.Error(), faults.StackError, arm.state.executingPC, arm.state.registers[rSP])
+		arm.stackFault(fmt.Errorf("stack collides (SP %08x) with variables (memtop %08x)",
+			arm.state.registers[rSP], arm.state.variableMemtop))
 	}
 }
 
@@ -73,16 +75,6 @@ func (arm *ARM) stackProtectCheckProgramMemory() {
 
 	stackMemory, _ := arm.mem.MapAddress(arm.state.registers[rSP], true, false)
 	if stackMemory == arm.state.programMemory {
-		arm.state.yield.Type = coprocessor.YieldMemoryFault
-		arm.state.yield.Error = fmt.Errorf("stack is in program memory (%08x)", arm.state.registers[rSP])
-		arm.state.stackHasErrors = true
-	} else {
-		return
-	}
-
-	arm.state.stackHasErrors = true
-
-	if arm.dev != nil {
-		arm.dev.MemoryFault(arm.state.yield.Error.Error(), faults.StackError, arm.state.executingPC, arm.state.registers[rSP])
+		arm.stackFault(fmt.Errorf("stack is in program memory (%08x)", arm.state.registers[rSP]))
 	}
 }
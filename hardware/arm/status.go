@@ -79,6 +79,36 @@ func (sr status) String() string {
 	return s.String()
 }
 
+// the flags as they appear in the top five bits of the CPSR/APSR register
+func (sr status) value() uint32 {
+	var v uint32
+	if sr.negative {
+		v |= 0x80000000
+	}
+	if sr.zero {
+		v |= 0x40000000
+	}
+	if sr.carry {
+		v |= 0x20000000
+	}
+	if sr.overflow {
+		v |= 0x10000000
+	}
+	if sr.saturation {
+		v |= 0x08000000
+	}
+	return v
+}
+
+// set flags from the top five bits of a CPSR/APSR value
+func (sr *status) load(v uint32) {
+	sr.negative = v&0x80000000 == 0x80000000
+	sr.zero = v&0x40000000 == 0x40000000
+	sr.carry = v&0x20000000 == 0x20000000
+	sr.overflow = v&0x10000000 == 0x10000000
+	sr.saturation = v&0x08000000 == 0x08000000
+}
+
 func (sr *status) reset() {
 	sr.negative = false
 	sr.zero = false
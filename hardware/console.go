@@ -10,6 +10,7 @@ import (
 	"github.com/jetsetilly/test7800/hardware/peripherals"
 	"github.com/jetsetilly/test7800/hardware/peripherals/savekey"
 	"github.com/jetsetilly/test7800/hardware/riot"
+	"github.com/jetsetilly/test7800/hardware/spec"
 	"github.com/jetsetilly/test7800/hardware/tia"
 	"github.com/jetsetilly/test7800/logger"
 )
@@ -44,8 +45,21 @@ type Console struct {
 	// ticking the TIA/RIOT on every other maria cycle
 	clkDiv bool
 
+	// the speed of MARIA in MHz. the speed of the CPU is derived from this
+	// value depending on the number of MARIA cycles in the CPU cycle
+	mariaClock float32
+
 	// frame limiter
 	limit *limiter
+
+	// the speed of the emulation and whether the turbo control is active. see
+	// the SetSpeed() function
+	speed float64
+	turbo bool
+
+	// the number of CPU cycles consumed by the most recent call to Step(). this includes cycles
+	// consumed by DMA and WSYNC and so can be larger than the number of cycles in the instruction
+	StepCycles int
 }
 
 type Context interface {
@@ -56,15 +70,23 @@ type Context interface {
 	Rand8Bit() uint8
 	Rand16Bit() uint16
 	UseAudio() bool
+	UseVSync() bool
 }
 
 func Create(ctx Context, g *gui.ChannelsDebugger) *Console {
 	spec := ctx.Spec()
 
+	// the vsync signal from the GUI is only used if requested
+	var vsync <-chan bool
+	if ctx.UseVSync() {
+		vsync = g.VSync
+	}
+
 	con := &Console{
 		ctx:   ctx,
 		g:     g,
-		limit: newLimiter(spec, ctx.UseAudio()),
+		limit: newLimiter(spec, vsync),
+		speed: 1,
 	}
 
 	// create and attach console components
@@ -74,7 +96,7 @@ func Create(ctx Context, g *gui.ChannelsDebugger) *Console {
 	con.MC = cpu.Create(con.Mem)
 	con.RIOT = riot.Create()
 	con.MARIA = maria.Create(ctx, g, con.Mem, con.MC, con.limit)
-	con.TIA = tia.Create(ctx, g, con.limit)
+	con.TIA = tia.Create(ctx, g)
 
 	addChips(con.MARIA, con.TIA, con.RIOT)
 
@@ -96,6 +118,9 @@ func (con *Console) End() {
 
 // if biosCheck is nil or if it returns false then the BIOS routines are bypassed
 func (con *Console) Reset(random bool, biosCheck func() bool) error {
+	// the specification may have changed since the previous reset
+	con.mariaClock = float32(con.ctx.Spec().HorizScan * spec.ClksScanline / clocks.Mhz)
+
 	con.Mem.Reset(random)
 	con.RIOT.Reset()
 	con.TIA.Reset()
@@ -210,6 +235,7 @@ func (con *Console) Insert(c external.CartridgeInsertor) error {
 
 func (con *Console) Step() error {
 	con.handleInput()
+	con.StepCycles = 0
 
 	// interrupts are atomic, meaning that the interrupt occurs between
 	// instruction boundaries and never during an instruction
@@ -227,6 +253,8 @@ func (con *Console) Step() error {
 	// at a slower rate
 	tick := func() error {
 		innerTick := func() {
+			con.StepCycles++
+
 			var mariaRDY bool
 			var tiaRDY bool
 
@@ -251,6 +279,10 @@ func (con *Console) Step() error {
 
 			// if either the MARIA or TIA RDY pins are inactive then the CPU's RDY pin is inactive
 			con.rdy = mariaRDY && tiaRDY
+
+			// coprocessors in the cartridge run in parallel with the console. an
+			// ELF cartridge ignores the step when the ARM is in immediate mode
+			con.Mem.External.Step(con.mariaClock / float32(mariaCycles))
 		}
 
 		innerTick()
@@ -13,42 +13,61 @@ func (con *Console) handleInput() {
 		default:
 			drained = true
 		case inp := <-con.g.UserInput:
-			if inp.Action == gui.AnalogueSelect && inp.Data.(bool) {
-				switch inp.Port {
-				case gui.Player0:
-					if con.players[0].IsController() && !con.players[0].IsAnalogue() {
-						if _, ok := con.players[0].(*peripherals.Paddles); !ok {
-							logger.Log(logger.Allow, "controllers", "plugging paddle into player 0 port")
-							con.players[0].Unplug()
-							con.players[0] = peripherals.NewPaddles(con.RIOT, con.TIA, false)
-							con.players[0].Reset()
-						}
-					}
-				case gui.Undefined:
-					fallthrough
-				case gui.Player1:
-					if con.players[1].IsController() && !con.players[1].IsAnalogue() {
-						if _, ok := con.players[1].(*peripherals.Paddles); !ok {
-							logger.Log(logger.Allow, "controllers", "plugging paddle into player 1 port")
-							con.players[1].Unplug()
-							con.players[1] = peripherals.NewPaddles(con.RIOT, con.TIA, true)
-							con.players[1].Reset()
-						}
-					}
+			con.HandleInput(inp)
+		}
+	}
+}
+
+// HandleInput forwards the input event to the correct peripheral. Input events
+// are normally received over the UserInput channel but this function can be
+// used to inject events from elsewhere
+func (con *Console) HandleInput(inp gui.Input) {
+	switch inp.Action {
+	case gui.Speed:
+		con.stepSpeed(inp.Data.(int))
+		return
+	case gui.Turbo:
+		con.turbo = inp.Data.(bool)
+		con.applySpeed()
+		return
+	case gui.Inspect, gui.FrameAdvance:
+		return
+	}
+
+	if inp.Action == gui.AnalogueSelect && inp.Data.(bool) {
+		switch inp.Port {
+		case gui.Player0:
+			if con.players[0].IsController() && !con.players[0].IsAnalogue() {
+				if _, ok := con.players[0].(*peripherals.Paddles); !ok {
+					logger.Log(logger.Allow, "controllers", "plugging paddle into player 0 port")
+					con.players[0].Unplug()
+					con.players[0] = peripherals.NewPaddles(con.RIOT, con.TIA, false)
+					con.players[0].Reset()
 				}
-			} else {
-				switch inp.Port {
-				case gui.Panel:
-					con.panel.Update(inp)
-				case gui.Player0:
-					con.players[0].Update(inp)
-				case gui.Player1:
-					con.players[1].Update(inp)
-				case gui.Undefined:
-					con.players[0].Update(inp)
-					con.players[1].Update(inp)
+			}
+		case gui.Undefined:
+			fallthrough
+		case gui.Player1:
+			if con.players[1].IsController() && !con.players[1].IsAnalogue() {
+				if _, ok := con.players[1].(*peripherals.Paddles); !ok {
+					logger.Log(logger.Allow, "controllers", "plugging paddle into player 1 port")
+					con.players[1].Unplug()
+					con.players[1] = peripherals.NewPaddles(con.RIOT, con.TIA, true)
+					con.players[1].Reset()
 				}
 			}
 		}
+	} else {
+		switch inp.Port {
+		case gui.Panel:
+			con.panel.Update(inp)
+		case gui.Player0:
+			con.players[0].Update(inp)
+		case gui.Player1:
+			con.players[1].Update(inp)
+		case gui.Undefined:
+			con.players[0].Update(inp)
+			con.players[1].Update(inp)
+		}
 	}
 }
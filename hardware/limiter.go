@@ -4,67 +4,99 @@ import (
 	"time"
 
 	"github.com/jetsetilly/test7800/hardware/spec"
+	"github.com/jetsetilly/test7800/logger"
 )
 
+// the number of frames over which the display refresh rate is measured in
+// vsync mode
+const vsyncMeasurement = 60
+
+// the amount by which the display refresh rate can differ from the refresh rate
+// of the console before vsync mode is abandoned. the audio can be adjusted
+// to match a small difference without the change in pitch being noticeable
+const vsyncTolerance = 0.02
+
 type limiter struct {
-	tick  *time.Ticker
-	nudge chan bool
+	tick *time.Ticker
 
-	// the payload function for the Wait() method
-	wait func()
-}
+	// the ideal duration of a frame
+	frame time.Duration
 
-func newLimiter(spec spec.Spec, syncStart bool) *limiter {
-	l := &limiter{
-		nudge: make(chan bool, 1),
-	}
+	// vsync signal from the GUI. nil if vsync mode is not being used
+	vsync <-chan bool
+
+	// speed of the emulation as a multiple of the ideal speed. a value of zero
+	// means that the emulation is not limited at all
+	speed float64
 
-	// the ideal speed of the console
+	// measurement of the display refresh rate in vsync mode
+	measureStart time.Time
+	measureCt    int
+}
+
+// the limiter paces the emulation to the speed of the console. by default this
+// is done with a ticker. in vsync mode the emulation is paced by the refresh of
+// the display, which must be close to the refresh rate of the console
+//
+// in both cases, any difference between the speed of the emulation and the
+// speed of the audio device is corrected by the TIA, which adjusts the rate at
+// which audio samples are generated
+func newLimiter(spec spec.Spec, vsync <-chan bool) *limiter {
 	hz := spec.HorizScan / float64(spec.AbsoluteBottom)
-	d := time.Second / time.Duration(hz)
-
-	// setLimiter() will be called when the main timeslice is to be used. in the case of syncStart
-	// this will be delayed for the duration of two nudges
-	setLimiter := func() {
-		l.tick = time.NewTicker(d)
-		l.wait = func() {
-			select {
-			case <-l.tick.C:
-			case <-l.nudge:
-			}
-		}
+	l := &limiter{
+		frame: time.Duration(float64(time.Second) / hz),
+		vsync: vsync,
+		speed: 1,
 	}
+	l.tick = time.NewTicker(l.frame)
+	return l
+}
 
-	if syncStart {
-		// the wait() function deliberatey starts slow and then changes state after a few nudges to
-		// normal operation
-		//
-		// this helps ensure that the audio and video synchronise after startup
-		var ct int
-		l.wait = func() {
-			select {
-			case <-time.After(time.Duration(float64(d) * 1.025)):
-			case <-l.nudge:
-				ct++
-				if ct > 2 {
-					setLimiter()
-				}
-			}
-		}
-	} else {
-		setLimiter()
+// SetSpeed changes the speed of the emulation as a multiple of the ideal speed.
+// a value of zero means that the emulation is not limited. vsync mode is only
+// used when the speed is one
+func (l *limiter) SetSpeed(speed float64) {
+	l.speed = speed
+	if speed > 0 {
+		l.tick.Reset(time.Duration(float64(l.frame) / speed))
 	}
-
-	return l
+	l.measureCt = 0
 }
 
 func (l *limiter) Wait() {
-	l.wait()
-}
+	if l.speed == 0 {
+		return
+	}
+
+	if l.vsync == nil || l.speed != 1 {
+		<-l.tick.C
+		return
+	}
 
-func (l *limiter) Nudge() {
+	// the display is not being refreshed, probably because the window is
+	// minimised. the emulation continues at the ideal speed until the vsync
+	// signal returns
 	select {
-	case l.nudge <- true:
-	default:
+	case <-l.vsync:
+	case <-time.After(l.frame * 2):
+		l.measureCt = 0
+		return
+	}
+
+	if l.measureCt == 0 {
+		l.measureStart = time.Now()
+	}
+	l.measureCt++
+
+	if l.measureCt > vsyncMeasurement {
+		frame := time.Since(l.measureStart) / time.Duration(l.measureCt-1)
+		l.measureCt = 0
+
+		d := float64(frame-l.frame) / float64(l.frame)
+		if d < -vsyncTolerance || d > vsyncTolerance {
+			logger.Logf(logger.Allow, "limiter", "display refresh rate of %.2fHz is not suitable for vsync. using timer",
+				float64(time.Second)/float64(frame))
+			l.vsync = nil
+		}
 	}
 }
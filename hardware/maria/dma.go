@@ -1,6 +1,9 @@
 package maria
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/jetsetilly/test7800/hardware/clocks"
 	"github.com/jetsetilly/test7800/hardware/spec"
 )
@@ -100,3 +103,76 @@ func (d *dma) unset() {
 	d.active = false
 	d.hlt.HLT(false)
 }
+
+// DMABudget is the maximum number of cycles available to DMA on a scanline
+const DMABudget = maxDMA
+
+// DMAOverrun is wrapped by the error sent to Context.Break() when a scanline
+// requires more DMA cycles than are available. the error is sent for the first
+// such scanline in a frame
+var DMAOverrun = errors.New("dma overrun")
+
+// DMALine is the DMA usage for a single scanline
+type DMALine struct {
+	Scanline int `json:"scanline"`
+
+	// the DLL entry used to construct the scanline. a value of -1 indicates
+	// that the scanline was not part of a zone
+	Zone int `json:"zone"`
+
+	// the number of DL headers read and the number of DMA cycles used
+	Objects int `json:"objects"`
+	Cycles  int `json:"cycles"`
+
+	// DMA was stopped before all graphics data could be read. TruncatedDL is
+	// the first DL entry that was not completed and TruncatedOrigin is the
+	// address of its header
+	Truncated       bool   `json:"truncated"`
+	TruncatedDL     int    `json:"truncatedDL"`
+	TruncatedOrigin uint16 `json:"truncatedOrigin"`
+}
+
+// Remaining returns the number of DMA cycles left unused on the scanline. The
+// value will be negative if the scanline required more DMA than was available
+func (l DMALine) Remaining() int {
+	return DMABudget - l.Cycles
+}
+
+func (l DMALine) String() string {
+	s := fmt.Sprintf("scanline %d: zone %d, %d objects, %d/%d cycles (%d remaining)",
+		l.Scanline, l.Zone, l.Objects, l.Cycles, DMABudget, l.Remaining())
+	if l.Truncated {
+		s = fmt.Sprintf("%s: truncated at DL %d (%04x)", s, l.TruncatedDL, l.TruncatedOrigin)
+	}
+	return s
+}
+
+// CompletedFrameDMA returns the DMA usage of every scanline on which DMA was
+// active in the most recently completed frame. As with CompletedFrameDL(), the
+// memory is reused once the next frame has completed
+func (mar *Maria) CompletedFrameDMA() []DMALine {
+	return mar.prevFrame.dma
+}
+
+// CurrentFrameDMA returns the DMA usage of every scanline on which DMA has
+// been active so far in the current frame
+func (mar *Maria) CurrentFrameDMA() []DMALine {
+	return mar.currentFrame.dma
+}
+
+// called at the end of DMA for a scanline. the first overrun in a frame is sent
+// to Context.Break(). the other overruns are only recorded
+func (mar *Maria) recordDMA(l DMALine) {
+	l.Scanline = mar.Coords.Scanline
+	l.Cycles = mar.dma.cycles
+	l.Zone = -1
+	if mar.Coords.Scanline > mar.Spec.DMATop {
+		l.Zone = mar.DLL.ct
+	}
+	mar.currentFrame.dma = append(mar.currentFrame.dma, l)
+
+	if l.Truncated && !mar.currentFrame.dmaOverrun {
+		mar.currentFrame.dmaOverrun = true
+		mar.ctx.Break(fmt.Errorf("%w: %w: %s", ContextError, DMAOverrun, l))
+	}
+}
@@ -0,0 +1,242 @@
+package maria
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jetsetilly/test7800/hardware/spec"
+)
+
+// FrameObject is a single DL header as it was used during a frame
+type FrameObject struct {
+	// the number of the DL in the zone and the address of the header
+	Index  int    `json:"index"`
+	Origin uint16 `json:"origin"`
+
+	// the fields of the header
+	Long               bool   `json:"long"`
+	Indirect           bool   `json:"indirect"`
+	Writemode          bool   `json:"writemode"`
+	Address            uint16 `json:"address"`
+	Palette            uint8  `json:"palette"`
+	Width              uint8  `json:"width"`
+	HorizontalPosition uint8  `json:"horizontalPosition"`
+
+	// the number of pixels (in 160 pixel resolution) written to lineram by
+	// the object
+	Pixels int `json:"pixels"`
+
+	// the number of DMA cycles spent on the object over every scanline in the
+	// zone, including the header
+	DMACycles int `json:"dmaCycles"`
+}
+
+func (o FrameObject) String() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "%02d origin=%04x addr=%04x pal=%d width=%02d pos=%02x",
+		o.Index, o.Origin, o.Address, o.Palette, o.Width, o.HorizontalPosition)
+	if o.Long {
+		fmt.Fprintf(&s, " indirect=%v writemode=%v", o.Indirect, o.Writemode)
+	}
+	fmt.Fprintf(&s, " dma=%d", o.DMACycles)
+	return s.String()
+}
+
+// Bounds returns the horizontal extent of the object in 160 pixel resolution.
+// Objects that wrap around the right hand edge of lineram will have a negative
+// left value
+func (o FrameObject) Bounds() (int, int) {
+	x := int(o.HorizontalPosition)
+	if x+o.Pixels > 256 {
+		x -= 256
+	}
+	return x, x + o.Pixels
+}
+
+// FrameZone is a single DLL entry as it was used during a frame
+type FrameZone struct {
+	// the number of the DLL entry and the address of the entry
+	Index  int    `json:"index"`
+	Origin uint16 `json:"origin"`
+
+	// the fields of the DLL entry
+	DLI       bool   `json:"dli"`
+	H16       bool   `json:"h16"`
+	H8        bool   `json:"h8"`
+	Offset    uint8  `json:"offset"`
+	DLAddress uint16 `json:"dlAddress"`
+
+	// the first scanline on which the zone is displayed and the number of
+	// scanlines the zone covers
+	Scanline int `json:"scanline"`
+	Height   int `json:"height"`
+
+	// the total number of DMA cycles spent in the zone
+	DMACycles int `json:"dmaCycles"`
+
+	Objects []FrameObject `json:"objects"`
+}
+
+func (z FrameZone) String() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "zone %02d origin=%04x dl=%04x scanline=%d height=%d", z.Index, z.Origin, z.DLAddress, z.Scanline, z.Height)
+	if z.DLI {
+		s.WriteString(" dli")
+	}
+	if z.H16 {
+		s.WriteString(" h16")
+	}
+	if z.H8 {
+		s.WriteString(" h8")
+	}
+	fmt.Fprintf(&s, " dma=%d", z.DMACycles)
+	return s.String()
+}
+
+// FrameDL is the record of every DLL zone and DL header used to construct a
+// frame
+type FrameDL struct {
+	Frame int         `json:"frame"`
+	Zones []FrameZone `json:"zones"`
+
+	// the origin of the frame image in television coordinates. this is
+	// the same as the origin of the image returned by CompletedFrame()
+	Left int `json:"left"`
+	Top  int `json:"top"`
+}
+
+func (f FrameDL) String() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "frame %d: %d zones\n", f.Frame, len(f.Zones))
+	for _, z := range f.Zones {
+		s.WriteString(z.String())
+		s.WriteString("\n")
+		for _, o := range z.Objects {
+			fmt.Fprintf(&s, "  %s\n", o.String())
+		}
+	}
+	return s.String()
+}
+
+// ImagePosition converts a television scanline and a horizontal position in
+// 160 pixel resolution to coordinates in the frame image
+func (f FrameDL) ImagePosition(scanline int, x int) (int, int) {
+	return spec.ClksHBLANK + (x * 2) - f.Left, scanline - f.Top
+}
+
+// At returns the zone and objects that cover the image coordinates. The
+// objects are returned in the order they are drawn
+func (f FrameDL) At(x int, y int) (*FrameZone, []FrameObject) {
+	for i := range f.Zones {
+		z := &f.Zones[i]
+		zx, zy := f.ImagePosition(z.Scanline, 0)
+		if y < zy || y >= zy+z.Height {
+			continue // for loop
+		}
+
+		var objs []FrameObject
+		for _, o := range z.Objects {
+			l, r := o.Bounds()
+			if x >= zx+(l*2) && x < zx+(r*2) {
+				objs = append(objs, o)
+			}
+		}
+		return z, objs
+	}
+	return nil, nil
+}
+
+// CompletedFrameDL returns the record of display lists for the most recently
+// completed frame. The record matches the image returned by CompletedFrame()
+//
+// The memory used by the record is reused once the next frame has completed.
+// The record should be copied if it is required for longer than that
+func (mar *Maria) CompletedFrameDL() FrameDL {
+	return mar.prevFrame.dl
+}
+
+// called at the start of DMA for a scanline. adds the current DLL to the record
+// for the frame if it is not already present
+func (mar *Maria) recordZone() {
+	// the DLL is not reset until the end of the DMA on the first scanline
+	if mar.Coords.Scanline <= mar.Spec.DMATop {
+		return
+	}
+
+	f := &mar.currentFrame.dl
+	if n := len(f.Zones); n > 0 && f.Zones[n-1].Index == mar.DLL.ct && f.Zones[n-1].Origin == mar.DLL.origin {
+		f.Zones[n-1].Height++
+		return
+	}
+
+	// the zones are reused from an earlier frame. the memory used by the
+	// objects of a reused zone is also reused
+	var objs []FrameObject
+	if n := len(f.Zones); n < cap(f.Zones) {
+		objs = f.Zones[:n+1][n].Objects[:0]
+	}
+
+	// lineram prepared on this scanline is displayed on the next scanline
+	f.Zones = append(f.Zones, FrameZone{
+		Index:     mar.DLL.ct,
+		Origin:    mar.DLL.origin,
+		DLI:       mar.DLL.dli,
+		H16:       mar.DLL.h16,
+		H8:        mar.DLL.h8,
+		Offset:    mar.DLL.offset,
+		DLAddress: (uint16(mar.DLL.highAddress) << 8) | uint16(mar.DLL.lowAddress),
+		Scanline:  mar.Coords.Scanline + 1,
+		Height:    1,
+		Objects:   objs,
+	})
+}
+
+// called at the end of DMA for a scanline with the total number of DMA cycles
+// used on the scanline
+func (mar *Maria) recordZoneCycles(cycles int) {
+	f := &mar.currentFrame.dl
+	if len(f.Zones) == 0 || mar.Coords.Scanline <= mar.Spec.DMATop {
+		return
+	}
+	f.Zones[len(f.Zones)-1].DMACycles += cycles
+}
+
+// called after each DL has been processed with the number of DMA cycles used
+func (mar *Maria) recordObject(cycles int) {
+	f := &mar.currentFrame.dl
+	if len(f.Zones) == 0 || mar.Coords.Scanline <= mar.Spec.DMATop {
+		return
+	}
+	z := &f.Zones[len(f.Zones)-1]
+
+	// objects are recorded on the first scanline of the zone. on subsequent
+	// scanlines the DMA cycles are accumulated
+	for i := range z.Objects {
+		if z.Objects[i].Index == mar.DL.ct && z.Objects[i].Origin == mar.DL.origin {
+			z.Objects[i].DMACycles += cycles
+			return
+		}
+	}
+
+	pixels := int(mar.DL.width) * 4
+	if mar.DL.writemode {
+		pixels = int(mar.DL.width) * 2
+	}
+	if mar.ctrl.charWidth && mar.DL.indirect {
+		pixels *= 2
+	}
+
+	z.Objects = append(z.Objects, FrameObject{
+		Index:              mar.DL.ct,
+		Origin:             mar.DL.origin,
+		Long:               mar.DL.long,
+		Indirect:           mar.DL.indirect,
+		Writemode:          mar.DL.writemode,
+		Address:            (uint16(mar.DL.highAddress) << 8) | uint16(mar.DL.lowAddress),
+		Palette:            mar.DL.palette,
+		Width:              mar.DL.width,
+		HorizontalPosition: mar.DL.horizontalPosition,
+		Pixels:             pixels,
+		DMACycles:          cycles,
+	})
+}
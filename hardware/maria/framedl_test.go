@@ -0,0 +1,202 @@
+package maria
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jetsetilly/test7800/gui"
+	"github.com/jetsetilly/test7800/hardware/spec"
+	"github.com/jetsetilly/test7800/test"
+)
+
+type testContext struct {
+	breaks []error
+}
+
+func (ctx *testContext) RandN(int) int    { return 0 }
+func (ctx *testContext) Break(err error)  { ctx.breaks = append(ctx.breaks, err) }
+func (ctx *testContext) Spec() spec.Spec  { return spec.NTSC }
+func (ctx *testContext) UseOverlay() bool { return false }
+func (ctx *testContext) Overscan() string { return "" }
+
+type testMemory [0x10000]uint8
+
+func (mem *testMemory) Read(address uint16) (uint8, error) { return mem[address], nil }
+func (mem *testMemory) Write(address uint16, data uint8) error {
+	mem[address] = data
+	return nil
+}
+func (mem *testMemory) HLT(bool) {}
+
+type testCPU struct{}
+
+func (cpu testCPU) InInterrupt() bool { return false }
+
+type testLimiter struct{}
+
+func (l testLimiter) Wait() {}
+
+func TestFrameDL(t *testing.T) {
+	var mem testMemory
+
+	// DLL of three zones. the final zone is large enough to cover the rest of the screen
+	copy(mem[0x1800:], []uint8{
+		0x07, 0x19, 0x00,
+		0x8f, 0x1a, 0x00,
+		0x0f, 0x1b, 0x00,
+	})
+	for a := uint16(0x1809); a < 0x1880; a += 3 {
+		copy(mem[a:], []uint8{0x0f, 0x1b, 0x00})
+	}
+
+	// four byte header. palette 2, width 4, position $10
+	copy(mem[0x1900:], []uint8{0x00, 0x5c, 0xc0, 0x10, 0x00, 0x00})
+
+	// five byte header. palette 1, width 3, position $f8 (wraps around to the left edge)
+	copy(mem[0x1a00:], []uint8{0x00, 0x40, 0xc1, 0x3d, 0xf8, 0x00, 0x00})
+
+	// empty display list
+	copy(mem[0x1b00:], []uint8{0x00, 0x00})
+
+	var ctx testContext
+	mar := Create(&ctx, &gui.ChannelsDebugger{}, &mem, testCPU{}, testLimiter{})
+	test.DemandSuccess(t, mar.Write(0x02c, 0x18))
+	test.DemandSuccess(t, mar.Write(0x030, 0x00))
+	test.DemandSuccess(t, mar.Write(0x03c, 0x40))
+
+	for mar.Coords.Frame < 2 {
+		mar.Tick(true)
+	}
+
+	test.ExpectEquality(t, len(ctx.breaks), 0)
+
+	fdl := mar.CompletedFrameDL()
+	test.ExpectEquality(t, fdl.Frame, 1)
+	test.DemandSuccess(t, len(fdl.Zones) > 2)
+
+	z := fdl.Zones[0]
+	test.ExpectEquality(t, z.Index, 0)
+	test.ExpectEquality(t, z.Origin, 0x1800)
+	test.ExpectEquality(t, z.DLAddress, 0x1900)
+	test.ExpectEquality(t, z.Scanline, spec.NTSC.DMATop+2)
+	test.ExpectEquality(t, z.Height, 8)
+	test.DemandEquality(t, len(z.Objects), 1)
+
+	o := z.Objects[0]
+	test.ExpectEquality(t, o.Address, 0xc000)
+	test.ExpectEquality(t, o.Palette, 2)
+	test.ExpectEquality(t, o.Width, 4)
+	test.ExpectEquality(t, o.HorizontalPosition, 0x10)
+	test.ExpectEquality(t, o.Pixels, 16)
+	test.ExpectEquality(t, o.DMACycles, 8*(dmaShortDLHeader+4*dmaDirectGfx))
+
+	z = fdl.Zones[1]
+	test.ExpectEquality(t, z.Index, 1)
+	test.ExpectSuccess(t, z.DLI)
+	test.ExpectEquality(t, z.Scanline, spec.NTSC.DMATop+10)
+	test.ExpectEquality(t, z.Height, 16)
+	test.DemandEquality(t, len(z.Objects), 1)
+
+	o = z.Objects[0]
+	test.ExpectSuccess(t, o.Long)
+	test.ExpectEquality(t, o.Address, 0xc100)
+	test.ExpectEquality(t, o.Palette, 1)
+	test.ExpectEquality(t, o.Width, 3)
+	test.ExpectEquality(t, o.Pixels, 12)
+	l, r := o.Bounds()
+	test.ExpectEquality(t, l, -8)
+	test.ExpectEquality(t, r, 4)
+
+	// the object in the second zone covers the left edge of the screen
+	x, y := fdl.ImagePosition(spec.NTSC.DMATop+12, 1)
+	zp, objs := fdl.At(x, y)
+	test.DemandSuccess(t, zp != nil)
+	test.ExpectEquality(t, zp.Index, 1)
+	test.ExpectEquality(t, len(objs), 1)
+
+	// but not the middle of the screen
+	x, y = fdl.ImagePosition(spec.NTSC.DMATop+12, 80)
+	zp, objs = fdl.At(x, y)
+	test.DemandSuccess(t, zp != nil)
+	test.ExpectEquality(t, len(objs), 0)
+
+	// the memory used by the record is reused two frames later. the record of
+	// the frame in between is not affected
+	zone := &fdl.Zones[0]
+	obj := &fdl.Zones[0].Objects[0]
+	zs := fdl.Zones[0].String()
+	for mar.Coords.Frame < 3 {
+		mar.Tick(true)
+	}
+	fdl = mar.CompletedFrameDL()
+	test.ExpectEquality(t, fdl.Frame, 2)
+	test.ExpectInequality(t, &fdl.Zones[0], zone)
+
+	for mar.Coords.Frame < 4 {
+		mar.Tick(true)
+	}
+	fdl = mar.CompletedFrameDL()
+	test.ExpectEquality(t, fdl.Frame, 3)
+	test.ExpectEquality(t, &fdl.Zones[0], zone)
+	test.ExpectEquality(t, &fdl.Zones[0].Objects[0], obj)
+	test.ExpectEquality(t, fdl.Zones[0].String(), zs)
+}
+
+func TestDMAOverrun(t *testing.T) {
+	var mem testMemory
+
+	// DLL of a single zone that covers the rest of the screen
+	for a := uint16(0x1800); a < 0x1880; a += 3 {
+		copy(mem[a:], []uint8{0x0f, 0x19, 0x00})
+	}
+
+	// display list of twenty objects, each of which is 32 bytes wide. this is far more
+	// graphics data than can be read in a single scanline
+	for a := uint16(0x1900); a < 0x1900+(20*5); a += 5 {
+		copy(mem[a:], []uint8{0x00, 0x40, 0xc0, 0x00, 0x00})
+	}
+
+	var ctx testContext
+	mar := Create(&ctx, &gui.ChannelsDebugger{}, &mem, testCPU{}, testLimiter{})
+	test.DemandSuccess(t, mar.Write(0x02c, 0x18))
+	test.DemandSuccess(t, mar.Write(0x030, 0x00))
+	test.DemandSuccess(t, mar.Write(0x03c, 0x40))
+
+	for mar.Coords.Frame < 1 {
+		mar.Tick(true)
+	}
+	ctx.breaks = ctx.breaks[:0]
+	for mar.Coords.Frame < 2 {
+		mar.Tick(true)
+	}
+
+	// every scanline in the zone is truncated but only the first overrun in
+	// the frame is sent to Break()
+	test.DemandEquality(t, len(ctx.breaks), 1)
+	test.ExpectSuccess(t, errors.Is(ctx.breaks[0], ContextError))
+	test.ExpectSuccess(t, errors.Is(ctx.breaks[0], DMAOverrun))
+
+	lines := mar.CompletedFrameDMA()
+	test.DemandSuccess(t, len(lines) > 2)
+	var truncated int
+	for _, l := range lines {
+		if l.Truncated {
+			truncated++
+		}
+	}
+	test.ExpectSuccess(t, truncated > 1)
+
+	l := lines[1]
+	test.ExpectEquality(t, l.Scanline, spec.NTSC.DMATop+1)
+	test.ExpectEquality(t, l.Zone, 0)
+	test.ExpectEquality(t, l.Objects, 20)
+	test.ExpectSuccess(t, l.Truncated)
+	test.ExpectSuccess(t, l.Remaining() < 0)
+
+	// each object requires a header and 32 bytes of graphics data. the first object to be
+	// truncated can be calculated from that
+	perObject := dmaLongDLHeader + 32*dmaDirectGfx
+	dl := (DMABudget - dmaStart) / perObject
+	test.ExpectEquality(t, l.TruncatedDL, dl)
+	test.ExpectEquality(t, l.TruncatedOrigin, uint16(0x1900+dl*5))
+}
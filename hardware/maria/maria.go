@@ -49,6 +49,16 @@ type frame struct {
 	// automatic overscan requires us to track the top-most scanline that is used
 	autoOverscanTop   int
 	autoOverscanTopCt int
+
+	// record of display lists used to construct the frame
+	dl FrameDL
+
+	// DMA usage for each scanline in the frame
+	dma []DMALine
+
+	// a DMA overrun has been sent to Context.Break() during the frame. only
+	// the first overrun in a frame is sent
+	dmaOverrun bool
 }
 
 type Maria struct {
@@ -391,6 +401,10 @@ func (mar *Maria) newFrame() {
 		mar.currentFrame.autoOverscanTop = 0
 	}()
 
+	// the records of the frame before the previous frame are no longer
+	// required and their memory is reused by the new frame
+	reuse := mar.prevFrame
+
 	mar.prevFrame = mar.currentFrame
 	mar.currentFrame.overscan = mar.ctx.Overscan()
 
@@ -436,6 +450,16 @@ func (mar *Maria) newFrame() {
 		}
 	}
 
+	mar.currentFrame.dl = FrameDL{
+		Frame: mar.Coords.Frame,
+		Zones: reuse.dl.Zones[:0],
+		Left:  mar.currentFrame.left,
+		Top:   mar.currentFrame.top,
+	}
+
+	mar.currentFrame.dma = reuse.dma[:0]
+	mar.currentFrame.dmaOverrun = false
+
 	mar.currentFrame.main = image.NewRGBA(image.Rect(0, 0,
 		mar.currentFrame.right-mar.currentFrame.left,
 		mar.currentFrame.bottom-mar.currentFrame.top),
@@ -447,6 +471,12 @@ func (mar *Maria) newFrame() {
 	)
 }
 
+// CompletedFrame returns the most recently completed frame. The image should not
+// be modified
+func (mar *Maria) CompletedFrame() *image.RGBA {
+	return mar.prevFrame.main
+}
+
 func (mar *Maria) PushRender() {
 	var cursor = [2]int{
 		mar.Coords.Clk - mar.currentFrame.left,
@@ -620,6 +650,8 @@ func (mar *Maria) Tick(dmaLatch bool) (dma bool, rdy bool, nmi bool) {
 					mar.dma.cycles += dmaStart
 				}
 
+				mar.recordZone()
+
 				err := mar.nextDL(true)
 				if err != nil {
 					mar.ctx.Break(fmt.Errorf("%w: %w", ContextError, err))
@@ -627,7 +659,14 @@ func (mar *Maria) Tick(dmaLatch bool) (dma bool, rdy bool, nmi bool) {
 
 				var hasWritten bool
 
+				// DMA usage for the scanline
+				usage := DMALine{TruncatedDL: -1}
+
 				for !mar.DL.isEnd {
+					usage.Objects++
+
+					startCycles := mar.dma.cycles
+
 					// DMA cycle accumulation for DL header
 					if mar.DL.long {
 						mar.dma.cycles += dmaLongDLHeader
@@ -641,6 +680,11 @@ func (mar *Maria) Tick(dmaLatch bool) (dma bool, rdy bool, nmi bool) {
 
 					for w := range mar.DL.width {
 						if mar.dma.cycles > maxDMA {
+							if !usage.Truncated {
+								usage.Truncated = true
+								usage.TruncatedDL = mar.DL.ct
+								usage.TruncatedOrigin = mar.DL.origin
+							}
 							break // for loop
 						}
 
@@ -756,12 +800,17 @@ func (mar *Maria) Tick(dmaLatch bool) (dma bool, rdy bool, nmi bool) {
 					}
 
 					mar.RecentDL = append(mar.RecentDL, mar.DL)
+					mar.recordObject(mar.dma.cycles - startCycles)
+
 					err := mar.nextDL(false)
 					if err != nil {
 						mar.ctx.Break(fmt.Errorf("%w: %w", ContextError, err))
 					}
 				}
 
+				mar.recordZoneCycles(mar.dma.cycles)
+				mar.recordDMA(usage)
+
 				// check overscan range
 				if hasWritten {
 					if mar.currentFrame.autoOverscanTop == 0 && mar.Coords.Scanline >= mar.Spec.OverscanTop && mar.Coords.Scanline < mar.Spec.SafeTop {
@@ -0,0 +1,442 @@
+// Package a78 reads and writes the header of a78 cartridge files. The header
+// is described at:
+//
+// https://7800.8bitdev.org/index.php/A78_Header_Specification
+//
+// https://forums.atariage.com/topic/333208-old-world-a78-format-10-31-primer/
+package a78
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// the string that identifies the data as an a78 file
+const Magic = "ATARI7800"
+
+// the string that marks the end of the header
+const EndOfHeader = "ACTUAL CART DATA STARTS HERE"
+
+// the size of a header written by Bytes()
+const HeaderSize = 128
+
+// the location of the fields in the header
+const (
+	offsetVersion     = 0x00
+	offsetMagic       = 0x01
+	offsetTitle       = 0x11
+	offsetSize        = 0x31
+	offsetCartType    = 0x35
+	offsetController1 = 0x37
+	offsetController2 = 0x38
+	offsetTV          = 0x39
+	offsetSaveDevice  = 0x3a
+	offsetExpansion   = 0x3f
+
+	// version 4 fields
+	offsetMapper        = 0x40
+	offsetMapperOptions = 0x41
+	offsetAudio         = 0x42
+	offsetInterrupts    = 0x44
+
+	// the hardware model of an ELF cartridge is not part of the a78
+	// specification. it is stored in the first of the reserved bytes after
+	// the version 4 fields
+	offsetELFModel = 0x46
+
+	offsetEndOfHeader = HeaderSize - len(EndOfHeader)
+)
+
+// the bits of the cartridge type that specify additional chips rather than the
+// mapper
+const (
+	CartTypePokey4000 = 0x0001
+	CartTypePokey0450 = 0x0040
+	CartTypePokey0440 = 0x0400
+	CartTypePokey0800 = 0x8000
+	CartTypeYM2151    = 0x0800
+
+	CartTypePokeys = CartTypePokey4000 | CartTypePokey0450 | CartTypePokey0440 | CartTypePokey0800
+	CartTypeChips  = CartTypePokeys | CartTypeYM2151
+)
+
+// the cartridge type for each of the mappers that can be described by the
+// header. the names are the same as those used by the game database
+var Mappers = map[string]uint16{
+	"FLAT":            0x0000,
+	"MRAM":            0x0080,
+	"SUPERGAME":       0x0002,
+	"SUPERGAME_EXRAM": 0x0006,
+	"SUPERGAME_EXROM": 0x000a,
+	"ACTIVISION":      0x0100,
+	"ABSOLUTE":        0x0200,
+	"BANKSETS":        0x2002,
+	"BANKSETS_RAM":    0x6002,
+}
+
+// the cartridge type bit for each of the POKEY addresses
+var pokeyBits = map[uint16]uint16{
+	0x4000: CartTypePokey4000,
+	0x0450: CartTypePokey0450,
+	0x0440: CartTypePokey0440,
+	0x0800: CartTypePokey0800,
+}
+
+// the names of the controller types. the names used by the emulator are in
+// lower case
+var Controllers = []string{
+	"none", "7800_joystick", "lightgun", "paddle", "trakball", "2600_joystick",
+	"2600_driving", "2600_keypad", "st_mouse", "amiga_mouse", "atarivox", "snes2atari",
+}
+
+// the names of the mappers in the version 4 mapper field
+var mappersV4 = []string{"linear", "supergame", "activision", "absolute", "souper"}
+
+// the version 4 mapper and mapper options for each of the mappers
+var mappersV4Values = map[string][2]uint8{
+	"FLAT":            {0, 0},
+	"MRAM":            {0, 2},
+	"SUPERGAME":       {1, 0},
+	"SUPERGAME_EXRAM": {1, 1},
+	"SUPERGAME_EXROM": {1, 4},
+	"ACTIVISION":      {2, 0},
+	"ABSOLUTE":        {3, 0},
+	"BANKSETS":        {1, 0x80},
+	"BANKSETS_RAM":    {1, 0x81},
+}
+
+// the names of the POKEY values in the lowest three bits of the version 4 audio
+// field
+var audioPokeyV4 = []string{"none", "0440", "0450", "0450+0440", "0800", "4000"}
+
+// the version 4 audio value for each of the POKEY addresses. the key for the
+// combination of two POKEYs is the two addresses added together
+var audioPokeyV4Values = map[uint16]uint16{
+	0x0000:          0,
+	0x0440:          1,
+	0x0450:          2,
+	0x0450 + 0x0440: 3,
+	0x0800:          4,
+	0x4000:          5,
+}
+
+// the bits of the version 4 audio field above the POKEY value
+const (
+	audioYM2151 = 0x0008
+	audioCOVOX  = 0x0010
+	audioADPCM  = 0x0020
+)
+
+// the names of the hardware models that can be stored in the ELF model byte.
+// the value of the byte is the index into the list. a value of zero means that
+// the header does not specify a model
+var ELFModels = []string{"none", "HARMONY", "PLUSCART", "UNOCART"}
+
+// Header is the a78 header
+type Header struct {
+	Version uint8
+	Title   string
+
+	// size of the cartridge data not including the header
+	Size uint32
+
+	CartType    uint16
+	Controller1 uint8
+	Controller2 uint8
+	TV          uint8
+	SaveDevice  uint8
+	Expansion   uint8
+
+	// version 4 fields. these fields are not used by the emulator
+	Mapper        uint8
+	MapperOptions uint8
+	Audio         uint16
+	Interrupts    uint16
+
+	// hardware model of an ELF cartridge. this is an extension to the a78
+	// specification. see ELFModels for the meaning of the value
+	ELFModel uint8
+
+	// the header as it was parsed. bytes that are not represented by one of
+	// the fields are written unchanged by Bytes()
+	raw []byte
+}
+
+// IsA78 returns true if the data begins with an a78 header
+func IsA78(d []byte) bool {
+	return len(d) > offsetMagic+len(Magic) && bytes.Equal(d[offsetMagic:offsetMagic+len(Magic)], []byte(Magic))
+}
+
+// Parse the a78 header at the start of the data. returns the header and the
+// offset of the cartridge data
+func Parse(d []byte) (Header, int, error) {
+	if !IsA78(d) {
+		return Header{}, 0, fmt.Errorf("a78: not an a78 file")
+	}
+
+	dataStart := bytes.Index(d, []byte(EndOfHeader))
+	if dataStart == -1 {
+		return Header{}, 0, fmt.Errorf("a78: malformed header. no end of header indicator")
+	}
+	dataStart += len(EndOfHeader)
+
+	if dataStart < offsetELFModel+1 {
+		return Header{}, 0, fmt.Errorf("a78: malformed header. header is too short")
+	}
+
+	h := Header{
+		Version:     d[offsetVersion],
+		Title:       strings.TrimSpace(strings.TrimRight(string(d[offsetTitle:offsetSize]), "\x00")),
+		Size:        binary.BigEndian.Uint32(d[offsetSize:]),
+		CartType:    binary.BigEndian.Uint16(d[offsetCartType:]),
+		Controller1: d[offsetController1],
+		Controller2: d[offsetController2],
+		TV:          d[offsetTV],
+		SaveDevice:  d[offsetSaveDevice],
+		Expansion:   d[offsetExpansion],
+
+		Mapper:        d[offsetMapper],
+		MapperOptions: d[offsetMapperOptions],
+		Audio:         binary.BigEndian.Uint16(d[offsetAudio:]),
+		Interrupts:    binary.BigEndian.Uint16(d[offsetInterrupts:]),
+
+		ELFModel: d[offsetELFModel],
+
+		raw: slices.Clone(d[:dataStart]),
+	}
+
+	return h, dataStart, nil
+}
+
+// Bytes returns the header in the a78 format. the header is always HeaderSize
+// bytes long and ends with the end of header indicator
+func (h Header) Bytes() []byte {
+	d := make([]byte, HeaderSize)
+	copy(d[:offsetEndOfHeader], h.raw)
+
+	d[offsetVersion] = h.Version
+	clear(d[offsetMagic:offsetTitle])
+	copy(d[offsetMagic:], Magic)
+	clear(d[offsetTitle:offsetSize])
+	copy(d[offsetTitle:offsetSize], h.Title)
+	binary.BigEndian.PutUint32(d[offsetSize:], h.Size)
+	binary.BigEndian.PutUint16(d[offsetCartType:], h.CartType)
+	d[offsetController1] = h.Controller1
+	d[offsetController2] = h.Controller2
+	d[offsetTV] = h.TV
+	d[offsetSaveDevice] = h.SaveDevice
+	d[offsetExpansion] = h.Expansion
+
+	d[offsetMapper] = h.Mapper
+	d[offsetMapperOptions] = h.MapperOptions
+	binary.BigEndian.PutUint16(d[offsetAudio:], h.Audio)
+	binary.BigEndian.PutUint16(d[offsetInterrupts:], h.Interrupts)
+
+	d[offsetELFModel] = h.ELFModel
+
+	copy(d[offsetEndOfHeader:], EndOfHeader)
+
+	return d
+}
+
+// IsPAL returns true if the TV field specifies PAL
+func (h Header) IsPAL() bool {
+	return h.TV&0x01 == 0x01
+}
+
+// UseHSC returns true if the save device field specifies the high score
+// cartridge
+func (h Header) UseHSC() bool {
+	return h.SaveDevice&0x01 == 0x01
+}
+
+// UseSavekey returns true if the save device field specifies the savekey
+func (h Header) UseSavekey() bool {
+	return h.SaveDevice&0x02 == 0x02
+}
+
+// MapperName returns the name of the mapper specified by the cartridge type.
+// the name is one of the keys in the Mappers map. if the cartridge type does
+// not match one of the mappers then the mapper bits are returned as a string
+func (h Header) MapperName() string {
+	t := h.CartType &^ CartTypeChips
+	for _, k := range slices.Sorted(maps.Keys(Mappers)) {
+		if Mappers[k] == t {
+			return k
+		}
+	}
+	return fmt.Sprintf("%#04x", t)
+}
+
+// Pokeys returns the addresses of the POKEYs specified by the cartridge type
+func (h Header) Pokeys() []uint16 {
+	var p []uint16
+	for _, a := range slices.Sorted(maps.Keys(pokeyBits)) {
+		if h.CartType&pokeyBits[a] == pokeyBits[a] {
+			p = append(p, a)
+		}
+	}
+	return p
+}
+
+// ControllerName returns the name of the controller type. returns the value as
+// a string if it is not recognised
+func ControllerName(v uint8) string {
+	if int(v) < len(Controllers) {
+		return Controllers[v]
+	}
+	return fmt.Sprintf("%#02x", v)
+}
+
+// MapperV4Name returns the name of the version 4 mapper field
+func (h Header) MapperV4Name() string {
+	if int(h.Mapper) < len(mappersV4) {
+		return mappersV4[h.Mapper]
+	}
+	return fmt.Sprintf("%#02x", h.Mapper)
+}
+
+// ELFModelName returns the name of the hardware model in the ELF model byte.
+// returns the empty string if the header does not specify a model or if the
+// value is not recognised
+func (h Header) ELFModelName() string {
+	if h.ELFModel == 0 || int(h.ELFModel) >= len(ELFModels) {
+		return ""
+	}
+	return ELFModels[h.ELFModel]
+}
+
+// AudioV4Names returns the names of the audio hardware in the version 4 audio
+// field
+func (h Header) AudioV4Names() []string {
+	var s []string
+	if p := int(h.Audio & 0x07); p > 0 {
+		if p < len(audioPokeyV4) {
+			s = append(s, fmt.Sprintf("POKEY %s", audioPokeyV4[p]))
+		} else {
+			s = append(s, fmt.Sprintf("POKEY %#x", p))
+		}
+	}
+	if h.Audio&audioYM2151 == audioYM2151 {
+		s = append(s, "YM2151")
+	}
+	if h.Audio&audioCOVOX == audioCOVOX {
+		s = append(s, "COVOX")
+	}
+	if h.Audio&audioADPCM == audioADPCM {
+		s = append(s, "ADPCM")
+	}
+	return s
+}
+
+// SetController sets the controller type for the port. the port is 1 or 2
+func (h *Header) SetController(port int, name string) error {
+	v := slices.Index(Controllers, strings.ToLower(name))
+	if v == -1 {
+		return fmt.Errorf("a78: unrecognised controller: %s", name)
+	}
+	switch port {
+	case 1:
+		h.Controller1 = uint8(v)
+	case 2:
+		h.Controller2 = uint8(v)
+	default:
+		return fmt.Errorf("a78: no controller port %d", port)
+	}
+	return nil
+}
+
+// SetTV sets the TV specification. the spec is NTSC or PAL
+func (h *Header) SetTV(spec string) error {
+	switch strings.ToUpper(spec) {
+	case "NTSC":
+		h.TV &^= 0x01
+	case "PAL":
+		h.TV |= 0x01
+	default:
+		return fmt.Errorf("a78: TV must be NTSC or PAL")
+	}
+	return nil
+}
+
+// SetSaveDevice sets the save device bits
+func (h *Header) SetSaveDevice(hsc bool, savekey bool) {
+	h.SaveDevice &^= 0x03
+	if hsc {
+		h.SaveDevice |= 0x01
+	}
+	if savekey {
+		h.SaveDevice |= 0x02
+	}
+}
+
+// SetMapper sets the mapper bits of the cartridge type. the name is one of the
+// keys in the Mappers map. the version 4 mapper fields are also set if the
+// header is version 4 or later
+func (h *Header) SetMapper(name string) error {
+	name = strings.ToUpper(name)
+	t, ok := Mappers[name]
+	if !ok {
+		return fmt.Errorf("a78: unrecognised mapper: %s", name)
+	}
+	h.CartType = (h.CartType & CartTypeChips) | t
+
+	if h.Version >= 4 {
+		v := mappersV4Values[name]
+		h.Mapper = v[0]
+		h.MapperOptions = v[1]
+	}
+	return nil
+}
+
+// SetAudio sets the audio bits of the cartridge type. the POKEY addresses must
+// be one of 0x4000, 0x0450, 0x0440 or 0x0800. the version 4 audio field is also
+// set if the header is version 4 or later
+func (h *Header) SetAudio(pokeys []uint16, ym2151 bool) error {
+	var bits uint16
+	var key uint16
+	for _, a := range pokeys {
+		b, ok := pokeyBits[a]
+		if !ok {
+			return fmt.Errorf("a78: unsupported POKEY address: %#04x", a)
+		}
+		bits |= b
+		key += a
+	}
+
+	h.CartType &^= CartTypeChips
+	h.CartType |= bits
+	if ym2151 {
+		h.CartType |= CartTypeYM2151
+	}
+
+	if h.Version >= 4 {
+		v, ok := audioPokeyV4Values[key]
+		if !ok {
+			return fmt.Errorf("a78: combination of POKEYs cannot be described by a version 4 header")
+		}
+		h.Audio &^= 0x07 | audioYM2151
+		h.Audio |= v
+		if ym2151 {
+			h.Audio |= audioYM2151
+		}
+	}
+	return nil
+}
+
+// SetELFModel sets the hardware model of an ELF cartridge. the name is one of
+// the entries in the ELFModels list
+func (h *Header) SetELFModel(name string) error {
+	v := slices.IndexFunc(ELFModels, func(m string) bool {
+		return strings.EqualFold(m, name)
+	})
+	if v == -1 {
+		return fmt.Errorf("a78: unrecognised ELF model: %s", name)
+	}
+	h.ELFModel = uint8(v)
+	return nil
+}
@@ -0,0 +1,105 @@
+package a78_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jetsetilly/test7800/hardware/memory/external/a78"
+	"github.com/jetsetilly/test7800/test"
+)
+
+func TestRoundTrip(t *testing.T) {
+	hdr := a78.Header{
+		Version:  3,
+		Title:    "Test Game",
+		Size:     0x8000,
+		CartType: 0x0002,
+	}
+	test.DemandSuccess(t, hdr.SetController(1, "7800_JOYSTICK"))
+	test.DemandSuccess(t, hdr.SetController(2, "paddle"))
+	test.DemandSuccess(t, hdr.SetTV("PAL"))
+	hdr.SetSaveDevice(true, false)
+
+	b := hdr.Bytes()
+	test.ExpectEquality(t, len(b), a78.HeaderSize)
+	test.ExpectSuccess(t, bytes.HasSuffix(b, []byte(a78.EndOfHeader)))
+	test.ExpectSuccess(t, a78.IsA78(b))
+
+	d := append(b, make([]byte, 0x8000)...)
+	p, dataStart, err := a78.Parse(d)
+	test.DemandSuccess(t, err)
+	test.ExpectEquality(t, dataStart, a78.HeaderSize)
+	test.ExpectEquality(t, p.Version, uint8(3))
+	test.ExpectEquality(t, p.Title, "Test Game")
+	test.ExpectEquality(t, p.Size, uint32(0x8000))
+	test.ExpectEquality(t, p.MapperName(), "SUPERGAME")
+	test.ExpectEquality(t, a78.ControllerName(p.Controller1), "7800_joystick")
+	test.ExpectEquality(t, a78.ControllerName(p.Controller2), "paddle")
+	test.ExpectEquality(t, p.IsPAL(), true)
+	test.ExpectEquality(t, p.UseHSC(), true)
+	test.ExpectEquality(t, p.UseSavekey(), false)
+
+	// bytes that are not represented by a field are preserved
+	d[0x50] = 0xaa
+	p, _, err = a78.Parse(d)
+	test.DemandSuccess(t, err)
+	test.ExpectEquality(t, p.Bytes()[0x50], uint8(0xaa))
+}
+
+func TestVersion4(t *testing.T) {
+	hdr := a78.Header{Version: 4}
+	test.DemandSuccess(t, hdr.SetMapper("activision"))
+	test.DemandSuccess(t, hdr.SetAudio([]uint16{0x0450, 0x0440}, true))
+	test.ExpectEquality(t, hdr.MapperName(), "ACTIVISION")
+	test.ExpectEquality(t, hdr.MapperV4Name(), "activision")
+	test.ExpectEquality(t, len(hdr.Pokeys()), 2)
+	test.ExpectEquality(t, hdr.CartType&a78.CartTypeYM2151, uint16(a78.CartTypeYM2151))
+	test.ExpectEquality(t, len(hdr.AudioV4Names()), 2)
+
+	// changing the audio does not change the mapper
+	test.DemandSuccess(t, hdr.SetAudio(nil, false))
+	test.ExpectEquality(t, hdr.MapperName(), "ACTIVISION")
+	test.ExpectEquality(t, len(hdr.Pokeys()), 0)
+	test.ExpectEquality(t, len(hdr.AudioV4Names()), 0)
+
+	test.ExpectFailure(t, hdr.SetMapper("SN"))
+	test.ExpectFailure(t, hdr.SetAudio([]uint16{0x1234}, false))
+	test.ExpectFailure(t, hdr.SetAudio([]uint16{0x4000, 0x0800}, false))
+	test.ExpectFailure(t, hdr.SetController(1, "keyboard"))
+	test.ExpectFailure(t, hdr.SetTV("SECAM"))
+}
+
+func TestELFModel(t *testing.T) {
+	hdr := a78.Header{Version: 4}
+	test.ExpectEquality(t, hdr.ELFModelName(), "")
+	test.DemandSuccess(t, hdr.SetELFModel("harmony"))
+	test.ExpectEquality(t, hdr.ELFModelName(), "HARMONY")
+	test.ExpectFailure(t, hdr.SetELFModel("MELODY"))
+
+	// the ELF model does not share a byte with any of the version 4 fields
+	for _, m := range []string{"SUPERGAME_EXRAM", "SUPERGAME_EXROM", "BANKSETS_RAM"} {
+		test.DemandSuccess(t, hdr.SetMapper(m))
+		test.DemandSuccess(t, hdr.SetAudio([]uint16{0x0450}, true))
+		p, _, err := a78.Parse(hdr.Bytes())
+		test.DemandSuccess(t, err)
+		test.ExpectEquality(t, p.ELFModelName(), "HARMONY")
+		test.ExpectEquality(t, p.MapperName(), m)
+	}
+
+	test.DemandSuccess(t, hdr.SetMapper("SUPERGAME_EXRAM"))
+	test.DemandSuccess(t, hdr.SetELFModel("NONE"))
+	p, _, err := a78.Parse(hdr.Bytes())
+	test.DemandSuccess(t, err)
+	test.ExpectEquality(t, p.ELFModelName(), "")
+	test.ExpectEquality(t, p.MapperOptions, uint8(1))
+}
+
+func TestParseErrors(t *testing.T) {
+	_, _, err := a78.Parse([]byte("not an a78 file"))
+	test.ExpectFailure(t, err)
+
+	hdr := a78.Header{Version: 3}
+	b := hdr.Bytes()
+	_, _, err = a78.Parse(b[:a78.HeaderSize-1])
+	test.ExpectFailure(t, err)
+}
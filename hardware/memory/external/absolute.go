@@ -64,3 +64,16 @@ func (ext *Absolute) Access(write bool, address uint16, data uint8) (uint8, erro
 	// fixed 32k block, upper 16k
 	return ext.data[3][address-0xc000], nil
 }
+
+func (ext *Absolute) Bank(address uint16) (int, bool) {
+	if address < 0x4000 {
+		return 0, false
+	}
+	if address < 0x8000 {
+		return ext.bank, true
+	}
+	if address < 0xc000 {
+		return 2, true
+	}
+	return 3, true
+}
@@ -88,3 +88,19 @@ func (ext *Activision) Access(write bool, address uint16, data uint8) (uint8, er
 	// first 8k of bank 7
 	return ext.data[7][address-0xe000], nil
 }
+
+func (ext *Activision) Bank(address uint16) (int, bool) {
+	if address < 0x4000 {
+		return 0, false
+	}
+	if address < 0x8000 {
+		return 6, true
+	}
+	if address < 0xa000 {
+		return 7, true
+	}
+	if address < 0xe000 {
+		return ext.bank, true
+	}
+	return 7, true
+}
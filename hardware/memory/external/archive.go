@@ -0,0 +1,195 @@
+package external
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// the extensions of the files in a zip archive that are treated as cartridges
+var archiveExtensions = []string{".a78", ".bin", ".elf"}
+
+// the largest file that will be extracted from an archive
+const archiveMaxSize = 64 * 1024 * 1024
+
+// ArchiveEntry is a cartridge file extracted from an archive
+type ArchiveEntry struct {
+	// the name of the archive joined with the name of the file inside the
+	// archive. the name of the file is important because it can be used to
+	// decide how the cartridge is inserted
+	Filename string
+
+	// the path of the file inside the archive. the path always uses forward
+	// slashes as the separator
+	Path string
+
+	Data []uint8
+}
+
+// Name returns the name of the file inside the archive
+func (e ArchiveEntry) Name() string {
+	return filepath.Base(e.Filename)
+}
+
+// ArchiveChoice is the error returned by Fingerprint() and FingerprintBlob()
+// when an archive contains more than one cartridge file. one of the entries
+// should be chosen and given to FingerprintBlob()
+//
+// alternatively, the entry can be chosen by giving Fingerprint() a filename
+// of the form "archive.zip:path" where path is the path of the entry inside the
+// archive
+type ArchiveChoice struct {
+	Archive string
+	Entries []ArchiveEntry
+}
+
+func (e ArchiveChoice) Error() string {
+	var paths []string
+	for _, n := range e.Entries {
+		paths = append(paths, n.Path)
+	}
+	return fmt.Sprintf("%s contains %d cartridge files: %s. choose one with %s:<file>",
+		filepath.Base(e.Archive), len(e.Entries), strings.Join(paths, ", "), filepath.Base(e.Archive))
+}
+
+// the separator between the name of an archive and the path of an entry inside
+// the archive
+const archiveSelector = ":"
+
+// splits a filename of the form "archive.zip:path" into the archive filename
+// and the path of the entry inside the archive. the archive must have a .zip
+// or .gz extension. returns false if the filename does not have that form
+func splitArchiveSelector(filename string) (string, string, bool) {
+	idx := strings.LastIndex(filename, archiveSelector)
+	if idx == -1 {
+		return "", "", false
+	}
+	archive := filename[:idx]
+	inner := filename[idx+len(archiveSelector):]
+	if inner == "" {
+		return "", "", false
+	}
+	if !hasArchiveExtension(archive) {
+		return "", "", false
+	}
+	return archive, inner, true
+}
+
+// the filename has the extension of a zip or gzip archive
+func hasArchiveExtension(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".zip", ".gz":
+		return true
+	}
+	return false
+}
+
+// SelectArchiveEntry returns the entry with the path. the path can be the full
+// path of the entry inside the archive or just the name of the file, so long as
+// the name is unique
+func SelectArchiveEntry(entries []ArchiveEntry, path string) (ArchiveEntry, error) {
+	path = filepath.ToSlash(path)
+	var matches []ArchiveEntry
+	for _, e := range entries {
+		if e.Path == path {
+			return e, nil
+		}
+		if e.Name() == path {
+			matches = append(matches, e)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return ArchiveEntry{}, fmt.Errorf("archive: no cartridge file named %s", path)
+	case 1:
+		return matches[0], nil
+	}
+	return ArchiveEntry{}, fmt.Errorf("archive: more than one cartridge file named %s", path)
+}
+
+// the data starts like a zip or gzip archive. a headerless cartridge can start
+// with the same bytes so the data is not certain to be an archive
+func isArchive(d []uint8) bool {
+	return bytes.HasPrefix(d, []byte("PK\x03\x04")) || bytes.HasPrefix(d, []byte{0x1f, 0x8b})
+}
+
+// read data from the reader. returns an error if the data is larger than
+// archiveMaxSize
+func archiveRead(r io.Reader) ([]uint8, error) {
+	d, err := io.ReadAll(io.LimitReader(r, archiveMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(d) > archiveMaxSize {
+		return nil, fmt.Errorf("archive: file is too large")
+	}
+	return d, nil
+}
+
+// Unarchive returns the cartridge files in a zip or gzip archive. files in a
+// zip archive are only returned if they have one of the extensions used for
+// cartridges. a gzip archive always contains exactly one file
+func Unarchive(filename string, d []uint8) ([]ArchiveEntry, error) {
+	if bytes.HasPrefix(d, []byte{0x1f, 0x8b}) {
+		r, err := gzip.NewReader(bytes.NewReader(d))
+		if err != nil {
+			return nil, fmt.Errorf("archive: %w", err)
+		}
+		defer r.Close()
+
+		data, err := archiveRead(r)
+		if err != nil {
+			return nil, fmt.Errorf("archive: %w", err)
+		}
+
+		// the name of the compressed file is optional. if it's not present
+		// then the name of the archive without the .gz extension is used
+		name := path.Base(r.Name)
+		if r.Name == "" {
+			name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+		}
+
+		return []ArchiveEntry{
+			{Filename: filepath.Join(filename, name), Path: name, Data: data},
+		}, nil
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(d), int64(len(d)))
+	if err != nil {
+		return nil, fmt.Errorf("archive: %w", err)
+	}
+
+	var entries []ArchiveEntry
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "__MACOSX/") {
+			continue // for loop
+		}
+		if !slices.Contains(archiveExtensions, strings.ToLower(path.Ext(f.Name))) {
+			continue // for loop
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("archive: %w", err)
+		}
+		data, err := archiveRead(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("archive: %s: %w", f.Name, err)
+		}
+
+		entries = append(entries, ArchiveEntry{
+			Filename: filepath.Join(filename, filepath.FromSlash(f.Name)),
+			Path:     f.Name,
+			Data:     data,
+		})
+	}
+
+	return entries, nil
+}
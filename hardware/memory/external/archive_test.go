@@ -0,0 +1,167 @@
+package external
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jetsetilly/test7800/test"
+)
+
+// returns a zip archive containing the files
+func testZip(t *testing.T, files map[string][]uint8) []uint8 {
+	t.Helper()
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for n, d := range files {
+		f, err := w.Create(n)
+		test.DemandSuccess(t, err)
+		_, err = f.Write(d)
+		test.DemandSuccess(t, err)
+	}
+	test.DemandSuccess(t, w.Close())
+	return b.Bytes()
+}
+
+func TestArchive_zip(t *testing.T) {
+	rom := fillData(0x8000)
+
+	d := testZip(t, map[string][]uint8{
+		"game (OM).bin": rom,
+		"readme.txt":    []uint8("not a cartridge"),
+	})
+
+	entries, err := Unarchive("games.zip", d)
+	test.DemandSuccess(t, err)
+	test.DemandEquality(t, len(entries), 1)
+	test.ExpectEquality(t, entries[0].Name(), "game (OM).bin")
+	test.ExpectEquality(t, entries[0].Filename, filepath.Join("games.zip", "game (OM).bin"))
+	test.ExpectSuccess(t, bytes.Equal(entries[0].Data, rom))
+
+	// the single cartridge is used and the filename is the name inside the archive
+	c, err := FingerprintBlob("games.zip", d, "AUTO")
+	test.DemandSuccess(t, err)
+	test.ExpectEquality(t, c.Filename(), filepath.Join("games.zip", "game (OM).bin"))
+	test.ExpectSuccess(t, bytes.Equal(c.Data(), rom))
+
+	// more than one cartridge requires a choice
+	d = testZip(t, map[string][]uint8{
+		"a.a78": rom,
+		"b.BIN": rom,
+	})
+	_, err = FingerprintBlob("games.zip", d, "AUTO")
+	var choice ArchiveChoice
+	test.DemandSuccess(t, errors.As(err, &choice))
+	test.ExpectEquality(t, len(choice.Entries), 2)
+
+	// the error lists the entries so that the user can choose one without a
+	// dialog
+	test.ExpectSuccess(t, strings.Contains(err.Error(), "a.a78"))
+	test.ExpectSuccess(t, strings.Contains(err.Error(), "b.BIN"))
+
+	// no cartridges is an error
+	d = testZip(t, map[string][]uint8{
+		"readme.txt": []uint8("not a cartridge"),
+	})
+	_, err = FingerprintBlob("games.zip", d, "AUTO")
+	test.ExpectFailure(t, err)
+	test.ExpectFailure(t, errors.As(err, &choice))
+}
+
+func TestArchive_gzip(t *testing.T) {
+	rom := fillData(0x8000)
+
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	_, err := w.Write(rom)
+	test.DemandSuccess(t, err)
+	test.DemandSuccess(t, w.Close())
+
+	// without a name in the gzip header the name of the archive is used
+	c, err := FingerprintBlob("game.bin.gz", b.Bytes(), "AUTO")
+	test.DemandSuccess(t, err)
+	test.ExpectEquality(t, filepath.Base(c.Filename()), "game.bin")
+	test.ExpectSuccess(t, bytes.Equal(c.Data(), rom))
+
+	b.Reset()
+	w = gzip.NewWriter(&b)
+	w.Name = "other.a78"
+	_, err = w.Write(rom)
+	test.DemandSuccess(t, err)
+	test.DemandSuccess(t, w.Close())
+
+	c, err = FingerprintBlob("game.bin.gz", b.Bytes(), "AUTO")
+	test.DemandSuccess(t, err)
+	test.ExpectEquality(t, filepath.Base(c.Filename()), "other.a78")
+}
+
+func TestArchive_headerless(t *testing.T) {
+	// a headerless cartridge that happens to start with the same bytes as an
+	// archive is inserted as a cartridge
+	for _, magic := range [][]uint8{{0x1f, 0x8b}, []uint8("PK\x03\x04")} {
+		rom := fillData(0x8000)
+		copy(rom, magic)
+
+		c, err := FingerprintBlob("game.bin", rom, "AUTO")
+		test.DemandSuccess(t, err)
+		test.ExpectEquality(t, c.Filename(), "game.bin")
+		test.ExpectSuccess(t, bytes.Equal(c.Data(), rom))
+
+		// the same data named as an archive is an error
+		_, err = FingerprintBlob("game.zip", rom, "AUTO")
+		test.ExpectFailure(t, err)
+		_, err = FingerprintBlob("game.bin.gz", rom, "AUTO")
+		test.ExpectFailure(t, err)
+	}
+}
+
+func TestArchive_selector(t *testing.T) {
+	rom := fillData(0x8000)
+	other := fillData(0x4000)
+
+	d := testZip(t, map[string][]uint8{
+		"a.a78":     rom,
+		"dir/b.bin": other,
+		"dup/b.bin": other,
+		"c.bin":     other,
+	})
+
+	archive := filepath.Join(t.TempDir(), "games.zip")
+	test.DemandSuccess(t, os.WriteFile(archive, d, 0644))
+
+	// without a selector the archive requires a choice
+	_, err := Fingerprint(archive, "AUTO")
+	var choice ArchiveChoice
+	test.DemandSuccess(t, errors.As(err, &choice))
+
+	c, err := Fingerprint(archive+":a.a78", "AUTO")
+	test.DemandSuccess(t, err)
+	test.ExpectEquality(t, c.Filename(), filepath.Join(archive, "a.a78"))
+	test.ExpectSuccess(t, bytes.Equal(c.Data(), rom))
+
+	// the full path inside the archive
+	c, err = Fingerprint(archive+":dir/b.bin", "AUTO")
+	test.DemandSuccess(t, err)
+	test.ExpectEquality(t, c.Filename(), filepath.Join(archive, "dir", "b.bin"))
+
+	// the name of a file is enough if it is unique
+	c, err = Fingerprint(archive+":c.bin", "AUTO")
+	test.DemandSuccess(t, err)
+	test.ExpectSuccess(t, bytes.Equal(c.Data(), other))
+
+	// but not if it isn't
+	_, err = Fingerprint(archive+":b.bin", "AUTO")
+	test.ExpectFailure(t, err)
+
+	_, err = Fingerprint(archive+":missing.bin", "AUTO")
+	test.ExpectFailure(t, err)
+
+	// a selector is only recognised for archives
+	_, err = Fingerprint(filepath.Join(t.TempDir(), "game.bin:a.a78"), "AUTO")
+	test.ExpectFailure(t, err)
+}
@@ -182,6 +182,23 @@ func (ext *Banksets) Access(write bool, address uint16, data uint8) (uint8, erro
 	return (*ext.data)[0][address-ext.origin], nil
 }
 
+func (ext *Banksets) Bank(address uint16) (int, bool) {
+	// only supergame bankset ROMs are banked
+	if len(*ext.data) <= 1 || address < 0x4000 {
+		return 0, false
+	}
+	if address < 0x8000 {
+		if len(*ext.ram) > 0 {
+			return 0, false
+		}
+		return len(*ext.data) - 2, true
+	}
+	if address < 0xc000 {
+		return ext.bank, true
+	}
+	return len(*ext.data) - 1, true
+}
+
 func (ext *Banksets) HLT(hlt bool) {
 	ext.hlt = hlt
 	if hlt {
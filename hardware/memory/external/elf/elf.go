@@ -25,6 +25,7 @@ import (
 	"github.com/jetsetilly/test7800/hardware/arm"
 	"github.com/jetsetilly/test7800/hardware/cpu"
 	"github.com/jetsetilly/test7800/hardware/spec"
+	"github.com/jetsetilly/test7800/logger"
 )
 
 const (
@@ -41,17 +42,30 @@ type Context interface {
 	Break(e error)
 	Spec() spec.Spec
 	IsAtari7800() bool
+	ARMPreferences() *arm.Preferences
+
+	// the hardware model requested by the user. one of the values in
+	// ModelOptions or the empty string
+	ELFModel() string
 }
 
 type Elf struct {
 	ctx     Context
 	version string
 
+	// the hardware model being emulated. one of the values in ModelOptions
+	model string
+
 	arm *arm.ARM
 	mem *elfMemory
 
 	// the hook that handles cartridge yields
 	yieldHook coprocessor.CartYieldHook
+
+	// the number of ARM cycles that have not yet been accounted for by the
+	// passing of time in the console. only used when the ARM is not in
+	// immediate mode
+	pending float32
 }
 
 // elfReaderAt is an implementation of io.ReaderAt and is used with elf.NewFile()
@@ -78,8 +92,10 @@ func (r *elfReaderAt) ReadAt(p []byte, start int64) (n int, err error) {
 	return n, nil
 }
 
-// NewElf is the preferred method of initialisation for the Elf type.
-func NewElf(ctx Context, d []byte) (*Elf, error) {
+// NewElf is the preferred method of initialisation for the Elf type. The model
+// argument is the hardware model specified by the container of the ELF data (eg.
+// an a78 header) and can be empty.
+func NewElf(ctx Context, d []byte, model string) (*Elf, error) {
 	r := &elfReaderAt{data: d}
 
 	// ELF file is read via our elfReaderAt instance
@@ -110,13 +126,24 @@ func NewElf(ctx Context, d []byte) (*Elf, error) {
 		return nil, fmt.Errorf("ELF: is not little-endian")
 	}
 
+	model, source, err := selectModel(ctx.ELFModel(), model, modelFromNote(ef))
+	if err != nil {
+		return nil, fmt.Errorf("ELF: %w", err)
+	}
+	cartArch, err := modelArchitecture(model)
+	if err != nil {
+		return nil, fmt.Errorf("ELF: %w", err)
+	}
+	logger.Logf(logger.Allow, "ELF", "hardware model: %s (%s)", model, source)
+
 	cart := &Elf{
 		ctx:       ctx,
+		model:     model,
 		yieldHook: coprocessor.StubCartYieldHook{},
 	}
 
-	cart.mem = newElfMemory(ctx)
-	cart.arm = arm.NewARM(cart.mem.model, cart.mem, cart)
+	cart.mem = newElfMemory(ctx, cartArch)
+	cart.arm = arm.NewARM(cart.mem.model, ctx.ARMPreferences(), cart.mem, cart)
 	cart.arm.CycleDuringImmediateMode(true)
 	cart.mem.arm = cart.arm
 	err = cart.mem.decode(ef)
@@ -144,11 +171,22 @@ func (cart *Elf) Label() string {
 	return "ELF"
 }
 
+// Model returns the hardware model being emulated
+func (cart *Elf) Model() string {
+	return cart.model
+}
+
 // reset is distinct from Reset(). this reset function is implied by the
 // reading of the reset address.
 func (cart *Elf) reset() {
-	// stream bytes rather than injecting them into the VCS as they arrive
-	cart.mem.stream.active = !cart.mem.stream.disabled
+	// stream bytes rather than injecting them into the VCS as they arrive. byte
+	// streaming allows the ARM to run ahead of the console so it can't be used
+	// when cycles are being counted
+	cart.mem.stream.active = !cart.mem.stream.disabled && cart.arm.Preferences().Immediate.Get()
+	cart.pending = 0
+
+	// windows are set up by the program after reset
+	cart.mem.windows = [numWindows]window{}
 
 	// initialise ROM for the VCS
 	if cart.mem.stream.active {
@@ -194,7 +232,14 @@ func (cart *Elf) reset() {
 	cart.arm.SetInitialRegisters(argOrigin)
 }
 
-func (cart *Elf) Access(_ bool, addr uint16, _ uint8) (uint8, error) {
+func (cart *Elf) Access(write bool, addr uint16, data uint8) (uint8, error) {
+	if write && cart.mem.windowWrite(addr, data) {
+		return data, nil
+	}
+	if d, ok := cart.mem.windowRead(addr); ok {
+		return d, nil
+	}
+
 	if cart.mem.stream.active {
 		if !cart.mem.stream.drain {
 			if cart.runARM(addr) {
@@ -234,7 +279,9 @@ func (cart *Elf) runARM(addr uint16) bool {
 	defer cart.arm.ProcessProfiling()
 
 	// call arm once and then check for yield conditions
-	cart.mem.yield, _ = cart.arm.Run()
+	var cycles float32
+	cart.mem.yield, cycles = cart.arm.Run()
+	cart.addPending(cycles)
 
 	// keep calling runArm() for as long as program does not need to sync with the VCS
 	for cart.mem.yield.Type != coprocessor.YieldSyncWithVCS {
@@ -255,13 +302,25 @@ func (cart *Elf) runARM(addr uint16) bool {
 		case coprocessor.YieldHookEnd:
 			return false
 		case coprocessor.YieldHookContinue:
-			cart.mem.yield, _ = cart.arm.Run()
+			cart.mem.yield, cycles = cart.arm.Run()
+			cart.addPending(cycles)
 		}
 	}
 
 	return true
 }
 
+// addPending adds the cycles used by the ARM program to the number of pending
+// cycles. cycles are not counted when bytes are being streamed because the ARM
+// is allowed to run ahead of the console in that mode. this can happen if the
+// arm.immediate preference is changed after the most recent reset
+func (cart *Elf) addPending(cycles float32) {
+	if cart.mem.stream.active {
+		return
+	}
+	cart.pending += cycles
+}
+
 func (cart *Elf) BusChange(addr uint16, data uint8) error {
 	if cart.mem.stream.active && cart.mem.stream.drain {
 		return nil
@@ -281,6 +340,12 @@ func (cart *Elf) BusChange(addr uint16, data uint8) error {
 		cart.reset()
 	}
 
+	// the ARM does not synchronise with accesses to a window
+	if cart.mem.inWindow(addr) {
+		cart.mem.parallelARM = true
+		return nil
+	}
+
 	// set GPIO data and address information
 	cart.mem.gpio.data[DATA_IDR] = data
 	cart.mem.gpio.data[ADDR_IDR] = uint8(addr)
@@ -292,6 +357,12 @@ func (cart *Elf) BusChange(addr uint16, data uint8) error {
 		return nil
 	}
 
+	// the ARM program should have completed by the time the console next
+	// accesses the cartridge
+	if !cart.mem.parallelARM && cart.pending > 0 {
+		cart.overrun()
+	}
+
 	// handle ARM synchronisation for non-byte-streaming mode. the sequence of
 	// calls to runARM() and whatever strongarm function might be active was
 	// arrived through experimentation. a more efficient way of doing this
@@ -338,7 +409,34 @@ func (cart *Elf) BusChange(addr uint16, data uint8) error {
 	return nil
 }
 
+// overrun is called when the console accesses the cartridge before the ARM
+// program has completed
+func (cart *Elf) overrun() {
+	yld := coprocessor.CoProcYield{
+		Type:  coprocessor.YieldSyncOverrun,
+		Error: fmt.Errorf("ARM program overran by %.0f cycles", cart.pending),
+	}
+	cart.pending = 0
+	logger.Logf(logger.Allow, "ELF", "%s: %v", yld.Type, yld.Error)
+
+	// the program will continue regardless of the hook's response
+	_ = cart.yieldHook.CartYield(yld)
+}
+
 func (cart *Elf) Step(clock float32) {
+	// the ARM timer has already been advanced for any cycles used by the ARM
+	// program. the timer is not advanced again until the console has caught up
+	if cart.pending > 0 {
+		cart.pending -= cart.arm.Clk / clock
+		cart.pending = max(cart.pending, 0)
+		return
+	}
+
+	// in immediate mode the ARM timer is advanced by the ARM itself as the
+	// program executes. see arm.CycleDuringImmediateMode()
+	if cart.arm.ImmediateMode() {
+		return
+	}
 	cart.arm.Step(clock)
 }
 
@@ -371,14 +469,41 @@ func (cart *Elf) BusStuff() (uint8, bool) {
 	return 0, false
 }
 
+// Section returns the data and origin of the named section. the data of a
+// section that has been loaded into SRAM is the current contents of SRAM
 func (cart *Elf) Section(name string) ([]uint8, uint32) {
 	if idx, ok := cart.mem.sectionsByName[name]; ok {
 		s := cart.mem.sections[idx]
+		if s.inSRAM {
+			sram := *cart.mem.sram.Data()
+			return sram[s.origin-cart.mem.sramOrigin : s.origin-cart.mem.sramOrigin+uint32(len(s.data))], s.origin
+		}
 		return s.data, s.origin
 	}
 	return nil, 0
 }
 
+// Symbol returns the address of a symbol defined by the ELF program. the
+// address of a Thumb function has the lowest bit set
+func (cart *Elf) Symbol(name string) (uint32, bool) {
+	addr, ok := cart.mem.symbolAddrs[name]
+	return addr, ok
+}
+
+// HighAddress returns the highest address used by the variables of the ELF
+// program. the variables are loaded into the bottom of SRAM and the stack
+// grows down towards them from the top of SRAM. returns zero if there are no
+// variables
+func (cart *Elf) HighAddress() uint32 {
+	var high uint32
+	for _, s := range cart.mem.sections {
+		if s.inSRAM {
+			high = max(high, s.memtop)
+		}
+	}
+	return high
+}
+
 func (cart *Elf) CoProcExecutionState() coprocessor.CoProcExecutionState {
 	if cart.mem.parallelARM {
 		return coprocessor.CoProcExecutionState{
@@ -0,0 +1,103 @@
+package elf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jetsetilly/test7800/coprocessor"
+	"github.com/jetsetilly/test7800/test"
+)
+
+// testYieldHook records the yields given to the cartridge's yield hook
+type testYieldHook struct {
+	yields []coprocessor.CoProcYield
+}
+
+func (hook *testYieldHook) CartYield(yld coprocessor.CoProcYield) coprocessor.YieldHookResponse {
+	hook.yields = append(hook.yields, yld)
+	return coprocessor.YieldHookEnd
+}
+
+// returns a cartridge with the ARM counting cycles. the cartridge has not been
+// reset so the ARM program will run from the beginning of main()
+func testCycleCounting(t *testing.T) (*Elf, *testYieldHook) {
+	t.Helper()
+	cart, err := NewElf(&testContext{}, testProgram, "")
+	test.DemandSuccess(t, err)
+	cart.arm.Preferences().Immediate.Set(false)
+	hook := &testYieldHook{}
+	cart.SetYieldHook(hook)
+	return cart, hook
+}
+
+func TestPending(t *testing.T) {
+	cart, _ := testCycleCounting(t)
+
+	// a clock speed that is one tenth the speed of the ARM
+	clock := cart.arm.Clk / 10
+
+	cart.addPending(25)
+	test.ExpectEquality(t, cart.pending, 25)
+
+	// every step of the console accounts for ten ARM cycles
+	cart.Step(clock)
+	test.ExpectEquality(t, cart.pending, 15)
+	cart.Step(clock)
+	test.ExpectEquality(t, cart.pending, 5)
+
+	// pending cycles never go below zero
+	cart.Step(clock)
+	test.ExpectEquality(t, cart.pending, 0)
+
+	// the ARM program uses cycles when it runs
+	test.ExpectSuccess(t, cart.runARM(OriginCart))
+	test.ExpectEquality(t, cart.mem.yield.Type, coprocessor.YieldSyncWithVCS)
+	test.ExpectSuccess(t, cart.pending > 0)
+
+	// cycles are not counted if bytes are being streamed. this can happen if
+	// the immediate preference is changed after the most recent reset
+	cart.pending = 0
+	cart.mem.stream.active = true
+	cart.addPending(25)
+	test.ExpectEquality(t, cart.pending, 0)
+}
+
+func TestOverrun(t *testing.T) {
+	cart, hook := testCycleCounting(t)
+
+	// the console accessing a non-cartridge address is not an overrun because
+	// the ARM program runs in parallel with the console
+	cart.pending = 50
+	cart.BusChange(0x0000, 0x00)
+	for _, yld := range hook.yields {
+		test.ExpectInequality(t, yld.Type, coprocessor.YieldSyncOverrun)
+	}
+
+	// accessing the cartridge with cycles still pending is an overrun
+	cart.pending = 50
+	hook.yields = hook.yields[:0]
+	cart.BusChange(OriginCart, 0x00)
+	test.DemandSuccess(t, len(hook.yields) > 0)
+	test.ExpectEquality(t, hook.yields[0].Type, coprocessor.YieldSyncOverrun)
+	test.ExpectSuccess(t, strings.Contains(hook.yields[0].Error.Error(), "50 cycles"))
+
+	// an access with no pending cycles is not an overrun
+	cart.pending = 0
+	hook.yields = hook.yields[:0]
+	cart.BusChange(OriginCart, 0x00)
+	for _, yld := range hook.yields {
+		test.ExpectInequality(t, yld.Type, coprocessor.YieldSyncOverrun)
+	}
+}
+
+func TestStepImmediate(t *testing.T) {
+	cart, _ := testCycleCounting(t)
+	cart.arm.Preferences().Immediate.Set(true)
+
+	// pending cycles are accounted for even if the ARM has returned to
+	// immediate mode. otherwise the next access to the cartridge would be an
+	// overrun
+	cart.pending = 25
+	cart.Step(cart.arm.Clk / 10)
+	test.ExpectEquality(t, cart.pending, 15)
+}
@@ -5,6 +5,7 @@ import (
 	_ "embed"
 	"testing"
 
+	"github.com/jetsetilly/test7800/hardware/arm"
 	"github.com/jetsetilly/test7800/hardware/memory/external/elf"
 	"github.com/jetsetilly/test7800/hardware/spec"
 	"github.com/jetsetilly/test7800/logger"
@@ -18,6 +19,7 @@ var testfile []byte
 var testfile_log []byte
 
 type Context struct {
+	model string
 }
 
 func (c *Context) Rand8Bit() uint8 {
@@ -35,8 +37,19 @@ func (c *Context) IsAtari7800() bool {
 	return true
 }
 
+func (c *Context) ARMPreferences() *arm.Preferences {
+	return nil
+}
+
+func (c *Context) ELFModel() string {
+	return c.model
+}
+
 func TestELF(t *testing.T) {
-	e, err := elf.NewElf(&Context{}, testfile)
+	// the log is compared with the expected output below
+	logger.Clear()
+
+	e, err := elf.NewElf(&Context{}, testfile, "")
 	test.ExpectSuccess(t, err)
 	test.ExpectInequality(t, e, nil)
 
@@ -48,8 +61,40 @@ func TestELF(t *testing.T) {
 	s, _ = e.Section(".foo")
 	test.ExpectFailure(t, s != nil)
 
+	// the entry function is a Thumb function at the start of the .text section
+	_, origin := e.Section(".text")
+	addr, ok := e.Symbol("elf_main")
+	test.ExpectEquality(t, ok, true)
+	test.ExpectEquality(t, addr, origin|1)
+	_, ok = e.Symbol("foo")
+	test.ExpectEquality(t, ok, false)
+
 	// logging output
 	b := &bytes.Buffer{}
 	logger.Tail(b, -1)
 	test.ExpectEquality(t, b.String(), string(testfile_log))
 }
+
+func TestModel(t *testing.T) {
+	// the default model loads the program into CCM
+	e, err := elf.NewElf(&Context{}, testfile, "")
+	test.DemandSuccess(t, err)
+	test.ExpectEquality(t, e.Model(), "PLUSCART")
+	_, origin := e.Section(".text")
+	test.ExpectEquality(t, origin, uint32(0x10000000))
+
+	// the model in the a78 header has priority over the default
+	e, err = elf.NewElf(&Context{}, testfile, "HARMONY")
+	test.DemandSuccess(t, err)
+	test.ExpectEquality(t, e.Model(), "HARMONY")
+	_, origin = e.Section(".text")
+	test.ExpectEquality(t, origin, uint32(0x00000800))
+
+	// the model requested by the user has priority over everything
+	e, err = elf.NewElf(&Context{model: "unocart"}, testfile, "HARMONY")
+	test.DemandSuccess(t, err)
+	test.ExpectEquality(t, e.Model(), "UNOCART")
+
+	_, err = elf.NewElf(&Context{model: "MELODY"}, testfile, "")
+	test.ExpectFailure(t, err)
+}
@@ -43,11 +43,13 @@ func (g *gpio) Snapshot() *gpio {
 	return &n
 }
 
-func newGPIO() *gpio {
+// the origin of the GPIO data is different for each cartridge architecture.
+// programs find the GPIO data through the lookup table, which does not move
+func newGPIO(dataOrigin uint32) *gpio {
 	g := gpio{
 		data:       make([]byte, GPIO_MEMTOP),
-		dataOrigin: 0x40000000,
-		dataMemtop: 0x40000000 | GPIO_MEMTOP,
+		dataOrigin: dataOrigin,
+		dataMemtop: dataOrigin | GPIO_MEMTOP,
 
 		lookup:       make([]byte, GPIO_MEMTOP),
 		lookupOrigin: 0x40020000,
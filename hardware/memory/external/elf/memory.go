@@ -53,6 +53,10 @@ type elfSection struct {
 	// this can happen when trying to execute the last instruction in the
 	// program
 	trailingBytes uint32
+
+	// the section has been loaded into SRAM. the data field holds the initial
+	// contents of the section and the live contents are in SRAM
+	inSRAM bool
 }
 
 func (sec elfSection) readOnly() bool {
@@ -70,6 +74,12 @@ func (sec elfSection) inMemory() bool {
 		sec.debugging == false
 }
 
+// variables are in the writable data sections. the init array sections are
+// writable but they're only used during initialisation
+func (sec elfSection) variables() bool {
+	return sec.inMemory() && !sec.readOnly() && !sec.executable() && sec.typ != elf.SHT_INIT_ARRAY
+}
+
 func (s *elfSection) String() string {
 	return fmt.Sprintf("%s %d %08x %08x", s.name, len(s.data), s.origin, s.memtop)
 }
@@ -92,6 +102,9 @@ type elfMemory struct {
 	// input/output pins
 	gpio *gpio
 
+	// the address at which the ELF sections are loaded
+	programOrigin uint32
+
 	// RAM memory for the ARM
 	sram       crunched.Data
 	sramOrigin uint32
@@ -107,6 +120,9 @@ type elfMemory struct {
 
 	symbols []elf.Symbol
 
+	// the address of every symbol defined by the program. keyed by name
+	symbolAddrs map[string]uint32
+
 	// strongARM support. like the elf sections, the strongARM program is placed
 	// in flash memory
 	strongArmProgram []byte
@@ -154,6 +170,9 @@ type elfMemory struct {
 	// byte stream support
 	stream stream
 
+	// cartridge addresses that are served directly from ARM memory
+	windows [numWindows]window
+
 	// last mapping infomation. the address and whether the memory space
 	// contains executable instructions. used by IsExecutable() to return
 	// additional information about the address
@@ -185,19 +204,41 @@ type elfMemory struct {
 	md5sum string
 }
 
-func newElfMemory(ctx Context) *elfMemory {
+// the amount of SRAM
+const sramSize = 0x10000
+
+// the stack starts this number of bytes below the top of SRAM
+const sramStackOffset = 0x24
+
+// the amount of SRAM that must be left free for the stack after the variables
+// have been loaded. the stack can still grow into the variables, in which case
+// the ARM will detect the collision
+const sramStackSize = 0x400
+
+func newElfMemory(ctx Context, cart architecture.CartArchitecture) *elfMemory {
 	mem := &elfMemory{
 		ctx:            ctx,
-		gpio:           newGPIO(),
 		sectionsByName: make(map[string]int),
 		args:           make([]byte, argMemtop-argOrigin),
 	}
 
-	// always using PlusCart model for now
-	mem.model = architecture.NewMap(architecture.PlusCart)
+	mem.model = architecture.NewMap(cart)
+
+	// the Harmony driver occupies the first 2k of flash memory. the PlusCart
+	// and UnoCart firmware load the program into CCM
+	//
+	// the Harmony SRAM occupies the address normally used for the GPIO data so
+	// the GPIO data is moved to the address of the LPC2000 GPIO
+	switch mem.model.CartArchitecture {
+	case architecture.Harmony:
+		mem.programOrigin = mem.model.Regions["Flash"].Origin + 0x0800
+		mem.gpio = newGPIO(0xe0028000)
+	default:
+		mem.programOrigin = mem.model.Regions["CCM"].Origin
+		mem.gpio = newGPIO(0x40000000)
+	}
 
 	// SRAM creation
-	const sramSize = 0x10000 // 64kb of SRAM
 	mem.sram = crunched.NewQuick(sramSize)
 	mem.sramOrigin = mem.model.Regions["SRAM"].Origin
 	mem.sramMemtop = mem.sramOrigin + sramSize
@@ -217,8 +258,10 @@ func (mem *elfMemory) decode(ef *elf.File) error {
 	// note byte order
 	mem.byteOrder = ef.ByteOrder
 
-	// load sections
-	origin := mem.model.Regions["CCM"].Origin
+	// load sections. variables are loaded into the bottom of SRAM, the same
+	// memory as the stack. everything else is loaded into program memory
+	origin := mem.programOrigin
+	dataOrigin := mem.sramOrigin
 	for _, sec := range ef.Sections {
 		section := &elfSection{
 			name:      sec.Name,
@@ -242,13 +285,19 @@ func (mem *elfMemory) decode(ef *elf.File) error {
 
 		// we know about and record data for all sections but we don't load all of them into the corprocessor's memory
 		if section.inMemory() {
-			section.origin = origin
+			next := &origin
+			if section.variables() {
+				next = &dataOrigin
+				section.inSRAM = true
+			}
+
+			section.origin = *next
 			section.memtop = section.origin + uint32(len(section.data))
 
 			// prepare origin of next section and use that to  extend memtop so
 			// that it is continuous with the following section
-			origin = (section.memtop + 4) & 0xfffffffc
-			section.trailingBytes = origin - section.memtop
+			*next = (section.memtop + 4) & 0xfffffffc
+			section.trailingBytes = *next - section.memtop
 			if section.trailingBytes > 0 {
 				extend := make([]byte, section.trailingBytes)
 				section.data = append(section.data, extend...)
@@ -276,6 +325,11 @@ func (mem *elfMemory) decode(ef *elf.File) error {
 		}
 	}
 
+	// the variables must leave room for the stack at the top of SRAM
+	if dataOrigin >= mem.sramMemtop-sramStackSize {
+		return fmt.Errorf("ELF: variables (%d bytes) do not fit in SRAM", dataOrigin-mem.sramOrigin)
+	}
+
 	// sort section names
 	sort.Strings(mem.sectionNames)
 
@@ -292,6 +346,19 @@ func (mem *elfMemory) decode(ef *elf.File) error {
 		return fmt.Errorf("ELF: %w", err)
 	}
 
+	// note the address of symbols that are in a loaded section
+	mem.symbolAddrs = make(map[string]uint32)
+	for _, sym := range mem.symbols {
+		if sym.Name == "" || sym.Section == elf.SHN_UNDEF || int(sym.Section) >= len(ef.Sections) {
+			continue // for loop
+		}
+		if idx, ok := mem.sectionsByName[ef.Sections[sym.Section].Name]; ok {
+			if mem.sections[idx].inMemory() {
+				mem.symbolAddrs[sym.Name] = mem.sections[idx].origin + uint32(sym.Value)
+			}
+		}
+	}
+
 	// relocate all sections
 	for _, rel := range ef.Sections {
 		// ignore non-relocation sections for now
@@ -594,6 +661,14 @@ func (mem *elfMemory) decode(ef *elf.File) error {
 	// strongarm program has been created so we adjust the memtop value
 	mem.strongArmMemtop -= 1
 
+	// copy the relocated variables into SRAM
+	sram := *mem.sram.Data()
+	for _, s := range mem.sections {
+		if s.inSRAM {
+			copy(sram[s.origin-mem.sramOrigin:], s.data)
+		}
+	}
+
 	// strongarm address information
 	logger.Logf(logger.Allow, "ELF", "strongarm: %08x to %08x (%d)",
 		mem.strongArmOrigin, mem.strongArmMemtop, len(mem.strongArmProgram))
@@ -610,8 +685,8 @@ func (mem *elfMemory) runInitialisation(arm *arm.ARM) error {
 	// the link register should really link to a program that will indicate the
 	// program has ended. if we were emulating the real Uno/PlusCart firmware,
 	// the link register would point to the resume address in the firmware
-	mem.resetSP = mem.model.Regions["SRAM"].Origin | 0x0000ffdc
-	mem.resetLR = mem.model.Regions["CCM"].Origin
+	mem.resetSP = mem.sramOrigin | (sramSize - sramStackOffset)
+	mem.resetLR = mem.programOrigin
 
 	for _, typ := range []elf.SectionType{elf.SHT_PREINIT_ARRAY, elf.SHT_INIT_ARRAY} {
 		for _, sec := range mem.sections {
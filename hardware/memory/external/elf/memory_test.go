@@ -0,0 +1,96 @@
+package elf
+
+import (
+	_ "embed"
+	"testing"
+
+	"github.com/jetsetilly/test7800/coprocessor"
+	"github.com/jetsetilly/test7800/coprocessor/faults"
+	"github.com/jetsetilly/test7800/test"
+)
+
+//go:embed "test_data/7800backgroundcolors.bin"
+var testProgram []byte
+
+// testDeveloper records the memory faults reported by the ARM. every fault
+// stops the ARM
+type testDeveloper struct {
+	highAddress uint32
+	faults      []faults.Category
+}
+
+func (dev *testDeveloper) MemoryFault(_ string, explanation faults.Category, _ uint32, _ uint32) faults.Policy {
+	dev.faults = append(dev.faults, explanation)
+	return faults.Break
+}
+
+func (dev *testDeveloper) HighAddress() uint32                         { return dev.highAddress }
+func (dev *testDeveloper) CheckBreakpoint(_ uint32) bool               { return false }
+func (dev *testDeveloper) Profiling() *coprocessor.CartCoProcProfiler  { return nil }
+func (dev *testDeveloper) StartProfiling()                             {}
+func (dev *testDeveloper) ProcessProfiling()                           {}
+func (dev *testDeveloper) OnYield(_ uint32, _ coprocessor.CoProcYield) {}
+
+// the register number of the stack pointer
+const testSP = 13
+
+func TestVariablesInSRAM(t *testing.T) {
+	for _, model := range []string{"PLUSCART", "HARMONY"} {
+		cart, err := NewElf(&testContext{}, testProgram, model)
+		test.DemandSuccess(t, err)
+
+		// the variables are at the bottom of SRAM, in the same memory block as
+		// the stack
+		_, origin := cart.Section(".data")
+		test.ExpectEquality(t, origin, cart.mem.sramOrigin)
+		high := cart.HighAddress()
+		test.ExpectSuccess(t, high > cart.mem.sramOrigin)
+
+		_, stackOrigin := cart.mem.MapAddress(cart.mem.resetSP, true, false)
+		_, variableOrigin := cart.mem.MapAddress(high, true, false)
+		test.ExpectEquality(t, stackOrigin, variableOrigin)
+
+		// section data is the live contents of SRAM
+		(*cart.mem.sram.Data())[origin-cart.mem.sramOrigin] = 0xaa
+		d, _ := cart.Section(".data")
+		test.ExpectEquality(t, d[0], uint8(0xaa))
+	}
+}
+
+func TestStackCollision(t *testing.T) {
+	run := func(sp uint32) (coprocessor.CoProcYield, *testDeveloper, uint32) {
+		cart, err := NewElf(&testContext{}, testProgram, "")
+		test.DemandSuccess(t, err)
+		dev := &testDeveloper{highAddress: cart.HighAddress()}
+		cart.arm.SetDeveloper(dev)
+		test.DemandSuccess(t, cart.arm.SetInitialRegisters())
+		if sp != 0 {
+			cart.arm.RegisterSet(testSP, sp)
+		}
+		yld, _ := cart.arm.Run()
+		return yld, dev, cart.mem.sramOrigin
+	}
+
+	// the stack starts at the top of SRAM and the program runs until it calls
+	// the first vcsLib function without a stack error
+	yld, dev, _ := run(0)
+	test.ExpectEquality(t, yld.Type, coprocessor.YieldSyncWithVCS)
+	test.ExpectEquality(t, len(dev.faults), 0)
+
+	// the variables in the test program occupy the first eight bytes of SRAM.
+	// the program pushes five registers (20 bytes) and then one more register
+	// onto the stack before calling the first vcsLib function. with the stack
+	// 48 bytes above the start of SRAM the pushes do not reach the variables
+	yld, dev, sram := run(0)
+	test.ExpectEquality(t, dev.highAddress, sram+7)
+	yld, dev, _ = run(sram + 48)
+	test.ExpectEquality(t, yld.Type, coprocessor.YieldSyncWithVCS)
+	test.ExpectEquality(t, len(dev.faults), 0)
+
+	// but with the stack 28 bytes above the start of SRAM the second push
+	// collides with the variables
+	yld, dev, _ = run(sram + 28)
+	test.ExpectEquality(t, yld.Type, coprocessor.YieldMemoryFault)
+	test.DemandEquality(t, len(dev.faults), 1)
+	test.ExpectEquality(t, dev.faults[0], faults.StackError)
+}
@@ -0,0 +1,99 @@
+package elf
+
+import (
+	"debug/elf"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jetsetilly/test7800/hardware/arm/architecture"
+)
+
+// ModelOptions lists the hardware models that an ELF cartridge can target. AUTO
+// means that the model is taken from the cartridge data
+var ModelOptions = []string{"AUTO", "HARMONY", "PLUSCART", "UNOCART"}
+
+// the model used when the cartridge data does not specify one
+const defaultModel = "PLUSCART"
+
+// the owner name and type of the ELF note that specifies the hardware model
+const (
+	modelNoteName = "CART"
+	modelNoteType = 1
+)
+
+// returns the cartridge architecture for the model
+func modelArchitecture(model string) (architecture.CartArchitecture, error) {
+	switch strings.ToUpper(model) {
+	case "HARMONY":
+		return architecture.Harmony, nil
+	case "PLUSCART", "UNOCART":
+		return architecture.PlusCart, nil
+	}
+	return "", fmt.Errorf("unknown hardware model: %s", model)
+}
+
+// returns the hardware model specified by a note in the ELF file. returns the
+// empty string if there is no such note
+//
+// the note is in the standard ELF note format. the owner name is "CART", the
+// type is 1 and the description is the name of the model
+func modelFromNote(ef *elf.File) string {
+	for _, sec := range ef.Sections {
+		if sec.Type != elf.SHT_NOTE {
+			continue
+		}
+		d, err := sec.Data()
+		if err != nil {
+			continue
+		}
+
+		// the name and description are padded to a multiple of four bytes
+		align := func(n uint32) uint32 {
+			return (n + 3) &^ 3
+		}
+
+		for len(d) >= 12 {
+			namesz := ef.ByteOrder.Uint32(d[0:])
+			descsz := ef.ByteOrder.Uint32(d[4:])
+			typ := ef.ByteOrder.Uint32(d[8:])
+			d = d[12:]
+
+			if uint64(align(namesz))+uint64(align(descsz)) > uint64(len(d)) {
+				break // for loop
+			}
+			name := strings.TrimRight(string(d[:namesz]), "\x00")
+			d = d[align(namesz):]
+			desc := strings.TrimRight(string(d[:descsz]), "\x00")
+			d = d[align(descsz):]
+
+			if name == modelNoteName && typ == modelNoteType {
+				return strings.ToUpper(strings.TrimSpace(desc))
+			}
+		}
+	}
+	return ""
+}
+
+// selects the hardware model. the model requested by the user has priority
+// over the model in the a78 header, which has priority over the model in the
+// ELF note
+func selectModel(requested string, header string, note string) (string, string, error) {
+	requested = strings.ToUpper(requested)
+	if requested != "" && requested != "AUTO" {
+		if !slices.Contains(ModelOptions, requested) {
+			return "", "", fmt.Errorf("unknown hardware model: %s", requested)
+		}
+		return requested, "requested", nil
+	}
+	if header != "" {
+		return header, "a78 header", nil
+	}
+	if note != "" {
+		if !slices.Contains(ModelOptions[1:], note) {
+			return "", "", fmt.Errorf("unknown hardware model in ELF note: %s", note)
+		}
+		return note, "ELF note", nil
+	}
+	return defaultModel, "default", nil
+}
@@ -253,6 +253,48 @@ func getStrongArmDefinition(mem *elfMemory, name string) (bool, uint32, error) {
 			function: vcsSnoopRead,
 			support:  false,
 		})
+	case "vcsWriteAbs6":
+		tgt, err = mem.relocateStrongArmFunction(strongArmFunctionSpec{
+			name:     name,
+			function: vcsWriteAbs6,
+			support:  false,
+		})
+	case "vcsStaAbs4":
+		tgt, err = mem.relocateStrongArmFunction(strongArmFunctionSpec{
+			name:     name,
+			function: vcsStaAbs4,
+			support:  false,
+		})
+	case "vcsReadAbs4":
+		tgt, err = mem.relocateStrongArmFunction(strongArmFunctionSpec{
+			name:     name,
+			function: vcsReadAbs4,
+			support:  false,
+		})
+	case "vcsWritePokey":
+		tgt, err = mem.relocateStrongArmFunction(strongArmFunctionSpec{
+			name:     name,
+			function: vcsWritePokey,
+			support:  false,
+		})
+	case "vcsSetWindow":
+		tgt, err = mem.relocateStrongArmFunction(strongArmFunctionSpec{
+			name:     name,
+			function: vcsSetWindow,
+			support:  true,
+		})
+	case "vcsSetWindowBanks":
+		tgt, err = mem.relocateStrongArmFunction(strongArmFunctionSpec{
+			name:     name,
+			function: vcsSetWindowBanks,
+			support:  true,
+		})
+	case "vcsGetWindowBank":
+		tgt, err = mem.relocateStrongArmFunction(strongArmFunctionSpec{
+			name:     name,
+			function: vcsGetWindowBank,
+			support:  true,
+		})
 
 	// function for NV memory
 	case "vcsInitNvStore":
@@ -15,6 +15,8 @@
 
 package elf
 
+import "github.com/jetsetilly/test7800/logger"
+
 func vcsInjectDmaData(mem *elfMemory) {
 	// vcsInjectDmaData() cannot be streamed so we disable streaming from here on in
 	if mem.stream.active {
@@ -108,3 +110,223 @@ func vcsSnoopRead(mem *elfMemory) {
 		mem.endStrongArmFunction()
 	}
 }
+
+// returns the full 16bit address on the address bus. the 2600 functions mask
+// the address with Memtop but the cartridge space of the 7800 is much larger
+func (mem *elfMemory) addressBus() uint16 {
+	return uint16(mem.gpio.data[ADDR_IDR]) | uint16(mem.gpio.data[ADDR_IDR+1])<<8
+}
+
+// like yieldDataBus() but the full 16bit address is compared
+func (mem *elfMemory) yieldDataBusAbs(addr uint16) bool {
+	if mem.stream.active {
+		return true
+	}
+	return mem.addressBus() == addr
+}
+
+// void vcsWriteAbs6(uint16_t address, uint8_t data)
+func vcsWriteAbs6(mem *elfMemory) {
+	address := uint16(mem.strongarm.running.registers[0])
+	data := uint8(mem.strongarm.running.registers[1])
+	switch mem.strongarm.running.state {
+	case 0:
+		if mem.injectRomByte(0xa9) {
+			mem.strongarm.running.state++
+		}
+	case 1:
+		if mem.injectRomByte(data) {
+			mem.strongarm.running.state++
+		}
+	case 2:
+		if mem.injectRomByte(0x8d) {
+			mem.strongarm.running.state++
+		}
+	case 3:
+		if mem.injectRomByte(uint8(address)) {
+			mem.strongarm.running.state++
+		}
+	case 4:
+		if mem.injectRomByte(uint8(address >> 8)) {
+			mem.strongarm.running.state++
+		}
+	case 5:
+		if mem.yieldDataBusAbs(address) {
+			mem.endStrongArmFunction()
+		}
+	}
+}
+
+// void vcsStaAbs4(uint16_t address)
+func vcsStaAbs4(mem *elfMemory) {
+	address := uint16(mem.strongarm.running.registers[0])
+	switch mem.strongarm.running.state {
+	case 0:
+		if mem.injectRomByte(0x8d) {
+			mem.strongarm.running.state++
+		}
+	case 1:
+		if mem.injectRomByte(uint8(address)) {
+			mem.strongarm.running.state++
+		}
+	case 2:
+		if mem.injectRomByte(uint8(address >> 8)) {
+			mem.strongarm.running.state++
+		}
+	case 3:
+		if mem.yieldDataBusAbs(address) {
+			mem.endStrongArmFunction()
+		}
+	}
+}
+
+// uint8_t vcsReadAbs4(uint16_t address)
+func vcsReadAbs4(mem *elfMemory) {
+	// the same as vcsRead4() except that the address is not masked
+	address := uint16(mem.strongarm.running.registers[0])
+
+	switch mem.strongarm.running.state {
+	case 0:
+		if mem.injectRomByte(0xad) {
+			mem.strongarm.running.state++
+		}
+	case 1:
+		if mem.injectRomByte(uint8(address)) {
+			mem.strongarm.running.state++
+		}
+	case 2:
+		if mem.injectRomByte(uint8(address >> 8)) {
+			if mem.stream.active {
+				mem.endStrongArmFunction()
+				mem.stream.startDrain()
+				mem.stream.snoopDataBus = snoopDataBus_streaming
+				mem.stream.snoopDataBusAddr = mem.strongarm.nextRomAddress
+			} else {
+				mem.setStrongArmFunction(snoopDataBus)
+			}
+		}
+	}
+}
+
+// void vcsWritePokey(uint16_t address, const uint8_t* pRegisters, uint8_t count)
+//
+// writes count registers, starting with the register at address. each
+// register is written with an LDA immediate and STA absolute instruction
+func vcsWritePokey(mem *elfMemory) {
+	address := uint16(mem.strongarm.running.registers[0])
+	buffer := mem.strongarm.running.registers[1]
+	count := int(uint8(mem.strongarm.running.registers[2]))
+
+	switch mem.strongarm.running.state {
+	case 0:
+		mem.strongarm.running.state++
+		mem.strongarm.running.counter = 0
+		mem.strongarm.running.subCounter = 0
+		fallthrough
+	case 1:
+		if mem.strongarm.running.counter >= count {
+			mem.endStrongArmFunction()
+			return
+		}
+
+		reg := address + uint16(mem.strongarm.running.counter)
+
+		switch mem.strongarm.running.subCounter {
+		case 0:
+			if mem.injectRomByte(0xa9) {
+				mem.strongarm.running.subCounter++
+			}
+		case 1:
+			var v uint8
+			a := buffer + uint32(mem.strongarm.running.counter)
+			data, origin := mem.MapAddress(a, false, false)
+			if data != nil {
+				v = (*data)[a-origin]
+			}
+			if mem.injectRomByte(v) {
+				mem.strongarm.running.subCounter++
+			}
+		case 2:
+			if mem.injectRomByte(0x8d) {
+				mem.strongarm.running.subCounter++
+			}
+		case 3:
+			if mem.injectRomByte(uint8(reg)) {
+				mem.strongarm.running.subCounter++
+			}
+		case 4:
+			if mem.injectRomByte(uint8(reg >> 8)) {
+				mem.strongarm.running.subCounter++
+			}
+		case 5:
+			if mem.yieldDataBusAbs(reg) {
+				mem.strongarm.running.counter++
+				mem.strongarm.running.subCounter = 0
+			}
+		}
+	}
+}
+
+// the following window functions should be executed with
+// runStrongArmFunction() and not setStrongArmFunction()
+
+// void vcsSetWindow(uint8_t window, uint16_t address, uint16_t length, const uint8_t* pBuffer)
+//
+// reads by the console from the range of addresses are served from the buffer
+// in ARM memory. a length of zero disables the window
+func vcsSetWindow(mem *elfMemory) {
+	idx := int(uint8(mem.strongarm.running.registers[0]))
+	if idx >= numWindows {
+		return
+	}
+
+	address := uint16(mem.strongarm.running.registers[1])
+	length := uint16(mem.strongarm.running.registers[2])
+	if length == 0 {
+		mem.windows[idx] = window{}
+		return
+	}
+
+	// the window must not extend beyond the end of the address space
+	if uint32(address)+uint32(length) > 0x10000 {
+		logger.Logf(logger.Allow, "ELF", "vcsSetWindow: window %d at %04x with length %d is beyond the end of memory", idx, address, length)
+		mem.windows[idx] = window{}
+		return
+	}
+
+	mem.windows[idx] = window{
+		enabled: true,
+		origin:  address,
+		memtop:  address + length - 1,
+		buffer:  mem.strongarm.running.registers[3],
+	}
+}
+
+// void vcsSetWindowBanks(uint8_t window, uint16_t hotspot, uint16_t mask, uint8_t banks)
+//
+// divides the buffer of the window into banks, each the length of the window.
+// a write by the console to an address that matches the hotspot when masked
+// selects the bank. the bank is the value written modulo the number of banks
+func vcsSetWindowBanks(mem *elfMemory) {
+	idx := int(uint8(mem.strongarm.running.registers[0]))
+	if idx >= numWindows {
+		return
+	}
+
+	w := &mem.windows[idx]
+	w.hotspot = uint16(mem.strongarm.running.registers[1])
+	w.hotspotMask = uint16(mem.strongarm.running.registers[2])
+	w.hotspot &= w.hotspotMask
+	w.banks = uint8(mem.strongarm.running.registers[3])
+	w.bank = 0
+}
+
+// uint8_t vcsGetWindowBank(uint8_t window)
+func vcsGetWindowBank(mem *elfMemory) {
+	idx := int(uint8(mem.strongarm.running.registers[0]))
+	if idx >= numWindows {
+		mem.arm.RegisterSet(0, 0)
+		return
+	}
+	mem.arm.RegisterSet(0, uint32(mem.windows[idx].bank))
+}
@@ -0,0 +1,159 @@
+package elf
+
+import (
+	"testing"
+
+	"github.com/jetsetilly/test7800/coprocessor/faults"
+	"github.com/jetsetilly/test7800/hardware/arm"
+	"github.com/jetsetilly/test7800/hardware/arm/architecture"
+	"github.com/jetsetilly/test7800/hardware/spec"
+	"github.com/jetsetilly/test7800/test"
+)
+
+type testContext struct{}
+
+func (ctx *testContext) Rand8Bit() uint8                  { return 0 }
+func (ctx *testContext) Break(_ error)                    {}
+func (ctx *testContext) Spec() spec.Spec                  { return spec.NTSC }
+func (ctx *testContext) IsAtari7800() bool                { return true }
+func (ctx *testContext) ARMPreferences() *arm.Preferences { return nil }
+func (ctx *testContext) ELFModel() string                 { return "" }
+
+// testARM stands in for the ARM. the registers are the arguments to the
+// strongarm function
+type testARM struct {
+	registers [arm.NumCoreRegisters]uint32
+}
+
+func (a *testARM) Interrupt()                                  {}
+func (a *testARM) MemoryFault(_ string, _ faults.Category)     {}
+func (a *testARM) CoreRegisters() [arm.NumCoreRegisters]uint32 { return a.registers }
+func (a *testARM) RegisterSet(reg int, value uint32) bool      { a.registers[reg] = value; return true }
+
+// returns an elfMemory instance with a strongarm function ready to run. the
+// arguments are passed to the function
+func testMemory(f strongArmFunction, args ...uint32) (*elfMemory, *testARM) {
+	mem := newElfMemory(&testContext{}, architecture.PlusCart)
+	a := &testARM{}
+	copy(a.registers[:], args)
+	mem.arm = a
+	mem.setNextRomAddress(0xf000)
+	if f != nil {
+		mem.setStrongArmFunction(f)
+	}
+	return mem, a
+}
+
+// puts the address and data on the bus and runs the strongarm function.
+// returns the data driven by the cartridge
+func (mem *elfMemory) testBus(addr uint16, data uint8) uint8 {
+	mem.gpio.data[DATA_IDR] = data
+	mem.gpio.data[ADDR_IDR] = uint8(addr)
+	mem.gpio.data[ADDR_IDR+1] = uint8(addr >> 8)
+	if mem.strongarm.running.function != nil {
+		mem.strongarm.running.function(mem)
+	}
+	return mem.gpio.data[DATA_ODR]
+}
+
+// the sequence of bytes fetched by the 6502 from the address
+func (mem *elfMemory) testFetch(t *testing.T, addr uint16, expected ...uint8) {
+	t.Helper()
+	for i, e := range expected {
+		test.ExpectEquality(t, mem.testBus(addr+uint16(i), 0), e)
+	}
+}
+
+func TestWriteAbs6(t *testing.T) {
+	mem, _ := testMemory(vcsWriteAbs6, 0x2040, 0x55)
+	mem.testFetch(t, 0xf000, 0xa9, 0x55, 0x8d, 0x40, 0x20)
+
+	// the address is not masked so a write to a mirror of the address does not
+	// end the function
+	mem.testBus(0x0040, 0x55)
+	test.ExpectEquality(t, mem.strongarm.running.function == nil, false)
+	mem.testBus(0x2040, 0x55)
+	test.ExpectEquality(t, mem.strongarm.running.function == nil, true)
+}
+
+func TestStaAbs4(t *testing.T) {
+	mem, _ := testMemory(vcsStaAbs4, 0x0450)
+	mem.testFetch(t, 0xf000, 0x8d, 0x50, 0x04)
+	mem.testBus(0x0450, 0x00)
+	test.ExpectEquality(t, mem.strongarm.running.function == nil, true)
+}
+
+func TestReadAbs4(t *testing.T) {
+	mem, a := testMemory(vcsReadAbs4, 0x2345)
+	mem.testFetch(t, 0xf000, 0xad, 0x45, 0x23)
+
+	// the data read from the address is on the data bus when the 6502 fetches
+	// the next instruction
+	mem.testBus(0x2345, 0x00)
+	mem.testBus(0xf003, 0x99)
+	test.ExpectEquality(t, mem.strongarm.running.function == nil, true)
+	test.ExpectEquality(t, a.registers[0], uint32(0x99))
+}
+
+func TestWritePokey(t *testing.T) {
+	mem, _ := testMemory(nil)
+
+	// register values in SRAM
+	regs := []uint8{0x10, 0x20, 0x30}
+	copy((*mem.sram.Data())[0x100:], regs)
+
+	mem.arm.(*testARM).registers = [arm.NumCoreRegisters]uint32{0x4000, mem.sramOrigin + 0x100, uint32(len(regs))}
+	mem.setStrongArmFunction(vcsWritePokey)
+
+	addr := uint16(0xf000)
+	for i, v := range regs {
+		mem.testFetch(t, addr, 0xa9, v, 0x8d, uint8(i), 0x40)
+		addr += 5
+		mem.testBus(0x4000+uint16(i), v)
+	}
+	mem.testBus(addr, 0x00)
+	test.ExpectEquality(t, mem.strongarm.running.function == nil, true)
+}
+
+func TestWindow(t *testing.T) {
+	mem, _ := testMemory(nil)
+
+	// two banks of four bytes
+	copy((*mem.sram.Data())[0x200:], []uint8{1, 2, 3, 4, 5, 6, 7, 8})
+
+	mem.runStrongArmFunction(vcsSetWindow, 0, 0x8000, 4, mem.sramOrigin+0x200)
+	test.ExpectEquality(t, mem.inWindow(0x8003), true)
+	test.ExpectEquality(t, mem.inWindow(0x8004), false)
+
+	// reads can be in any order
+	d, ok := mem.windowRead(0x8002)
+	test.ExpectEquality(t, ok, true)
+	test.ExpectEquality(t, d, uint8(3))
+	d, _ = mem.windowRead(0x8000)
+	test.ExpectEquality(t, d, uint8(1))
+	_, ok = mem.windowRead(0x7fff)
+	test.ExpectEquality(t, ok, false)
+
+	// bankswitching with any write in the range 0xc000 to 0xffff
+	mem.runStrongArmFunction(vcsSetWindowBanks, 0, 0xc000, 0xc000, 2)
+	test.ExpectEquality(t, mem.windowWrite(0x8000, 1), false)
+	test.ExpectEquality(t, mem.windowWrite(0xc123, 3), true)
+	d, _ = mem.windowRead(0x8002)
+	test.ExpectEquality(t, d, uint8(7))
+
+	mem.runStrongArmFunction(vcsGetWindowBank, 0)
+	test.ExpectEquality(t, mem.arm.(*testARM).registers[0], uint32(1))
+
+	// a length of zero disables the window
+	mem.runStrongArmFunction(vcsSetWindow, 0, 0x8000, 0, 0)
+	test.ExpectEquality(t, mem.inWindow(0x8000), false)
+
+	// a window can end at the last address
+	mem.runStrongArmFunction(vcsSetWindow, 0, 0xff00, 0x100, mem.sramOrigin+0x200)
+	test.ExpectEquality(t, mem.inWindow(0xffff), true)
+
+	// but a window that extends beyond the last address is rejected
+	mem.runStrongArmFunction(vcsSetWindow, 0, 0xff00, 0x200, mem.sramOrigin+0x200)
+	test.ExpectEquality(t, mem.windows[0].enabled, false)
+	test.ExpectEquality(t, mem.inWindow(0xff00), false)
+}
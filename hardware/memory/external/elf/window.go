@@ -0,0 +1,72 @@
+package elf
+
+// the number of windows available to the ARM program
+const numWindows = 4
+
+// a window is a range of cartridge addresses that is served directly from ARM
+// memory. the console can read from a window at any time without the ARM
+// needing to synchronise with the console. this is useful for display lists
+// and DLLs because MARIA reads them in a non-sequential order
+//
+// the ARM memory for a window can be divided into banks. a write by the
+// console to the hotspot of the window selects the bank
+type window struct {
+	enabled bool
+	origin  uint16
+	memtop  uint16
+
+	// address in ARM memory of the first bank
+	buffer uint32
+
+	// a write to any address that matches the hotspot when masked selects the
+	// bank. the bank is the value written modulo the number of banks
+	hotspot     uint16
+	hotspotMask uint16
+	banks       uint8
+	bank        uint8
+}
+
+func (w *window) size() uint32 {
+	return uint32(w.memtop-w.origin) + 1
+}
+
+// returns true if the address is in one of the windows
+func (mem *elfMemory) inWindow(addr uint16) bool {
+	for i := range mem.windows {
+		w := &mem.windows[i]
+		if w.enabled && addr >= w.origin && addr <= w.memtop {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the data for the address if it is in one of the windows
+func (mem *elfMemory) windowRead(addr uint16) (uint8, bool) {
+	for i := range mem.windows {
+		w := &mem.windows[i]
+		if w.enabled && addr >= w.origin && addr <= w.memtop {
+			a := w.buffer + uint32(w.bank)*w.size() + uint32(addr-w.origin)
+			data, origin := mem.mapAddress(a, false)
+			if data == nil || int(a-origin) >= len(*data) {
+				return 0, true
+			}
+			return (*data)[a-origin], true
+		}
+	}
+	return 0, false
+}
+
+// selects the bank of any window with a hotspot that matches the address.
+// returns true if the write was to a hotspot
+func (mem *elfMemory) windowWrite(addr uint16, data uint8) bool {
+	var hotspot bool
+	for i := range mem.windows {
+		w := &mem.windows[i]
+		if w.enabled && w.banks > 0 && addr&w.hotspotMask == w.hotspot {
+			w.bank = data % w.banks
+			hotspot = true
+		}
+	}
+	return hotspot
+}
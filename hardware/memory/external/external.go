@@ -27,6 +27,9 @@ type Device struct {
 	ctx      Context
 	inserted Bus
 	chips    []OptionalBus
+
+	// the inserted device if it implements the stepper interface. nil otherwise
+	stepper stepper
 }
 
 type Context interface {
@@ -66,6 +69,10 @@ func (dev *Device) Insert(c CartridgeInsertor) error {
 		logger.Log(logger.Allow, "chips", s.Label())
 	}
 
+	// the stepper is resolved before the cartridge is wrapped by the high-score
+	// cartridge, which does not forward calls to Step()
+	dev.stepper, _ = dev.inserted.(stepper)
+
 	if c.UseHSC {
 		logger.Logf(logger.Allow, "HSC", "inserting %s into high-score cartridge", dev.inserted.Label())
 		dev.inserted = hsc.Create(dev.ctx, dev.inserted)
@@ -77,6 +84,7 @@ func (dev *Device) Insert(c CartridgeInsertor) error {
 func (dev *Device) Eject() {
 	dev.inserted = nil
 	dev.chips = dev.chips[:0]
+	dev.stepper = nil
 }
 
 func (dev *Device) IsEjected() bool {
@@ -133,6 +141,36 @@ func (dev *Device) GetCoProcBus() coprocessor.CartCoProcBus {
 	return nil
 }
 
+// external devices that can report which bank is mapped into an address will
+// implement the bankable interface
+type bankable interface {
+	Bank(address uint16) (int, bool)
+}
+
+// Bank returns the cartridge bank that is mapped into the address. The boolean
+// result is false if the cartridge has no banks or if the address is not a
+// banked address
+func (dev *Device) Bank(address uint16) (int, bool) {
+	if d, ok := dev.inserted.(bankable); ok {
+		return d.Bank(address)
+	}
+	return 0, false
+}
+
+// external devices that run in parallel with the console will implement the
+// stepper interface
+type stepper interface {
+	Step(clock float32)
+}
+
+// Step should be called once per CPU cycle. The clock argument is the speed of
+// the CPU cycle in MHz
+func (dev *Device) Step(clock float32) {
+	if dev.stepper != nil {
+		dev.stepper.Step(clock)
+	}
+}
+
 // external devices that want to know about the HLT line will implement the hlt interface
 type hlt interface {
 	HLT(bool)
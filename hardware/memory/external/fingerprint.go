@@ -5,12 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"slices"
 	"strings"
 	"unicode"
 
+	"github.com/jetsetilly/test7800/hardware/memory/external/a78"
 	"github.com/jetsetilly/test7800/hardware/memory/external/elf"
-	"github.com/jetsetilly/test7800/hardware/pokey"
+	"github.com/jetsetilly/test7800/hardware/memory/external/gamedb"
 	"github.com/jetsetilly/test7800/logger"
 )
 
@@ -20,7 +22,28 @@ var UnrecognisedData = errors.New("unrecognised data")
 func Fingerprint(filename string, mapper string) (CartridgeInsertor, error) {
 	d, err := os.ReadFile(filename)
 	if err != nil {
-		return CartridgeInsertor{}, err
+		// the filename might select one of the cartridges in an archive
+		archive, inner, ok := splitArchiveSelector(filename)
+		if !ok {
+			return CartridgeInsertor{}, err
+		}
+		d, err = os.ReadFile(archive)
+		if err != nil {
+			return CartridgeInsertor{}, err
+		}
+		if !isArchive(d) {
+			return CartridgeInsertor{}, fmt.Errorf("archive: %s is not an archive", filepath.Base(archive))
+		}
+		entries, err := Unarchive(archive, d)
+		if err != nil {
+			return CartridgeInsertor{}, err
+		}
+		e, err := SelectArchiveEntry(entries, inner)
+		if err != nil {
+			return CartridgeInsertor{}, err
+		}
+		logger.Logf(logger.Allow, "archive", "using %s", e.Path)
+		return FingerprintBlob(e.Filename, e.Data, mapper)
 	}
 	return FingerprintBlob(filename, d, mapper)
 }
@@ -29,13 +52,50 @@ func FingerprintBlob(filename string, d []uint8, mapper string) (CartridgeInsert
 	// normalise mapper string
 	mapper = strings.ToUpper(mapper)
 
+	// the cartridge might be inside an archive. if there is more than one
+	// cartridge in the archive then the caller must choose which one to use
+	//
+	// a headerless cartridge might start with the same bytes as an archive.
+	// unless the filename says that the file is an archive, data that can't be
+	// unarchived is treated as a cartridge
+	if isArchive(d) {
+		entries, err := Unarchive(filename, d)
+		if err == nil && len(entries) > 0 {
+			if len(entries) == 1 {
+				logger.Logf(logger.Allow, "archive", "using %s", entries[0].Name())
+				return FingerprintBlob(entries[0].Filename, entries[0].Data, mapper)
+			}
+			return CartridgeInsertor{}, ArchiveChoice{
+				Archive: filename,
+				Entries: entries,
+			}
+		}
+		if hasArchiveExtension(filename) {
+			if err != nil {
+				return CartridgeInsertor{}, err
+			}
+			return CartridgeInsertor{}, fmt.Errorf("archive: %s contains no cartridge files", filepath.Base(filename))
+		}
+	}
+
 	// try ELF first because it's the most solidly defined of all ROM types
 	if slices.Contains([]string{"ELF", "AUTO"}, mapper) {
 		if bytes.Contains(d, []byte{0x7f, 'E', 'L', 'F'}) {
+			// the ELF data might be preceded by an a78 header, in which case
+			// the header can specify the hardware model
+			var dataStart int
+			var model string
+			if a78.IsA78(d) {
+				if hdr, idx, err := a78.Parse(d); err == nil {
+					dataStart = idx
+					model = hdr.ELFModelName()
+				}
+			}
+
 			return CartridgeInsertor{
 				data: d,
 				creator: func(ctx Context, d []uint8) (Bus, error) {
-					return elf.NewElf(ctx, d)
+					return elf.NewElf(ctx, d[dataStart:], model)
 				},
 				reset: CartridgeReset{
 					BypassBIOS: true,
@@ -53,30 +113,25 @@ func FingerprintBlob(filename string, d []uint8, mapper string) (CartridgeInsert
 	// https://7800.8bitdev.org/index.php/A78_Header_Specification
 	// https://forums.atariage.com/topic/333208-old-world-a78-format-10-31-primer/
 	if slices.Contains([]string{"A78", "AUTO"}, mapper) {
-		if bytes.Equal(d[0x01:0x0a], []byte("ATARI7800")) {
-			version := d[0x00]
+		if a78.IsA78(d) {
+			hdr, dataStart, err := a78.Parse(d)
+			if err != nil {
+				return CartridgeInsertor{}, err
+			}
 
 			// log a78 version and game title
-			logger.Logf(logger.Allow, "a78", "version: %#02x", version)
-			logger.Logf(logger.Allow, "a78", "title: %s", strings.TrimSpace(string(d[0x11:0x31])))
-
-			const endOfHeader = "ACTUAL CART DATA STARTS HERE"
-			dataStart := bytes.Index(d, []uint8(endOfHeader))
-			if dataStart == -1 {
-				return CartridgeInsertor{}, fmt.Errorf("malfored A78 header. no end of header indicator")
-			}
-			dataStart += len(endOfHeader)
+			logger.Logf(logger.Allow, "a78", "version: %#02x", hdr.Version)
+			logger.Logf(logger.Allow, "a78", "title: %s", hdr.Title)
 
 			// cartridge size
-			size := (uint32(d[0x31]) << 24) | (uint32(d[0x32]) << 16) | (uint32(d[0x33]) << 8) | uint32(d[0x34])
-			if len(d)-dataStart != int(size) {
-				logger.Logf(logger.Allow, "a78", "cropping payload data to %d", size)
-				d = d[:dataStart+int(size)]
+			if len(d)-dataStart != int(hdr.Size) {
+				logger.Logf(logger.Allow, "a78", "cropping payload data to %d", hdr.Size)
+				d = d[:min(len(d), dataStart+int(hdr.Size))]
 			}
 
 			// controller type
 			var controller string
-			switch d[0x37] {
+			switch hdr.Controller1 {
 			case 0x00:
 				// no controller, don't care
 				logger.Log(logger.Allow, "a78", "controllers: no controller information")
@@ -96,173 +151,35 @@ func FingerprintBlob(filename string, d []uint8, mapper string) (CartridgeInsert
 				controller = "snes2atari"
 				logger.Log(logger.Allow, "a78", "controllers: snes2atari")
 			default:
-				logger.Logf(logger.Allow, "a78", "controllers: unrecognised controller: %#02x", d[0x37])
+				logger.Logf(logger.Allow, "a78", "controllers: unrecognised controller: %#02x", hdr.Controller1)
 			}
 
 			// tv spec
 			var spec string
-			if d[0x39]&0x01 == 0x01 {
+			if hdr.IsPAL() {
 				spec = "PAL"
 			} else {
 				spec = "NTSC"
 			}
 
-			// save device
-			useHSC := d[0x3a]&0x01 == 0x01
-			useSavekey := d[0x3a]&0x02 == 0x02
-
 			// cartridge type
-			cartType := (uint16(d[0x35]) << 8) | uint16(d[0x36])
+			cartType := hdr.CartType
 			logger.Logf(logger.Allow, "a78", "cart type: %08b %08b", uint8(cartType>>8), uint8(cartType))
 
-			if cartType&0x0800 == 0x0800 {
-				logger.Logf(logger.Allow, "a78", "YM2151 required but not supported")
-				cartType &= (0x0800 ^ 0xffff)
+			props := cartProperties{
+				cartType:   cartType,
+				controller: controller,
+				spec:       spec,
+				useHSC:     hdr.UseHSC(),
+				useSavekey: hdr.UseSavekey(),
 			}
 
-			// list of creator functions for additional chips
-			var chips []func(Context) (OptionalBus, error)
-
-			if cartType&0x0001 == 0x0001 {
-				pk := func(ctx Context) (OptionalBus, error) {
-					return pokey.NewAudio(ctx, 0x4000)
-				}
-				chips = append(chips, pk)
-				cartType &= (0x0001 ^ 0xffff)
-			}
-			if cartType&0x0040 == 0x0040 {
-				pk := func(ctx Context) (OptionalBus, error) {
-					return pokey.NewAudio(ctx, 0x0450)
-				}
-				chips = append(chips, pk)
-				cartType &= (0x0040 ^ 0xffff)
-			}
-			if cartType&0x0400 == 0x0400 {
-				pk := func(ctx Context) (OptionalBus, error) {
-					return pokey.NewAudio(ctx, 0x0440)
-				}
-				chips = append(chips, pk)
-				cartType &= (0x0400 ^ 0xffff)
-			}
-			if cartType&0x8000 == 0x8000 {
-				pk := func(ctx Context) (OptionalBus, error) {
-					return pokey.NewAudio(ctx, 0x0800)
-				}
-				chips = append(chips, pk)
-				cartType &= (0x8000 ^ 0xffff)
-			}
-
-			if cartType == 0x0000 {
-				return CartridgeInsertor{
-					data: d,
-					creator: func(ctx Context, d []uint8) (Bus, error) {
-						return NewFlat(ctx, d[dataStart:])
-					},
-					Controller: controller,
-					spec:       spec,
-					chips:      chips,
-					UseHSC:     useHSC,
-					UseSavekey: useSavekey,
-				}, nil
-			}
-
-			// mRAM chip in flat ROM cartridge (no bankswitching)
-			//
-			// it's not clear if the mRAM chip (with a masked address line) can be used in
-			// conjunction with a bankswitching method. as it stands, I think only 'Rescue On
-			// Fractalus' uses this type of RAM chip and that has a flat ROM map
-			if cartType == 0x0080 {
-				return CartridgeInsertor{
-					data: d,
-					creator: func(ctx Context, d []uint8) (Bus, error) {
-						return NewMRAM(ctx, d[dataStart:])
-					},
-					Controller: controller,
-					spec:       spec,
-					chips:      chips,
-					UseHSC:     useHSC,
-					UseSavekey: useSavekey,
-				}, nil
-			}
-
-			// activision
-			if cartType == 0x0100 {
-				// if cartridge name contians the '(OM)' string then the cartridge has been dumped
-				// with "original ordering". alternative ordering can be indicated with '(AM)' but
-				// we don't look for that and we assume that type of ordering by default
-				originalOrder := strings.Contains(filename, "(OM)")
-
-				return CartridgeInsertor{
-					filename: filename,
-					data:     d,
-					creator: func(ctx Context, d []uint8) (Bus, error) {
-						return NewActivision(ctx, d[dataStart:], originalOrder)
-					},
-					Controller: controller,
-					spec:       spec,
-					chips:      chips,
-					UseHSC:     useHSC,
-					UseSavekey: useSavekey,
-				}, nil
-			}
-
-			// absolute
-			if cartType == 0x0200 {
-				return CartridgeInsertor{
-					filename: filename,
-					data:     d,
-					creator: func(ctx Context, d []uint8) (Bus, error) {
-						return NewAbsolute(ctx, d[dataStart:])
-					},
-					Controller: controller,
-					spec:       spec,
-					chips:      chips,
-					UseHSC:     useHSC,
-					UseSavekey: useSavekey,
-				}, nil
+			// the game database corrects mistakes in the header
+			if e, ok := gamedb.Lookup(d[dataStart:]); ok {
+				props.apply(e)
 			}
 
-			// banksets
-			if cartType&0x2000 == 0x2000 {
-				supergame := cartType&0x02 == 0x02
-				banksetRAM := cartType&0x4000 == 0x4000
-				return CartridgeInsertor{
-					filename: filename,
-					data:     d,
-					creator: func(ctx Context, d []uint8) (Bus, error) {
-						return NewBanksets(ctx, supergame, d[dataStart:], banksetRAM)
-					},
-					Controller: controller,
-					spec:       spec,
-					chips:      chips,
-					UseHSC:     useHSC,
-					UseSavekey: useSavekey,
-				}, nil
-			}
-
-			// supergame
-			banked := cartType&0x02 == 0x02
-			exram := cartType&0x04 == 0x04
-			exrom := cartType&0x08 == 0x08
-
-			if banked || exrom || exram {
-				return CartridgeInsertor{
-					filename: filename,
-					data:     d,
-					creator: func(ctx Context, d []uint8) (Bus, error) {
-						return NewSupergame(ctx, d[dataStart:],
-							banked, exram, exrom,
-						)
-					},
-					Controller: controller,
-					spec:       spec,
-					chips:      chips,
-					UseHSC:     useHSC,
-					UseSavekey: useSavekey,
-				}, nil
-			}
-
-			return CartridgeInsertor{}, fmt.Errorf("a78: unsupported cartridge type (%#04x)", cartType)
+			return props.insertor(filename, d, dataStart)
 		}
 
 		// if user requested A78 explicitely as the mapper then return an error
@@ -283,11 +200,29 @@ func FingerprintBlob(filename string, d []uint8, mapper string) (CartridgeInsert
 		}, nil
 	}
 
+	// headerless dumps that are in the game database
+	if mapper == "AUTO" {
+		if e, ok := gamedb.Lookup(d); ok {
+			props := cartProperties{
+				controller: "7800_joystick",
+			}
+			props.apply(e)
+			return props.insertor(filename, d, 0)
+		}
+	}
+
 	// check to see if data contains any non-ASCII bytes. if it does then we assume
-	// it is a flat cartridge dump. data continaing only ASCII suggests that it is a
+	// it is a cartridge dump. data continaing only ASCII suggests that it is a
 	// script or a boot file that can be further interpreted by the debugger
 	for _, c := range d {
 		if c > unicode.MaxASCII {
+			// the mapper for a headerless dump is decided by heuristics
+			if mapper == "AUTO" {
+				props, _ := detectMapper(filename, d)
+				props.controller = "7800_joystick"
+				return props.insertor(filename, d, 0)
+			}
+
 			return CartridgeInsertor{
 				filename: filename,
 				data:     d,
@@ -0,0 +1,224 @@
+package gamedb
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	_ "embed"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jetsetilly/test7800/logger"
+	"github.com/jetsetilly/test7800/resources"
+)
+
+//go:embed "gamedb.txt"
+var embedded string
+
+// the name of the file in the resources directory. entries in the file replace
+// entries in the embedded database with the same hash
+const userFile = "gamedb"
+
+// the values accepted by the mapper field
+var Mappers = []string{
+	"FLAT", "MRAM", "SUPERGAME", "SUPERGAME_EXRAM", "SUPERGAME_EXROM",
+	"ACTIVISION", "ABSOLUTE", "BANKSETS", "BANKSETS_RAM", "SN", "EAGLE",
+}
+
+// the values accepted by the controller field
+var Controllers = []string{"7800_JOYSTICK", "2600_JOYSTICK", "PADDLE", "TRAKBALL", "SNES2ATARI"}
+
+// the addresses at which a POKEY can be placed. the same addresses as are
+// supported by the a78 header
+var PokeyAddresses = []uint16{0x4000, 0x0450, 0x0440, 0x0800}
+
+// Entry is the information about a single cartridge. fields with the zero
+// value are not specified by the database and should be taken from the
+// cartridge header or from the default for the cartridge
+type Entry struct {
+	Name string
+
+	// one of the values in the Mappers list
+	Mapper string
+
+	// one of the values in the Controllers list
+	Controller string
+
+	// NTSC or PAL
+	Spec string
+
+	// address of the POKEY. the NoPokey field is true if the database
+	// specifies that there is no POKEY
+	Pokey   uint16
+	NoPokey bool
+
+	// the cartridge uses a YM2151
+	YM2151 bool
+
+	// save devices used by the cartridge
+	HSC     bool
+	Savekey bool
+
+	// the BIOS must be bypassed for the cartridge to work
+	BypassBIOS bool
+
+	// known problems with the cartridge or with its emulation
+	Quirks []string
+}
+
+// Database of cartridges keyed by the MD5 or SHA1 of the cartridge data. the
+// cartridge data does not include any a78 header
+type Database struct {
+	entries map[string]*Entry
+}
+
+// NewDatabase returns an empty database
+func NewDatabase() *Database {
+	return &Database{
+		entries: make(map[string]*Entry),
+	}
+}
+
+// Parse entries from the text and add them to the database. entries replace
+// existing entries with the same hash
+//
+// each entry begins with one or more lines giving the hash of the cartridge
+// data, either "md5 <hash>" or "sha1 <hash>". the lines following the hashes
+// are fields of the entry, one per line
+func (db *Database) Parse(text string) error {
+	var e *Entry
+	var hashes bool
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	var ln int
+	for scanner.Scan() {
+		ln++
+		f := strings.Fields(scanner.Text())
+		if len(f) == 0 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+
+		key := strings.ToLower(f[0])
+		args := f[1:]
+
+		if key == "md5" || key == "sha1" {
+			if len(args) != 1 {
+				return fmt.Errorf("gamedb: line %d: %s requires a hash", ln, key)
+			}
+			hash := strings.ToLower(args[0])
+			if (key == "md5" && len(hash) != md5.Size*2) || (key == "sha1" && len(hash) != sha1.Size*2) {
+				return fmt.Errorf("gamedb: line %d: %s hash is the wrong length", ln, key)
+			}
+
+			// consecutive hashes refer to the same entry
+			if !hashes {
+				e = &Entry{}
+				hashes = true
+			}
+			db.entries[hash] = e
+			continue
+		}
+		hashes = false
+
+		if e == nil {
+			return fmt.Errorf("gamedb: line %d: field before the first hash", ln)
+		}
+
+		value := strings.Join(args, " ")
+
+		switch key {
+		case "name":
+			e.Name = value
+		case "quirk":
+			e.Quirks = append(e.Quirks, value)
+		case "mapper":
+			e.Mapper = strings.ToUpper(value)
+			if !slices.Contains(Mappers, e.Mapper) {
+				return fmt.Errorf("gamedb: line %d: unrecognised mapper: %s", ln, value)
+			}
+		case "controller":
+			e.Controller = strings.ToUpper(value)
+			if !slices.Contains(Controllers, e.Controller) {
+				return fmt.Errorf("gamedb: line %d: unrecognised controller: %s", ln, value)
+			}
+		case "spec":
+			e.Spec = strings.ToUpper(value)
+			if e.Spec != "NTSC" && e.Spec != "PAL" {
+				return fmt.Errorf("gamedb: line %d: spec must be NTSC or PAL", ln)
+			}
+		case "pokey":
+			if strings.ToLower(value) == "none" {
+				e.Pokey = 0
+				e.NoPokey = true
+				break // switch
+			}
+			v, err := strconv.ParseUint(strings.TrimPrefix(value, "$"), 16, 16)
+			if err != nil || !slices.Contains(PokeyAddresses, uint16(v)) {
+				return fmt.Errorf("gamedb: line %d: unsupported POKEY address: %s", ln, value)
+			}
+			e.Pokey = uint16(v)
+			e.NoPokey = false
+		case "ym2151":
+			e.YM2151 = true
+		case "hsc":
+			e.HSC = true
+		case "savekey":
+			e.Savekey = true
+		case "bypassbios":
+			e.BypassBIOS = true
+		default:
+			return fmt.Errorf("gamedb: line %d: unrecognised field: %s", ln, f[0])
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Lookup the cartridge data in the database. the data should not include any
+// a78 header
+func (db *Database) Lookup(d []byte) (Entry, bool) {
+	if e, ok := db.entries[fmt.Sprintf("%x", md5.Sum(d))]; ok {
+		return *e, true
+	}
+	if e, ok := db.entries[fmt.Sprintf("%x", sha1.Sum(d))]; ok {
+		return *e, true
+	}
+	return Entry{}, false
+}
+
+// the database is loaded the first time it is needed
+var (
+	loaded     *Database
+	loadedOnce sync.Once
+)
+
+// Load returns the embedded database with the entries from the user file in
+// the resources directory added to it. the embedded database does not
+// currently have any entries so in practice the database is the user file. the
+// database is only loaded once
+func Load() *Database {
+	loadedOnce.Do(func() {
+		loaded = NewDatabase()
+		err := loaded.Parse(embedded)
+		if err != nil {
+			panic(err)
+		}
+
+		s, err := resources.Read(userFile)
+		if err == nil {
+			err = loaded.Parse(s)
+		}
+		if err != nil {
+			logger.Log(logger.Allow, "gamedb", err)
+		}
+	})
+	return loaded
+}
+
+// Lookup the cartridge data in the database returned by Load()
+func Lookup(d []byte) (Entry, bool) {
+	return Load().Lookup(d)
+}
@@ -0,0 +1,74 @@
+package gamedb_test
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"fmt"
+	"testing"
+
+	"github.com/jetsetilly/test7800/hardware/memory/external/gamedb"
+	"github.com/jetsetilly/test7800/test"
+)
+
+func TestLookup(t *testing.T) {
+	a := []byte{0x01, 0x02, 0x03}
+	b := []byte{0x04, 0x05, 0x06}
+
+	db := gamedb.NewDatabase()
+	err := db.Parse(fmt.Sprintf(`
+# comment
+md5 %x
+sha1 %x
+name Test Game
+mapper supergame
+controller paddle
+spec pal
+pokey 0450
+hsc
+quirk first quirk
+quirk second quirk
+`, md5.Sum(a), sha1.Sum(b)))
+	test.DemandSuccess(t, err)
+
+	e, ok := db.Lookup(a)
+	test.ExpectEquality(t, ok, true)
+	test.ExpectEquality(t, e.Name, "Test Game")
+	test.ExpectEquality(t, e.Mapper, "SUPERGAME")
+	test.ExpectEquality(t, e.Controller, "PADDLE")
+	test.ExpectEquality(t, e.Spec, "PAL")
+	test.ExpectEquality(t, e.Pokey, uint16(0x0450))
+	test.ExpectEquality(t, e.HSC, true)
+	test.ExpectEquality(t, e.Savekey, false)
+	test.ExpectEquality(t, len(e.Quirks), 2)
+
+	// both hashes refer to the same entry
+	e, ok = db.Lookup(b)
+	test.ExpectEquality(t, ok, true)
+	test.ExpectEquality(t, e.Name, "Test Game")
+
+	_, ok = db.Lookup([]byte{0x00})
+	test.ExpectEquality(t, ok, false)
+
+	// a later entry replaces the entry with the same hash
+	err = db.Parse(fmt.Sprintf("md5 %x\nname Replacement\npokey none\n", md5.Sum(a)))
+	test.DemandSuccess(t, err)
+	e, _ = db.Lookup(a)
+	test.ExpectEquality(t, e.Name, "Replacement")
+	test.ExpectEquality(t, e.NoPokey, true)
+	e, _ = db.Lookup(b)
+	test.ExpectEquality(t, e.Name, "Test Game")
+}
+
+func TestParseErrors(t *testing.T) {
+	db := gamedb.NewDatabase()
+	test.ExpectFailure(t, db.Parse("name No Hash\n"))
+	test.ExpectFailure(t, db.Parse("md5 1234\n"))
+
+	const hash = "md5 0123456789abcdef0123456789abcdef\n"
+	test.ExpectSuccess(t, db.Parse(hash))
+	test.ExpectFailure(t, db.Parse(hash+"mapper foo\n"))
+	test.ExpectFailure(t, db.Parse(hash+"controller keyboard\n"))
+	test.ExpectFailure(t, db.Parse(hash+"spec secam\n"))
+	test.ExpectFailure(t, db.Parse(hash+"pokey 1234\n"))
+	test.ExpectFailure(t, db.Parse(hash+"colour red\n"))
+}
@@ -0,0 +1,394 @@
+package external
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jetsetilly/test7800/logger"
+)
+
+// the maximum score a mapper can be given by the heuristics. the score is
+// made up of evidence from the reset vector, from the bankswitching stores
+// and from one additional test that is particular to the mapper
+const (
+	heuristicsReset      = 40
+	heuristicsResetCode  = heuristicsReset / 2
+	heuristicsBankswitch = 40
+	heuristicsExtra      = 20
+	heuristicsMax        = heuristicsReset + heuristicsBankswitch + heuristicsExtra
+)
+
+// the result of the heuristics for one mapper
+type heuristicsCandidate struct {
+	name  string
+	props cartProperties
+	score int
+
+	// the reasoning for the score. one entry for each piece of evidence
+	reasons []string
+}
+
+func (c *heuristicsCandidate) evidence(score int, reason string, args ...any) {
+	c.score += score
+	c.reasons = append(c.reasons, fmt.Sprintf("%+d %s", score, fmt.Sprintf(reason, args...)))
+}
+
+// the stores to absolute addresses found by scanning the cartridge data
+type heuristicsStores struct {
+	// number of stores to each address
+	count map[uint16]int
+
+	// the immediate values loaded into the register immediately before the
+	// store. only recorded when the store follows an immediate load of the
+	// same register
+	immediate map[uint16][]uint8
+
+	// number of indexed stores to each address
+	indexed map[uint16]int
+}
+
+// scan the data for STA, STX and STY instructions using absolute addressing.
+// the scan does not follow the flow of the program so it will find stores in
+// data as well as in code. the heuristics are written with that in mind
+func scanStores(d []uint8) heuristicsStores {
+	s := heuristicsStores{
+		count:     make(map[uint16]int),
+		immediate: make(map[uint16][]uint8),
+		indexed:   make(map[uint16]int),
+	}
+
+	// the immediate load that corresponds to each of the store opcodes
+	load := map[uint8]uint8{
+		0x8d: 0xa9, // STA abs / LDA #
+		0x8e: 0xa2, // STX abs / LDX #
+		0x8c: 0xa0, // STY abs / LDY #
+	}
+
+	for i := 0; i+2 < len(d); i++ {
+		ld, ok := load[d[i]]
+		if !ok {
+			continue // for loop
+		}
+		addr := uint16(d[i+1]) | (uint16(d[i+2]) << 8)
+		s.count[addr]++
+		if i >= 2 && d[i-2] == ld {
+			s.immediate[addr] = append(s.immediate[addr], d[i-1])
+		}
+	}
+
+	// STA abs,X and STA abs,Y are commonly used to write to a range of
+	// registers but are never used for bankswitching. they're kept separate
+	// and only used for detecting a POKEY
+	for i := 0; i+2 < len(d); i++ {
+		if d[i] == 0x9d || d[i] == 0x99 {
+			addr := uint16(d[i+1]) | (uint16(d[i+2]) << 8)
+			s.indexed[addr]++
+		}
+	}
+
+	return s
+}
+
+// the total number of stores to addresses in the range, inclusive
+func (s heuristicsStores) rangeCount(lo uint16, hi uint16) int {
+	var n int
+	for a, c := range s.count {
+		if a >= lo && a <= hi {
+			n += c
+		}
+	}
+	return n
+}
+
+// the number of different addresses in the range, inclusive, that are
+// stored to
+func (s heuristicsStores) rangeDistinct(lo uint16, hi uint16) int {
+	var n int
+	for a := range s.count {
+		if a >= lo && a <= hi {
+			n++
+		}
+	}
+	return n
+}
+
+// converts a CPU address into an offset in the cartridge data for a mapper.
+// returns false if the CPU address is not mapped to a fixed area of the
+// cartridge
+type heuristicsOffset func(address uint16) (int, bool)
+
+// test the reset vector for a mapper and add the evidence to the candidate
+func (c *heuristicsCandidate) resetVector(d []uint8, offset heuristicsOffset) {
+	o, ok := offset(0xfffc)
+	if !ok || o+1 >= len(d) {
+		c.evidence(0, "reset vector is outside of the data")
+		return
+	}
+	vector := uint16(d[o]) | (uint16(d[o+1]) << 8)
+
+	o, ok = offset(vector)
+	if !ok || o >= len(d) {
+		c.evidence(0, "reset vector %#04x does not point to fixed ROM", vector)
+		return
+	}
+
+	// most programs begin with SEI or CLD
+	if d[o] == 0x78 || d[o] == 0xd8 {
+		c.evidence(heuristicsReset, "reset vector %#04x points to SEI/CLD", vector)
+		return
+	}
+	c.evidence(heuristicsResetCode, "reset vector %#04x points to fixed ROM", vector)
+}
+
+// detectPOKEY looks for stores to the registers of a POKEY at one of the
+// supported addresses. the cartridge type bit for the most likely address is
+// returned, or zero if there is no evidence of a POKEY
+func detectPOKEY(s heuristicsStores) (uint16, string) {
+	var bit uint16
+	var reason string
+	var best int
+
+	for _, pk := range []struct {
+		bit  uint16
+		addr uint16
+	}{
+		{bit: cartTypePokey4000, addr: 0x4000},
+		{bit: cartTypePokey0450, addr: 0x0450},
+		{bit: cartTypePokey0440, addr: 0x0440},
+		{bit: cartTypePokey0800, addr: 0x0800},
+	} {
+		// a POKEY must be initialised by writing to AUDCTL or SKCTL and a
+		// program that plays sound will write to several of the registers
+		const audctl = 0x08
+		const skctl = 0x0f
+		if s.count[pk.addr+audctl] == 0 && s.count[pk.addr+skctl] == 0 {
+			continue // for loop
+		}
+
+		var n, distinct int
+		for a := pk.addr; a < pk.addr+0x10; a++ {
+			if c := s.count[a] + s.indexed[a]; c > 0 {
+				n += c
+				distinct++
+			}
+		}
+		if distinct < 4 {
+			continue // for loop
+		}
+
+		if n > best {
+			best = n
+			bit = pk.bit
+			reason = fmt.Sprintf("%d stores to POKEY registers at %#04x", n, pk.addr)
+		}
+	}
+
+	return bit, reason
+}
+
+// detectMapper uses the contents of headerless cartridge data to decide which
+// mapper is most likely to be correct. the evidence used is the size of the
+// data, the location of the reset vector, the addresses of bankswitching stores
+// and the presence of stores to a POKEY
+//
+// the confidence is a percentage. a mapper that is clearly ahead of all the
+// other mappers is reported with more confidence than a mapper that is only
+// slightly ahead of another. the reasoning is logged
+func detectMapper(filename string, d []uint8) (cartProperties, int) {
+	stores := scanStores(d)
+	size := len(d)
+
+	var candidates []*heuristicsCandidate
+
+	// flat cartridges of up to 48k
+	if size > 0 && size <= 0xc000 {
+		c := &heuristicsCandidate{name: "FLAT"}
+		origin := 0x10000 - size
+		c.resetVector(d, func(address uint16) (int, bool) {
+			return int(address) - origin, int(address) >= origin
+		})
+
+		// a flat cartridge has no reason to write to its own ROM. stores to
+		// 0x4000 to 0x7fff are ignored because that area might be used by a
+		// POKEY or by RAM
+		if n := stores.rangeCount(uint16(max(origin, 0x8000)), 0xffff); n > 0 {
+			c.evidence(0, "%d stores to ROM", n)
+		} else {
+			c.evidence(heuristicsBankswitch, "no stores to ROM")
+		}
+
+		switch size {
+		case 0x4000, 0x8000, 0xc000:
+			c.evidence(heuristicsExtra, "size is %dk", size/1024)
+		}
+
+		candidates = append(candidates, c)
+	}
+
+	// absolute cartridges are always 64k. absolute is considered before
+	// supergame so that absolute is chosen if the scores are equal
+	if size == 0x10000 {
+		c := &heuristicsCandidate{name: "ABSOLUTE", props: cartProperties{cartType: 0x0200}}
+
+		// 0x8000 to 0xffff is fixed
+		c.resetVector(d, func(address uint16) (int, bool) {
+			return int(address), address >= 0x8000
+		})
+
+		if n := stores.count[0x8000]; n > 0 {
+			c.evidence(min(n*heuristicsBankswitch/4, heuristicsBankswitch), "%d stores to 0x8000", n)
+		} else {
+			c.evidence(0, "no stores to 0x8000")
+		}
+
+		// the bank is selected by writing 1 or 2
+		if v := stores.immediate[0x8000]; len(v) > 0 {
+			if !slices.ContainsFunc(v, func(b uint8) bool { return b != 1 && b != 2 }) {
+				c.evidence(heuristicsExtra, "only values 1 and 2 stored to 0x8000")
+			}
+		}
+
+		candidates = append(candidates, c)
+	}
+
+	// the supergame bankswitch is a store to anywhere in 0x8000 to 0xbfff
+	supergame := func(c *heuristicsCandidate, banks int) {
+		c.resetVector(d, func(address uint16) (int, bool) {
+			return size - 0x4000 + int(address) - 0xc000, address >= 0xc000
+		})
+		if n := stores.rangeCount(0x8000, 0xbfff); n > 0 {
+			c.evidence(min(n*heuristicsBankswitch/4, heuristicsBankswitch), "%d stores to 0x8000-0xbfff", n)
+		} else {
+			c.evidence(0, "no stores to 0x8000-0xbfff")
+		}
+		if banks&(banks-1) == 0 {
+			c.evidence(heuristicsExtra, "%d banks", banks)
+		}
+	}
+
+	if size%0x4000 == 0 && size >= 0x10000 {
+		c := &heuristicsCandidate{name: "SUPERGAME", props: cartProperties{cartType: 0x0002}}
+		supergame(c, size/0x4000)
+		candidates = append(candidates, c)
+
+		// the extra ROM is an additional 16k at the start of the data
+		c = &heuristicsCandidate{name: "SUPERGAME_EXROM", props: cartProperties{cartType: 0x000a}}
+		supergame(c, size/0x4000-1)
+		candidates = append(candidates, c)
+	}
+
+	// activision cartridges are always 128k
+	if size == 0x20000 {
+		c := &heuristicsCandidate{name: "ACTIVISION", props: cartProperties{cartType: 0x0100}}
+
+		// the first 8k of bank 7 is mapped to 0xe000 and the second 8k to
+		// 0x8000. the order is swapped if the file has been dumped in the
+		// original order
+		first := 7*0x4000 + 0x0000
+		second := 7*0x4000 + 0x2000
+		if strings.Contains(filename, "(OM)") {
+			first, second = second, first
+		}
+		c.resetVector(d, func(address uint16) (int, bool) {
+			if address >= 0xe000 {
+				return first + int(address) - 0xe000, true
+			}
+			if address >= 0x8000 && address < 0xa000 {
+				return second + int(address) - 0x8000, true
+			}
+			return 0, false
+		})
+
+		// the bankswitch is a store to 0xff80 or above. the bank is selected
+		// by the lower bits of the address
+		if n := stores.rangeDistinct(0xff80, 0xff87); n > 1 {
+			c.evidence(heuristicsBankswitch, "stores to %d banks at 0xff80-0xff87", n)
+		} else if n > 0 {
+			c.evidence(heuristicsBankswitch/2, "stores to 1 bank at 0xff80-0xff87")
+		} else {
+			c.evidence(0, "no stores to 0xff80-0xff87")
+		}
+
+		// activision games do not use the supergame bankswitch
+		if stores.rangeCount(0x8000, 0xbfff) == 0 {
+			c.evidence(heuristicsExtra, "no stores to 0x8000-0xbfff")
+		}
+
+		candidates = append(candidates, c)
+	}
+
+	// SN and EAGLE cartridges are made of 4k banks. the fixed bank 7 is the
+	// eighth 4k block of data regardless of the size of the data
+	if size%0x1000 == 0 && size >= 0x8000 {
+		mapper := "SN"
+		if size > 0x40000 {
+			mapper = "EAGLE"
+		}
+		c := &heuristicsCandidate{name: mapper, props: cartProperties{mapper: mapper}}
+
+		c.resetVector(d, func(address uint16) (int, bool) {
+			return 7*0x1000 + int(address) - 0xf000, address >= 0xf000
+		})
+
+		var hotspots int
+		for a := uint16(0x8000); a <= 0xe000; a += 0x1000 {
+			if stores.count[a] > 0 {
+				hotspots++
+			}
+		}
+		if hotspots > 1 {
+			c.evidence(heuristicsBankswitch, "stores to %d bank hotspots", hotspots)
+		} else if hotspots > 0 {
+			c.evidence(heuristicsBankswitch/4, "stores to 1 bank hotspot")
+		} else {
+			c.evidence(0, "no stores to bank hotspots")
+		}
+
+		if n := stores.count[0xffff]; n > 0 {
+			c.evidence(heuristicsExtra, "%d stores to RAM control at 0xffff", n)
+		}
+
+		candidates = append(candidates, c)
+	}
+
+	// choose the candidate with the highest score. the order of the candidates
+	// decides which candidate is chosen when scores are equal
+	var best, second *heuristicsCandidate
+	for _, c := range candidates {
+		for _, r := range c.reasons {
+			logger.Logf(logger.Allow, "heuristics", "%s: %s", strings.ToLower(c.name), r)
+		}
+		logger.Logf(logger.Allow, "heuristics", "%s: score %d/%d", strings.ToLower(c.name), c.score, heuristicsMax)
+
+		if best == nil || c.score > best.score {
+			best, second = c, best
+		} else if second == nil || c.score > second.score {
+			second = c
+		}
+	}
+
+	if best == nil {
+		logger.Logf(logger.Allow, "heuristics", "no mapper supports a size of %d bytes. using flat", size)
+		return cartProperties{}, 0
+	}
+
+	confidence := best.score * 100 / heuristicsMax
+	if second != nil {
+		confidence -= second.score * 50 / heuristicsMax
+	}
+	confidence = max(confidence, 0)
+
+	props := best.props
+	if bit, reason := detectPOKEY(stores); bit != 0 {
+		props.cartType |= bit
+		logger.Logf(logger.Allow, "heuristics", "%s", reason)
+	}
+
+	logger.Logf(logger.Allow, "heuristics", "mapper: %s (%d%% confidence)", best.name, confidence)
+	if confidence < 50 {
+		logger.Logf(logger.Allow, "heuristics", "low confidence. the cartridge can be added to the game database")
+	}
+
+	return props, confidence
+}
@@ -0,0 +1,120 @@
+package external
+
+import (
+	"testing"
+
+	"github.com/jetsetilly/test7800/test"
+)
+
+// returns headerless cartridge data of the size given. the data is filled with
+// a value that is not an opcode recognised by the heuristics
+func fillData(size int) []uint8 {
+	d := make([]uint8, size)
+	for i := range d {
+		d[i] = 0xea
+	}
+	return d
+}
+
+// place the reset vector at offset o and the start of a program at offset
+// p. the program begins with SEI and CLD
+func placeReset(d []uint8, o int, vector uint16, p int) {
+	d[o] = uint8(vector)
+	d[o+1] = uint8(vector >> 8)
+	d[p] = 0x78
+	d[p+1] = 0xd8
+}
+
+// place a LDA #v, STA addr sequence at offset o
+func placeStore(d []uint8, o int, v uint8, addr uint16) {
+	copy(d[o:], []uint8{0xa9, v, 0x8d, uint8(addr), uint8(addr >> 8)})
+}
+
+func TestHeuristics_flat(t *testing.T) {
+	d := fillData(0x8000)
+	placeReset(d, 0x7ffc, 0x8000, 0x0000)
+
+	props, confidence := detectMapper("", d)
+	test.ExpectEquality(t, props.cartType, uint16(0x0000))
+	test.ExpectEquality(t, props.mapper, "")
+	test.ExpectEquality(t, confidence, 100)
+}
+
+func TestHeuristics_supergame(t *testing.T) {
+	d := fillData(0x20000)
+	placeReset(d, 0x1fffc, 0xc000, 0x1c000)
+	for i := range 4 {
+		placeStore(d, 0x1c100+i*5, uint8(i), 0x8000)
+	}
+
+	props, confidence := detectMapper("", d)
+	test.ExpectEquality(t, props.cartType, uint16(0x0002))
+	test.ExpectEquality(t, props.mapper, "")
+	test.ExpectSuccess(t, confidence >= 50)
+
+	// the same data with an extra 16k at the start
+	d = append(fillData(0x4000), d...)
+	props, _ = detectMapper("", d)
+	test.ExpectEquality(t, props.cartType, uint16(0x000a))
+}
+
+func TestHeuristics_activision(t *testing.T) {
+	d := fillData(0x20000)
+	placeReset(d, 0x1dffc, 0xe000, 0x1c000)
+	placeStore(d, 0x1c100, 0, 0xff80)
+	placeStore(d, 0x1c105, 0, 0xff81)
+
+	props, confidence := detectMapper("", d)
+	test.ExpectEquality(t, props.cartType, uint16(0x0100))
+	test.ExpectSuccess(t, confidence >= 50)
+
+	// the same data in the original order is still detected as activision but
+	// with more confidence if the filename says that it is in the original order
+	for i := 0; i < len(d); i += 0x4000 {
+		b := append([]uint8{}, d[i:i+0x2000]...)
+		copy(d[i:], d[i+0x2000:i+0x4000])
+		copy(d[i+0x2000:], b)
+	}
+	props, withoutOM := detectMapper("", d)
+	test.ExpectEquality(t, props.cartType, uint16(0x0100))
+	props, withOM := detectMapper("game (OM).bin", d)
+	test.ExpectEquality(t, props.cartType, uint16(0x0100))
+	test.ExpectSuccess(t, withOM > withoutOM)
+}
+
+func TestHeuristics_absolute(t *testing.T) {
+	d := fillData(0x10000)
+	placeReset(d, 0xfffc, 0xc000, 0xc000)
+	placeStore(d, 0xc100, 1, 0x8000)
+	placeStore(d, 0xc105, 2, 0x8000)
+
+	props, _ := detectMapper("", d)
+	test.ExpectEquality(t, props.cartType, uint16(0x0200))
+
+	// a value other than 1 or 2 suggests supergame
+	placeStore(d, 0xc10a, 0, 0x8000)
+	props, _ = detectMapper("", d)
+	test.ExpectEquality(t, props.cartType, uint16(0x0002))
+}
+
+func TestHeuristics_sn(t *testing.T) {
+	d := fillData(0x10000)
+	placeReset(d, 0x7ffc, 0xf000, 0x7000)
+	placeStore(d, 0x7100, 8, 0x8000)
+	placeStore(d, 0x7105, 9, 0x9000)
+	placeStore(d, 0x710a, 10, 0xa000)
+
+	props, _ := detectMapper("", d)
+	test.ExpectEquality(t, props.mapper, "SN")
+}
+
+func TestHeuristics_pokey(t *testing.T) {
+	d := fillData(0x8000)
+	placeReset(d, 0x7ffc, 0x8000, 0x0000)
+	for i, r := range []uint16{0x00, 0x01, 0x02, 0x08, 0x0f} {
+		placeStore(d, 0x0100+i*5, 0, 0x0450+r)
+	}
+
+	props, _ := detectMapper("", d)
+	test.ExpectEquality(t, props.cartType, uint16(cartTypePokey0450))
+}
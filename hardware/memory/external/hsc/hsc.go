@@ -108,6 +108,21 @@ func (dev *Device) Access(write bool, address uint16, data uint8) (uint8, error)
 	return 0, nil
 }
 
+// Bank returns the bank of the inserted cartridge that is mapped into the
+// address. The HSC itself is not banked
+func (dev *Device) Bank(address uint16) (int, bool) {
+	if address >= biosOrigin && address <= biosMemtop {
+		return 0, false
+	}
+	if address >= sramOrigin && address <= sramMemtop {
+		return 0, false
+	}
+	if d, ok := dev.inserted.(interface{ Bank(uint16) (int, bool) }); ok {
+		return d.Bank(address)
+	}
+	return 0, false
+}
+
 func (dev *Device) save() {
 	p, err := resources.JoinPath(hsc_nvram)
 	if err != nil {
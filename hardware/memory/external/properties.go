@@ -0,0 +1,264 @@
+package external
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jetsetilly/test7800/hardware/memory/external/a78"
+	"github.com/jetsetilly/test7800/hardware/memory/external/gamedb"
+	"github.com/jetsetilly/test7800/hardware/pokey"
+	"github.com/jetsetilly/test7800/logger"
+)
+
+// the bits of the a78 cartridge type that specify additional chips rather
+// than the mapper
+const (
+	cartTypePokey4000 = a78.CartTypePokey4000
+	cartTypePokey0450 = a78.CartTypePokey0450
+	cartTypePokey0440 = a78.CartTypePokey0440
+	cartTypePokey0800 = a78.CartTypePokey0800
+	cartTypeYM2151    = a78.CartTypeYM2151
+
+	cartTypePokeys = a78.CartTypePokeys
+	cartTypeChips  = a78.CartTypeChips
+)
+
+// the properties of a cartridge that decide how it is inserted. the properties
+// come from the a78 header, from the game database or from the defaults for a
+// headerless dump
+type cartProperties struct {
+	// cartridge type in the a78 header format
+	cartType uint16
+
+	// the SN and EAGLE mappers cannot be described by the a78 cartridge type.
+	// if the mapper field is not empty then the mapper part of the cartridge
+	// type is ignored
+	mapper string
+
+	controller string
+	spec       string
+	useHSC     bool
+	useSavekey bool
+	bypassBIOS bool
+}
+
+// apply the game database entry to the properties. fields that are not
+// specified by the entry are not changed
+func (p *cartProperties) apply(e gamedb.Entry) {
+	logger.Logf(logger.Allow, "gamedb", "found: %s", e.Name)
+
+	if e.Mapper != "" {
+		if t, ok := a78.Mappers[e.Mapper]; ok {
+			p.cartType = (p.cartType & cartTypeChips) | t
+			p.mapper = ""
+		} else {
+			p.cartType &= cartTypeChips
+			p.mapper = e.Mapper
+		}
+		logger.Logf(logger.Allow, "gamedb", "mapper: %s", e.Mapper)
+	}
+
+	if e.Controller != "" {
+		p.controller = strings.ToLower(e.Controller)
+		logger.Logf(logger.Allow, "gamedb", "controllers: %s", p.controller)
+	}
+
+	if e.Spec != "" {
+		p.spec = e.Spec
+		logger.Logf(logger.Allow, "gamedb", "spec: %s", p.spec)
+	}
+
+	if e.NoPokey || e.Pokey != 0 {
+		p.cartType &^= cartTypePokeys
+		switch e.Pokey {
+		case 0x4000:
+			p.cartType |= cartTypePokey4000
+		case 0x0450:
+			p.cartType |= cartTypePokey0450
+		case 0x0440:
+			p.cartType |= cartTypePokey0440
+		case 0x0800:
+			p.cartType |= cartTypePokey0800
+		}
+	}
+
+	if e.YM2151 {
+		p.cartType |= cartTypeYM2151
+	}
+
+	p.useHSC = p.useHSC || e.HSC
+	p.useSavekey = p.useSavekey || e.Savekey
+	p.bypassBIOS = p.bypassBIOS || e.BypassBIOS
+
+	for _, q := range e.Quirks {
+		logger.Logf(logger.Allow, "gamedb", "quirk: %s", q)
+	}
+}
+
+// returns the cartridge insertor for the properties. the cartridge data
+// starts at dataStart
+func (p cartProperties) insertor(filename string, d []uint8, dataStart int) (CartridgeInsertor, error) {
+	cartType := p.cartType
+
+	if cartType&cartTypeYM2151 == cartTypeYM2151 {
+		logger.Logf(logger.Allow, "a78", "YM2151 required but not supported")
+		cartType &^= cartTypeYM2151
+	}
+
+	// list of creator functions for additional chips
+	var chips []func(Context) (OptionalBus, error)
+
+	for _, pk := range []struct {
+		bit  uint16
+		addr uint16
+	}{
+		{bit: cartTypePokey4000, addr: 0x4000},
+		{bit: cartTypePokey0450, addr: 0x0450},
+		{bit: cartTypePokey0440, addr: 0x0440},
+		{bit: cartTypePokey0800, addr: 0x0800},
+	} {
+		if cartType&pk.bit == pk.bit {
+			chips = append(chips, func(ctx Context) (OptionalBus, error) {
+				return pokey.NewAudio(ctx, pk.addr)
+			})
+			cartType &^= pk.bit
+		}
+	}
+
+	c := CartridgeInsertor{
+		filename:   filename,
+		data:       d,
+		reset:      CartridgeReset{BypassBIOS: p.bypassBIOS},
+		Controller: p.controller,
+		spec:       p.spec,
+		chips:      chips,
+		UseHSC:     p.useHSC,
+		UseSavekey: p.useSavekey,
+	}
+
+	// SN/EAGLE mapper
+	if p.mapper != "" {
+		mapper := p.mapper
+		c.creator = func(ctx Context, d []uint8) (Bus, error) {
+			return NewSN(ctx, d[dataStart:], mapper)
+		}
+		return c, nil
+	}
+
+	if cartType == 0x0000 {
+		c.creator = func(ctx Context, d []uint8) (Bus, error) {
+			return NewFlat(ctx, d[dataStart:])
+		}
+		return c, nil
+	}
+
+	// mRAM chip in flat ROM cartridge (no bankswitching)
+	//
+	// it's not clear if the mRAM chip (with a masked address line) can be used in
+	// conjunction with a bankswitching method. as it stands, I think only 'Rescue On
+	// Fractalus' uses this type of RAM chip and that has a flat ROM map
+	if cartType == 0x0080 {
+		c.creator = func(ctx Context, d []uint8) (Bus, error) {
+			return NewMRAM(ctx, d[dataStart:])
+		}
+		return c, nil
+	}
+
+	// activision
+	if cartType == 0x0100 {
+		// if cartridge name contians the '(OM)' string then the cartridge has been dumped
+		// with "original ordering". alternative ordering can be indicated with '(AM)' but
+		// we don't look for that and we assume that type of ordering by default
+		originalOrder := strings.Contains(filename, "(OM)")
+
+		c.creator = func(ctx Context, d []uint8) (Bus, error) {
+			return NewActivision(ctx, d[dataStart:], originalOrder)
+		}
+		return c, nil
+	}
+
+	// absolute
+	if cartType == 0x0200 {
+		c.creator = func(ctx Context, d []uint8) (Bus, error) {
+			return NewAbsolute(ctx, d[dataStart:])
+		}
+		return c, nil
+	}
+
+	// banksets
+	if cartType&0x2000 == 0x2000 {
+		supergame := cartType&0x02 == 0x02
+		banksetRAM := cartType&0x4000 == 0x4000
+		c.creator = func(ctx Context, d []uint8) (Bus, error) {
+			return NewBanksets(ctx, supergame, d[dataStart:], banksetRAM)
+		}
+		return c, nil
+	}
+
+	// supergame
+	banked := cartType&0x02 == 0x02
+	exram := cartType&0x04 == 0x04
+	exrom := cartType&0x08 == 0x08
+
+	if banked || exrom || exram {
+		c.creator = func(ctx Context, d []uint8) (Bus, error) {
+			return NewSupergame(ctx, d[dataStart:],
+				banked, exram, exrom,
+			)
+		}
+		return c, nil
+	}
+
+	return CartridgeInsertor{}, fmt.Errorf("a78: unsupported cartridge type (%#04x)", cartType)
+}
+
+// NewHeader returns an a78 header for headerless cartridge data. the properties
+// of the cartridge are decided by the game database or by the heuristics, in
+// the same way as they are for a headerless dump given to FingerprintBlob()
+func NewHeader(filename string, d []uint8) (a78.Header, error) {
+	props := cartProperties{
+		controller: "7800_joystick",
+	}
+
+	title := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+
+	if e, ok := gamedb.Lookup(d); ok {
+		props.apply(e)
+		if e.Name != "" {
+			title = e.Name
+		}
+	} else {
+		props, _ = detectMapper(filename, d)
+		props.controller = "7800_joystick"
+	}
+
+	if props.mapper != "" {
+		return a78.Header{}, fmt.Errorf("a78: %s mapper cannot be described by an a78 header", props.mapper)
+	}
+
+	h := a78.Header{
+		Version:  3,
+		Title:    title,
+		Size:     uint32(len(d)),
+		CartType: props.cartType,
+	}
+
+	for port := range 2 {
+		err := h.SetController(port+1, props.controller)
+		if err != nil {
+			return a78.Header{}, err
+		}
+	}
+
+	if props.spec != "" {
+		err := h.SetTV(props.spec)
+		if err != nil {
+			return a78.Header{}, err
+		}
+	}
+
+	h.SetSaveDevice(props.useHSC, props.useSavekey)
+
+	return h, nil
+}
@@ -110,3 +110,19 @@ func (ext *Supergame) Access(write bool, address uint16, data uint8) (uint8, err
 	// return data from bank 7 for all addresses of 0xc000 and above
 	return ext.data[len(ext.data)-1][address-0xc000], nil
 }
+
+func (ext *Supergame) Bank(address uint16) (int, bool) {
+	if address < 0x4000 {
+		return 0, false
+	}
+	if address < 0x8000 {
+		if len(ext.exrom) > 0 || len(ext.exram) > 0 {
+			return 0, false
+		}
+		return len(ext.data) - 2, true
+	}
+	if address < 0xc000 {
+		return ext.bank, true
+	}
+	return len(ext.data) - 1, true
+}
@@ -42,6 +42,10 @@ type Memory struct {
 	LastCPUAddress uint16
 	LastCPUData    uint8
 	LastCPUWrite   bool
+
+	// if BusTrace is not nil it is called for every read and write on the
+	// memory bus. this includes the reads made by MARIA during DMA
+	BusTrace func(address uint16, data uint8, write bool)
 }
 
 type Context interface {
@@ -395,6 +399,9 @@ func (mem *Memory) Read(address uint16) (uint8, error) {
 	mem.LastCPUAddress = address
 	mem.LastCPUWrite = false
 	mem.LastCPUData = data
+	if mem.BusTrace != nil {
+		mem.BusTrace(address, data, false)
+	}
 
 	return data, nil
 }
@@ -434,6 +441,10 @@ func (mem *Memory) Write(address uint16, data uint8) error {
 	mem.LastCPUAddress = address
 	mem.LastCPUWrite = true
 	mem.LastCPUData = data
+	if mem.BusTrace != nil {
+		// the data bus holds the value that was written
+		mem.BusTrace(address, mem.dataBus, true)
+	}
 
 	return nil
 }
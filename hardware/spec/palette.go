@@ -0,0 +1,238 @@
+package spec
+
+import (
+	"embed"
+	"fmt"
+	"image/color"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+//go:embed "palettes"
+var palettes embed.FS
+
+// the number of bytes in a palette file. three bytes (red, green, blue) for
+// each of the 256 colours
+const paletteFileSize = 768
+
+// the name of an embedded palette is the path of the palette file inside the
+// palettes directory without the extension. returns false if the file is not
+// a palette file
+func paletteName(p string) (string, bool) {
+	if strings.ToLower(path.Ext(p)) != ".pal" {
+		return "", false
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(p, "palettes/"), path.Ext(p)), true
+}
+
+// Palettes returns the names of the palettes embedded in the program. The
+// names can be used with LoadPalette(). Palettes in a subdirectory are named
+// with the subdirectory (eg. trebor/NTSC_A78_CRTTV_BRT)
+func Palettes() []string {
+	var names []string
+	fs.WalkDir(palettes, "palettes", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if n, ok := paletteName(p); ok {
+			names = append(names, n)
+		}
+		return nil
+	})
+	slices.Sort(names)
+	return names
+}
+
+// LoadPalette returns the named palette. The name can be one of the names
+// returned by Palettes(), the filename of a palette file, or the word GENERATE
+// optionally followed by a colon and a list of parameters for
+// GeneratePalette(). For example:
+//
+//	GENERATE:phase=26.2,saturation=0.3
+//
+// A palette file is 768 bytes long and contains the red, green and blue
+// values for each of the 256 colours
+func LoadPalette(name string) ([256]color.RGBA, error) {
+	if strings.HasPrefix(strings.ToUpper(name), "GENERATE") {
+		s := name[len("GENERATE"):]
+		if s != "" {
+			if s[0] != ':' {
+				return [256]color.RGBA{}, fmt.Errorf("palette: unrecognised palette: %s", name)
+			}
+			s = s[1:]
+		}
+		params, err := ParsePaletteParams(s)
+		if err != nil {
+			return [256]color.RGBA{}, err
+		}
+		return GeneratePalette(params), nil
+	}
+
+	var data []byte
+
+	err := fs.WalkDir(palettes, "palettes", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || data != nil {
+			return nil
+		}
+		if n, ok := paletteName(p); ok && strings.EqualFold(n, filepath.ToSlash(name)) {
+			data, err = palettes.ReadFile(p)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return [256]color.RGBA{}, fmt.Errorf("palette: %w", err)
+	}
+
+	if data == nil {
+		data, err = os.ReadFile(name)
+		if err != nil {
+			return [256]color.RGBA{}, fmt.Errorf("palette: %w", err)
+		}
+	}
+
+	return decodePalette(data)
+}
+
+func decodePalette(data []byte) ([256]color.RGBA, error) {
+	var pal [256]color.RGBA
+	if len(data) != paletteFileSize {
+		return pal, fmt.Errorf("palette: data is %d bytes long. should be %d bytes (256 * 3)", len(data), paletteFileSize)
+	}
+	for i := range pal {
+		p := i * 3
+		pal[i] = color.RGBA{R: data[p], G: data[p+1], B: data[p+2], A: 255}
+	}
+	return pal, nil
+}
+
+// EncodePalette returns the palette in the palette file format
+func EncodePalette(pal [256]color.RGBA) []byte {
+	data := make([]byte, 0, paletteFileSize)
+	for _, c := range pal {
+		data = append(data, c.R, c.G, c.B)
+	}
+	return data
+}
+
+// PaletteParams are the parameters used by GeneratePalette()
+type PaletteParams struct {
+	// the rotation of every colour in degrees. hue one has the same phase as
+	// the colour burst when this value is zero
+	Hue float64
+
+	// the angle in degrees between each hue. this is the value adjusted by
+	// the colour adjustment pot inside the console
+	Phase float64
+
+	Saturation float64
+	Contrast   float64
+	Brightness float64
+	Gamma      float64
+}
+
+// DefaultPaletteParams are the parameters used by GeneratePalette() for any
+// value that is not specified
+var DefaultPaletteParams = PaletteParams{
+	Hue:        0.0,
+	Phase:      25.7,
+	Saturation: 0.25,
+	Contrast:   0.9,
+	Brightness: 0.05,
+	Gamma:      1.0,
+}
+
+// ParsePaletteParams parses a comma separated list of key=value pairs. The
+// keys are hue, phase, saturation, contrast, brightness and gamma. Any key not
+// specified takes its value from DefaultPaletteParams
+func ParsePaletteParams(s string) (PaletteParams, error) {
+	params := DefaultPaletteParams
+
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return params, nil
+	}
+
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return params, fmt.Errorf("palette: parameter should be in the form key=value: %s", kv)
+		}
+
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return params, fmt.Errorf("palette: value for %s is not a number: %s", k, v)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(k)) {
+		case "hue":
+			params.Hue = f
+		case "phase":
+			params.Phase = f
+		case "saturation", "sat":
+			params.Saturation = f
+		case "contrast":
+			params.Contrast = f
+		case "brightness":
+			params.Brightness = f
+		case "gamma":
+			if f <= 0 {
+				return params, fmt.Errorf("palette: gamma must be greater than zero")
+			}
+			params.Gamma = f
+		default:
+			return params, fmt.Errorf("palette: unrecognised parameter: %s", k)
+		}
+	}
+
+	return params, nil
+}
+
+// GeneratePalette creates a palette in the same way as the console. The upper
+// nibble of the colour index selects the hue and the lower nibble selects the
+// luminance. Hue zero has no colour. The other hues are created by delaying
+// the colour burst signal by an increasing amount
+func GeneratePalette(params PaletteParams) [256]color.RGBA {
+	var pal [256]color.RGBA
+
+	clamp := func(v float64) uint8 {
+		v = max(0, min(1, v))
+		v = math.Pow(v, 1/params.Gamma)
+		return uint8(math.Round(v * 255))
+	}
+
+	for hue := range 16 {
+		var u, v float64
+		if hue > 0 {
+			// hue one is the same phase as the colour burst, which is at 180 degrees
+			angle := (180 + params.Hue - float64(hue-1)*params.Phase) * math.Pi / 180
+			u = params.Saturation * math.Cos(angle)
+			v = params.Saturation * math.Sin(angle)
+		}
+
+		for lum := range 16 {
+			y := params.Brightness + params.Contrast*float64(lum)/15
+
+			pal[(hue<<4)|lum] = color.RGBA{
+				R: clamp(y + 1.140*v),
+				G: clamp(y - 0.395*u - 0.581*v),
+				B: clamp(y + 2.032*u),
+				A: 255,
+			}
+		}
+	}
+
+	return pal
+}
@@ -0,0 +1,77 @@
+package spec_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jetsetilly/test7800/hardware/spec"
+	"github.com/jetsetilly/test7800/test"
+)
+
+func TestLoadPalette(t *testing.T) {
+	names := spec.Palettes()
+	test.DemandSuccess(t, len(names) > 0)
+
+	for _, n := range names {
+		_, err := spec.LoadPalette(n)
+		test.ExpectSuccess(t, err, n)
+	}
+
+	// the default NTSC palette is one of the embedded palettes. the name
+	// includes the subdirectory
+	pal, err := spec.LoadPalette("trebor/ntsc_a78_crttv_brt")
+	test.DemandSuccess(t, err)
+	test.ExpectEquality(t, pal, spec.NTSC.Palette)
+
+	// the name of the file is not enough if the palette is in a subdirectory
+	_, err = spec.LoadPalette("ntsc_a78_crttv_brt")
+	test.ExpectFailure(t, err)
+
+	// palette files are saved and loaded in the same format
+	fn := filepath.Join(t.TempDir(), "test.pal")
+	test.DemandSuccess(t, os.WriteFile(fn, spec.EncodePalette(pal), 0644))
+	pal, err = spec.LoadPalette(fn)
+	test.DemandSuccess(t, err)
+	test.ExpectEquality(t, pal, spec.NTSC.Palette)
+
+	// palette files must be the correct length
+	test.DemandSuccess(t, os.WriteFile(fn, make([]byte, 100), 0644))
+	_, err = spec.LoadPalette(fn)
+	test.ExpectFailure(t, err)
+
+	_, err = spec.LoadPalette("no such palette")
+	test.ExpectFailure(t, err)
+}
+
+func TestGeneratePalette(t *testing.T) {
+	pal, err := spec.LoadPalette("GENERATE")
+	test.DemandSuccess(t, err)
+	test.ExpectEquality(t, pal, spec.GeneratePalette(spec.DefaultPaletteParams))
+
+	// hue zero is greyscale and increases in brightness
+	for i := range 16 {
+		test.ExpectEquality(t, pal[i].R, pal[i].G)
+		test.ExpectEquality(t, pal[i].R, pal[i].B)
+		if i > 0 {
+			test.ExpectSuccess(t, pal[i].R > pal[i-1].R)
+		}
+	}
+
+	// hue one has the same phase as the colour burst and is a shade of yellow
+	c := pal[0x18]
+	test.ExpectSuccess(t, c.R > c.B && c.G > c.B)
+
+	// changing the phase does not change hue one but does change the other hues
+	shifted, err := spec.LoadPalette("generate:phase=20")
+	test.DemandSuccess(t, err)
+	test.ExpectEquality(t, shifted[0x18], pal[0x18])
+	test.ExpectInequality(t, shifted[0x48], pal[0x48])
+
+	_, err = spec.LoadPalette("generate:phase")
+	test.ExpectFailure(t, err)
+	_, err = spec.LoadPalette("generate:colour=1")
+	test.ExpectFailure(t, err)
+	_, err = spec.LoadPalette("generate:gamma=0")
+	test.ExpectFailure(t, err)
+}
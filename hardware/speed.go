@@ -0,0 +1,78 @@
+package hardware
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/jetsetilly/test7800/logger"
+)
+
+// SpeedSteps are the speeds selected by the faster and slower speed controls
+var SpeedSteps = []float64{0.25, 0.5, 1, 2, 4}
+
+// the fastest speed that can be set with SetSpeed(), other than the unlimited
+// speed
+const maxSpeed = 16
+
+// SetSpeed changes the speed of the emulation as a multiple of the normal speed.
+// A value of zero means that the emulation runs as fast as possible. Audio is
+// muted at any speed other than the normal speed
+func (con *Console) SetSpeed(speed float64) error {
+	if speed < 0 || speed > maxSpeed {
+		return fmt.Errorf("speed must be between 0 and %d", maxSpeed)
+	}
+	con.speed = speed
+	con.applySpeed()
+	return nil
+}
+
+// Speed returns the speed of the emulation as set by SetSpeed() and whether the
+// turbo control is active. The turbo control overrides the speed
+func (con *Console) Speed() (float64, bool) {
+	return con.speed, con.turbo
+}
+
+func (con *Console) applySpeed() {
+	speed := con.speed
+	if con.turbo {
+		speed = 0
+	}
+	con.limit.SetSpeed(speed)
+	con.TIA.SetMute(speed != 1)
+}
+
+// changes the speed to the next (or previous) entry in SpeedSteps. the unlimited
+// speed is treated as being faster than every entry
+func (con *Console) stepSpeed(dir int) {
+	speed := con.speed
+	switch {
+	case dir == 0:
+		speed = 1
+	case dir > 0:
+		if speed == 0 {
+			return
+		}
+		i := slices.IndexFunc(SpeedSteps, func(s float64) bool { return s > speed })
+		if i == -1 {
+			return
+		}
+		speed = SpeedSteps[i]
+	case dir < 0:
+		if speed == 0 {
+			speed = SpeedSteps[len(SpeedSteps)-1]
+		} else {
+			i := slices.IndexFunc(SpeedSteps, func(s float64) bool { return s >= speed })
+			if i == -1 {
+				i = len(SpeedSteps)
+			}
+			if i == 0 {
+				return
+			}
+			speed = SpeedSteps[i-1]
+		}
+	}
+
+	con.speed = speed
+	con.applySpeed()
+	logger.Logf(logger.Allow, "speed", "emulation speed is %gx", speed)
+}
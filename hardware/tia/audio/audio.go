@@ -16,6 +16,7 @@
 package audio
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/jetsetilly/test7800/hardware/spec"
@@ -63,6 +64,15 @@ type Audio struct {
 
 	// any chips in the external device that provide sound
 	externalChips []ExternalSoundChip
+
+	// the output of each channel before mixing. the first two entries are the
+	// TIA channels and the remaining entries are the channels of the external
+	// chips in order. updated by the Mono() and Stereo() functions
+	stems []int16
+
+	// the number of channels in each external chip. updated at the same time
+	// as the stems slice
+	stemsPerChip []int
 }
 
 // NewAudio is the preferred method of initialisation for the Audio sub-system.
@@ -134,15 +144,33 @@ func (au *Audio) Step() bool {
 // additional amplification for external sound chips
 const externalChipAmplification = 1
 
-// Mono returns the mixed volume from all audio sources
-func (au *Audio) Mono() int16 {
-	sum := int32(mix.Mono(au.vol0, au.vol1))
+// collects the output of every external chip channel. the result of each
+// channel, after amplification, is passed to the yield function along with the
+// index of the chip
+func (au *Audio) external(yield func(chip int, v int32)) {
+	s0, s1 := mix.Stereo(au.vol0, au.vol1)
+	au.stems = append(au.stems[:0], s0, s1)
+	au.stemsPerChip = au.stemsPerChip[:0]
 
-	for _, xc := range au.externalChips {
+	for i, xc := range au.externalChips {
+		var n int
 		xc.Volume(func(v int16) {
-			sum += int32(v) << externalChipAmplification
+			v32 := int32(v) << externalChipAmplification
+			au.stems = append(au.stems, mix.Clip(v32))
+			yield(i, v32)
+			n++
 		})
+		au.stemsPerChip = append(au.stemsPerChip, n)
 	}
+}
+
+// Mono returns the mixed volume from all audio sources
+func (au *Audio) Mono() int16 {
+	sum := int32(mix.Mono(au.vol0, au.vol1))
+
+	au.external(func(_ int, v int32) {
+		sum += v
+	})
 
 	return mix.Clip(sum)
 }
@@ -152,26 +180,52 @@ func (au *Audio) Stereo() (int16, int16) {
 	ch1 := int32(s1)
 	ch2 := int32(s2)
 
-	switch len(au.externalChips) {
-	case 0:
-	case 1:
-		for _, xc := range au.externalChips {
-			xc.Volume(func(v int16) {
-				ch1 += int32(v) << externalChipAmplification
-				ch2 += int32(v) << externalChipAmplification
-			})
+	single := len(au.externalChips) == 1
+	au.external(func(i int, v int32) {
+		if single {
+			ch1 += v
+			ch2 += v
+		} else if i&0x01 == 0x01 {
+			ch1 += v
+		} else {
+			ch2 += v
 		}
-	default:
-		for i, xc := range au.externalChips {
-			xc.Volume(func(v int16) {
-				if i&0x01 == 0x01 {
-					ch1 += int32(v) << externalChipAmplification
-				} else {
-					ch2 += int32(v) << externalChipAmplification
-				}
-			})
+	})
+
+	return mix.Clip(ch1), mix.Clip(ch2)
+}
+
+// Stems returns the output of each audio channel, before mixing, at the time
+// of the most recent call to Mono() or Stereo(). The first two values are the
+// TIA channels and are followed by the channels of each external sound chip.
+// The returned slice is reused and should not be retained
+func (au *Audio) Stems() []int16 {
+	return au.stems
+}
+
+// StemNames returns a short name for each of the values returned by Stems().
+// The names are suitable for use in filenames. eg. tia_ch0 or pokey_ch3
+func (au *Audio) StemNames() []string {
+	names := []string{"tia_ch0", "tia_ch1"}
+
+	seen := make(map[string]int)
+	for i, n := range au.stemsPerChip {
+		if i >= len(au.externalChips) {
+			break
+		}
+
+		// the first word of the chip label. the second chip with the same name
+		// has the number 2 appended and so on
+		chip := strings.ToLower(strings.Fields(au.externalChips[i].Label() + " chip")[0])
+		seen[chip]++
+		if seen[chip] > 1 {
+			chip = fmt.Sprintf("%s%d", chip, seen[chip])
+		}
+
+		for ch := range n {
+			names = append(names, fmt.Sprintf("%s_ch%d", chip, ch))
 		}
 	}
 
-	return mix.Clip(ch1), mix.Clip(ch2)
+	return names
 }
@@ -0,0 +1,77 @@
+package audio_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jetsetilly/test7800/hardware/memory/external"
+	"github.com/jetsetilly/test7800/hardware/tia/audio"
+	"github.com/jetsetilly/test7800/hardware/tia/audio/mix"
+	"github.com/jetsetilly/test7800/test"
+)
+
+// a sound chip with a fixed output for each channel
+type testChip struct {
+	label  string
+	volume []int16
+}
+
+func (c *testChip) Label() string {
+	return c.label
+}
+
+func (c *testChip) Access(_ bool, _ uint16, data uint8) (uint8, bool, error) {
+	return data, false, nil
+}
+
+func (c *testChip) Step() {
+}
+
+func (c *testChip) Volume(yield func(int16)) {
+	for _, v := range c.volume {
+		yield(v)
+	}
+}
+
+func TestStems(t *testing.T) {
+	au := audio.NewAudio()
+
+	// channel zero outputs a constant volume and channel one is silent
+	au.Channel0.Registers.Control = 0x00
+	au.Channel0.Registers.Volume = 0x0a
+	for range 1000 {
+		au.Step()
+	}
+
+	chips := []*testChip{
+		{label: "POKEY @ 0x4000", volume: []int16{0x100, 0x200, 0x300, 0x400}},
+		{label: "POKEY @ 0x450", volume: []int16{0x500}},
+	}
+	au.PiggybackExternalSound(func(yield func(external.OptionalBus)) {
+		for _, c := range chips {
+			yield(c)
+		}
+	})
+
+	mono := au.Mono()
+	stems := au.Stems()
+	test.DemandEquality(t, len(stems), 7)
+	test.ExpectEquality(t, stems[0], mix.Mono(0x0a, 0))
+	test.ExpectEquality(t, stems[1], int16(0))
+	test.ExpectEquality(t, stems[2], mix.Clip(0x200))
+	test.ExpectEquality(t, stems[6], mix.Clip(0xa00))
+
+	// the stems do not change the mixed output
+	sum := int32(mix.Mono(0x0a, 0)) + (0x100+0x200+0x300+0x400+0x500)<<1
+	test.ExpectEquality(t, mono, mix.Clip(sum))
+
+	test.ExpectEquality(t, fmt.Sprint(au.StemNames()),
+		"[tia_ch0 tia_ch1 pokey_ch0 pokey_ch1 pokey_ch2 pokey_ch3 pokey2_ch0]")
+
+	// stereo mixing puts the TIA channels on opposite sides and sends
+	// alternate chips to alternate sides
+	left, right := au.Stereo()
+	test.ExpectEquality(t, left, mix.Clip(int32(mix.Mono(0x0a, 0))+0x500<<1))
+	test.ExpectEquality(t, right, mix.Clip((0x100+0x200+0x300+0x400)<<1))
+	test.ExpectEquality(t, len(au.Stems()), 7)
+}
@@ -0,0 +1,209 @@
+// Package resample converts audio from one sampling rate to another using
+// band-limited interpolation.
+//
+// Each output sample is calculated by convolving the input with a windowed
+// sinc function centred on the position of the output sample. The sinc
+// function is low-pass filtered at the Nyquist frequency of the lower of the
+// two rates, meaning that frequencies that can not be represented at the
+// output rate are removed rather than being folded back into the audible
+// range. This is in contrast to picking the nearest input sample, which is
+// equivalent to a zero-order hold and produces strong aliases when the input
+// contains high frequencies, as is often the case with the distortion modes of
+// the TIA and POKEY.
+//
+// The filter kernel is precalculated for a fixed number of fractional
+// positions (a polyphase filter) and the kernel for a position between two
+// phases is interpolated.
+package resample
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// the number of input samples on each side of the output position used to
+	// calculate an output sample. the length of the filter is twice this value
+	halfTaps = 32
+
+	// the number of fractional positions for which the kernel is precalculated
+	phases = 256
+
+	// the shape of the Kaiser window. a value of eight gives a stopband
+	// attenuation of approximately 80dB
+	kaiserBeta = 8.0
+
+	// the cutoff frequency as a proportion of the lower Nyquist frequency.
+	// slightly less than one so that the transition band of the filter is
+	// mostly below the Nyquist frequency
+	cutoff = 0.9
+)
+
+// Resampler converts a stream of multi-channel samples from the input rate to
+// the output rate
+type Resampler struct {
+	channels int
+
+	// the number of input samples for every output sample. the base step is
+	// the step before any adjustment by SetRatio()
+	step     float64
+	baseStep float64
+
+	// the kernel for each phase. there is one more row than the number of
+	// phases so that the final phase can be interpolated
+	kernel [phases + 1][halfTaps * 2]float64
+
+	// the most recent input samples for each channel. the history is stored
+	// twice so that the samples from oldest to newest are always contiguous,
+	// starting at the head index
+	history [][halfTaps * 4]float64
+	head    int
+
+	// the number of input samples received
+	count int
+
+	// the position of the next output sample in input samples
+	pos float64
+
+	// the kernel interpolated for the position of the current output sample
+	interpolated [halfTaps * 2]float64
+
+	// the output samples for each channel. reused on each call to Push()
+	frame []int16
+}
+
+// NewResampler creates a Resampler for the input and output rates (in Hz) and
+// the number of channels in each sample
+func NewResampler(in float64, out float64, channels int) (*Resampler, error) {
+	if in <= 0 || out <= 0 {
+		return nil, fmt.Errorf("resample: rates must be greater than zero")
+	}
+	if channels < 1 {
+		return nil, fmt.Errorf("resample: there must be at least one channel")
+	}
+
+	r := &Resampler{
+		channels: channels,
+		step:     in / out,
+		baseStep: in / out,
+		history:  make([][halfTaps * 4]float64, channels),
+		frame:    make([]int16, channels),
+	}
+
+	// the cutoff is relative to the input rate, where 1.0 is the Nyquist
+	// frequency of the input
+	fc := cutoff * min(1.0, out/in)
+
+	for p := range phases + 1 {
+		frac := float64(p) / phases
+		var sum float64
+		for j := range halfTaps * 2 {
+			// the distance between the output position and the input sample
+			x := frac + halfTaps - 1 - float64(j)
+			v := fc * sinc(fc*x) * kaiser(x/halfTaps)
+			r.kernel[p][j] = v
+			sum += v
+		}
+
+		// normalise so that every phase has a gain of one for a constant signal
+		for j := range halfTaps * 2 {
+			r.kernel[p][j] /= sum
+		}
+	}
+
+	return r, nil
+}
+
+// SetRatio adjusts the number of input samples consumed for every output
+// sample. A value greater than one produces fewer output samples and a value
+// less than one produces more. The value should be close to one because the
+// filter is designed for the rates given to NewResampler()
+func (r *Resampler) SetRatio(ratio float64) {
+	r.step = r.baseStep * ratio
+}
+
+// Channels returns the number of channels in each sample
+func (r *Resampler) Channels() int {
+	return r.channels
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	x *= math.Pi
+	return math.Sin(x) / x
+}
+
+// the Kaiser window for values of x between -1 and 1
+func kaiser(x float64) float64 {
+	if x <= -1 || x >= 1 {
+		return 0
+	}
+	return bessel(kaiserBeta*math.Sqrt(1-x*x)) / bessel(kaiserBeta)
+}
+
+// the zeroth order modified Bessel function of the first kind
+func bessel(x float64) float64 {
+	sum := 1.0
+	term := 1.0
+	for k := 1; k < 50; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+		if term < sum*1e-12 {
+			break
+		}
+	}
+	return sum
+}
+
+// Push adds a sample to the input stream. The sample must have a value for
+// every channel. The yield function is called for every output sample that
+// can be created. The slice passed to the yield function is reused and should
+// not be retained
+func (r *Resampler) Push(sample []int16, yield func([]int16)) {
+	for c := range r.channels {
+		var v float64
+		if c < len(sample) {
+			v = float64(sample[c])
+		}
+		r.history[c][r.head] = v
+		r.history[c][r.head+halfTaps*2] = v
+	}
+	r.head++
+	if r.head >= halfTaps*2 {
+		r.head = 0
+	}
+	r.count++
+
+	// an output sample at position pos needs the input samples from
+	// floor(pos)-halfTaps+1 to floor(pos)+halfTaps. positions are relative to
+	// the start of the stream and so the filter introduces a delay of halfTaps
+	// input samples
+	//
+	// the loop condition means that the input samples required are always the
+	// samples in the history, from oldest to newest
+	for r.pos+halfTaps < float64(r.count) {
+		f := (r.pos - math.Floor(r.pos)) * phases
+		p := int(f)
+		t := f - float64(p)
+
+		k0 := &r.kernel[p]
+		k1 := &r.kernel[p+1]
+		for j := range halfTaps * 2 {
+			r.interpolated[j] = k0[j] + (k1[j]-k0[j])*t
+		}
+
+		for c := range r.channels {
+			h := r.history[c][r.head : r.head+halfTaps*2]
+			var v float64
+			for j, k := range r.interpolated {
+				v += h[j] * k
+			}
+			r.frame[c] = int16(max(-32768, min(32767, math.Round(v))))
+		}
+
+		yield(r.frame)
+		r.pos += r.step
+	}
+}
@@ -0,0 +1,138 @@
+package resample_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/jetsetilly/test7800/hardware/tia/audio/resample"
+	"github.com/jetsetilly/test7800/test"
+)
+
+// the native sampling rate of the TIA for NTSC consoles
+const native = 15734.26 * 2
+
+// generates a tone for each channel at the native rate and returns the
+// resampled output for each channel
+func resampleTones(t *testing.T, out float64, tones []float64, n int) [][]float64 {
+	t.Helper()
+
+	r, err := resample.NewResampler(native, out, len(tones))
+	test.DemandSuccess(t, err)
+
+	result := make([][]float64, len(tones))
+	sample := make([]int16, len(tones))
+	for i := range n {
+		for c, f := range tones {
+			sample[c] = int16(16000 * math.Sin(2*math.Pi*f*float64(i)/native))
+		}
+		r.Push(sample, func(s []int16) {
+			for c := range s {
+				result[c] = append(result[c], float64(s[c]))
+			}
+		})
+	}
+	return result
+}
+
+// the amplitude of the frequency in the signal. a Hann window is applied to
+// reduce leakage from other frequencies. the start of the signal is ignored
+// because of the delay introduced by the filter
+func amplitude(signal []float64, rate float64, freq float64) float64 {
+	signal = signal[256:]
+	var re, im, wsum float64
+	for i, v := range signal {
+		w := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(len(signal)-1))
+		a := 2 * math.Pi * freq * float64(i) / rate
+		re += v * w * math.Cos(a)
+		im += v * w * math.Sin(a)
+		wsum += w
+	}
+	return 2 * math.Hypot(re, im) / wsum
+}
+
+// the level of the frequency relative to the reference frequency in decibels
+func level(signal []float64, rate float64, freq float64, ref float64) float64 {
+	return 20 * math.Log10(amplitude(signal, rate, freq)/amplitude(signal, rate, ref))
+}
+
+func TestErrors(t *testing.T) {
+	_, err := resample.NewResampler(0, 48000, 1)
+	test.ExpectFailure(t, err)
+	_, err = resample.NewResampler(native, 48000, 0)
+	test.ExpectFailure(t, err)
+}
+
+func TestRate(t *testing.T) {
+	res := resampleTones(t, 48000, []float64{1000}, 31468)
+
+	// one second of input produces one second of output, less the delay of
+	// the filter
+	test.ExpectSuccess(t, math.Abs(float64(len(res[0]))-48000) < 100)
+}
+
+func TestPassband(t *testing.T) {
+	for _, out := range []float64{22050, 44100, 48000} {
+		for _, f := range []float64{100, 1000, 5000, 9000} {
+			// the passband extends to approximately 70% of the lower Nyquist
+			// frequency
+			if f > 0.35*min(out, native) {
+				continue
+			}
+			res := resampleTones(t, out, []float64{f}, 32768)
+			a := amplitude(res[0], out, f)
+			test.ExpectSuccess(t, math.Abs(a-16000) < 16000*0.01, out, f, a)
+		}
+	}
+}
+
+func TestUpsampleImages(t *testing.T) {
+	// a high tone at the native rate. upsampling by repeating input samples
+	// creates an image of the tone at native-f, which is within the range of
+	// the output rate
+	const out = 48000
+	const f = 12000
+	res := resampleTones(t, out, []float64{f}, 32768)
+
+	image := native - f
+	test.ExpectSuccess(t, level(res[0], out, image, f) < -60, level(res[0], out, image, f))
+}
+
+func TestDownsampleAliases(t *testing.T) {
+	// a tone above the Nyquist frequency of the output rate would be folded
+	// back into the output as an alias if it was not removed
+	const out = 22050.0
+	const f = 13000
+	const ref = 3000
+	res := resampleTones(t, out, []float64{f, ref}, 32768)
+
+	alias := out - f
+	test.ExpectSuccess(t, amplitude(res[0], out, alias) < 16000*0.001, amplitude(res[0], out, alias))
+
+	// the tone in the other channel is unaffected
+	test.ExpectSuccess(t, math.Abs(amplitude(res[1], out, ref)-16000) < 16000*0.01)
+}
+
+func TestStereo(t *testing.T) {
+	// each channel has a different tone and there is no crosstalk
+	const out = 48000
+	res := resampleTones(t, out, []float64{440, 3520}, 32768)
+	test.DemandEquality(t, len(res[0]), len(res[1]))
+	test.ExpectSuccess(t, level(res[0], out, 3520, 440) < -80)
+	test.ExpectSuccess(t, level(res[1], out, 440, 3520) < -80)
+}
+
+func TestConstant(t *testing.T) {
+	// a constant signal is unchanged once the filter has filled
+	r, err := resample.NewResampler(native, 44100, 1)
+	test.DemandSuccess(t, err)
+
+	var res []int16
+	for range 1000 {
+		r.Push([]int16{1000}, func(s []int16) {
+			res = append(res, s[0])
+		})
+	}
+	for _, v := range res[100:] {
+		test.DemandEquality(t, v, int16(1000))
+	}
+}
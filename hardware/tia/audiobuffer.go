@@ -2,16 +2,60 @@ package tia
 
 import "sync"
 
+// the amount of audio data kept in the audioBuffer, in samples per second of
+// audio. a value of 20 means that the buffer targets 1/20th of a second of
+// audio (50ms)
+const audioBufferTargetDivisor = 20
+
+// if the amount of data in the buffer grows beyond this multiple of the target
+// then the oldest data is discarded. this should only happen if the emulation
+// has been running much faster than normal
+const audioBufferTrim = 4
+
+// the number of bytes in a sample (2 channel, 16bit)
+const audioBufferSampleSize = 4
+
 // audioBuffer is an io.Reader implementation that forwards TIA audio generated
 // data to something that can play it back (or store it, etc.)
 type audioBuffer struct {
-	limit Limiter
-	crit  sync.Mutex
-	data  []uint8
+	crit sync.Mutex
+	data []uint8
+
+	// the target number of samples in the buffer
+	target int
+}
+
+// the buffer begins with the target amount of silence so that the audio device
+// has data to play while the emulation starts
+func newAudioBuffer(freq int) *audioBuffer {
+	target := max(freq/audioBufferTargetDivisor, 1)
+	return &audioBuffer{
+		data:   make([]uint8, target*audioBufferSampleSize, target*audioBufferSampleSize*audioBufferTrim),
+		target: target,
+	}
+}
+
+// discard any data in the buffer and fill it with the target amount of silence
+func (b *audioBuffer) reset() {
+	b.crit.Lock()
+	defer b.crit.Unlock()
+	b.data = b.data[:b.target*audioBufferSampleSize]
+	clear(b.data)
 }
 
-func (b *audioBuffer) Nudge() {
-	b.limit.Nudge()
+// push a sample to the buffer. returns the number of samples in the buffer
+func (b *audioBuffer) push(v0 int16, v1 int16) int {
+	b.crit.Lock()
+	defer b.crit.Unlock()
+
+	b.data = append(b.data, uint8(v0), uint8(v0>>8), uint8(v1), uint8(v1>>8))
+
+	if len(b.data) > b.target*audioBufferSampleSize*audioBufferTrim {
+		n := len(b.data) - b.target*audioBufferSampleSize
+		b.data = b.data[:copy(b.data, b.data[n:])]
+	}
+
+	return len(b.data) / audioBufferSampleSize
 }
 
 func (b *audioBuffer) Read(buf []uint8) (int, error) {
@@ -19,18 +63,19 @@ func (b *audioBuffer) Read(buf []uint8) (int, error) {
 	defer b.crit.Unlock()
 
 	n := min(len(b.data), len(buf))
+
+	// the number of bytes returned needs to be a multiple of four because of
+	// the sample format (2 channel, 16bit little-endian)
+	n -= n % audioBufferSampleSize
+
 	copy(buf, b.data[:n])
-	b.data = b.data[n:]
+	b.data = b.data[:copy(b.data, b.data[n:])]
 
 	// return zero bytes is problematic for the WASM build of the emulator. we
 	// could get around this by returning a minimum of 4 bytes, however, this
 	// can cause the audio to drift out of sync with the video if too many of
 	// these are sent
 	//
-	// we could use just 1 byte but this means the sample data becomes
-	// unaligned. the number of bytes returned needs to be a multiple of two
-	// because of the sample format (2 channel, 16bit little-endian)
-	//
 	// https://github.com/ebitengine/oto/issues/261
 	return n, nil
 }
@@ -0,0 +1,56 @@
+package tia
+
+// the maximum adjustment made to the rate at which audio is generated. a change
+// of half a percent is not noticeable as a change in pitch
+const rateControlMaxAdjustment = 0.005
+
+// the number of samples between each adjustment. the amount of data in the
+// audio buffer varies a lot from sample to sample because the audio device
+// takes data in blocks. the amount is averaged over this period to smooth out
+// the variation
+const rateControlPeriod = 512
+
+// rateControl decides how the rate of audio generation should be adjusted so
+// that the amount of data in the audio buffer stays close to the target. if
+// there is more data in the buffer than the target then fewer samples should
+// be generated, and if there is less data then more samples should be
+// generated
+//
+// this is sometimes called dynamic rate control. it means that the emulation
+// does not need to be paced by the audio device and that the audio device
+// never runs out of data (which causes crackles) or has too much data (which
+// causes increasing latency and eventually pops when the buffer is trimmed)
+type rateControl struct {
+	// the target number of samples in the buffer
+	target int
+
+	// sum of the number of samples in the buffer over the current period
+	sum   int
+	count int
+}
+
+// update is called after every sample with the number of samples in the
+// buffer. returns the new adjustment at the end of every period. the
+// adjustment is a factor close to 1.0 that should be applied to the ratio of
+// input samples to output samples
+func (rc *rateControl) update(fill int) (float64, bool) {
+	rc.sum += fill
+	rc.count++
+	if rc.count < rateControlPeriod {
+		return 0, false
+	}
+
+	avg := float64(rc.sum) / float64(rc.count)
+	rc.sum = 0
+	rc.count = 0
+
+	d := (avg - float64(rc.target)) / float64(rc.target)
+	d = max(-1, min(1, d))
+	return 1 + d*rateControlMaxAdjustment, true
+}
+
+// discard the samples counted in the current period
+func (rc *rateControl) reset() {
+	rc.sum = 0
+	rc.count = 0
+}
@@ -0,0 +1,88 @@
+package tia
+
+import (
+	"testing"
+
+	"github.com/jetsetilly/test7800/hardware/tia/audio/resample"
+	"github.com/jetsetilly/test7800/test"
+)
+
+// simulates an audio device that runs at a slightly different speed to the
+// emulation. the device reads from the buffer in large blocks. the amount of
+// data in the buffer should never run out and should settle close to the
+// target
+func simulateRateControl(t *testing.T, drift float64) {
+	t.Helper()
+
+	native := 15734.26 * 2
+	const freq = 48000
+	const block = 1024
+	const seconds = 60
+
+	buf := newAudioBuffer(freq)
+	r, err := resample.NewResampler(native, freq, 2)
+	test.DemandSuccess(t, err)
+	rc := rateControl{target: buf.target}
+
+	var fill int
+	push := func(s []int16) {
+		fill = buf.push(s[0], s[1])
+		if ratio, ok := rc.update(fill); ok {
+			r.SetRatio(ratio)
+		}
+	}
+
+	// the time in seconds of the device and of the emulation
+	var device float64
+	var emulation float64
+	read := make([]uint8, block*audioBufferSampleSize)
+	sample := []int16{0, 0}
+
+	lowest := buf.target
+
+	n := int(native) * seconds
+	for i := range n {
+		emulation = float64(i) / native
+		r.Push(sample, push)
+
+		// the device reads a block of data when it has played the previous block
+		for device+block/(freq*(1+drift)) <= emulation {
+			device += block / (freq * (1 + drift))
+			n, _ := buf.Read(read)
+			test.DemandEquality(t, n, len(read))
+			lowest = min(lowest, fill-block)
+		}
+	}
+
+	// the data in the buffer never gets close to running out and is close to
+	// the target at the end of the simulation
+	test.ExpectSuccess(t, lowest > 0, lowest)
+	test.ExpectSuccess(t, fill > buf.target/2 && fill < buf.target*3/2, fill, buf.target)
+}
+
+func TestRateControlFastDevice(t *testing.T) {
+	simulateRateControl(t, 0.003)
+}
+
+func TestRateControlSlowDevice(t *testing.T) {
+	simulateRateControl(t, -0.003)
+}
+
+func TestAudioBufferTrim(t *testing.T) {
+	buf := newAudioBuffer(48000)
+	test.ExpectEquality(t, len(buf.data), buf.target*audioBufferSampleSize)
+
+	// the buffer is trimmed if nothing is being read
+	var fill int
+	for range buf.target * audioBufferTrim * 2 {
+		fill = buf.push(1, 2)
+	}
+	test.ExpectSuccess(t, fill <= buf.target*audioBufferTrim)
+
+	// reads are always a whole number of samples
+	b := make([]uint8, 7)
+	n, err := buf.Read(b)
+	test.ExpectSuccess(t, err)
+	test.ExpectEquality(t, n, 4)
+	test.ExpectEquality(t, [4]uint8(b[:4]), [4]uint8{1, 0, 2, 0})
+}
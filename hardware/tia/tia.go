@@ -7,6 +7,7 @@ import (
 	"github.com/jetsetilly/test7800/gui"
 	"github.com/jetsetilly/test7800/hardware/spec"
 	"github.com/jetsetilly/test7800/hardware/tia/audio"
+	"github.com/jetsetilly/test7800/hardware/tia/audio/resample"
 	"github.com/jetsetilly/test7800/logger"
 )
 
@@ -34,11 +35,42 @@ type TIA struct {
 	inpt [6]uint8
 	aud  *audio.Audio
 	buf  *audioBuffer
+	tap  AudioTap
+	stem StemTap
 
-	// count number of clocks before retreiving sample
-	sampleCount      int
-	sampleCountLimit int
-	sampleCountStep  int
+	// the sampling rate of the audio
+	freq int
+
+	// the native sampling rate of the TIA
+	nativeFreq float64
+
+	// converts audio from the native sampling rate to the requested sampling
+	// rate for the AudioTap. nil if no sampling rate has been requested
+	resampler *resample.Resampler
+
+	// converts audio from the native sampling rate for playback. the rate is
+	// adjusted by the rateControl so that the amount of data in the audio
+	// buffer stays constant. this means that the audio for playback is not
+	// deterministic and is kept separate from the audio sent to the AudioTap
+	playbackResampler *resample.Resampler
+	rateControl       rateControl
+
+	// audio playback is muted when the emulation is not running at the normal
+	// speed. the AudioTap and StemTap are not affected
+	muted bool
+
+	// the stems are resampled separately because the number of stems can
+	// change when a new cartridge is inserted
+	stemResampler *resample.Resampler
+
+	// the mixed sample at the native sampling rate
+	native [2]int16
+
+	// callbacks for the resamplers. created once to avoid allocation on every
+	// sample
+	playbackOutput  func([]int16)
+	resampledOutput func([]int16)
+	resampledStems  func([]int16)
 
 	// use stereo mixing for audio
 	stereo bool
@@ -59,49 +91,75 @@ type Context interface {
 	SampleRate() (int, bool)
 }
 
-type Limiter interface {
-	Nudge()
+// AudioTap receives a copy of every audio sample generated by the TIA,
+// including the samples of any external sound chip. Mono audio is sent as two
+// identical channels
+type AudioTap interface {
+	Sample(left int16, right int16)
 }
 
-func Create(ctx Context, g *gui.ChannelsDebugger, limiter Limiter) *TIA {
+// StemTap receives the output of every audio channel, before mixing, at the
+// same time as the AudioTap receives the mixed sample. The names of the
+// channels are returned by the StemNames() function
+type StemTap interface {
+	Stems(v []int16)
+}
+
+func Create(ctx Context, g *gui.ChannelsDebugger) *TIA {
 	tia := &TIA{
 		aud:    audio.NewAudio(),
 		stereo: ctx.UseStereo(),
 	}
 
-	if g.AudioSetup != nil {
-		tia.buf = &audioBuffer{
-			data:  make([]uint8, 0, 4096),
-			limit: limiter,
-		}
+	tia.playbackOutput = func(s []int16) {
+		tia.playback(s[0], s[1])
+	}
+	tia.resampledOutput = func(s []int16) {
+		tia.tap.Sample(s[0], s[1])
+	}
+	tia.resampledStems = func(s []int16) {
+		tia.stem.Stems(s)
+	}
 
-		// decide on sampling rate
-		var freq int
-		freq = int(ctx.Spec().HorizScan * audio.SamplesPerScanline)
-		if f, ok := ctx.SampleRate(); ok {
-			freq = f
-			r := float64(f) / (ctx.Spec().HorizScan * audio.SamplesPerScanline)
-			r = 56.0 / r
-			tia.sampleCountLimit = int(r * 10)
-			tia.sampleCountStep = 10
+	// decide on sampling rate. if a sampling rate has been requested then the
+	// audio is resampled from the native rate
+	tia.nativeFreq = ctx.Spec().HorizScan * audio.SamplesPerScanline
+	tia.freq = int(tia.nativeFreq)
+	if f, ok := ctx.SampleRate(); ok {
+		r, err := resample.NewResampler(tia.nativeFreq, float64(f), 2)
+		if err != nil {
+			logger.Log(logger.Allow, "TIA", err.Error())
+		} else {
+			tia.freq = f
+			tia.resampler = r
 		}
+	}
 
-		logger.Logf(logger.Allow, "TIA", "using sampling rate of %d", freq)
-
+	if g.AudioSetup != nil {
 		// notify UI of audio requirements
 		var audioSetup gui.AudioSetup
+
 		if ctx.UseAudio() {
-			audioSetup = gui.AudioSetup{
-				Read: tia.AudioBuffer(),
-				Freq: freq,
-			}
-		} else {
-			go func() {
-				for range 4 {
-					limiter.Nudge()
+			// audio for playback is always resampled, even if the output rate
+			// is the same as the native rate, so that the rate can be adjusted
+			// to match the speed of the audio device
+			r, err := resample.NewResampler(tia.nativeFreq, float64(tia.freq), 2)
+			if err != nil {
+				logger.Log(logger.Allow, "TIA", err.Error())
+			} else {
+				tia.buf = newAudioBuffer(tia.freq)
+				tia.playbackResampler = r
+				tia.rateControl.target = tia.buf.target
+
+				logger.Logf(logger.Allow, "TIA", "using sampling rate of %d", tia.freq)
+
+				audioSetup = gui.AudioSetup{
+					Read: tia.AudioBuffer(),
+					Freq: tia.freq,
 				}
-			}()
+			}
 		}
+
 		select {
 		case g.AudioSetup <- audioSetup:
 		default:
@@ -136,10 +194,54 @@ func (tia *TIA) Insert(externalChips audio.SoundChipIterator) error {
 	return nil
 }
 
+// AudioBuffer returns the reader for audio playback. Returns nil if audio is
+// not being used
 func (tia *TIA) AudioBuffer() gui.AudioReader {
+	if tia.buf == nil {
+		return nil
+	}
 	return tia.buf
 }
 
+// SampleRate returns the number of audio samples generated per second
+func (tia *TIA) SampleRate() int {
+	return tia.freq
+}
+
+// SetMute stops audio playback. When playback is resumed the audio buffer is
+// refilled with silence so that the audio device has data to play while the
+// audio generation catches up
+func (tia *TIA) SetMute(muted bool) {
+	if tia.muted == muted {
+		return
+	}
+	tia.muted = muted
+	if !muted && tia.buf != nil {
+		tia.buf.reset()
+		tia.rateControl.reset()
+		tia.playbackResampler.SetRatio(1)
+	}
+}
+
+// SetAudioTap sets the AudioTap that receives a copy of every audio sample. A
+// value of nil removes the tap
+func (tia *TIA) SetAudioTap(tap AudioTap) {
+	tia.tap = tap
+}
+
+// SetStemTap sets the StemTap that receives the output of every audio channel.
+// A value of nil removes the tap
+func (tia *TIA) SetStemTap(tap StemTap) {
+	tia.stem = tap
+	tia.stemResampler = nil
+}
+
+// StemNames returns the names of the values sent to the StemTap. The number of
+// names can change when a new cartridge is inserted
+func (tia *TIA) StemNames() []string {
+	return tia.aud.StemNames()
+}
+
 func (tia *TIA) Label() string {
 	return "TIA"
 }
@@ -240,41 +342,57 @@ func (tia *TIA) Tick() bool {
 		return !tia.wsync
 	}
 
-	if tia.buf == nil {
+	if tia.buf == nil && tia.tap == nil && tia.stem == nil {
+		return !tia.wsync
+	}
+
+	if !tia.aud.Step() {
 		return !tia.wsync
 	}
 
-	sample := tia.aud.Step()
+	if tia.stereo {
+		tia.native[0], tia.native[1] = tia.aud.Stereo()
+	} else {
+		tia.native[0] = tia.aud.Mono()
+		tia.native[1] = tia.native[0]
+	}
 
-	// if sampling rate has been specified then we do our own count
-	if tia.sampleCountLimit > 0 {
-		tia.sampleCount += tia.sampleCountStep
-		if tia.sampleCount > tia.sampleCountLimit {
-			sample = true
-			tia.sampleCount = 0
+	if tia.buf != nil && !tia.muted {
+		tia.playbackResampler.Push(tia.native[:], tia.playbackOutput)
+	}
+
+	if tia.tap != nil {
+		if tia.resampler == nil {
+			tia.tap.Sample(tia.native[0], tia.native[1])
 		} else {
-			sample = false
+			tia.resampler.Push(tia.native[:], tia.resampledOutput)
 		}
 	}
 
-	if sample {
-		tia.buf.crit.Lock()
-		defer tia.buf.crit.Unlock()
-
-		if tia.stereo {
-			v0, v1 := tia.aud.Stereo()
-			tia.buf.data = append(tia.buf.data, uint8(v0), uint8(v0>>8))
-			tia.buf.data = append(tia.buf.data, uint8(v1), uint8(v1>>8))
+	if tia.stem != nil {
+		stems := tia.aud.Stems()
+		if tia.resampler == nil {
+			tia.stem.Stems(stems)
 		} else {
-			v := tia.aud.Mono()
-			tia.buf.data = append(tia.buf.data, uint8(v), uint8(v>>8))
-			tia.buf.data = append(tia.buf.data, uint8(v), uint8(v>>8))
+			if tia.stemResampler == nil || tia.stemResampler.Channels() != len(stems) {
+				tia.stemResampler, _ = resample.NewResampler(tia.nativeFreq, float64(tia.freq), len(stems))
+			}
+			tia.stemResampler.Push(stems, tia.resampledStems)
 		}
 	}
 
 	return !tia.wsync
 }
 
+// add a sample to the audio buffer for playback and adjust the rate of the
+// playback resampler if required
+func (tia *TIA) playback(v0 int16, v1 int16) {
+	fill := tia.buf.push(v0, v1)
+	if ratio, ok := tia.rateControl.update(fill); ok {
+		tia.playbackResampler.SetRatio(ratio)
+	}
+}
+
 func (tia *TIA) PaddlesGrounded() bool {
 	return tia.vblank&0x80 == 0x80
 }
@@ -20,10 +20,15 @@ func main() {
 
 	g := gui.NewChannels()
 
+	// the program exits with a non-zero status if the debugger returns an
+	// error. this is important for scripts run in a CI environment
+	var exitCode int
+
 	go func() {
 		err := debugger.Launch(endDebugger, g.Debugger(), os.Args[1:])
 		if err != nil {
 			fmt.Printf("*** %s\n", err)
+			exitCode = 1
 		}
 		endGui <- true
 		endDebuggerAck <- true
@@ -36,4 +41,6 @@ func main() {
 
 	endDebugger <- true
 	<-endDebuggerAck
+
+	os.Exit(exitCode)
 }
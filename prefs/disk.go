@@ -0,0 +1,149 @@
+package prefs
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/jetsetilly/test7800/resources"
+)
+
+// the separator between key and value in the preferences file
+const separator = "::"
+
+// Disk is a collection of preference values that can be saved to and loaded
+// from a file in the resources directory
+type Disk struct {
+	filename string
+	values   map[string]Value
+
+	// lines in the file for keys that have not been added to the Disk
+	unknown map[string]string
+}
+
+// NewDisk returns a Disk for the file in the resources directory
+func NewDisk(filename string) *Disk {
+	return &Disk{
+		filename: filename,
+		values:   make(map[string]Value),
+		unknown:  make(map[string]string),
+	}
+}
+
+// Add a value to the Disk. The key must be unique
+func (dsk *Disk) Add(key string, v Value) error {
+	key = strings.ToLower(key)
+	if _, ok := dsk.values[key]; ok {
+		return fmt.Errorf("prefs: key already exists: %s", key)
+	}
+	if strings.ContainsAny(key, " \t") || strings.Contains(key, separator) {
+		return fmt.Errorf("prefs: invalid key: %s", key)
+	}
+	dsk.values[key] = v
+	return nil
+}
+
+// Keys returns the keys of all values in the Disk in sorted order
+func (dsk *Disk) Keys() []string {
+	return slices.Sorted(maps.Keys(dsk.values))
+}
+
+// Get returns the value for the key. Keys are not case sensitive
+func (dsk *Disk) Get(key string) (Value, bool) {
+	v, ok := dsk.values[strings.ToLower(key)]
+	return v, ok
+}
+
+// Set the value for the key from a string
+func (dsk *Disk) Set(key string, s string) error {
+	v, ok := dsk.Get(key)
+	if !ok {
+		return fmt.Errorf("prefs: unknown key: %s", key)
+	}
+	err := v.Parse(s)
+	if err != nil {
+		return fmt.Errorf("prefs: %s: %w", strings.ToLower(key), err)
+	}
+	return nil
+}
+
+// Reset all values in the Disk to their default
+func (dsk *Disk) Reset() {
+	for _, v := range dsk.values {
+		v.Reset()
+	}
+}
+
+// Parse values from the text. A line that can not be parsed does not prevent
+// the remaining lines from being parsed. The first error is returned
+func (dsk *Disk) Parse(text string) error {
+	var firstErr error
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	var ln int
+	for scanner.Scan() {
+		ln++
+		l := strings.TrimSpace(scanner.Text())
+		if l == "" {
+			continue
+		}
+
+		key, val, ok := strings.Cut(l, separator)
+		key = strings.ToLower(strings.TrimSpace(key))
+		val = strings.TrimSpace(val)
+		if !ok || key == "" {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("prefs: line %d: malformed preference", ln)
+			}
+			continue
+		}
+
+		v, ok := dsk.values[key]
+		if !ok {
+			dsk.unknown[key] = val
+			continue
+		}
+		err := v.Parse(val)
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("prefs: line %d: %s: %w", ln, key, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return firstErr
+}
+
+func (dsk *Disk) String() string {
+	var s strings.Builder
+
+	all := maps.Clone(dsk.unknown)
+	for k, v := range dsk.values {
+		all[k] = v.String()
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(all)) {
+		fmt.Fprintf(&s, "%s %s %s\n", k, separator, all[k])
+	}
+
+	return s.String()
+}
+
+// Load the values from the file. Values are not changed if the file does not
+// exist
+func (dsk *Disk) Load() error {
+	s, err := resources.Read(dsk.filename)
+	if err != nil {
+		return err
+	}
+	return dsk.Parse(s)
+}
+
+// Save the values to the file
+func (dsk *Disk) Save() error {
+	return resources.Write(dsk.filename, dsk.String())
+}
@@ -0,0 +1,209 @@
+// Package prefs implements typed preference values that can be saved to and
+// loaded from the resources directory.
+//
+// The Bool, Int, Float and String types hold a single preference value. The
+// values are safe to read from one goroutine while being set from another.
+// Number values can be restricted to a range and string values can be
+// restricted to a list of options.
+//
+// Values are collected into a Disk instance, with each value identified by a
+// key. The values in a Disk are written to a text file, one value per line:
+//
+//	arm.clock :: 70
+//	arm.immediate :: true
+//
+// Lines in the file for keys that are not in the Disk are kept and written
+// back to the file when the Disk is saved.
+package prefs
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Value is implemented by all preference types
+type Value interface {
+	// Parse sets the value from a string
+	Parse(s string) error
+
+	// String returns the value in a form that is accepted by Parse()
+	String() string
+
+	// Reset the value to its default
+	Reset()
+}
+
+// Bool is a boolean preference value
+type Bool struct {
+	value atomic.Bool
+	def   bool
+}
+
+// NewBool returns a Bool set to the default value
+func NewBool(def bool) *Bool {
+	p := &Bool{def: def}
+	p.Reset()
+	return p
+}
+
+func (p *Bool) Get() bool {
+	return p.value.Load()
+}
+
+func (p *Bool) Set(v bool) {
+	p.value.Store(v)
+}
+
+func (p *Bool) Parse(s string) error {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("not a boolean value: %s", s)
+	}
+	p.Set(v)
+	return nil
+}
+
+func (p *Bool) String() string {
+	return strconv.FormatBool(p.Get())
+}
+
+func (p *Bool) Reset() {
+	p.Set(p.def)
+}
+
+// Int is an integer preference value
+type Int struct {
+	value    atomic.Int64
+	def      int
+	min, max int
+}
+
+// NewInt returns an Int set to the default value. The value must be between
+// min and max inclusive
+func NewInt(def int, min int, max int) *Int {
+	p := &Int{def: def, min: min, max: max}
+	p.Reset()
+	return p
+}
+
+func (p *Int) Get() int {
+	return int(p.value.Load())
+}
+
+func (p *Int) Set(v int) error {
+	if v < p.min || v > p.max {
+		return fmt.Errorf("value must be between %d and %d", p.min, p.max)
+	}
+	p.value.Store(int64(v))
+	return nil
+}
+
+func (p *Int) Parse(s string) error {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("not an integer value: %s", s)
+	}
+	return p.Set(v)
+}
+
+func (p *Int) String() string {
+	return strconv.Itoa(p.Get())
+}
+
+func (p *Int) Reset() {
+	p.value.Store(int64(p.def))
+}
+
+// Float is a floating-point preference value
+type Float struct {
+	value    atomic.Uint64
+	def      float64
+	min, max float64
+}
+
+// NewFloat returns a Float set to the default value. The value must be between
+// min and max inclusive
+func NewFloat(def float64, min float64, max float64) *Float {
+	p := &Float{def: def, min: min, max: max}
+	p.Reset()
+	return p
+}
+
+func (p *Float) Get() float64 {
+	return math.Float64frombits(p.value.Load())
+}
+
+func (p *Float) Set(v float64) error {
+	if math.IsNaN(v) || v < p.min || v > p.max {
+		return fmt.Errorf("value must be between %g and %g", p.min, p.max)
+	}
+	p.value.Store(math.Float64bits(v))
+	return nil
+}
+
+func (p *Float) Parse(s string) error {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("not a number: %s", s)
+	}
+	return p.Set(v)
+}
+
+func (p *Float) String() string {
+	return strconv.FormatFloat(p.Get(), 'g', -1, 64)
+}
+
+func (p *Float) Reset() {
+	p.value.Store(math.Float64bits(p.def))
+}
+
+// String is a string preference value
+type String struct {
+	value   atomic.Pointer[string]
+	def     string
+	options []string
+}
+
+// NewString returns a String set to the default value. If any options are
+// specified then the value must be one of the options. Options are not case
+// sensitive and are stored in upper case
+func NewString(def string, options ...string) *String {
+	p := &String{def: def}
+	for _, o := range options {
+		p.options = append(p.options, strings.ToUpper(o))
+	}
+	p.Reset()
+	return p
+}
+
+func (p *String) Get() string {
+	return *p.value.Load()
+}
+
+func (p *String) Set(v string) error {
+	if len(p.options) > 0 {
+		v = strings.ToUpper(v)
+		if !slices.Contains(p.options, v) {
+			return fmt.Errorf("value must be one of %s", strings.Join(p.options, ", "))
+		}
+	}
+	p.value.Store(&v)
+	return nil
+}
+
+func (p *String) Parse(s string) error {
+	return p.Set(s)
+}
+
+func (p *String) String() string {
+	return p.Get()
+}
+
+func (p *String) Reset() {
+	v := p.def
+	p.value.Store(&v)
+}
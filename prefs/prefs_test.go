@@ -0,0 +1,73 @@
+package prefs_test
+
+import (
+	"testing"
+
+	"github.com/jetsetilly/test7800/prefs"
+	"github.com/jetsetilly/test7800/test"
+)
+
+func TestValues(t *testing.T) {
+	b := prefs.NewBool(true)
+	test.ExpectEquality(t, b.Get(), true)
+	test.ExpectSuccess(t, b.Parse("false"))
+	test.ExpectEquality(t, b.Get(), false)
+	test.ExpectFailure(t, b.Parse("maybe"))
+	b.Reset()
+	test.ExpectEquality(t, b.Get(), true)
+
+	i := prefs.NewInt(1, -1, 2)
+	test.ExpectSuccess(t, i.Parse("-1"))
+	test.ExpectEquality(t, i.Get(), -1)
+	test.ExpectFailure(t, i.Parse("3"))
+	test.ExpectFailure(t, i.Parse("1.5"))
+	test.ExpectEquality(t, i.Get(), -1)
+
+	f := prefs.NewFloat(70, 1, 1000)
+	test.ExpectEquality(t, f.String(), "70")
+	test.ExpectSuccess(t, f.Parse("62.5"))
+	test.ExpectEquality(t, f.Get(), 62.5)
+	test.ExpectFailure(t, f.Parse("0"))
+	test.ExpectFailure(t, f.Parse("NaN"))
+	test.ExpectEquality(t, f.Get(), 62.5)
+
+	s := prefs.NewString("MONO", "MONO", "STEREO", "NONE")
+	test.ExpectSuccess(t, s.Parse("stereo"))
+	test.ExpectEquality(t, s.Get(), "STEREO")
+	test.ExpectFailure(t, s.Parse("quad"))
+	test.ExpectEquality(t, s.Get(), "STEREO")
+
+	s = prefs.NewString("")
+	test.ExpectSuccess(t, s.Parse("any value"))
+	test.ExpectEquality(t, s.Get(), "any value")
+}
+
+func TestDisk(t *testing.T) {
+	dsk := prefs.NewDisk("test")
+	clk := prefs.NewFloat(70, 1, 1000)
+	imm := prefs.NewBool(true)
+	pal := prefs.NewString("DEFAULT")
+	test.DemandSuccess(t, dsk.Add("arm.clock", clk))
+	test.DemandSuccess(t, dsk.Add("arm.immediate", imm))
+	test.DemandSuccess(t, dsk.Add("palette", pal))
+	test.ExpectFailure(t, dsk.Add("ARM.Clock", prefs.NewBool(false)))
+	test.ExpectFailure(t, dsk.Add("bad key", prefs.NewBool(false)))
+
+	test.ExpectSuccess(t, dsk.Set("ARM.CLOCK", "60"))
+	test.ExpectEquality(t, clk.Get(), 60.0)
+	test.ExpectFailure(t, dsk.Set("arm.mam", "1"))
+
+	// a bad line does not prevent the remaining lines from being parsed.
+	// unknown keys are preserved
+	err := dsk.Parse("arm.immediate :: false\narm.clock :: fast\nmalformed\nfuture.key :: 10\npalette :: PAL :: GENERATE\n")
+	test.ExpectFailure(t, err)
+	test.ExpectEquality(t, imm.Get(), false)
+	test.ExpectEquality(t, clk.Get(), 60.0)
+	test.ExpectEquality(t, pal.Get(), "PAL :: GENERATE")
+
+	test.ExpectEquality(t, dsk.String(), "arm.clock :: 60\narm.immediate :: false\nfuture.key :: 10\npalette :: PAL :: GENERATE\n")
+
+	dsk.Reset()
+	test.ExpectEquality(t, clk.Get(), 70.0)
+	test.ExpectEquality(t, imm.Get(), true)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/jetsetilly/test7800/gui"
 	"github.com/jetsetilly/test7800/gui/ebiten"
 	"github.com/jetsetilly/test7800/hardware"
+	"github.com/jetsetilly/test7800/hardware/arm"
 	"github.com/jetsetilly/test7800/hardware/spec"
 	"github.com/jetsetilly/test7800/logger"
 )
@@ -33,6 +34,14 @@ func (ctx *context) IsAtari7800() bool {
 	return ctx.console == "7800"
 }
 
+func (ctx *context) ARMPreferences() *arm.Preferences {
+	return nil
+}
+
+func (ctx *context) ELFModel() string {
+	return "AUTO"
+}
+
 func (ctx *context) Reset() {
 	ctx.Breaks = ctx.Breaks[:0]
 	ctx.rand = rand.New(rand.NewPCG(0, 0))
@@ -62,6 +71,10 @@ func (ctx *context) UseStereo() bool {
 	return false
 }
 
+func (ctx *context) UseVSync() bool {
+	return false
+}
+
 func (ctx *context) SampleRate() (int, bool) {
 	return 48000, true
 }